@@ -20,24 +20,32 @@ limitations under the License.
 // NOTE: See the below link for details on what is happening here.
 // https://github.com/golang/go/wiki/Modules#how-can-i-track-tool-dependencies-for-a-module
 
-// Remove existing CRDs
-//go:generate rm -rf ../package/crds
+// Remove existing CRDs and webhook configurations
+//go:generate rm -rf ../package/crds ../package/webhookconfigurations
 
-// Remove generated files
-//go:generate bash -c "find . -iname 'zz_*' -delete"
-//go:generate bash -c "find . -type d -empty -delete"
-//go:generate bash -c "find ../internal/controller -iname 'zz_*' -delete"
-//go:generate bash -c "find ../internal/controller -type d -empty -delete"
+// Remove the files generated by the tools below. The generator removes the
+// stale files it generated itself, except those of the resources that are not
+// selected with --include and --exclude.
+//go:generate bash -c "find . -iname 'zz_generated.*' -delete"
 
 // Run Terrajet generator
-//go:generate go run -tags generate ../cmd/generator/main.go .. "${TERRAFORM_PROVIDER_SOURCE}"
+//go:generate go run -tags generate ../cmd/generator/main.go ..
 
 // Generate deepcopy methodsets and CRD manifests
 //go:generate go run -tags generate sigs.k8s.io/controller-tools/cmd/controller-gen object:headerFile=../hack/boilerplate.go.txt paths=./... crd:allowDangerousTypes=true,crdVersions=v1 output:artifacts:config=../package/crds
 
+// Generate the webhook configurations of the managed resources
+//go:generate go run -tags generate sigs.k8s.io/controller-tools/cmd/controller-gen webhook paths=../internal/controller/... output:webhook:artifacts:config=../package/webhookconfigurations
+
+// Convert the CRDs served in several API versions with the conversion webhook
+//go:generate go run -tags generate ../cmd/generator/main.go conversion-webhooks ../package/crds
+
 // Generate crossplane-runtime methodsets (resource.Claim, etc)
 //go:generate go run -tags generate github.com/crossplane/crossplane-tools/cmd/angryjet generate-methodsets --header-file=../hack/boilerplate.go.txt ./...
 
+// Validate the example manifests against the generated CRDs
+//go:generate go run -tags generate ../cmd/generator/main.go --validate-examples ..
+
 package apis
 
 import (
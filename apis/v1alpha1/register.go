@@ -45,6 +45,14 @@ var (
 	ProviderConfigGroupVersionKind = SchemeGroupVersion.WithKind(ProviderConfigKind)
 )
 
+// NamespacedProviderConfig type metadata.
+var (
+	NamespacedProviderConfigKind             = reflect.TypeOf(NamespacedProviderConfig{}).Name()
+	NamespacedProviderConfigGroupKind        = schema.GroupKind{Group: Group, Kind: NamespacedProviderConfigKind}.String()
+	NamespacedProviderConfigKindAPIVersion   = NamespacedProviderConfigKind + "." + SchemeGroupVersion.String()
+	NamespacedProviderConfigGroupVersionKind = SchemeGroupVersion.WithKind(NamespacedProviderConfigKind)
+)
+
 // ProviderConfigUsage type metadata.
 var (
 	ProviderConfigUsageKind             = reflect.TypeOf(ProviderConfigUsage{}).Name()
@@ -57,6 +65,16 @@ var (
 	ProviderConfigUsageListKindAPIVersion   = ProviderConfigUsageListKind + "." + SchemeGroupVersion.String()
 	ProviderConfigUsageListGroupVersionKind = SchemeGroupVersion.WithKind(ProviderConfigUsageListKind)
 
+	NamespacedProviderConfigUsageKind             = reflect.TypeOf(NamespacedProviderConfigUsage{}).Name()
+	NamespacedProviderConfigUsageGroupKind        = schema.GroupKind{Group: Group, Kind: NamespacedProviderConfigUsageKind}.String()
+	NamespacedProviderConfigUsageKindAPIVersion   = NamespacedProviderConfigUsageKind + "." + SchemeGroupVersion.String()
+	NamespacedProviderConfigUsageGroupVersionKind = SchemeGroupVersion.WithKind(NamespacedProviderConfigUsageKind)
+
+	NamespacedProviderConfigUsageListKind             = reflect.TypeOf(NamespacedProviderConfigUsageList{}).Name()
+	NamespacedProviderConfigUsageListGroupKind        = schema.GroupKind{Group: Group, Kind: NamespacedProviderConfigUsageListKind}.String()
+	NamespacedProviderConfigUsageListKindAPIVersion   = NamespacedProviderConfigUsageListKind + "." + SchemeGroupVersion.String()
+	NamespacedProviderConfigUsageListGroupVersionKind = SchemeGroupVersion.WithKind(NamespacedProviderConfigUsageListKind)
+
 	StoreConfigKind             = reflect.TypeOf(StoreConfig{}).Name()
 	StoreConfigGroupKind        = schema.GroupKind{Group: Group, Kind: StoreConfigKind}.String()
 	StoreConfigKindAPIVersion   = StoreConfigKind + "." + SchemeGroupVersion.String()
@@ -65,6 +83,8 @@ var (
 
 func init() {
 	SchemeBuilder.Register(&ProviderConfig{}, &ProviderConfigList{})
+	SchemeBuilder.Register(&NamespacedProviderConfig{}, &NamespacedProviderConfigList{})
 	SchemeBuilder.Register(&ProviderConfigUsage{}, &ProviderConfigUsageList{})
+	SchemeBuilder.Register(&NamespacedProviderConfigUsage{}, &NamespacedProviderConfigUsageList{})
 	SchemeBuilder.Register(&StoreConfig{}, &StoreConfigList{})
 }
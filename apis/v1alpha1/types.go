@@ -26,8 +26,171 @@ import (
 type ProviderConfigSpec struct {
 	// Credentials required to authenticate to this provider.
 	Credentials ProviderCredentials `json:"credentials"`
+
+	// ProviderVersion pins the version of the native Terraform provider used
+	// for the managed resources referencing this ProviderConfig. It must be
+	// one of the versions present in the filesystem mirror of the provider.
+	// Defaults to the version the provider was started with.
+	// +optional
+	ProviderVersion string `json:"providerVersion,omitempty"`
+
+	// ApprovalMode determines which changes of the existing external
+	// resources wait for the approval of their Terraform plan before they
+	// are applied. None applies all changes, Destructive waits for the
+	// approval of the changes that would destroy or replace external
+	// resources, and Always waits for the approval of every change.
+	// +kubebuilder:validation:Enum=None;Destructive;Always
+	// +optional
+	ApprovalMode ApprovalMode `json:"approvalMode,omitempty"`
+
+	// MaintenanceWindows restrict when the changes of the managed resources
+	// referencing this ProviderConfig are applied. Outside of them, the
+	// managed resources are still observed but their creations, updates and
+	// deletions are deferred to the next window. Changes are applied at any
+	// time if no window is configured.
+	// +optional
+	MaintenanceWindows []MaintenanceWindow `json:"maintenanceWindows,omitempty"`
+
+	// RateLimit is the maximum number of reconciles per second of the
+	// managed resources referencing this ProviderConfig. It applies within
+	// the provider-wide limit. Unlimited if unset.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	RateLimit *int `json:"rateLimit,omitempty"`
+
+	// MaxConcurrentOperations is the maximum number of managed resources
+	// referencing this ProviderConfig that are reconciled, or whose async
+	// Terraform operations run, at the same time. Unlimited if unset.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxConcurrentOperations *int `json:"maxConcurrentOperations,omitempty"`
+
+	// MaxResources is the maximum number of managed resources that can use
+	// this ProviderConfig. The managed resources over the quota are not
+	// reconciled until other managed resources stop using it. Unlimited if
+	// unset.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxResources *int `json:"maxResources,omitempty"`
+
+	// StateBackend stores the Terraform state of the managed resources
+	// referencing this ProviderConfig outside of their workspaces. The state
+	// is kept only in the workspaces if unset.
+	// +optional
+	StateBackend *StateBackend `json:"stateBackend,omitempty"`
+
+	// StateHistoryLimit is the number of Terraform state snapshots kept for
+	// every managed resource referencing this ProviderConfig. A snapshot is
+	// taken after every apply that changes the state, and the parameters of
+	// a managed resource can be rolled back to one of its snapshots. No
+	// snapshots are kept if unset or zero.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	StateHistoryLimit *int `json:"stateHistoryLimit,omitempty"`
+}
+
+// A StateBackend stores the Terraform state of every managed resource under
+// the UID of the managed resource, and locks it while Terraform runs.
+type StateBackend struct {
+	// Type of the backend. Local keeps the state only in the workspaces,
+	// Kubernetes stores it in Secrets and HTTP in an HTTP backend.
+	// +kubebuilder:validation:Enum=Local;Kubernetes;HTTP
+	// +kubebuilder:default=Local
+	Type StateBackendType `json:"type"`
+
+	// Kubernetes configures the Kubernetes backend.
+	// +optional
+	Kubernetes *KubernetesStateBackend `json:"kubernetes,omitempty"`
+
+	// HTTP configures the HTTP backend.
+	// +optional
+	HTTP *HTTPStateBackend `json:"http,omitempty"`
 }
 
+// A StateBackendType is a type of state backend.
+type StateBackendType string
+
+// State backend types.
+const (
+	StateBackendLocal      StateBackendType = "Local"
+	StateBackendKubernetes StateBackendType = "Kubernetes"
+	StateBackendHTTP       StateBackendType = "HTTP"
+)
+
+// A KubernetesStateBackend stores the state in Secrets and locks it with
+// Leases, in the layout of the kubernetes backend of Terraform with the UID
+// of the managed resource as its secret_suffix.
+type KubernetesStateBackend struct {
+	// Namespace of the Secrets and the Leases. Defaults to the namespace of
+	// the provider for ProviderConfigs and must be the namespace of the
+	// managed resources for NamespacedProviderConfigs.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// An HTTPStateBackend stores the state in a server implementing the protocol
+// of the http backend of Terraform.
+type HTTPStateBackend struct {
+	// Address of the state of a managed resource. {uid} is replaced with the
+	// UID of the managed resource, which is appended as a path segment if the
+	// address has no {uid}.
+	Address string `json:"address"`
+
+	// LockAddress of the state of a managed resource, in the format of the
+	// address. Defaults to the address.
+	// +optional
+	LockAddress string `json:"lockAddress,omitempty"`
+
+	// UnlockAddress of the state of a managed resource, in the format of the
+	// address. Defaults to the lock address.
+	// +optional
+	UnlockAddress string `json:"unlockAddress,omitempty"`
+
+	// Username for the basic authentication to the server.
+	// +optional
+	Username string `json:"username,omitempty"`
+
+	// PasswordSecretRef references the password for the basic
+	// authentication to the server.
+	// +optional
+	PasswordSecretRef *xpv1.SecretKeySelector `json:"passwordSecretRef,omitempty"`
+}
+
+// A MaintenanceWindow is a weekly recurring period of time.
+type MaintenanceWindow struct {
+	// Days of the week on which the window starts. Defaults to every day.
+	// +optional
+	Days []Weekday `json:"days,omitempty"`
+
+	// Start is the time of the day at which the window starts, in HH:MM
+	// format.
+	// +kubebuilder:validation:Pattern=`^([01][0-9]|2[0-3]):[0-5][0-9]$`
+	Start string `json:"start"`
+
+	// Duration of the window, e.g. 2h. It must not exceed a week.
+	Duration metav1.Duration `json:"duration"`
+
+	// TimeZone of the start of the window as an IANA time zone name, e.g.
+	// Europe/Berlin. Defaults to UTC.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// A Weekday is a day of the week.
+// +kubebuilder:validation:Enum=Monday;Tuesday;Wednesday;Thursday;Friday;Saturday;Sunday
+type Weekday string
+
+// An ApprovalMode determines which changes wait for the approval of their
+// Terraform plan.
+type ApprovalMode string
+
+// Approval modes.
+const (
+	ApprovalModeNone        ApprovalMode = "None"
+	ApprovalModeDestructive ApprovalMode = "Destructive"
+	ApprovalModeAlways      ApprovalMode = "Always"
+)
+
 // ProviderCredentials required to authenticate.
 type ProviderCredentials struct {
 	// Source of the provider credentials.
@@ -40,6 +203,12 @@ type ProviderCredentials struct {
 // A ProviderConfigStatus reflects the observed state of a ProviderConfig.
 type ProviderConfigStatus struct {
 	xpv1.ProviderConfigStatus `json:",inline"`
+
+	// MaxResources is the maximum number of managed resources that can use
+	// this ProviderConfig. The number of managed resources that use it is
+	// reported as its users.
+	// +optional
+	MaxResources *int `json:"maxResources,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -48,6 +217,8 @@ type ProviderConfigStatus struct {
 // +kubebuilder:subresource:status
 // +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
 // +kubebuilder:printcolumn:name="SECRET-NAME",type="string",JSONPath=".spec.credentials.secretRef.name",priority=1
+// +kubebuilder:printcolumn:name="USERS",type="integer",JSONPath=".status.users",priority=1
+// +kubebuilder:printcolumn:name="MAX-RESOURCES",type="integer",JSONPath=".status.maxResources",priority=1
 // +kubebuilder:resource:scope=Cluster
 // +kubebuilder:resource:scope=Cluster,categories={crossplane,provider,templatejet}
 type ProviderConfig struct {
@@ -69,6 +240,33 @@ type ProviderConfigList struct {
 
 // +kubebuilder:object:root=true
 
+// A NamespacedProviderConfig configures a Template JET provider for the
+// namespace-scoped managed resources in its namespace.
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:printcolumn:name="SECRET-NAME",type="string",JSONPath=".spec.credentials.secretRef.name",priority=1
+// +kubebuilder:printcolumn:name="USERS",type="integer",JSONPath=".status.users",priority=1
+// +kubebuilder:printcolumn:name="MAX-RESOURCES",type="integer",JSONPath=".status.maxResources",priority=1
+// +kubebuilder:resource:scope=Namespaced,categories={crossplane,provider,templatejet}
+type NamespacedProviderConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ProviderConfigSpec   `json:"spec"`
+	Status ProviderConfigStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NamespacedProviderConfigList contains a list of NamespacedProviderConfig.
+type NamespacedProviderConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NamespacedProviderConfig `json:"items"`
+}
+
+// +kubebuilder:object:root=true
+
 // A ProviderConfigUsage indicates that a resource is using a ProviderConfig.
 // +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
 // +kubebuilder:printcolumn:name="CONFIG-NAME",type="string",JSONPath=".providerConfigRef.name"
@@ -91,6 +289,32 @@ type ProviderConfigUsageList struct {
 	Items           []ProviderConfigUsage `json:"items"`
 }
 
+// +kubebuilder:object:root=true
+
+// A NamespacedProviderConfigUsage indicates that a namespace-scoped resource
+// is using a NamespacedProviderConfig in its namespace.
+// +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:printcolumn:name="CONFIG-NAME",type="string",JSONPath=".providerConfigRef.name"
+// +kubebuilder:printcolumn:name="RESOURCE-KIND",type="string",JSONPath=".resourceRef.kind"
+// +kubebuilder:printcolumn:name="RESOURCE-NAME",type="string",JSONPath=".resourceRef.name"
+// +kubebuilder:resource:scope=Namespaced,categories={crossplane,provider,templatejet}
+type NamespacedProviderConfigUsage struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	xpv1.ProviderConfigUsage `json:",inline"`
+}
+
+// +kubebuilder:object:root=true
+
+// NamespacedProviderConfigUsageList contains a list of
+// NamespacedProviderConfigUsage.
+type NamespacedProviderConfigUsageList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NamespacedProviderConfigUsage `json:"items"`
+}
+
 // A StoreConfigSpec defines the desired state of a ProviderConfig.
 type StoreConfigSpec struct {
 	xpv1.SecretStoreConfig `json:",inline"`
@@ -19,22 +19,211 @@ limitations under the License.
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
-	"github.com/crossplane/terrajet/pkg/pipeline"
+	"gopkg.in/alecthomas/kingpin.v2"
 
 	"github.com/crossplane-contrib/provider-jet-template/config"
+	"github.com/crossplane-contrib/provider-jet-template/internal/examples"
+	"github.com/crossplane-contrib/provider-jet-template/internal/pipeline"
+	"github.com/crossplane-contrib/provider-jet-template/internal/schemadiff"
+	"github.com/crossplane-contrib/provider-jet-template/internal/tfschema"
 )
 
+// defaultRegistry is the registry that provider sources without a hostname
+// belong to.
+const defaultRegistry = "registry.terraform.io"
+
+type generateArgs struct {
+	rootDir    *string
+	namespaced *bool
+	include    *[]string
+	exclude    *[]string
+	dryRun     *bool
+	check      *bool
+	source     *string
+	version    *string
+	validate   *bool
+}
+
 func main() {
-	if len(os.Args) < 2 || os.Args[1] == "" {
-		panic("root directory is required to be given as argument")
+	var (
+		app = kingpin.New(filepath.Base(os.Args[0]), "Terrajet code generator for the Template provider").DefaultEnvars()
+
+		generateCmd = app.Command("generate", "Run the code generation pipeline.").Default()
+		gen         = generateArgs{
+			rootDir:    generateCmd.Arg("root-dir", "Root directory of the provider repository.").Required().String(),
+			namespaced: generateCmd.Flag("namespaced-resources", "Also generate a namespace-scoped variant of every managed resource.").Default("false").Envar("NAMESPACED_RESOURCES").Bool(),
+			include:    generateCmd.Flag("include", "Only generate the Terraform resources whose names match this glob. Can be repeated.").Strings(),
+			exclude:    generateCmd.Flag("exclude", "Do not generate the Terraform resources whose names match this glob. Can be repeated.").Strings(),
+			dryRun:     generateCmd.Flag("dry-run", "Print the generated files that would change instead of writing them.").Default("false").Bool(),
+			check:      generateCmd.Flag("check", "Exit with a non-zero code if the generated files differ from what would be generated.").Default("false").Bool(),
+			source:     generateCmd.Flag("provider-source", "Source of the Terraform provider that the API reference links to.").Envar("TERRAFORM_PROVIDER_SOURCE").String(),
+			version:    generateCmd.Flag("provider-version", "Version of the Terraform provider that the API reference links to.").Envar("TERRAFORM_PROVIDER_VERSION").String(),
+			validate:   generateCmd.Flag("validate-examples", "Validate the example manifests against the CRDs in package/crds instead of generating code.").Default("false").Bool(),
+		}
+
+		schemaCmd    = app.Command("schema", "Write the schema of a native Terraform provider binary in the format of \"terraform providers schema -json\".")
+		schemaBinary = schemaCmd.Flag("provider-binary", "Path of the native Terraform provider binary.").Required().ExistingFile()
+		schemaSource = schemaCmd.Flag("provider-source", "Source of the Terraform provider, e.g. hashicorp/null.").Envar("TERRAFORM_PROVIDER_SOURCE").Required().String()
+		schemaOutput = schemaCmd.Flag("output", "Path of the schema file to write.").Default(filepath.Join("config", "schema.json")).String()
+
+		webhooksCmd    = app.Command("conversion-webhooks", "Configure the CRDs served in several API versions to be converted by the conversion webhook of the provider.")
+		webhooksCRDDir = webhooksCmd.Arg("crd-dir", "Directory of the CRD manifests.").Required().ExistingDir()
+
+		diffCmd            = app.Command("diff", "Print a JSON report of the changes between two provider schema files or two CRD directories.")
+		diffOld            = diffCmd.Arg("old", "Old provider schema file or CRD directory.").Required().ExistingFileOrDir()
+		diffNew            = diffCmd.Arg("new", "New provider schema file or CRD directory.").Required().ExistingFileOrDir()
+		diffFailOnBreaking = diffCmd.Flag("fail-on-breaking", "Exit with a non-zero code if there are breaking changes that are not mitigated by a new API version.").Default("false").Bool()
+		diffNewResources   = diffCmd.Flag("new-resources-config", "resources.yaml whose replacement policies mark the ForceNew arguments of the new provider schema.").Default(filepath.Join("config", "resources.yaml")).ExistingFile()
+		diffOldResources   = diffCmd.Flag("old-resources-config", "resources.yaml whose replacement policies mark the ForceNew arguments of the old provider schema. Defaults to --new-resources-config.").ExistingFile()
+	)
+
+	switch kingpin.MustParse(app.Parse(os.Args[1:])) {
+	case schemaCmd.FullCommand():
+		kingpin.FatalIfError(writeSchema(*schemaBinary, *schemaSource, *schemaOutput), "cannot write provider schema")
+	case webhooksCmd.FullCommand():
+		kingpin.FatalIfError(pipeline.EnableConversionWebhooks(*webhooksCRDDir), "cannot enable conversion webhooks")
+	case diffCmd.FullCommand():
+		oldResources := *diffOldResources
+		if oldResources == "" {
+			oldResources = *diffNewResources
+		}
+		breaking, err := diffSchemas(*diffOld, oldResources, *diffNew, *diffNewResources)
+		kingpin.FatalIfError(err, "cannot compare schemas")
+		if breaking && *diffFailOnBreaking {
+			fmt.Fprintln(os.Stderr, "breaking API changes found, please introduce a new API version")
+			os.Exit(1)
+		}
+	default:
+		generate(gen)
+	}
+}
+
+// writeSchema fetches the schema of the given provider binary over the plugin
+// protocol and writes it to the given path.
+func writeSchema(binary, source, output string) error {
+	address := source
+	if strings.Count(source, "/") < 2 {
+		address = defaultRegistry + "/" + source
+	}
+	s, err := tfschema.Extract(context.Background(), binary, address)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(output, b, 0600)
+}
+
+// diffSchemas prints the report of the changes between the given schemas and
+// returns whether there are unmitigated breaking changes. The ForceNew
+// arguments of provider schemas are read from the given resources.yaml files.
+func diffSchemas(oldPath, oldResources, newPath, newResources string) (bool, error) {
+	from, err := loadSchema(oldPath, oldResources)
+	if err != nil {
+		return false, err
+	}
+	to, err := loadSchema(newPath, newResources)
+	if err != nil {
+		return false, err
+	}
+	r := schemadiff.Compare(from, to)
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return r.Breaking, enc.Encode(r)
+}
+
+func loadSchema(path, resources string) (*schemadiff.Schema, error) {
+	s, err := schemadiff.Load(path)
+	if err != nil {
+		return nil, err
 	}
-	absRootDir, err := filepath.Abs(os.Args[1])
+	b, err := os.ReadFile(filepath.Clean(resources))
 	if err != nil {
-		panic(fmt.Sprintf("cannot calculate the absolute path of %s", os.Args[1]))
+		return nil, err
+	}
+	rc, err := config.ParseResourcesConfig(b)
+	if err != nil {
+		return nil, err
+	}
+	s.MarkForceNew(rc.ForceNewFields())
+	return s, nil
+}
+
+// spokeOf returns the API version of a kind that the given key of the provider
+// configuration belongs to, unless it's the storage version.
+func spokeOf(key string) (pipeline.Spoke, bool) {
+	hub, v, ok := config.SpokeOf(key)
+	if !ok {
+		return pipeline.Spoke{}, false
+	}
+	moves := make([]pipeline.Move, len(v.Moves))
+	for i, m := range v.Moves {
+		moves[i] = pipeline.Move{From: m.From, To: m.To}
+	}
+	return pipeline.Spoke{Hub: hub, Moves: moves}, true
+}
+
+func generate(args generateArgs) {
+	absRootDir, err := filepath.Abs(*args.rootDir)
+	kingpin.FatalIfError(err, "cannot calculate the absolute path of %s", *args.rootDir)
+
+	if *args.validate {
+		kingpin.FatalIfError(examples.ValidateExamples(absRootDir), "cannot validate examples")
+		return
+	}
+
+	var cOpts []config.Option
+	pOpts := []pipeline.RunOption{
+		pipeline.WithProviderSource(*args.source, *args.version),
+		pipeline.WithSpokes(spokeOf),
+	}
+	if *args.namespaced {
+		cOpts = append(cOpts, config.WithNamespacedResources())
+		pOpts = append(pOpts, pipeline.WithNamespaced(config.IsNamespaced))
+	}
+	pc := config.GetProvider(cOpts...)
+	selected, err := pipeline.NewResourceFilter(*args.include, *args.exclude)
+	kingpin.FatalIfError(err, "cannot filter resources")
+
+	// Generate into a scratch copy of the repository and compare the result
+	// with the generated files of the repository. The files of the resources
+	// that are not selected are left as they are, whereas the files shared by
+	// all resources are generated for all of them.
+	scratchDir, err := pipeline.NewScratchDir(absRootDir)
+	kingpin.FatalIfError(err, "cannot prepare scratch directory")
+	defer os.RemoveAll(scratchDir) //nolint:errcheck
+	pipeline.Run(pc, scratchDir, pOpts...)
+	generated := pipeline.PipelineFiles
+	if *args.dryRun || *args.check {
+		// The drift of the files generated by the tools that go generate runs
+		// after the pipeline is reported as well.
+		kingpin.FatalIfError(pipeline.RunTools(scratchDir), "cannot run code generation tools")
+		generated = pipeline.GeneratedFiles
+	}
+	changes, err := pipeline.Diff(absRootDir, scratchDir, generated)
+	kingpin.FatalIfError(err, "cannot compare generated files")
+	changes = pipeline.Without(changes, pipeline.ResourceFiles(pc, func(name string) bool { return !selected(name) }))
+
+	if !*args.dryRun && !*args.check {
+		kingpin.FatalIfError(pipeline.Sync(absRootDir, scratchDir, changes), "cannot write generated files")
+		return
+	}
+	for _, c := range changes {
+		fmt.Printf("%s: %s\n", c.Type, c.Path)
+	}
+	if *args.check && len(changes) > 0 {
+		fmt.Fprintf(os.Stderr, "%d generated files are out of date, please run code generation\n", len(changes))
+		_ = os.RemoveAll(scratchDir)
+		os.Exit(1)
 	}
-	pipeline.Run(config.GetProvider(), absRootDir)
 }
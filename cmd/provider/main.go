@@ -39,9 +39,19 @@ import (
 	"github.com/crossplane-contrib/provider-jet-template/apis"
 	"github.com/crossplane-contrib/provider-jet-template/apis/v1alpha1"
 	"github.com/crossplane-contrib/provider-jet-template/config"
+	"github.com/crossplane-contrib/provider-jet-template/internal/backend"
 	"github.com/crossplane-contrib/provider-jet-template/internal/clients"
 	"github.com/crossplane-contrib/provider-jet-template/internal/controller"
+	"github.com/crossplane-contrib/provider-jet-template/internal/convert"
 	"github.com/crossplane-contrib/provider-jet-template/internal/features"
+	"github.com/crossplane-contrib/provider-jet-template/internal/jet"
+	"github.com/crossplane-contrib/provider-jet-template/internal/tfstate"
+	"github.com/crossplane-contrib/provider-jet-template/internal/workspace"
+)
+
+const (
+	executionCLI    = "cli"
+	executionNative = "native"
 )
 
 func main() {
@@ -53,10 +63,21 @@ func main() {
 		terraformVersion = app.Flag("terraform-version", "Terraform version.").Required().Envar("TERRAFORM_VERSION").String()
 		providerSource   = app.Flag("terraform-provider-source", "Terraform provider source.").Required().Envar("TERRAFORM_PROVIDER_SOURCE").String()
 		providerVersion  = app.Flag("terraform-provider-version", "Terraform provider version.").Required().Envar("TERRAFORM_PROVIDER_VERSION").String()
+		providerMirror   = app.Flag("terraform-provider-mirror", "Terraform provider filesystem mirror holding the provider versions ProviderConfigs can pin.").Default(clients.DefaultProviderMirror).Envar("TERRAFORM_PROVIDER_MIRROR").String()
 		maxReconcileRate = app.Flag("max-reconcile-rate", "The global maximum rate per second at which resources may checked for drift from the desired state.").Default("10").Int()
+		execution        = app.Flag("terraform-execution", "How the Terraform operations run: cli runs the Terraform CLI in the workspaces, native talks to the native provider binary over gRPC without the Terraform CLI.").Default(executionCLI).Envar("TERRAFORM_EXECUTION").Enum(executionCLI, executionNative)
+		nativeProvider   = app.Flag("terraform-native-provider-path", "The path of the native provider binary that the operations talk to if --terraform-execution is native.").Envar("TERRAFORM_NATIVE_PROVIDER_PATH").String()
+		maxProcesses     = app.Flag("max-terraform-processes", "The maximum number of Terraform operations that run at the same time across all managed resources. The operations on deleted and changed resources run before the drift checks. Unlimited if zero.").Default("0").Envar("MAX_TERRAFORM_PROCESSES").Int()
 
 		namespace                  = app.Flag("namespace", "Namespace used to set as default scope in default secret store config.").Default("crossplane-system").Envar("POD_NAMESPACE").String()
 		enableExternalSecretStores = app.Flag("enable-external-secret-stores", "Enable support for ExternalSecretStores.").Default("false").Envar("ENABLE_EXTERNAL_SECRET_STORES").Bool()
+		webhookTLSCertDir          = app.Flag("webhook-tls-cert-dir", "The directory of the TLS certificate that is used to serve the defaulting, validating and conversion webhooks of the managed resources. Webhooks are not served if it is not set.").Envar("WEBHOOK_TLS_CERT_DIR").String()
+		workspaceDir               = app.Flag("workspace-dir", "The directory the Terraform workspaces are kept in, e.g. on a mounted volume so that they survive restarts. Defaults to the temporary directory.").Envar("WORKSPACE_DIR").String()
+		pluginCacheDir             = app.Flag("terraform-plugin-cache-dir", "The directory of the plugin cache shared by the Terraform workspaces and of the workspaces they are initialised from. Defaults to the plugin-cache directory in the workspace directory.").Envar("TERRAFORM_PLUGIN_CACHE_DIR").String()
+		workspaceGCInterval        = app.Flag("workspace-gc-interval", "The interval at which the workspaces whose managed resources no longer exist are removed.").Default("1h").Envar("WORKSPACE_GC_INTERVAL").Duration()
+		encryptionKeysDir          = app.Flag("workspace-encryption-keys-dir", "Encrypt the files of the Terraform workspaces at rest with the key ring in the given directory, e.g. a mounted Secret. Its primary entry holds the ID of the key to encrypt with, and the other entries are 32 bytes long keys named after their IDs.").Envar("WORKSPACE_ENCRYPTION_KEYS_DIR").String()
+		encryptionKeysSecret       = app.Flag("workspace-encryption-keys-secret", "Encrypt the files of the Terraform workspaces at rest with the key ring in the Secret with the given name in the namespace of the provider, in the format of --workspace-encryption-keys-dir.").Envar("WORKSPACE_ENCRYPTION_KEYS_SECRET").String()
+		exportWorkspaceState       = app.Flag("export-workspace-state", "Mirror the redacted Terraform state of all managed resources into Secrets after every successful apply. Resources can opt in or out with the "+tfstate.AnnotationKeyExportState+" annotation.").Default("false").Envar("EXPORT_WORKSPACE_STATE").Bool()
 	)
 	kingpin.MustParse(app.Parse(os.Args[1:]))
 
@@ -71,6 +92,14 @@ func main() {
 
 	log.Debug("Starting", "sync-period", syncPeriod.String())
 
+	if *workspaceDir != "" {
+		kingpin.FatalIfError(workspace.SetRoot(*workspaceDir), "Cannot set workspace directory")
+	}
+	// The existing workspaces are reused, so that the managed resources need
+	// not be initialised and imported again.
+	uids, err := workspace.Discover(log)
+	kingpin.FatalIfError(err, "Cannot discover existing workspaces")
+	log.Info("Discovered existing workspaces", "dir", workspace.Root(), "count", len(uids))
 	cfg, err := ctrl.GetConfig()
 	kingpin.FatalIfError(err, "Cannot get API server rest config")
 
@@ -81,21 +110,67 @@ func main() {
 		LeaderElectionResourceLock: resourcelock.LeasesResourceLock,
 		LeaseDuration:              func() *time.Duration { d := 60 * time.Second; return &d }(),
 		RenewDeadline:              func() *time.Duration { d := 50 * time.Second; return &d }(),
+		CertDir:                    *webhookTLSCertDir,
 	})
 	kingpin.FatalIfError(err, "Cannot create controller manager")
 	kingpin.FatalIfError(apis.AddToScheme(mgr.GetScheme()), "Cannot add Template APIs to scheme")
-	o := tjcontroller.Options{
-		Options: xpcontroller.Options{
-			Logger:                  log,
-			GlobalRateLimiter:       ratelimiter.NewGlobal(*maxReconcileRate),
-			PollInterval:            1 * time.Minute,
-			MaxConcurrentReconciles: 1,
+	// use the following WorkspaceStoreOption to enable the shared gRPC mode
+	// terraform.WithProviderRunner(terraform.NewSharedProvider(log, os.Getenv("TERRAFORM_NATIVE_PROVIDER_PATH"), terraform.WithNativeProviderArgs("-debuggable")))
+	ws := terraform.NewWorkspaceStore(log)
+	limiter := jet.NewProcessLimiter(*maxProcesses)
+	operations := jet.NewOperations(mgr.GetClient(), log)
+	kingpin.FatalIfError(mgr.Add(workspace.NewGarbageCollector(mgr.GetClient(), ws, log, workspace.WithInterval(*workspaceGCInterval))), "Cannot add workspace garbage collector")
+	setupFn := clients.TerraformSetupBuilder(*terraformVersion, *providerSource, *providerVersion,
+		clients.WithProviderMirror(clients.NewProviderMirror(*providerMirror)),
+		clients.WithUsageReader(mgr.GetAPIReader()))
+	var native *jet.NativeProviders
+	if *execution == executionNative {
+		if *nativeProvider == "" {
+			kingpin.Fatalf("--terraform-native-provider-path is required if --terraform-execution is native")
+		}
+		native = jet.NewNativeProviders(*nativeProvider, log)
+		kingpin.FatalIfError(mgr.Add(native), "Cannot add native provider processes")
+	} else {
+		if *pluginCacheDir == "" {
+			*pluginCacheDir = filepath.Join(workspace.Root(), "plugin-cache")
+		}
+		initializer, err := workspace.NewInitializer(*pluginCacheDir, log)
+		kingpin.FatalIfError(err, "Cannot set up the plugin cache")
+		// Workspaces are initialised again when the provider version pinned
+		// by their ProviderConfig changes, from the shared workspace of their
+		// provider requirement.
+		setupFn = jet.InitFromSharedWorkspace(jet.ReinitOnVersionChange(setupFn), initializer)
+	}
+	o := jet.Options{
+		Options: tjcontroller.Options{
+			Options: xpcontroller.Options{
+				Logger:                  log,
+				GlobalRateLimiter:       ratelimiter.NewGlobal(*maxReconcileRate),
+				PollInterval:            1 * time.Minute,
+				MaxConcurrentReconciles: 1,
+			},
+			Provider:       config.GetProvider(),
+			WorkspaceStore: ws,
+			SetupFn:        setupFn,
 		},
-		Provider: config.GetProvider(),
-		// use the following WorkspaceStoreOption to enable the shared gRPC mode
-		// terraform.WithProviderRunner(terraform.NewSharedProvider(log, os.Getenv("TERRAFORM_NATIVE_PROVIDER_PATH"), terraform.WithNativeProviderArgs("-debuggable")))
-		WorkspaceStore: terraform.NewWorkspaceStore(log),
-		SetupFn:        clients.TerraformSetupBuilder(*terraformVersion, *providerSource, *providerVersion),
+		// The state is exported only for the resources that opt in unless the
+		// export is enabled for all resources.
+		StateExporter:   tfstate.NewExporter(mgr.GetClient(), *namespace, tfstate.WithExportByDefault(*exportWorkspaceState)),
+		StateHistory:    tfstate.NewHistory(mgr.GetClient(), *namespace),
+		StateBackends:   backend.NewFactory(mgr.GetClient(), mgr.GetAPIReader(), *namespace),
+		Throttler:       jet.NewThrottler(operations),
+		Deferrals:       jet.NewDeferrals(),
+		ProcessLimiter:  limiter,
+		Operations:      operations,
+		NativeProviders: native,
+	}
+	switch {
+	case *encryptionKeysDir != "" && *encryptionKeysSecret != "":
+		kingpin.Fatalf("Cannot use both --workspace-encryption-keys-dir and --workspace-encryption-keys-secret")
+	case *encryptionKeysDir != "":
+		o.Encrypter = workspace.NewEncrypter(workspace.NewDirectoryKeySource(*encryptionKeysDir))
+	case *encryptionKeysSecret != "":
+		o.Encrypter = workspace.NewEncrypter(workspace.NewSecretKeySource(mgr.GetClient(), *namespace, *encryptionKeysSecret))
 	}
 
 	if *enableExternalSecretStores {
@@ -118,5 +193,9 @@ func main() {
 	}
 
 	kingpin.FatalIfError(controller.Setup(mgr, o), "Cannot setup Template controllers")
+	if *webhookTLSCertDir != "" {
+		kingpin.FatalIfError(controller.SetupWebhooks(mgr, o), "Cannot setup Template webhooks")
+		kingpin.FatalIfError(convert.SetupWebhooks(mgr), "Cannot setup conversion webhooks")
+	}
 	kingpin.FatalIfError(mgr.Start(ctrl.SetupSignalHandler()), "Cannot start controller manager")
 }
@@ -0,0 +1,122 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"strings"
+
+	tjconfig "github.com/crossplane/terrajet/pkg/config"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+const (
+	// namespacedKindPrefix is prepended to the kinds of the namespace-scoped
+	// variants of the managed resources so that they can live in the same
+	// API group and version as their cluster-scoped counterparts.
+	namespacedKindPrefix = "Namespaced"
+	// namespacedKeySuffix is appended to the Terraform resource names to
+	// build the keys of the namespace-scoped variants in the provider
+	// configuration.
+	namespacedKeySuffix = "/namespaced"
+)
+
+// NamespacedKey returns the key of the namespace-scoped variant of the given
+// Terraform resource in the provider configuration.
+func NamespacedKey(name string) string {
+	return name + namespacedKeySuffix
+}
+
+// IsNamespaced returns whether the given key of the provider configuration
+// belongs to a namespace-scoped variant of a resource.
+func IsNamespaced(key string) bool {
+	return strings.HasSuffix(key, namespacedKeySuffix)
+}
+
+// addNamespacedVariants adds a namespace-scoped variant of every configured
+// resource to the given provider configuration. The variants get copies of
+// the configuration of the cluster-scoped resources except their kinds, so
+// that either can be configured without changing the other.
+func addNamespacedVariants(pc *tjconfig.Provider) {
+	for name, r := range pc.Resources {
+		if IsNamespaced(name) {
+			continue
+		}
+		nr := copyResource(r)
+		nr.Kind = namespacedKindPrefix + r.Kind
+		pc.Resources[NamespacedKey(name)] = nr
+	}
+}
+
+// copyResource returns a deep copy of the given resource configuration. The
+// functions it refers to are shared.
+func copyResource(r *tjconfig.Resource) *tjconfig.Resource {
+	nr := *r
+	nr.TerraformResource = copySchemaResource(r.TerraformResource)
+	nr.InitializerFns = append([]tjconfig.NewInitializerFn(nil), r.InitializerFns...)
+	nr.ExternalName.OmittedFields = append([]string(nil), r.ExternalName.OmittedFields...)
+	nr.References = make(tjconfig.References, len(r.References))
+	for k, v := range r.References {
+		nr.References[k] = v
+	}
+	nr.Sensitive = tjconfig.Sensitive{AdditionalConnectionDetailsFn: r.Sensitive.AdditionalConnectionDetailsFn}
+	for tf, xp := range r.Sensitive.GetFieldPaths() {
+		nr.Sensitive.AddFieldPath(tf, xp)
+	}
+	nr.LateInitializer = tjconfig.LateInitializer{IgnoredFields: append([]string(nil), r.LateInitializer.IgnoredFields...)}
+	for _, cf := range r.LateInitializer.GetIgnoredCanonicalFields() {
+		nr.LateInitializer.AddIgnoredCanonicalFields(cf)
+	}
+	return &nr
+}
+
+// copySchemaResource returns a deep copy of the schema of the given
+// Terraform resource, including its nested blocks.
+func copySchemaResource(r *schema.Resource) *schema.Resource {
+	if r == nil {
+		return nil
+	}
+	nr := *r
+	nr.StateUpgraders = append([]schema.StateUpgrader(nil), r.StateUpgraders...)
+	if r.Schema != nil {
+		nr.Schema = make(map[string]*schema.Schema, len(r.Schema))
+		for k, s := range r.Schema {
+			nr.Schema[k] = copySchema(s)
+		}
+	}
+	return &nr
+}
+
+// copySchema returns a deep copy of the given schema of a Terraform
+// attribute or block.
+func copySchema(s *schema.Schema) *schema.Schema {
+	if s == nil {
+		return nil
+	}
+	ns := *s
+	ns.ComputedWhen = append([]string(nil), s.ComputedWhen...)
+	ns.ConflictsWith = append([]string(nil), s.ConflictsWith...)
+	ns.ExactlyOneOf = append([]string(nil), s.ExactlyOneOf...)
+	ns.AtLeastOneOf = append([]string(nil), s.AtLeastOneOf...)
+	ns.RequiredWith = append([]string(nil), s.RequiredWith...)
+	switch e := s.Elem.(type) {
+	case *schema.Resource:
+		ns.Elem = copySchemaResource(e)
+	case *schema.Schema:
+		ns.Elem = copySchema(e)
+	}
+	return &ns
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"testing"
+
+	tjconfig "github.com/crossplane/terrajet/pkg/config"
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestAddNamespacedVariants(t *testing.T) {
+	r := &tjconfig.Resource{
+		Name: "test_resource",
+		Kind: "Resource",
+		TerraformResource: &schema.Resource{Schema: map[string]*schema.Schema{
+			"name": {Type: schema.TypeString, Optional: true, ConflictsWith: []string{"name_prefix"}},
+			"setting": {Type: schema.TypeList, Optional: true, Elem: &schema.Resource{Schema: map[string]*schema.Schema{
+				"mode": {Type: schema.TypeString, Optional: true},
+			}}},
+		}},
+		References:      tjconfig.References{"network": {Type: "Network"}},
+		LateInitializer: tjconfig.LateInitializer{IgnoredFields: []string{"name"}},
+	}
+	r.Sensitive.AddFieldPath("password", "spec.forProvider.passwordSecretRef")
+	r.LateInitializer.AddIgnoredCanonicalFields("Name")
+	pc := &tjconfig.Provider{Resources: map[string]*tjconfig.Resource{"test_resource": r}}
+
+	addNamespacedVariants(pc)
+	nr, ok := pc.Resources[NamespacedKey("test_resource")]
+	if !ok {
+		t.Fatal("addNamespacedVariants(...): no namespaced variant was added")
+	}
+	if diff := cmp.Diff("NamespacedResource", nr.Kind); diff != "" {
+		t.Errorf("addNamespacedVariants(...): -want kind, +got kind:\n%s", diff)
+	}
+
+	// Changing the variant must not change the cluster-scoped resource.
+	nr.TerraformResource.Schema["name"].Sensitive = true
+	nr.TerraformResource.Schema["name"].ConflictsWith[0] = "other"
+	nr.TerraformResource.Schema["setting"].Elem.(*schema.Resource).Schema["mode"].Sensitive = true
+	nr.TerraformResource.Schema["extra"] = &schema.Schema{Type: schema.TypeString}
+	nr.References["subnet"] = tjconfig.Reference{Type: "Subnet"}
+	nr.Sensitive.AddFieldPath("token", "spec.forProvider.tokenSecretRef")
+	nr.LateInitializer.IgnoredFields[0] = "other"
+	nr.LateInitializer.AddIgnoredCanonicalFields("Other")
+
+	got := map[string]interface{}{
+		"sensitive":        r.TerraformResource.Schema["name"].Sensitive,
+		"conflictsWith":    r.TerraformResource.Schema["name"].ConflictsWith,
+		"nestedSensitive":  r.TerraformResource.Schema["setting"].Elem.(*schema.Resource).Schema["mode"].Sensitive,
+		"schema":           len(r.TerraformResource.Schema),
+		"references":       len(r.References),
+		"sensitivePaths":   r.Sensitive.GetFieldPaths(),
+		"ignoredFields":    r.LateInitializer.IgnoredFields,
+		"ignoredCanonical": r.LateInitializer.GetIgnoredCanonicalFields(),
+	}
+	want := map[string]interface{}{
+		"sensitive":        false,
+		"conflictsWith":    []string{"name_prefix"},
+		"nestedSensitive":  false,
+		"schema":           2,
+		"references":       1,
+		"sensitivePaths":   map[string]string{"password": "spec.forProvider.passwordSecretRef"},
+		"ignoredFields":    []string{"name"},
+		"ignoredCanonical": []string{"Name"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("\nThe cluster-scoped resource should not change with its namespaced variant.\naddNamespacedVariants(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestIsNamespaced(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		key    string
+		want   bool
+	}{
+		"ClusterScoped": {
+			reason: "The key of a cluster-scoped resource should not be namespaced.",
+			key:    "null_resource",
+			want:   false,
+		},
+		"Namespaced": {
+			reason: "The key of a namespaced variant should be namespaced.",
+			key:    NamespacedKey("null_resource"),
+			want:   true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, IsNamespaced(tc.key)); diff != "" {
+				t.Errorf("\n%s\nIsNamespaced(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -24,5 +24,8 @@ import (
 func Configure(p *tjconfig.Provider) {
 	p.AddResourceConfigurator("null_resource", func(r *tjconfig.Resource) {
 		r.ExternalName = tjconfig.IdentifierFromProvider
+		// The provisioners of a null_resource can run for a long time, so
+		// it's applied and destroyed asynchronously.
+		r.UseAsync = true
 	})
 }
@@ -19,11 +19,11 @@ package config
 import (
 	// Note(turkenh): we are importing this to embed provider schema document
 	_ "embed"
+	"regexp"
 
 	tjconfig "github.com/crossplane/terrajet/pkg/config"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
-
-	"github.com/crossplane-contrib/provider-jet-template/config/null"
+	"github.com/pkg/errors"
 )
 
 const (
@@ -34,8 +34,32 @@ const (
 //go:embed schema.json
 var providerSchema string
 
+//go:embed resources.yaml
+var resourcesConfig []byte
+
+// An Option configures the provider configuration returned by GetProvider.
+type Option func(*options)
+
+type options struct {
+	namespaced bool
+}
+
+// WithNamespacedResources adds a namespace-scoped variant of every managed
+// resource to the provider configuration. The variants are named after the
+// cluster-scoped kinds with a "Namespaced" prefix.
+func WithNamespacedResources() Option {
+	return func(o *options) {
+		o.namespaced = true
+	}
+}
+
 // GetProvider returns provider configuration
-func GetProvider() *tjconfig.Provider {
+func GetProvider(opts ...Option) *tjconfig.Provider {
+	o := &options{}
+	for _, f := range opts {
+		f(o)
+	}
+
 	defaultResourceFn := func(name string, terraformResource *schema.Resource, opts ...tjconfig.ResourceOption) *tjconfig.Resource {
 		r := tjconfig.DefaultResource(name, terraformResource)
 		// Add any provider-specific defaulting here. For example:
@@ -43,16 +67,42 @@ func GetProvider() *tjconfig.Provider {
 		return r
 	}
 
-	pc := tjconfig.NewProviderWithSchema([]byte(providerSchema), resourcePrefix, modulePath,
-		tjconfig.WithDefaultResourceFn(defaultResourceFn))
+	var pc *tjconfig.Provider
+	for _, u := range Upstreams() {
+		// Only the resources named after the upstream are included so that
+		// every resource has exactly one upstream.
+		up := tjconfig.NewProviderWithSchema(u.Schema, resourcePrefix, modulePath,
+			tjconfig.WithDefaultResourceFn(defaultResourceFn),
+			tjconfig.WithIncludeList([]string{"^" + regexp.QuoteMeta(u.Name) + "_"}))
+		if pc == nil {
+			pc = up
+			continue
+		}
+		for name, r := range up.Resources {
+			pc.Resources[name] = r
+		}
+	}
+
+	rc, err := ParseResourcesConfig(resourcesConfig)
+	if err != nil {
+		panic(errors.Wrap(err, "cannot parse resources.yaml"))
+	}
+	if err := rc.Apply(pc); err != nil {
+		panic(errors.Wrap(err, "cannot apply resources.yaml"))
+	}
 
-	for _, configure := range []func(provider *tjconfig.Provider){
-		// add custom config functions
-		null.Configure,
-	} {
-		configure(pc)
+	for _, u := range Upstreams() {
+		for _, configure := range u.Configurators {
+			configure(pc)
+		}
 	}
 
 	pc.ConfigureResources()
+	if err := addVersions(pc); err != nil {
+		panic(errors.Wrap(err, "cannot add API versions"))
+	}
+	if o.namespaced {
+		addNamespacedVariants(pc)
+	}
 	return pc
 }
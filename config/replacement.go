@@ -0,0 +1,116 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/pkg/errors"
+)
+
+const (
+	errFmtReplacementPolicy = "unknown replacement policy %q, must be one of %s"
+	errFmtForceNewField     = "cannot set the replacement policy of field %s"
+)
+
+// A ReplacementPolicy determines what happens when an argument that forces
+// the replacement of the external resource, i.e. a ForceNew argument, is
+// changed.
+type ReplacementPolicy string
+
+const (
+	// ReplaceOnChange replaces the external resource whenever the argument
+	// changes, which is the behaviour of Terraform.
+	ReplaceOnChange ReplacementPolicy = "ReplaceOnChange"
+
+	// Immutable rejects the changes of the argument. The managed resource
+	// has to be deleted and created again to change it.
+	Immutable ReplacementPolicy = "Immutable"
+
+	// ReplaceWithApproval replaces the external resource when the argument
+	// changes only if the managed resource is annotated to approve the
+	// replacement.
+	ReplaceWithApproval ReplacementPolicy = "ReplaceWithApproval"
+)
+
+// DefaultReplacementPolicy is the policy of the ForceNew arguments that are
+// not configured otherwise, and of the arguments without a policy whose
+// changes are planned to replace the external resource.
+const DefaultReplacementPolicy = ReplaceWithApproval
+
+var replacementPolicies = []ReplacementPolicy{ReplaceOnChange, Immutable, ReplaceWithApproval}
+
+// ReplacementPolicies maps the dot-separated paths of the ForceNew arguments
+// of a Terraform resource, e.g. settings.name, to their policies. The
+// arguments with a policy are ForceNew even if the schema of the Terraform
+// resource does not say so.
+type ReplacementPolicies map[string]ReplacementPolicy
+
+// ReplacementPoliciesOf returns the replacement policies declared for the
+// Terraform resource with the given name in resources.yaml.
+func ReplacementPoliciesOf(name string) ReplacementPolicies {
+	rc, err := ParseResourcesConfig(resourcesConfig)
+	if err != nil {
+		panic(errors.Wrap(err, "cannot parse resources.yaml"))
+	}
+	return rc.Resources[name].ReplacementPolicies
+}
+
+// Of returns the policy of the argument at the given path with the given
+// schema, or an empty policy if it's not known to replace the resource. The
+// JSON schemas of the providers do not mark the ForceNew arguments, so only
+// the arguments with a policy, or marked by a configurator, are known.
+func (p ReplacementPolicies) Of(path string, sch *schema.Schema) ReplacementPolicy {
+	if rp, ok := p[path]; ok {
+		return rp
+	}
+	if sch.ForceNew {
+		return DefaultReplacementPolicy
+	}
+	return ""
+}
+
+// markForceNew marks the arguments with the given policies as forcing the
+// replacement of the given Terraform resource, since the JSON schemas of
+// the providers do not tell which arguments do.
+func markForceNew(r *schema.Resource, policies ReplacementPolicies) error {
+	for path, rp := range policies {
+		if !knownReplacementPolicy(rp) {
+			names := make([]string, len(replacementPolicies))
+			for i, k := range replacementPolicies {
+				names[i] = string(k)
+			}
+			return errors.Errorf(errFmtReplacementPolicy, rp, strings.Join(names, ", "))
+		}
+		sch, err := fieldSchema(r, strings.Split(path, "."))
+		if err != nil {
+			return errors.Wrapf(err, errFmtForceNewField, path)
+		}
+		sch.ForceNew = true
+	}
+	return nil
+}
+
+func knownReplacementPolicy(rp ReplacementPolicy) bool {
+	for _, k := range replacementPolicies {
+		if rp == k {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,296 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	tjconfig "github.com/crossplane/terrajet/pkg/config"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	errUnmarshalResources = "cannot unmarshal resource configuration"
+	errFmtUnknownResource = "resource %s is not in the provider schema"
+	errFmtExternalName    = "unknown external name strategy %q, must be one of %s"
+	errFmtSensitiveField  = "cannot mark field %s as sensitive"
+	errFmtDefault         = "cannot set the default of field %s"
+	errFmtConstraints     = "cannot set the constraints of field %s"
+	errFmtConfigure       = "cannot configure resource %s"
+)
+
+// externalNames are the external name strategies that can be used in the
+// resource configuration file.
+var externalNames = map[string]tjconfig.ExternalName{
+	"NameAsIdentifier":       tjconfig.NameAsIdentifier,
+	"IdentifierFromProvider": tjconfig.IdentifierFromProvider,
+}
+
+// ResourcesConfig is the declarative configuration of the resources of the
+// provider.
+type ResourcesConfig struct {
+	// Resources maps the names of Terraform resources to their
+	// configuration.
+	Resources map[string]ResourceConfig `json:"resources,omitempty"`
+}
+
+// ResourceConfig is the declarative configuration of a Terraform resource.
+// Unset fields keep the defaults of the resource.
+type ResourceConfig struct {
+	// ExternalName is the name of the external name strategy, either
+	// NameAsIdentifier or IdentifierFromProvider.
+	ExternalName string `json:"externalName,omitempty"`
+
+	// Kind overrides the kind of the managed resource.
+	Kind string `json:"kind,omitempty"`
+
+	// ShortGroup overrides the short API group of the managed resource.
+	ShortGroup string `json:"shortGroup,omitempty"`
+
+	// Version overrides the API version of the managed resource.
+	Version string `json:"version,omitempty"`
+
+	// References maps Terraform arguments to the resources they refer to.
+	References map[string]ReferenceConfig `json:"references,omitempty"`
+
+	// SensitiveFields are the dot-separated paths of the Terraform
+	// arguments and attributes that are sensitive even though the schema
+	// of the provider does not say so.
+	SensitiveFields []string `json:"sensitiveFields,omitempty"`
+
+	// Defaults maps the dot-separated paths of the Terraform arguments to
+	// the values that the admission webhook sets if they are unset, since
+	// the JSON schemas of the providers do not carry the defaults.
+	Defaults map[string]interface{} `json:"defaults,omitempty"`
+
+	// ConflictsWith, RequiredWith and ExactlyOneOf map the dot-separated
+	// paths of the Terraform arguments to the Terraform schema keys of the
+	// arguments, e.g. settings.0.name, that they conflict with, that they
+	// require, or of which exactly one must be set. The admission webhook
+	// enforces them since the JSON schemas of the providers do not carry
+	// these constraints.
+	ConflictsWith map[string][]string `json:"conflictsWith,omitempty"`
+	RequiredWith  map[string][]string `json:"requiredWith,omitempty"`
+	ExactlyOneOf  map[string][]string `json:"exactlyOneOf,omitempty"`
+
+	// LateInitIgnoredFields are the canonical paths of the fields that
+	// should not be late-initialized.
+	LateInitIgnoredFields []string `json:"lateInitIgnoredFields,omitempty"`
+
+	// ConnectionDetails maps the keys of the connection secret to the
+	// dot-separated paths of the Terraform attributes they are read from.
+	ConnectionDetails map[string]string `json:"connectionDetails,omitempty"`
+
+	// ReplacementPolicies maps the dot-separated paths of the arguments
+	// that force the replacement of the resource to their replacement
+	// policies, either ReplaceOnChange, Immutable or ReplaceWithApproval.
+	// The ForceNew arguments that are not listed get the
+	// DefaultReplacementPolicy.
+	ReplacementPolicies ReplacementPolicies `json:"replacementPolicies,omitempty"`
+}
+
+// ReferenceConfig is the declarative configuration of a cross-resource
+// reference. See tjconfig.Reference for the meaning of its fields.
+type ReferenceConfig struct {
+	Type              string `json:"type"`
+	Extractor         string `json:"extractor,omitempty"`
+	RefFieldName      string `json:"refFieldName,omitempty"`
+	SelectorFieldName string `json:"selectorFieldName,omitempty"`
+}
+
+// ParseResourcesConfig parses the given YAML resource configuration.
+func ParseResourcesConfig(data []byte) (*ResourcesConfig, error) {
+	rc := &ResourcesConfig{}
+	return rc, errors.Wrap(yaml.UnmarshalStrict(data, rc), errUnmarshalResources)
+}
+
+// ForceNewFields returns the dot-separated paths of the arguments with a
+// replacement policy, keyed by the names of their Terraform resources.
+func (rc *ResourcesConfig) ForceNewFields() map[string][]string {
+	result := map[string][]string{}
+	for name, c := range rc.Resources {
+		for path := range c.ReplacementPolicies {
+			result[name] = append(result[name], path)
+		}
+	}
+	return result
+}
+
+// Apply applies the resource configuration to the resources of the given
+// provider configuration.
+func (rc *ResourcesConfig) Apply(pc *tjconfig.Provider) error {
+	for name, c := range rc.Resources {
+		r, ok := pc.Resources[name]
+		if !ok {
+			return errors.Errorf(errFmtUnknownResource, name)
+		}
+		if err := c.apply(r); err != nil {
+			return errors.Wrapf(err, errFmtConfigure, name)
+		}
+	}
+	return nil
+}
+
+func (c ResourceConfig) apply(r *tjconfig.Resource) error {
+	if c.ExternalName != "" {
+		en, ok := externalNames[c.ExternalName]
+		if !ok {
+			return errors.Errorf(errFmtExternalName, c.ExternalName, strings.Join(externalNameKeys(), ", "))
+		}
+		r.ExternalName = en
+	}
+	if c.Kind != "" {
+		r.Kind = c.Kind
+	}
+	if c.ShortGroup != "" {
+		r.ShortGroup = c.ShortGroup
+	}
+	if c.Version != "" {
+		r.Version = c.Version
+	}
+	for field, ref := range c.References {
+		if r.References == nil {
+			r.References = tjconfig.References{}
+		}
+		r.References[field] = tjconfig.Reference{
+			Type:              ref.Type,
+			Extractor:         ref.Extractor,
+			RefFieldName:      ref.RefFieldName,
+			SelectorFieldName: ref.SelectorFieldName,
+		}
+	}
+	if err := c.applySchema(r.TerraformResource); err != nil {
+		return err
+	}
+	r.LateInitializer.IgnoredFields = append(r.LateInitializer.IgnoredFields, c.LateInitIgnoredFields...)
+	if len(c.ConnectionDetails) > 0 {
+		r.Sensitive.AdditionalConnectionDetailsFn = connectionDetailsFn(r.Sensitive.AdditionalConnectionDetailsFn, c.ConnectionDetails)
+	}
+	return nil
+}
+
+// applySchema sets the metadata of the arguments of the given Terraform
+// resource schema that the JSON schemas of the providers do not carry.
+func (c ResourceConfig) applySchema(r *schema.Resource) error {
+	for _, f := range c.SensitiveFields {
+		if err := markSensitive(r, strings.Split(f, ".")); err != nil {
+			return errors.Wrapf(err, errFmtSensitiveField, f)
+		}
+	}
+	if err := markForceNew(r, c.ReplacementPolicies); err != nil {
+		return err
+	}
+	for f, v := range c.Defaults {
+		sch, err := fieldSchema(r, strings.Split(f, "."))
+		if err != nil {
+			return errors.Wrapf(err, errFmtDefault, f)
+		}
+		sch.Default = v
+	}
+	for f := range c.constrainedFields() {
+		sch, err := fieldSchema(r, strings.Split(f, "."))
+		if err != nil {
+			return errors.Wrapf(err, errFmtConstraints, f)
+		}
+		sch.ConflictsWith = c.ConflictsWith[f]
+		sch.RequiredWith = c.RequiredWith[f]
+		sch.ExactlyOneOf = c.ExactlyOneOf[f]
+	}
+	return nil
+}
+
+func (c ResourceConfig) constrainedFields() map[string]bool {
+	fields := map[string]bool{}
+	for _, m := range []map[string][]string{c.ConflictsWith, c.RequiredWith, c.ExactlyOneOf} {
+		for f := range m {
+			fields[f] = true
+		}
+	}
+	return fields
+}
+
+// markSensitive marks the field at the given path of the given Terraform
+// resource schema as sensitive.
+func markSensitive(r *schema.Resource, path []string) error {
+	s, err := fieldSchema(r, path)
+	if err != nil {
+		return err
+	}
+	s.Sensitive = true
+	return nil
+}
+
+// fieldSchema returns the schema of the field at the given path of the given
+// Terraform resource schema.
+func fieldSchema(r *schema.Resource, path []string) (*schema.Schema, error) {
+	s, ok := r.Schema[path[0]]
+	if !ok {
+		return nil, errors.Errorf("no field named %s", path[0])
+	}
+	if len(path) == 1 {
+		return s, nil
+	}
+	nested, ok := s.Elem.(*schema.Resource)
+	if !ok {
+		return nil, errors.Errorf("field %s is not a block", path[0])
+	}
+	return fieldSchema(nested, path[1:])
+}
+
+// connectionDetailsFn returns an AdditionalConnectionDetailsFn that adds the
+// Terraform attributes at the given paths to the connection details returned
+// by the given function.
+func connectionDetailsFn(base tjconfig.AdditionalConnectionDetailsFn, keys map[string]string) tjconfig.AdditionalConnectionDetailsFn {
+	return func(attr map[string]interface{}) (map[string][]byte, error) {
+		conn := map[string][]byte{}
+		if base != nil {
+			c, err := base(attr)
+			if err != nil {
+				return nil, err
+			}
+			for k, v := range c {
+				conn[k] = v
+			}
+		}
+		paved := fieldpath.Pave(attr)
+		for k, p := range keys {
+			v, err := paved.GetValue(p)
+			if fieldpath.IsNotFound(err) || v == nil {
+				continue
+			}
+			if err != nil {
+				return nil, errors.Wrapf(err, "cannot get attribute %s", p)
+			}
+			conn[k] = []byte(fmt.Sprint(v))
+		}
+		return conn, nil
+	}
+}
+
+func externalNameKeys() []string {
+	keys := make([]string, 0, len(externalNames))
+	for k := range externalNames {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"testing"
+
+	tjconfig "github.com/crossplane/terrajet/pkg/config"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestApplySchemaMetadata(t *testing.T) {
+	rc, err := ParseResourcesConfig([]byte(`
+resources:
+  test_resource:
+    sensitiveFields: [password]
+    replacementPolicies:
+      zone: Immutable
+    defaults:
+      setting.mode: standard
+    conflictsWith:
+      name: [name_prefix]
+    requiredWith:
+      size: [zone]
+    exactlyOneOf:
+      name: [name, name_prefix]
+`))
+	if err != nil {
+		t.Fatalf("ParseResourcesConfig(...): %s", err)
+	}
+	res := &schema.Resource{Schema: map[string]*schema.Schema{
+		"name":        {Type: schema.TypeString, Optional: true},
+		"name_prefix": {Type: schema.TypeString, Optional: true},
+		"zone":        {Type: schema.TypeString, Optional: true},
+		"size":        {Type: schema.TypeInt, Optional: true},
+		"password":    {Type: schema.TypeString, Optional: true},
+		"setting": {Type: schema.TypeList, Optional: true, Elem: &schema.Resource{Schema: map[string]*schema.Schema{
+			"mode": {Type: schema.TypeString, Optional: true},
+		}}},
+	}}
+	pc := &tjconfig.Provider{Resources: map[string]*tjconfig.Resource{
+		"test_resource": {Name: "test_resource", TerraformResource: res},
+	}}
+	if err := rc.Apply(pc); err != nil {
+		t.Fatalf("Apply(...): %s", err)
+	}
+	want := map[string]*schema.Schema{
+		"name":        {Type: schema.TypeString, Optional: true, ConflictsWith: []string{"name_prefix"}, ExactlyOneOf: []string{"name", "name_prefix"}},
+		"name_prefix": {Type: schema.TypeString, Optional: true},
+		"zone":        {Type: schema.TypeString, Optional: true, ForceNew: true},
+		"size":        {Type: schema.TypeInt, Optional: true, RequiredWith: []string{"zone"}},
+		"password":    {Type: schema.TypeString, Optional: true, Sensitive: true},
+		"setting": {Type: schema.TypeList, Optional: true, Elem: &schema.Resource{Schema: map[string]*schema.Schema{
+			"mode": {Type: schema.TypeString, Optional: true, Default: "standard"},
+		}}},
+	}
+	if diff := cmp.Diff(want, res.Schema, cmpopts.IgnoreUnexported(schema.Resource{})); diff != "" {
+		t.Errorf("Apply(...): -want, +got:\n%s", diff)
+	}
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	tjconfig "github.com/crossplane/terrajet/pkg/config"
+	"github.com/crossplane/terrajet/pkg/terraform"
+
+	"github.com/crossplane-contrib/provider-jet-template/config/null"
+)
+
+// An Upstream is a Terraform provider bundled into this Crossplane provider.
+type Upstream struct {
+	// Name of the Terraform provider, e.g. "null". It is the prefix of the
+	// names of its resources, e.g. "null_resource".
+	Name string
+
+	// GroupPrefix is the prefix of the root API group of its resources,
+	// e.g. "hashicorp" puts the random_string resource into the
+	// random.hashicorp.jet.crossplane.io group. The resource prefix of this
+	// provider, i.e. "template", is used if it is empty.
+	GroupPrefix string
+
+	// Source and Version are the requirement of the native Terraform
+	// provider, e.g. "hashicorp/null" and "3.1.0". The requirement given
+	// to the provider with the --terraform-provider-source and
+	// --terraform-provider-version flags is used if they are empty.
+	Source  string
+	Version string
+
+	// Schema is the schema of the Terraform provider in the format of
+	// "terraform providers schema -json".
+	Schema []byte
+
+	// Configurators are the functions that configure the resources of the
+	// Terraform provider.
+	Configurators []func(provider *tjconfig.Provider)
+
+	// Configure returns the configuration of the Terraform provider block
+	// from the credentials of the referenced ProviderConfig. The provider
+	// block is left empty if it is nil.
+	Configure func(credentials map[string]string) terraform.ProviderConfiguration
+}
+
+// Upstreams returns the Terraform providers bundled into this provider. Add
+// an entry with its own schema file to bundle another Terraform provider.
+func Upstreams() []Upstream {
+	return []Upstream{
+		{
+			Name:   "null",
+			Schema: []byte(providerSchema),
+			Configurators: []func(provider *tjconfig.Provider){
+				// add custom config functions
+				null.Configure,
+			},
+			// set credentials in Terraform provider configuration, e.g.
+			//   Configure: func(creds map[string]string) terraform.ProviderConfiguration {
+			//   	return terraform.ProviderConfiguration{"username": creds["username"]}
+			//   },
+		},
+	}
+}
+
+// UpstreamOf returns the upstream Terraform provider of the Terraform
+// resource with the given name.
+func UpstreamOf(resource string) (Upstream, bool) {
+	for _, u := range Upstreams() {
+		if strings.HasPrefix(resource, u.Name+"_") {
+			return u, true
+		}
+	}
+	return Upstream{}, false
+}
+
+// RootGroup returns the root API group of the resources of the upstream,
+// e.g. "template.jet.crossplane.io".
+func (u Upstream) RootGroup() string {
+	prefix := u.GroupPrefix
+	if prefix == "" {
+		prefix = resourcePrefix
+	}
+	return fmt.Sprintf("%s.jet.crossplane.io", prefix)
+}
+
+// RootGroupOf returns the root API group of the Terraform resource with the
+// given name, which is the root group of its upstream.
+func RootGroupOf(resource string) string {
+	u, _ := UpstreamOf(resource)
+	return u.RootGroup()
+}
@@ -0,0 +1,244 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"strings"
+
+	tjconfig "github.com/crossplane/terrajet/pkg/config"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/pkg/errors"
+)
+
+const (
+	errFmtAddVersion = "cannot add version %s of resource %s"
+	errFmtMoveField  = "cannot move field %s to %s"
+)
+
+// An APIVersion is an API version of a resource that is served next to, and
+// converted to and from, its storage version. The storage version of a
+// resource is the version it's configured with.
+type APIVersion struct {
+	// Version is the name of the API version, e.g. v1alpha1.
+	Version string
+
+	// Moves are the fields of this version that are named or nested
+	// differently in the storage version.
+	Moves []Move
+}
+
+// A Move maps a field of an API version to a field of the storage version.
+// Fields are given as dot-separated Terraform attribute paths, e.g.
+// "settings.name". A field can only be moved into or out of the blocks that
+// are not shared by both paths if they have at most one item.
+type Move struct {
+	// From is the path of the field in the API version.
+	From string
+	// To is the path of the field in the storage version.
+	To string
+}
+
+// APIVersions returns the API versions of the resources that are served next
+// to their storage versions, keyed by Terraform resource name.
+func APIVersions() map[string][]APIVersion {
+	return map[string][]APIVersion{
+		// add API versions of the resources, e.g.
+		//   "null_resource": {
+		//   	{Version: "v1alpha1", Moves: []Move{{From: "triggers", To: "keepers"}}},
+		//   },
+	}
+}
+
+// VersionKey returns the key of the given API version of the given Terraform
+// resource in the provider configuration.
+func VersionKey(name, version string) string {
+	return name + "/" + version
+}
+
+// SpokeOf returns the key of the storage version of the resource and the
+// API version that the given key of the provider configuration belongs to.
+// It returns false if the key belongs to a storage version.
+func SpokeOf(key string) (string, APIVersion, bool) {
+	hub := strings.TrimSuffix(key, namespacedKeySuffix)
+	parts := strings.SplitN(hub, "/", 2)
+	if len(parts) != 2 {
+		return "", APIVersion{}, false
+	}
+	for _, v := range APIVersions()[parts[0]] {
+		if v.Version != parts[1] {
+			continue
+		}
+		if IsNamespaced(key) {
+			return NamespacedKey(parts[0]), v, true
+		}
+		return parts[0], v, true
+	}
+	return "", APIVersion{}, false
+}
+
+// addVersions adds the API versions returned by APIVersions to the given
+// provider configuration. An API version shares the configuration of the
+// storage version except the fields it moves.
+func addVersions(pc *tjconfig.Provider) error {
+	for name, versions := range APIVersions() {
+		r, ok := pc.Resources[name]
+		if !ok {
+			return errors.Errorf("no resource named %s", name)
+		}
+		for _, v := range versions {
+			vr, err := newVersion(r, v)
+			if err != nil {
+				return errors.Wrapf(err, errFmtAddVersion, v.Version, name)
+			}
+			pc.Resources[VersionKey(name, v.Version)] = vr
+		}
+	}
+	return nil
+}
+
+func newVersion(r *tjconfig.Resource, v APIVersion) (*tjconfig.Resource, error) {
+	if v.Version == r.Version {
+		return nil, errors.New("version is the storage version")
+	}
+	vr := *r
+	vr.Version = v.Version
+	vr.References = tjconfig.References{}
+	for k, ref := range r.References {
+		vr.References[k] = ref
+	}
+	for _, m := range v.Moves {
+		res, err := moveField(vr.TerraformResource, strings.Split(m.To, "."), strings.Split(m.From, "."))
+		if err != nil {
+			return nil, errors.Wrapf(err, errFmtMoveField, m.To, m.From)
+		}
+		vr.TerraformResource = res
+		if ref, ok := vr.References[m.To]; ok {
+			delete(vr.References, m.To)
+			vr.References[m.From] = ref
+		}
+	}
+	return &vr, nil
+}
+
+// moveField returns a copy of the given Terraform resource schema with the
+// field at the given path moved to the other given path. The given schema is
+// not modified.
+func moveField(r *schema.Resource, from, to []string) (*schema.Resource, error) {
+	if len(from) > 1 && len(to) > 1 && from[0] == to[0] {
+		s, nested, err := block(r, from[0], false)
+		if err != nil {
+			return nil, err
+		}
+		moved, err := moveField(nested, from[1:], to[1:])
+		if err != nil {
+			return nil, err
+		}
+		return withBlock(r, from[0], s, moved), nil
+	}
+	s, res, err := removeField(r, from)
+	if err != nil {
+		return nil, err
+	}
+	return addField(res, to, s)
+}
+
+// removeField returns the schema of the field at the given path and a copy of
+// the given Terraform resource schema without it.
+func removeField(r *schema.Resource, path []string) (*schema.Schema, *schema.Resource, error) {
+	if len(path) == 1 {
+		s, ok := r.Schema[path[0]]
+		if !ok {
+			return nil, nil, errors.Errorf("no field named %s", path[0])
+		}
+		return s, withField(r, path[0], nil), nil
+	}
+	parent, nested, err := block(r, path[0], true)
+	if err != nil {
+		return nil, nil, err
+	}
+	s, removed, err := removeField(nested, path[1:])
+	if err != nil {
+		return nil, nil, err
+	}
+	// Blocks left empty are removed as well.
+	if len(removed.Schema) == 0 {
+		return s, withField(r, path[0], nil), nil
+	}
+	return s, withBlock(r, path[0], parent, removed), nil
+}
+
+// addField returns a copy of the given Terraform resource schema with the
+// given field schema added at the given path.
+func addField(r *schema.Resource, path []string, s *schema.Schema) (*schema.Resource, error) {
+	if len(path) == 1 {
+		if _, ok := r.Schema[path[0]]; ok {
+			return nil, errors.Errorf("field %s already exists", path[0])
+		}
+		return withField(r, path[0], s), nil
+	}
+	parent, nested, err := block(r, path[0], true)
+	if err != nil {
+		return nil, err
+	}
+	added, err := addField(nested, path[1:], s)
+	if err != nil {
+		return nil, err
+	}
+	return withBlock(r, path[0], parent, added), nil
+}
+
+// block returns the schema of the block with the given name and the schema
+// of its fields. Blocks are lists in the API versions, so a field can only be
+// moved into or out of a block if it's a single item.
+func block(r *schema.Resource, name string, single bool) (*schema.Schema, *schema.Resource, error) {
+	s, ok := r.Schema[name]
+	if !ok {
+		return nil, nil, errors.Errorf("no field named %s", name)
+	}
+	nested, ok := s.Elem.(*schema.Resource)
+	if !ok {
+		return nil, nil, errors.Errorf("field %s is not a block", name)
+	}
+	if single && s.MaxItems != 1 {
+		return nil, nil, errors.Errorf("block %s can have more than one item", name)
+	}
+	return s, nested, nil
+}
+
+// withBlock returns a copy of the given Terraform resource schema with the
+// fields of the given block replaced.
+func withBlock(r *schema.Resource, name string, s *schema.Schema, nested *schema.Resource) *schema.Resource {
+	ns := *s
+	ns.Elem = nested
+	return withField(r, name, &ns)
+}
+
+// withField returns a copy of the given Terraform resource schema with the
+// given field set, or removed if the given schema is nil.
+func withField(r *schema.Resource, name string, s *schema.Schema) *schema.Resource {
+	res := *r
+	res.Schema = make(map[string]*schema.Schema, len(r.Schema))
+	for k, v := range r.Schema {
+		res.Schema[k] = v
+	}
+	if s == nil {
+		delete(res.Schema, name)
+	} else {
+		res.Schema[name] = s
+	}
+	return &res
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admission
+
+import (
+	"context"
+
+	"github.com/crossplane/terrajet/pkg/config"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	errSetParameters = "cannot set the parameters of the managed resource"
+)
+
+// A Defaulter sets the defaults declared in the schema of the Terraform
+// resource of a kind on its managed resources.
+type Defaulter struct {
+	config *config.Resource
+}
+
+// NewDefaulter returns a Defaulter of the managed resources configured with
+// the given resource configuration.
+func NewDefaulter(cfg *config.Resource) *Defaulter {
+	return &Defaulter{config: cfg}
+}
+
+// Default sets the defaults of the unset parameters of the given managed
+// resource.
+func (d *Defaulter) Default(_ context.Context, obj runtime.Object) error {
+	tr, params, err := parameters(obj)
+	if err != nil {
+		return err
+	}
+	if !defaults(d.config.TerraformResource, params) {
+		return nil
+	}
+	return errors.Wrap(tr.SetParameters(params), errSetParameters)
+}
+
+// defaults sets the defaults of the unset fields of the given parameters of
+// a block, or of the resource itself, and reports whether any is set.
+func defaults(res *schema.Resource, params map[string]interface{}) bool {
+	changed := false
+	for k, sch := range res.Schema {
+		if sch.Computed && !sch.Optional {
+			continue
+		}
+		v, ok := params[k]
+		if (!ok || v == nil) && sch.Default != nil && !sch.Sensitive {
+			params[k] = sch.Default
+			changed = true
+			continue
+		}
+		nested, ok := sch.Elem.(*schema.Resource)
+		items, isList := v.([]interface{})
+		if !ok || !isList {
+			continue
+		}
+		for _, e := range items {
+			if m, ok := e.(map[string]interface{}); ok && defaults(nested, m) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admission
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestDefault(t *testing.T) {
+	cfg := testResource()
+	cfg.TerraformResource.Schema["name_prefix"].Default = "example-"
+	cfg.TerraformResource.Schema["password"].Default = "secret"
+	cfg.TerraformResource.Schema["setting"].Elem.(*schema.Resource).Schema["mode"].Default = "standard"
+
+	cases := map[string]struct {
+		reason string
+		params map[string]interface{}
+		want   map[string]interface{}
+	}{
+		"Unset": {
+			reason: "Unset arguments and the unset arguments of blocks should get their defaults, except the sensitive ones.",
+			params: map[string]interface{}{"zone": "z", "setting": []interface{}{map[string]interface{}{}}},
+			want:   map[string]interface{}{"zone": "z", "name_prefix": "example-", "setting": []interface{}{map[string]interface{}{"mode": "standard"}}},
+		},
+		"Set": {
+			reason: "Set arguments should be kept.",
+			params: map[string]interface{}{"name_prefix": "mine-", "setting": []interface{}{map[string]interface{}{"mode": "fast"}}},
+			want:   map[string]interface{}{"name_prefix": "mine-", "setting": []interface{}{map[string]interface{}{"mode": "fast"}}},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			o := newObject(tc.params, nil)
+			if err := NewDefaulter(cfg).Default(context.Background(), o); err != nil {
+				t.Fatalf("Default(...): %s", err)
+			}
+			if diff := cmp.Diff(tc.want, o.params); diff != "" {
+				t.Errorf("\n%s\nDefault(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
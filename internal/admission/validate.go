@@ -0,0 +1,326 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package admission validates and defaults the managed resources against
+// the schemas of their Terraform resources when they are admitted.
+package admission
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/crossplane/terrajet/pkg/config"
+	"github.com/crossplane/terrajet/pkg/resource"
+	"github.com/crossplane/terrajet/pkg/types/name"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	providerconfig "github.com/crossplane-contrib/provider-jet-template/config"
+	"github.com/crossplane-contrib/provider-jet-template/internal/jet"
+)
+
+const (
+	errNotTerraformed = "object is not a Terraformed managed resource"
+	errGetParameters  = "cannot get the parameters of the managed resource"
+)
+
+// A Validator validates the managed resources of a kind against the schema
+// of their Terraform resource.
+type Validator struct {
+	config   *config.Resource
+	policies providerconfig.ReplacementPolicies
+}
+
+// NewValidator returns a Validator of the managed resources configured with
+// the given resource configuration.
+func NewValidator(cfg *config.Resource) *Validator {
+	return &Validator{
+		config:   cfg,
+		policies: providerconfig.ReplacementPoliciesOf(cfg.Name),
+	}
+}
+
+// ValidateCreate validates the given managed resource.
+func (v *Validator) ValidateCreate(_ context.Context, obj runtime.Object) error {
+	tr, params, err := parameters(obj)
+	if err != nil {
+		return err
+	}
+	return invalid(tr, v.validate(tr, params))
+}
+
+// ValidateUpdate validates the given managed resource and rejects the
+// changes of the fields that would force the replacement of the external
+// resource unless their replacement policies allow it.
+func (v *Validator) ValidateUpdate(_ context.Context, oldObj, newObj runtime.Object) error {
+	tr, params, err := parameters(newObj)
+	if err != nil {
+		return err
+	}
+	_, oldParams, err := parameters(oldObj)
+	if err != nil {
+		return err
+	}
+	errs := v.validate(tr, params)
+	r := &replacements{
+		policies: v.policies,
+		approved: tr.GetAnnotations()[jet.AnnotationKeyApproveReplacement] == "true",
+	}
+	errs = append(errs, r.immutable(v.config.TerraformResource, oldParams, params, "", forProviderPath)...)
+	return invalid(tr, errs)
+}
+
+// ValidateDelete does nothing since the managed resources can always be
+// deleted.
+func (v *Validator) ValidateDelete(_ context.Context, _ runtime.Object) error {
+	return nil
+}
+
+var forProviderPath = field.NewPath("spec", "forProvider")
+
+func (v *Validator) validate(tr resource.Terraformed, params map[string]interface{}) field.ErrorList {
+	var errs field.ErrorList
+	if tr.GetProviderConfigReference() == nil {
+		errs = append(errs, field.Required(field.NewPath("spec", "providerConfigRef"), "a ProviderConfig must be referenced"))
+	}
+	c := &checker{config: v.config, root: params}
+	return append(errs, c.block(v.config.TerraformResource, params, nil, forProviderPath)...)
+}
+
+// checker checks the parameters of a managed resource against the
+// constraints of the schema of its Terraform resource.
+type checker struct {
+	config *config.Resource
+	root   map[string]interface{}
+}
+
+// block checks the given parameters of the block at the given Terraform
+// attribute path, or of the resource itself if the path is empty.
+func (c *checker) block(res *schema.Resource, params map[string]interface{}, tfPath []string, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	for _, k := range sortedKeys(res.Schema) {
+		sch := res.Schema[k]
+		if sch.Computed && !sch.Optional {
+			continue
+		}
+		p := path.Child(jsonName(k, sch))
+		v, ok := params[k]
+		if !ok || v == nil {
+			if sch.Required && c.requiresValue(append(tfPath, k), sch) {
+				errs = append(errs, field.Required(p, ""))
+			}
+			continue
+		}
+		errs = append(errs, c.constraints(sch, p)...)
+		errs = append(errs, c.value(sch, v, append(tfPath, k), p)...)
+	}
+	return errs
+}
+
+// requiresValue returns whether a required field must be set in the spec. It
+// is not the case for the fields omitted in favour of the external name, the
+// fields that can be resolved from references, and the sensitive fields that
+// are read from Secrets.
+func (c *checker) requiresValue(tfPath []string, sch *schema.Schema) bool {
+	if sch.Sensitive {
+		return false
+	}
+	if len(tfPath) == 1 {
+		for _, f := range c.config.ExternalName.OmittedFields {
+			if f == tfPath[0] {
+				return false
+			}
+		}
+	}
+	_, isRef := c.config.References[strings.Join(tfPath, ".")]
+	return !isRef
+}
+
+// constraints checks the constraints of the given field on the other fields
+// of the resource.
+func (c *checker) constraints(sch *schema.Schema, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	for _, k := range sch.ConflictsWith {
+		if c.isSet(k) {
+			errs = append(errs, field.Forbidden(path, fmt.Sprintf("conflicts with %s", k)))
+		}
+	}
+	for _, k := range sch.RequiredWith {
+		if !c.isSet(k) {
+			errs = append(errs, field.Required(path, fmt.Sprintf("requires %s to be set", k)))
+		}
+	}
+	if n := c.countSet(sch.ExactlyOneOf); len(sch.ExactlyOneOf) > 0 && n != 1 {
+		errs = append(errs, field.Invalid(path, n, fmt.Sprintf("exactly one of %s must be set", strings.Join(sch.ExactlyOneOf, ", "))))
+	}
+	return errs
+}
+
+// value checks the given value of the field with the given schema.
+func (c *checker) value(sch *schema.Schema, v interface{}, tfPath []string, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k := range t {
+			if k == "" {
+				errs = append(errs, field.Invalid(path, k, "keys must not be empty"))
+			}
+		}
+	case []interface{}:
+		if sch.MaxItems > 0 && len(t) > sch.MaxItems {
+			errs = append(errs, field.TooMany(path, len(t), sch.MaxItems))
+		}
+		if sch.MinItems > 0 && len(t) < sch.MinItems {
+			errs = append(errs, field.Invalid(path, len(t), fmt.Sprintf("must have at least %d items", sch.MinItems)))
+		}
+		nested, ok := sch.Elem.(*schema.Resource)
+		if !ok {
+			break
+		}
+		for i, e := range t {
+			if m, ok := e.(map[string]interface{}); ok {
+				errs = append(errs, c.block(nested, m, tfPath, path.Index(i))...)
+			}
+		}
+	}
+	return errs
+}
+
+// isSet returns whether the field at the given Terraform schema path, such
+// as "settings.0.name", is set.
+func (c *checker) isSet(key string) bool {
+	var v interface{} = c.root
+	for _, s := range strings.Split(key, ".") {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			v = t[s]
+		case []interface{}:
+			i, err := strconv.Atoi(s)
+			if err != nil || i >= len(t) {
+				return false
+			}
+			v = t[i]
+		default:
+			return false
+		}
+	}
+	return v != nil
+}
+
+func (c *checker) countSet(keys []string) int {
+	n := 0
+	for _, k := range keys {
+		if c.isSet(k) {
+			n++
+		}
+	}
+	return n
+}
+
+// replacements rejects the changes of the fields that would replace the
+// external resource and that are not allowed by their replacement policies.
+type replacements struct {
+	policies providerconfig.ReplacementPolicies
+	approved bool
+}
+
+// immutable checks the changes of the fields of a block, or of the resource
+// itself. Setting a field for the first time is allowed so that it can be
+// late-initialized.
+func (r *replacements) immutable(res *schema.Resource, oldParams, params map[string]interface{}, prefix string, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	for _, k := range sortedKeys(res.Schema) {
+		sch := res.Schema[k]
+		p := path.Child(jsonName(k, sch))
+		oldValue, ok := oldParams[k]
+		if !ok || oldValue == nil {
+			continue
+		}
+		switch rp := r.policies.Of(prefix+k, sch); {
+		case rp == "" || rp == providerconfig.ReplaceOnChange:
+		case reflect.DeepEqual(oldValue, params[k]):
+			continue
+		case rp == providerconfig.Immutable:
+			errs = append(errs, field.Forbidden(p, "field is immutable, changing it would replace the external resource"))
+			continue
+		case !r.approved:
+			errs = append(errs, field.Forbidden(p, fmt.Sprintf("changing the field would replace the external resource, which must be approved with the %s: \"true\" annotation", jet.AnnotationKeyApproveReplacement)))
+			continue
+		}
+		errs = append(errs, r.immutableItems(sch, oldValue, params[k], prefix+k+".", p)...)
+	}
+	return errs
+}
+
+// immutableItems checks the items of the blocks that are in both the old and
+// the new values.
+func (r *replacements) immutableItems(sch *schema.Schema, oldValue, value interface{}, prefix string, path *field.Path) field.ErrorList {
+	nested, ok := sch.Elem.(*schema.Resource)
+	oldItems, oldOK := oldValue.([]interface{})
+	items, newOK := value.([]interface{})
+	if !ok || !oldOK || !newOK {
+		return nil
+	}
+	var errs field.ErrorList
+	for i := 0; i < len(oldItems) && i < len(items); i++ {
+		o, _ := oldItems[i].(map[string]interface{})
+		n, _ := items[i].(map[string]interface{})
+		errs = append(errs, r.immutable(nested, o, n, prefix, path.Index(i))...)
+	}
+	return errs
+}
+
+func parameters(obj runtime.Object) (resource.Terraformed, map[string]interface{}, error) {
+	tr, ok := obj.(resource.Terraformed)
+	if !ok {
+		return nil, nil, errors.New(errNotTerraformed)
+	}
+	params, err := tr.GetParameters()
+	return tr, params, errors.Wrap(err, errGetParameters)
+}
+
+func invalid(tr resource.Terraformed, errs field.ErrorList) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return kerrors.NewInvalid(tr.GetObjectKind().GroupVersionKind().GroupKind(), tr.GetName(), errs)
+}
+
+// jsonName returns the name of the field of the managed resource that the
+// Terrajet type builder generates for the given Terraform attribute.
+func jsonName(k string, sch *schema.Schema) string {
+	n := name.NewFromSnake(k)
+	if sch.Sensitive {
+		return name.NewFromCamel(n.Camel + "SecretRef").LowerCamelComputed
+	}
+	return n.LowerCamelComputed
+}
+
+func sortedKeys(m map[string]*schema.Schema) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admission
+
+import (
+	"context"
+	"testing"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/crossplane/terrajet/pkg/config"
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	"github.com/crossplane-contrib/provider-jet-template/apis/null/v1alpha1"
+	"github.com/crossplane-contrib/provider-jet-template/internal/jet"
+)
+
+// object is a managed resource whose parameters are kept as is so that they
+// can be checked against any schema.
+type object struct {
+	v1alpha1.Resource
+	params map[string]interface{}
+}
+
+func (o *object) GetParameters() (map[string]interface{}, error) {
+	return o.params, nil
+}
+
+func (o *object) SetParameters(p map[string]interface{}) error {
+	o.params = p
+	return nil
+}
+
+func newObject(params map[string]interface{}, annotations map[string]string) *object {
+	o := &object{params: params}
+	o.SetName("example")
+	o.SetAnnotations(annotations)
+	o.SetProviderConfigReference(&xpv1.Reference{Name: "default"})
+	return o
+}
+
+// testResource returns the configuration of a resource with the constraints
+// that resources.yaml can declare.
+func testResource() *config.Resource {
+	return &config.Resource{
+		Name: "test_resource",
+		TerraformResource: &schema.Resource{Schema: map[string]*schema.Schema{
+			"id":          {Type: schema.TypeString, Computed: true},
+			"name":        {Type: schema.TypeString, Optional: true, ConflictsWith: []string{"name_prefix"}, ExactlyOneOf: []string{"name", "name_prefix"}},
+			"name_prefix": {Type: schema.TypeString, Optional: true},
+			"zone":        {Type: schema.TypeString, Required: true, ForceNew: true},
+			"size":        {Type: schema.TypeInt, Optional: true, RequiredWith: []string{"zone"}},
+			"password":    {Type: schema.TypeString, Required: true, Sensitive: true},
+			"tags":        {Type: schema.TypeMap, Optional: true, Elem: &schema.Schema{Type: schema.TypeString}},
+			"setting": {Type: schema.TypeList, Optional: true, MaxItems: 1, Elem: &schema.Resource{Schema: map[string]*schema.Schema{
+				"mode": {Type: schema.TypeString, Required: true},
+			}}},
+		}},
+	}
+}
+
+func TestValidateCreate(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		params map[string]interface{}
+		want   field.ErrorList
+	}{
+		"Valid": {
+			reason: "Parameters that satisfy the schema should be valid.",
+			params: map[string]interface{}{"name": "a", "zone": "z", "size": 1.0, "setting": []interface{}{map[string]interface{}{"mode": "m"}}},
+		},
+		"MissingRequired": {
+			reason: "Required arguments other than sensitive ones should be set.",
+			params: map[string]interface{}{"name": "a", "setting": []interface{}{map[string]interface{}{}}},
+			want: field.ErrorList{
+				field.Required(forProviderPath.Child("setting").Index(0).Child("mode"), ""),
+				field.Required(forProviderPath.Child("zone"), ""),
+			},
+		},
+		"Conflicts": {
+			reason: "Arguments that conflict with each other should be rejected.",
+			params: map[string]interface{}{"name": "a", "name_prefix": "b", "zone": "z"},
+			want: field.ErrorList{
+				field.Forbidden(forProviderPath.Child("name"), "conflicts with name_prefix"),
+				field.Invalid(forProviderPath.Child("name"), 2, "exactly one of name, name_prefix must be set"),
+			},
+		},
+		"Blocks": {
+			reason: "Too many blocks and empty map keys should be rejected.",
+			params: map[string]interface{}{
+				"name_prefix": "b",
+				"zone":        "z",
+				"tags":        map[string]interface{}{"": "v"},
+				"setting":     []interface{}{map[string]interface{}{"mode": "m"}, map[string]interface{}{"mode": "m"}},
+			},
+			want: field.ErrorList{
+				field.TooMany(forProviderPath.Child("setting"), 2, 1),
+				field.Invalid(forProviderPath.Child("tags"), "", "keys must not be empty"),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			o := newObject(tc.params, nil)
+			err := NewValidator(testResource()).ValidateCreate(context.Background(), o)
+			if diff := cmp.Diff(invalid(o, tc.want), err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nValidateCreate(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestValidateUpdate(t *testing.T) {
+	approved := map[string]string{jet.AnnotationKeyApproveReplacement: "true"}
+	cases := map[string]struct {
+		reason      string
+		old         map[string]interface{}
+		params      map[string]interface{}
+		annotations map[string]string
+		want        field.ErrorList
+	}{
+		"Unchanged": {
+			reason: "Updates that do not change ForceNew arguments should be allowed.",
+			old:    map[string]interface{}{"name": "a", "zone": "z"},
+			params: map[string]interface{}{"name": "b", "zone": "z"},
+		},
+		"LateInitialized": {
+			reason: "Setting a ForceNew argument for the first time should be allowed.",
+			old:    map[string]interface{}{"name": "a"},
+			params: map[string]interface{}{"name": "a", "zone": "z"},
+		},
+		"NotApproved": {
+			reason: "Changing a ForceNew argument should be rejected unless the replacement is approved.",
+			old:    map[string]interface{}{"name": "a", "zone": "z"},
+			params: map[string]interface{}{"name": "a", "zone": "y"},
+			want: field.ErrorList{
+				field.Forbidden(forProviderPath.Child("zone"), "changing the field would replace the external resource, which must be approved with the "+jet.AnnotationKeyApproveReplacement+": \"true\" annotation"),
+			},
+		},
+		"Approved": {
+			reason:      "Changing a ForceNew argument should be allowed if the replacement is approved.",
+			old:         map[string]interface{}{"name": "a", "zone": "z"},
+			params:      map[string]interface{}{"name": "a", "zone": "y"},
+			annotations: approved,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			o := newObject(tc.params, tc.annotations)
+			err := NewValidator(testResource()).ValidateUpdate(context.Background(), newObject(tc.old, nil), o)
+			if diff := cmp.Diff(invalid(o, tc.want), err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nValidateUpdate(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
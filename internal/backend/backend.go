@@ -0,0 +1,202 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package backend stores the Terraform state of the managed resources outside
+// of their workspaces, in the layouts and with the locking protocols of the
+// Terraform backends, so that Terraform sessions configured with the same
+// backend share the state and the locks with the provider.
+package backend
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane-contrib/provider-jet-template/apis/v1alpha1"
+)
+
+const (
+	errFmtUnknownType         = "unknown state backend type %q"
+	errNoHTTP                 = "http state backend is not configured"
+	errCrossNamespaceBackend  = "state backend of a NamespacedProviderConfig must be in the namespace of the managed resource"
+	errGetPassword            = "cannot get the password of the state backend"
+	errFmtLocked              = "state is locked by %s for %s since %s, its lock ID is %s"
+	errFmtLockedUnknownHolder = "state is locked, its lock ID is %s"
+)
+
+// A Backend stores the Terraform states of managed resources under their
+// keys.
+type Backend interface {
+	// Get returns the state with the given key, or nil if there's none.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Put stores the given state under the given key. It must hold the
+	// lock with the given ID.
+	Put(ctx context.Context, key string, state []byte, lockID string) error
+	// Delete removes the state with the given key.
+	Delete(ctx context.Context, key string) error
+	// Lock locks the state with the given key, or returns a *LockedError if
+	// it's already locked.
+	Lock(ctx context.Context, key string, info *LockInfo) error
+	// Unlock releases the lock with the given info.
+	Unlock(ctx context.Context, key string, info *LockInfo) error
+	// Block returns the backend block of the Terraform configuration with
+	// which a Terraform session shares the state with the given key and its
+	// lock. The credentials are left to the environment of the session.
+	Block(key string) map[string]interface{}
+}
+
+// LockInfo describes the holder of a lock in the format of Terraform.
+type LockInfo struct {
+	ID        string
+	Operation string
+	Info      string
+	Who       string
+	Version   string
+	Created   time.Time
+	Path      string
+}
+
+// NewLockInfo returns the info of a new lock for the given operation on the
+// given managed resource.
+func NewLockInfo(operation string, mg resource.Managed) *LockInfo {
+	host, _ := os.Hostname()
+	return &LockInfo{
+		ID:        string(uuid.NewUUID()),
+		Operation: operation,
+		Info:      "managed resource " + types.NamespacedName{Namespace: mg.GetNamespace(), Name: mg.GetName()}.String(),
+		Who:       "provider-jet-template@" + host,
+		Created:   time.Now().UTC(),
+	}
+}
+
+// A LockedError is returned when a state is locked by someone else.
+type LockedError struct {
+	// Info of the lock, if known.
+	Info *LockInfo
+	// ID of the lock, if known without its info.
+	ID string
+}
+
+func (e *LockedError) Error() string {
+	if e.Info == nil {
+		return fmt.Sprintf(errFmtLockedUnknownHolder, e.ID)
+	}
+	return fmt.Sprintf(errFmtLocked, e.Info.Who, e.Info.Operation, e.Info.Created.Format(time.RFC3339), e.Info.ID)
+}
+
+// A Factory returns the state backends configured by ProviderConfigs.
+type Factory struct {
+	kube      client.Client
+	reader    client.Reader
+	http      *http.Client
+	namespace string
+}
+
+// NewFactory returns a new Factory. The given client is used to write the
+// Kubernetes state backends and to read the credentials of the HTTP state
+// backends. The locks are read with the given uncached reader. The
+// Kubernetes state backends of ProviderConfigs default to the given
+// namespace.
+func NewFactory(kube client.Client, reader client.Reader, namespace string) *Factory {
+	return &Factory{kube: kube, reader: reader, http: &http.Client{Timeout: time.Minute}, namespace: namespace}
+}
+
+// For returns the state backend of the given managed resource configured by
+// the given spec of its ProviderConfig, or nil if its state is kept only in
+// its workspace. A nil Factory returns nil as well.
+func (f *Factory) For(ctx context.Context, mg resource.Managed, spec *v1alpha1.ProviderConfigSpec) (Backend, error) {
+	if f == nil || spec.StateBackend == nil {
+		return nil, nil
+	}
+	sb := spec.StateBackend
+	switch sb.Type {
+	case v1alpha1.StateBackendLocal, "":
+		return nil, nil
+	case v1alpha1.StateBackendKubernetes:
+		cfg := sb.Kubernetes
+		if cfg == nil {
+			cfg = &v1alpha1.KubernetesStateBackend{}
+		}
+		return f.kubernetes(mg, cfg)
+	case v1alpha1.StateBackendHTTP:
+		if sb.HTTP == nil {
+			return nil, errors.New(errNoHTTP)
+		}
+		return f.httpBackend(ctx, mg, sb.HTTP)
+	default:
+		return nil, errors.Errorf(errFmtUnknownType, sb.Type)
+	}
+}
+
+func (f *Factory) kubernetes(mg resource.Managed, cfg *v1alpha1.KubernetesStateBackend) (Backend, error) {
+	ns := cfg.Namespace
+	switch {
+	case mg.GetNamespace() != "" && ns == "":
+		ns = mg.GetNamespace()
+	case mg.GetNamespace() != "" && ns != mg.GetNamespace():
+		return nil, errors.New(errCrossNamespaceBackend)
+	case ns == "":
+		ns = f.namespace
+	}
+	return &kubernetesBackend{kube: f.kube, reader: f.reader, namespace: ns}, nil
+}
+
+func (f *Factory) httpBackend(ctx context.Context, mg resource.Managed, cfg *v1alpha1.HTTPStateBackend) (Backend, error) {
+	b := &httpBackend{
+		client:        f.http,
+		address:       cfg.Address,
+		lockAddress:   cfg.LockAddress,
+		unlockAddress: cfg.UnlockAddress,
+		username:      cfg.Username,
+	}
+	if b.lockAddress == "" {
+		b.lockAddress = b.address
+	}
+	if b.unlockAddress == "" {
+		b.unlockAddress = b.lockAddress
+	}
+	ref := cfg.PasswordSecretRef
+	if ref == nil {
+		return b, nil
+	}
+	if mg.GetNamespace() != "" && ref.Namespace != mg.GetNamespace() {
+		return nil, errors.New(errCrossNamespaceBackend)
+	}
+	s := &corev1.Secret{}
+	if err := f.kube.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, s); err != nil {
+		return nil, errors.Wrap(err, errGetPassword)
+	}
+	b.password = string(s.Data[ref.Key])
+	return b, nil
+}
+
+// expand returns the given address of the state with the given key.
+func expand(address, key string) string {
+	if strings.Contains(address, "{uid}") {
+		return strings.ReplaceAll(address, "{uid}", key)
+	}
+	return strings.TrimSuffix(address, "/") + "/" + key
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backend
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5" // nolint:gosec
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/pkg/errors"
+)
+
+// The methods of the http backend of Terraform.
+const (
+	methodLock   = "LOCK"
+	methodUnlock = "UNLOCK"
+
+	errFmtRequest = "cannot %s the state"
+	errFmtStatus  = "cannot %s the state: unexpected status %s"
+)
+
+// httpBackend stores the states in a server implementing the protocol of the
+// http backend of Terraform.
+type httpBackend struct {
+	client        *http.Client
+	address       string
+	lockAddress   string
+	unlockAddress string
+	username      string
+	password      string
+}
+
+func (b *httpBackend) Get(ctx context.Context, key string) ([]byte, error) {
+	status, body, err := b.do(ctx, http.MethodGet, expand(b.address, key), nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, errFmtRequest, "get")
+	}
+	switch status {
+	case http.StatusOK:
+		if len(body) == 0 {
+			return nil, nil
+		}
+		return body, nil
+	case http.StatusNoContent, http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, errors.Errorf(errFmtStatus, "get", http.StatusText(status))
+	}
+}
+
+func (b *httpBackend) Put(ctx context.Context, key string, state []byte, lockID string) error {
+	u, err := url.Parse(expand(b.address, key))
+	if err != nil {
+		return errors.Wrapf(err, errFmtRequest, "put")
+	}
+	if lockID != "" {
+		q := u.Query()
+		q.Set("ID", lockID)
+		u.RawQuery = q.Encode()
+	}
+	status, _, err := b.do(ctx, http.MethodPost, u.String(), state)
+	if err != nil {
+		return errors.Wrapf(err, errFmtRequest, "put")
+	}
+	if status != http.StatusOK && status != http.StatusCreated && status != http.StatusNoContent {
+		return errors.Errorf(errFmtStatus, "put", http.StatusText(status))
+	}
+	return nil
+}
+
+func (b *httpBackend) Delete(ctx context.Context, key string) error {
+	status, _, err := b.do(ctx, http.MethodDelete, expand(b.address, key), nil)
+	if err != nil {
+		return errors.Wrapf(err, errFmtRequest, "delete")
+	}
+	if status != http.StatusOK && status != http.StatusNoContent && status != http.StatusNotFound {
+		return errors.Errorf(errFmtStatus, "delete", http.StatusText(status))
+	}
+	return nil
+}
+
+func (b *httpBackend) Lock(ctx context.Context, key string, info *LockInfo) error {
+	raw, err := json.Marshal(info)
+	if err != nil {
+		return errors.Wrap(err, errMarshalLock)
+	}
+	status, body, err := b.do(ctx, methodLock, expand(b.lockAddress, key), raw)
+	if err != nil {
+		return errors.Wrapf(err, errFmtRequest, "lock")
+	}
+	switch status {
+	case http.StatusOK:
+		return nil
+	case http.StatusLocked, http.StatusConflict:
+		// The server returns the info of the existing lock if it can.
+		existing := &LockInfo{}
+		if json.Unmarshal(body, existing) != nil || existing.ID == "" {
+			return &LockedError{}
+		}
+		return &LockedError{Info: existing}
+	default:
+		return errors.Errorf(errFmtStatus, "lock", http.StatusText(status))
+	}
+}
+
+func (b *httpBackend) Unlock(ctx context.Context, key string, info *LockInfo) error {
+	raw, err := json.Marshal(info)
+	if err != nil {
+		return errors.Wrap(err, errMarshalLock)
+	}
+	status, _, err := b.do(ctx, methodUnlock, expand(b.unlockAddress, key), raw)
+	if err != nil {
+		return errors.Wrapf(err, errFmtRequest, "unlock")
+	}
+	if status != http.StatusOK {
+		return errors.Errorf(errFmtStatus, "unlock", http.StatusText(status))
+	}
+	return nil
+}
+
+// do sends a request with the given method and body to the given address,
+// and returns the status and the body of the response.
+// Block returns the configuration of the http backend of Terraform, whose
+// default methods the backend uses. The Terraform session has to set the
+// password, e.g. with TF_HTTP_PASSWORD.
+func (b *httpBackend) Block(key string) map[string]interface{} {
+	cfg := map[string]interface{}{
+		"address":        expand(b.address, key),
+		"lock_address":   expand(b.lockAddress, key),
+		"unlock_address": expand(b.unlockAddress, key),
+	}
+	if b.username != "" {
+		cfg["username"] = b.username
+	}
+	return map[string]interface{}{"http": cfg}
+}
+
+func (b *httpBackend) do(ctx context.Context, method, address string, body []byte) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, address, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+		sum := md5.Sum(body) // nolint:gosec
+		req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+	}
+	if b.username != "" {
+		req.SetBasicAuth(b.username, b.password)
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close() // nolint:errcheck
+	raw, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, raw, err
+}
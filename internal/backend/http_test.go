@@ -0,0 +1,228 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backend
+
+import (
+	"context"
+	"crypto/md5" // nolint:gosec
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+// stateServer implements the protocol of the http backend of Terraform in
+// memory.
+type stateServer struct {
+	mu     sync.Mutex
+	states map[string][]byte
+	locks  map[string]*LockInfo
+	// putLockIDs are the lock IDs the states were put with.
+	putLockIDs []string
+}
+
+func newStateServer(t *testing.T) (*stateServer, *httpBackend) {
+	s := &stateServer{states: map[string][]byte{}, locks: map[string]*LockInfo{}}
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	return s, &httpBackend{
+		client:        srv.Client(),
+		address:       srv.URL + "/state/{uid}",
+		lockAddress:   srv.URL + "/lock",
+		unlockAddress: srv.URL + "/lock",
+		username:      "user",
+		password:      "secret",
+	}
+}
+
+func (s *stateServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if u, p, ok := r.BasicAuth(); !ok || u != "user" || p != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	if len(body) > 0 {
+		sum := md5.Sum(body) // nolint:gosec
+		if r.Header.Get("Content-MD5") != base64.StdEncoding.EncodeToString(sum[:]) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	key := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	switch r.Method {
+	case http.MethodGet:
+		st, ok := s.states[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(st)
+	case http.MethodPost:
+		s.states[key] = body
+		s.putLockIDs = append(s.putLockIDs, r.URL.Query().Get("ID"))
+	case http.MethodDelete:
+		delete(s.states, key)
+	case methodLock, methodUnlock:
+		s.lock(w, r.Method, key, body)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *stateServer) lock(w http.ResponseWriter, method, key string, body []byte) {
+	info := &LockInfo{}
+	if err := json.Unmarshal(body, info); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	existing, locked := s.locks[key]
+	switch {
+	case method == methodLock && locked:
+		w.WriteHeader(http.StatusLocked)
+		_ = json.NewEncoder(w).Encode(existing)
+	case method == methodLock:
+		s.locks[key] = info
+	case locked && existing.ID == info.ID:
+		delete(s.locks, key)
+	default:
+		w.WriteHeader(http.StatusConflict)
+	}
+}
+
+func TestHTTPBackendLock(t *testing.T) {
+	_, b := newStateServer(t)
+	ctx := context.Background()
+	created := time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC)
+	first := &LockInfo{ID: "first", Operation: "Update", Who: "provider", Created: created}
+	second := &LockInfo{ID: "second", Operation: "Observe", Who: "provider", Created: created}
+
+	if err := b.Lock(ctx, "uid", first); err != nil {
+		t.Fatalf("Lock(...): %s", err)
+	}
+	err := b.Lock(ctx, "uid", second)
+	if diff := cmp.Diff(&LockedError{Info: first}, err, test.EquateErrors()); diff != "" {
+		t.Errorf("\nA locked state should not be locked again.\nLock(...): -want, +got:\n%s", diff)
+	}
+	if err := b.Unlock(ctx, "uid", second); err == nil {
+		t.Error("Unlock(...): a lock held by someone else was released")
+	}
+	if err := b.Unlock(ctx, "uid", first); err != nil {
+		t.Fatalf("Unlock(...): %s", err)
+	}
+	if err := b.Lock(ctx, "uid", second); err != nil {
+		t.Errorf("Lock(...): the released state cannot be locked again: %s", err)
+	}
+	if err := b.Lock(ctx, "other", first); err != nil {
+		t.Errorf("Lock(...): the states of other keys should be locked independently: %s", err)
+	}
+}
+
+func TestHTTPBackendState(t *testing.T) {
+	s, b := newStateServer(t)
+	ctx := context.Background()
+
+	got, err := b.Get(ctx, "uid")
+	if err != nil || got != nil {
+		t.Fatalf("Get(...): got %q, %v for a missing state, want none", got, err)
+	}
+	state := []byte(`{"version":4,"serial":1}`)
+	if err := b.Put(ctx, "uid", state, "lock"); err != nil {
+		t.Fatalf("Put(...): %s", err)
+	}
+	got, err = b.Get(ctx, "uid")
+	if err != nil {
+		t.Fatalf("Get(...): %s", err)
+	}
+	if diff := cmp.Diff(string(state), string(got)); diff != "" {
+		t.Errorf("\nThe pulled state should be the pushed one.\nGet(...): -want, +got:\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"lock"}, s.putLockIDs); diff != "" {
+		t.Errorf("\nThe state should be pushed with the ID of the lock.\nPut(...): -want, +got:\n%s", diff)
+	}
+	if _, ok := s.states["uid"]; !ok {
+		t.Errorf("Put(...): the state was not stored under the key expanded into the address")
+	}
+	if err := b.Delete(ctx, "uid"); err != nil {
+		t.Fatalf("Delete(...): %s", err)
+	}
+	if got, err := b.Get(ctx, "uid"); err != nil || got != nil {
+		t.Errorf("Get(...): got %q, %v for a deleted state, want none", got, err)
+	}
+	if err := b.Delete(ctx, "uid"); err != nil {
+		t.Errorf("Delete(...): deleting a missing state should succeed: %s", err)
+	}
+}
+
+func TestHTTPBackendUnauthorized(t *testing.T) {
+	_, b := newStateServer(t)
+	b.password = "wrong"
+	want := errors.Errorf(errFmtStatus, "get", http.StatusText(http.StatusUnauthorized))
+	_, err := b.Get(context.Background(), "uid")
+	if diff := cmp.Diff(want, err, test.EquateErrors()); diff != "" {
+		t.Errorf("\nThe errors of the server should be returned.\nGet(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestHTTPBackendBlock(t *testing.T) {
+	b := &httpBackend{
+		address:       "https://state.example.org/{uid}",
+		lockAddress:   "https://state.example.org/{uid}/lock",
+		unlockAddress: "https://state.example.org/{uid}/lock",
+		username:      "user",
+		password:      "secret",
+	}
+	want := map[string]interface{}{
+		"http": map[string]interface{}{
+			"address":        "https://state.example.org/uid",
+			"lock_address":   "https://state.example.org/uid/lock",
+			"unlock_address": "https://state.example.org/uid/lock",
+			"username":       "user",
+		},
+	}
+	if diff := cmp.Diff(want, b.Block("uid")); diff != "" {
+		t.Errorf("\nThe block should configure the http backend without the password.\nBlock(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestExpand(t *testing.T) {
+	cases := map[string]struct {
+		address string
+		want    string
+	}{
+		"Placeholder":   {address: "https://state.example.org/{uid}/state", want: "https://state.example.org/uid/state"},
+		"Suffix":        {address: "https://state.example.org/states", want: "https://state.example.org/states/uid"},
+		"TrailingSlash": {address: "https://state.example.org/states/", want: "https://state.example.org/states/uid"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, expand(tc.address, "uid")); diff != "" {
+				t.Errorf("\nexpand(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
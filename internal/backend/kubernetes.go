@@ -0,0 +1,230 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backend
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// The layout of the kubernetes backend of Terraform.
+const (
+	keyState              = "tfstate"
+	labelKeyState         = "tfstate"
+	labelKeySecretSuffix  = "tfstateSecretSuffix"
+	labelKeyWorkspace     = "tfstateWorkspace"
+	labelKeyManagedBy     = "app.kubernetes.io/managed-by"
+	annotationKeyLockInfo = "app.terraform.io/lock-info"
+	workspaceName         = "default"
+	managedByTerraform    = "terraform"
+
+	errGetSecret     = "cannot get the state Secret"
+	errApplySecret   = "cannot apply the state Secret"
+	errDeleteSecret  = "cannot delete the state Secret"
+	errCompress      = "cannot compress the state"
+	errDecompress    = "cannot decompress the state"
+	errGetLease      = "cannot get the state lock Lease"
+	errApplyLease    = "cannot apply the state lock Lease"
+	errDeleteLease   = "cannot delete the state lock Lease"
+	errMarshalLock   = "cannot marshal the lock info"
+	errFmtLockID     = "state is locked with ID %s, not %s"
+	errFmtNotLocked  = "state is not locked, cannot release lock %s"
+	errUnmarshalLock = "cannot unmarshal the lock info"
+)
+
+// kubernetesBackend stores the states in Secrets and locks them with Leases
+// in the layout of the kubernetes backend of Terraform, using the keys of the
+// states as the secret_suffix in the default workspace. The Secrets and the
+// Leases are read without the cache, so that the locks are never stale.
+type kubernetesBackend struct {
+	kube      client.Client
+	reader    client.Reader
+	namespace string
+}
+
+func secretName(key string) string {
+	return "tfstate-" + workspaceName + "-" + key
+}
+
+func leaseName(key string) string {
+	return "lock-" + secretName(key)
+}
+
+func labels(key string) map[string]string {
+	return map[string]string{
+		labelKeyState:        "true",
+		labelKeySecretSuffix: key,
+		labelKeyWorkspace:    workspaceName,
+		labelKeyManagedBy:    managedByTerraform,
+	}
+}
+
+func (b *kubernetesBackend) Get(ctx context.Context, key string) ([]byte, error) {
+	s := &corev1.Secret{}
+	err := b.reader.Get(ctx, types.NamespacedName{Namespace: b.namespace, Name: secretName(key)}, s)
+	if kerrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, errGetSecret)
+	}
+	if len(s.Data[keyState]) == 0 {
+		return nil, nil
+	}
+	r, err := gzip.NewReader(bytes.NewReader(s.Data[keyState]))
+	if err != nil {
+		return nil, errors.Wrap(err, errDecompress)
+	}
+	state, err := io.ReadAll(r)
+	return state, errors.Wrap(err, errDecompress)
+}
+
+func (b *kubernetesBackend) Put(ctx context.Context, key string, state []byte, _ string) error {
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	if _, err := w.Write(state); err != nil {
+		return errors.Wrap(err, errCompress)
+	}
+	if err := w.Close(); err != nil {
+		return errors.Wrap(err, errCompress)
+	}
+	s := &corev1.Secret{}
+	err := b.reader.Get(ctx, types.NamespacedName{Namespace: b.namespace, Name: secretName(key)}, s)
+	if kerrors.IsNotFound(err) {
+		s = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: b.namespace, Name: secretName(key), Labels: labels(key)},
+			Data:       map[string][]byte{keyState: buf.Bytes()},
+		}
+		return errors.Wrap(b.kube.Create(ctx, s), errApplySecret)
+	}
+	if err != nil {
+		return errors.Wrap(err, errGetSecret)
+	}
+	if s.Data == nil {
+		s.Data = map[string][]byte{}
+	}
+	s.Data[keyState] = buf.Bytes()
+	return errors.Wrap(b.kube.Update(ctx, s), errApplySecret)
+}
+
+// Delete removes the Lease of the state as well.
+func (b *kubernetesBackend) Delete(ctx context.Context, key string) error {
+	s := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: b.namespace, Name: secretName(key)}}
+	if err := client.IgnoreNotFound(b.kube.Delete(ctx, s)); err != nil {
+		return errors.Wrap(err, errDeleteSecret)
+	}
+	l := &coordinationv1.Lease{ObjectMeta: metav1.ObjectMeta{Namespace: b.namespace, Name: leaseName(key)}}
+	return errors.Wrap(client.IgnoreNotFound(b.kube.Delete(ctx, l)), errDeleteLease)
+}
+
+// Lock takes the Lease of the state unless it has a holder. The Leases taken
+// concurrently by others are detected by the API server with the resource
+// version of the Lease.
+func (b *kubernetesBackend) Lock(ctx context.Context, key string, info *LockInfo) error {
+	raw, err := json.Marshal(info)
+	if err != nil {
+		return errors.Wrap(err, errMarshalLock)
+	}
+	l := &coordinationv1.Lease{}
+	err = b.reader.Get(ctx, types.NamespacedName{Namespace: b.namespace, Name: leaseName(key)}, l)
+	if kerrors.IsNotFound(err) {
+		l = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   b.namespace,
+				Name:        leaseName(key),
+				Labels:      labels(key),
+				Annotations: map[string]string{annotationKeyLockInfo: string(raw)},
+			},
+			Spec: coordinationv1.LeaseSpec{HolderIdentity: &info.ID},
+		}
+		return b.apply(b.kube.Create(ctx, l))
+	}
+	if err != nil {
+		return errors.Wrap(err, errGetLease)
+	}
+	if h := l.Spec.HolderIdentity; h != nil && *h != "" {
+		return lockedBy(l)
+	}
+	if l.Annotations == nil {
+		l.Annotations = map[string]string{}
+	}
+	l.Annotations[annotationKeyLockInfo] = string(raw)
+	l.Spec.HolderIdentity = &info.ID
+	return b.apply(b.kube.Update(ctx, l))
+}
+
+// apply returns a *LockedError if the given error of taking a Lease means
+// that it was taken concurrently by someone else.
+func (b *kubernetesBackend) apply(err error) error {
+	if kerrors.IsAlreadyExists(err) || kerrors.IsConflict(err) {
+		return &LockedError{}
+	}
+	return errors.Wrap(err, errApplyLease)
+}
+
+func (b *kubernetesBackend) Unlock(ctx context.Context, key string, info *LockInfo) error {
+	l := &coordinationv1.Lease{}
+	if err := b.reader.Get(ctx, types.NamespacedName{Namespace: b.namespace, Name: leaseName(key)}, l); err != nil {
+		return errors.Wrap(err, errGetLease)
+	}
+	h := l.Spec.HolderIdentity
+	if h == nil || *h == "" {
+		return errors.Errorf(errFmtNotLocked, info.ID)
+	}
+	if *h != info.ID {
+		return errors.Errorf(errFmtLockID, *h, info.ID)
+	}
+	l.Spec.HolderIdentity = nil
+	delete(l.Annotations, annotationKeyLockInfo)
+	return errors.Wrap(b.kube.Update(ctx, l), errApplyLease)
+}
+
+// Block returns the configuration of the kubernetes backend of Terraform.
+// The Terraform session has to configure the access to the cluster, e.g.
+// with KUBE_CONFIG_PATH.
+func (b *kubernetesBackend) Block(key string) map[string]interface{} {
+	return map[string]interface{}{
+		"kubernetes": map[string]interface{}{
+			"secret_suffix": key,
+			"namespace":     b.namespace,
+		},
+	}
+}
+
+// lockedBy returns a *LockedError for the given Lease.
+func lockedBy(l *coordinationv1.Lease) error {
+	raw, ok := l.Annotations[annotationKeyLockInfo]
+	if !ok {
+		return &LockedError{ID: *l.Spec.HolderIdentity}
+	}
+	info := &LockInfo{}
+	if err := json.Unmarshal([]byte(raw), info); err != nil {
+		return errors.Wrap(err, errUnmarshalLock)
+	}
+	return &LockedError{Info: info}
+}
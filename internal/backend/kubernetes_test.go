@@ -0,0 +1,213 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backend
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const testNamespace = "crossplane-system"
+
+func newKubernetesBackend() (client.Client, *kubernetesBackend) {
+	kube := fake.NewClientBuilder().Build()
+	return kube, &kubernetesBackend{kube: kube, reader: kube, namespace: testNamespace}
+}
+
+// staleReader reads the Leases as they were when it was created.
+type staleReader struct {
+	client.Reader
+	lease *coordinationv1.Lease
+}
+
+func (r *staleReader) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	l, ok := obj.(*coordinationv1.Lease)
+	if !ok {
+		return r.Reader.Get(ctx, key, obj)
+	}
+	if r.lease == nil {
+		return kerrors.NewNotFound(schema.GroupResource{Group: coordinationv1.GroupName, Resource: "leases"}, key.Name)
+	}
+	r.lease.DeepCopyInto(l)
+	return nil
+}
+
+func TestKubernetesBackendLock(t *testing.T) {
+	kube, b := newKubernetesBackend()
+	ctx := context.Background()
+	created := time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC)
+	first := &LockInfo{ID: "first", Operation: "Update", Who: "provider", Created: created}
+	second := &LockInfo{ID: "second", Operation: "Observe", Who: "provider", Created: created}
+
+	if err := b.Unlock(ctx, "uid", first); err == nil {
+		t.Error("Unlock(...): a state that was never locked was released")
+	}
+	if err := b.Lock(ctx, "uid", first); err != nil {
+		t.Fatalf("Lock(...): %s", err)
+	}
+	l := &coordinationv1.Lease{}
+	if err := kube.Get(ctx, types.NamespacedName{Namespace: testNamespace, Name: "lock-tfstate-default-uid"}, l); err != nil {
+		t.Fatalf("Get(...): the Lease was not created in the layout of the kubernetes backend: %s", err)
+	}
+	if diff := cmp.Diff(labels("uid"), l.Labels); diff != "" {
+		t.Errorf("\nThe Lease should have the labels of the kubernetes backend.\nLock(...): -want, +got:\n%s", diff)
+	}
+	err := b.Lock(ctx, "uid", second)
+	if diff := cmp.Diff(&LockedError{Info: first}, err, test.EquateErrors()); diff != "" {
+		t.Errorf("\nA locked state should not be locked again.\nLock(...): -want, +got:\n%s", diff)
+	}
+	if err := b.Unlock(ctx, "uid", second); err == nil {
+		t.Error("Unlock(...): a lock held by someone else was released")
+	}
+	if err := b.Unlock(ctx, "uid", first); err != nil {
+		t.Fatalf("Unlock(...): %s", err)
+	}
+	if err := b.Unlock(ctx, "uid", first); err == nil {
+		t.Error("Unlock(...): a released lock was released again")
+	}
+	if err := b.Lock(ctx, "uid", second); err != nil {
+		t.Errorf("Lock(...): the released state cannot be locked again: %s", err)
+	}
+	if err := b.Lock(ctx, "other", first); err != nil {
+		t.Errorf("Lock(...): the states of other keys should be locked independently: %s", err)
+	}
+}
+
+func TestKubernetesBackendConflict(t *testing.T) {
+	ctx := context.Background()
+	first := &LockInfo{ID: "first", Operation: "Update", Who: "provider"}
+	second := &LockInfo{ID: "second", Operation: "Observe", Who: "terraform"}
+
+	cases := map[string]struct {
+		reason string
+		// released is whether the Lease was released before it was read.
+		released bool
+	}{
+		"Created": {
+			reason: "A Lease created concurrently by someone else should be reported as a lock.",
+		},
+		"Updated": {
+			reason:   "A released Lease taken concurrently by someone else should be reported as a lock.",
+			released: true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			kube, b := newKubernetesBackend()
+			stale := &staleReader{Reader: kube}
+			if tc.released {
+				if err := b.Lock(ctx, "uid", first); err != nil {
+					t.Fatalf("Lock(...): %s", err)
+				}
+				if err := b.Unlock(ctx, "uid", first); err != nil {
+					t.Fatalf("Unlock(...): %s", err)
+				}
+				stale.lease = &coordinationv1.Lease{}
+				if err := kube.Get(ctx, types.NamespacedName{Namespace: testNamespace, Name: leaseName("uid")}, stale.lease); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// Someone else takes the Lease after it was read.
+			if err := b.Lock(ctx, "uid", second); err != nil {
+				t.Fatalf("Lock(...): %s", err)
+			}
+			racing := &kubernetesBackend{kube: kube, reader: stale, namespace: testNamespace}
+			err := racing.Lock(ctx, "uid", first)
+			if diff := cmp.Diff(&LockedError{}, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nLock(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestKubernetesBackendState(t *testing.T) {
+	kube, b := newKubernetesBackend()
+	ctx := context.Background()
+
+	got, err := b.Get(ctx, "uid")
+	if err != nil || got != nil {
+		t.Fatalf("Get(...): got %q, %v for a missing state, want none", got, err)
+	}
+	for _, state := range [][]byte{[]byte(`{"version":4,"serial":1}`), []byte(`{"version":4,"serial":2}`)} {
+		if err := b.Put(ctx, "uid", state, "lock"); err != nil {
+			t.Fatalf("Put(...): %s", err)
+		}
+		got, err = b.Get(ctx, "uid")
+		if err != nil {
+			t.Fatalf("Get(...): %s", err)
+		}
+		if diff := cmp.Diff(string(state), string(got)); diff != "" {
+			t.Errorf("\nThe pulled state should be the pushed one.\nGet(...): -want, +got:\n%s", diff)
+		}
+	}
+
+	s := &corev1.Secret{}
+	if err := kube.Get(ctx, types.NamespacedName{Namespace: testNamespace, Name: "tfstate-default-uid"}, s); err != nil {
+		t.Fatalf("Get(...): the Secret was not created in the layout of the kubernetes backend: %s", err)
+	}
+	if diff := cmp.Diff(labels("uid"), s.Labels); diff != "" {
+		t.Errorf("\nThe Secret should have the labels of the kubernetes backend.\nPut(...): -want, +got:\n%s", diff)
+	}
+	r, err := gzip.NewReader(bytes.NewReader(s.Data[keyState]))
+	if err != nil {
+		t.Fatalf("Put(...): the state is not compressed like the kubernetes backend does: %s", err)
+	}
+	if raw, err := io.ReadAll(r); err != nil || string(raw) != string(got) {
+		t.Errorf("Put(...): got compressed state %q, %v, want %q", raw, err, got)
+	}
+
+	if err := b.Lock(ctx, "uid", &LockInfo{ID: "lock"}); err != nil {
+		t.Fatalf("Lock(...): %s", err)
+	}
+	if err := b.Delete(ctx, "uid"); err != nil {
+		t.Fatalf("Delete(...): %s", err)
+	}
+	if got, err := b.Get(ctx, "uid"); err != nil || got != nil {
+		t.Errorf("Get(...): got %q, %v for a deleted state, want none", got, err)
+	}
+	l := &coordinationv1.Lease{}
+	if err := kube.Get(ctx, types.NamespacedName{Namespace: testNamespace, Name: leaseName("uid")}, l); !kerrors.IsNotFound(err) {
+		t.Errorf("Delete(...): the Lease of the state was not deleted: %v", err)
+	}
+	if err := b.Delete(ctx, "uid"); err != nil {
+		t.Errorf("Delete(...): deleting a missing state should succeed: %s", err)
+	}
+}
+
+func TestKubernetesBackendBlock(t *testing.T) {
+	_, b := newKubernetesBackend()
+	want := map[string]interface{}{
+		"kubernetes": map[string]interface{}{"secret_suffix": "uid", "namespace": testNamespace},
+	}
+	if diff := cmp.Diff(want, b.Block("uid")); diff != "" {
+		t.Errorf("\nThe block should configure the kubernetes backend with the Secret of the state.\nBlock(...): -want, +got:\n%s", diff)
+	}
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clients
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultProviderMirror is the filesystem mirror of the native Terraform
+	// providers in the provider image.
+	DefaultProviderMirror = "/terraform/provider-mirror"
+
+	defaultRegistry = "registry.terraform.io"
+
+	errFmtReadMirror = "cannot read the filesystem mirror of provider %s"
+)
+
+// ProviderAddress returns the fully qualified address of the Terraform
+// provider with the given source, e.g. registry.terraform.io/hashicorp/null
+// for hashicorp/null.
+func ProviderAddress(source string) string {
+	if strings.Count(source, "/") < 2 {
+		return defaultRegistry + "/" + source
+	}
+	return source
+}
+
+// A ProviderMirror is a Terraform provider filesystem mirror.
+type ProviderMirror struct {
+	dir string
+}
+
+// NewProviderMirror returns a ProviderMirror rooted at the given directory.
+func NewProviderMirror(dir string) *ProviderMirror {
+	return &ProviderMirror{dir: dir}
+}
+
+// Versions returns the sorted versions of the Terraform provider with the
+// given source present in the mirror, in both the unpacked and the packed
+// layouts.
+func (m *ProviderMirror) Versions(source string) ([]string, error) {
+	dir := filepath.Join(m.dir, filepath.FromSlash(ProviderAddress(source)))
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, errFmtReadMirror, source)
+	}
+	// Packed archives are named terraform-provider-<type>_<version>_<os>_<arch>.zip
+	packed := fmt.Sprintf("terraform-provider-%s_", filepath.Base(dir))
+	seen := map[string]bool{}
+	for _, e := range entries {
+		name := e.Name()
+		switch {
+		case e.IsDir():
+			seen[name] = true
+		case strings.HasPrefix(name, packed) && strings.HasSuffix(name, ".zip"):
+			if parts := strings.SplitN(strings.TrimPrefix(name, packed), "_", 2); len(parts) == 2 {
+				seen[parts[0]] = true
+			}
+		}
+	}
+	versions := make([]string, 0, len(seen))
+	for v := range seen {
+		versions = append(versions, v)
+	}
+	sort.Strings(versions)
+	return versions, nil
+}
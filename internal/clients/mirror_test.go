@@ -0,0 +1,105 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clients
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestProviderMirrorVersions(t *testing.T) {
+	type want struct {
+		versions []string
+		err      error
+	}
+	cases := map[string]struct {
+		reason string
+		files  []string
+		source string
+		want   want
+	}{
+		"MissingDir": {
+			reason: "A provider that is not in the mirror should have no versions.",
+			source: "hashicorp/null",
+		},
+		"Unpacked": {
+			reason: "The version directories of the unpacked layout should be returned sorted.",
+			files: []string{
+				"registry.terraform.io/hashicorp/null/3.1.1/linux_amd64/terraform-provider-null_v3.1.1_x5",
+				"registry.terraform.io/hashicorp/null/3.1.0/linux_amd64/terraform-provider-null_v3.1.0_x5",
+			},
+			source: "hashicorp/null",
+			want:   want{versions: []string{"3.1.0", "3.1.1"}},
+		},
+		"Packed": {
+			reason: "The versions of the archives of the packed layout should be returned sorted.",
+			files: []string{
+				"registry.terraform.io/hashicorp/null/terraform-provider-null_3.1.1_linux_amd64.zip",
+				"registry.terraform.io/hashicorp/null/terraform-provider-null_3.1.0_linux_arm64.zip",
+				"registry.terraform.io/hashicorp/null/terraform-provider-null_3.1.0_linux_amd64.zip",
+			},
+			source: "hashicorp/null",
+			want:   want{versions: []string{"3.1.0", "3.1.1"}},
+		},
+		"Mixed": {
+			reason: "A version present in both layouts should be returned once and unrelated files should be ignored.",
+			files: []string{
+				"registry.terraform.io/hashicorp/null/3.1.1/linux_amd64/terraform-provider-null_v3.1.1_x5",
+				"registry.terraform.io/hashicorp/null/terraform-provider-null_3.1.1_linux_amd64.zip",
+				"registry.terraform.io/hashicorp/null/terraform-provider-null_3.2.0_linux_amd64.zip",
+				"registry.terraform.io/hashicorp/null/terraform-provider-random_3.3.0_linux_amd64.zip",
+				"registry.terraform.io/hashicorp/null/SHA256SUMS",
+			},
+			source: "hashicorp/null",
+			want:   want{versions: []string{"3.1.1", "3.2.0"}},
+		},
+		"Hostname": {
+			reason: "A source with a hostname should be looked up under that hostname.",
+			files: []string{
+				"example.com/hashicorp/null/3.1.1/linux_amd64/terraform-provider-null_v3.1.1_x5",
+				"registry.terraform.io/hashicorp/null/3.1.0/linux_amd64/terraform-provider-null_v3.1.0_x5",
+			},
+			source: "example.com/hashicorp/null",
+			want:   want{versions: []string{"3.1.1"}},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range tc.files {
+				p := filepath.Join(dir, filepath.FromSlash(f))
+				if err := os.MkdirAll(filepath.Dir(p), 0o750); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(p, nil, 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := NewProviderMirror(dir).Versions(tc.source)
+			if diff := cmp.Diff(tc.want.err, err); diff != "" {
+				t.Errorf("\n%s\nVersions(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.versions, got, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("\n%s\nVersions(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
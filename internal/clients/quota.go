@@ -0,0 +1,143 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clients
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane-contrib/provider-jet-template/apis/v1alpha1"
+)
+
+const (
+	// TypeQuotaExceeded is the condition that reports whether a managed
+	// resource is over the quota of its ProviderConfig.
+	TypeQuotaExceeded xpv1.ConditionType = "QuotaExceeded"
+
+	// ReasonMaxResourcesReached means that the ProviderConfig is used by
+	// its maximum number of managed resources.
+	ReasonMaxResourcesReached xpv1.ConditionReason = "MaxResourcesReached"
+	// ReasonWithinQuota means that the managed resource is within the quota.
+	ReasonWithinQuota xpv1.ConditionReason = "WithinQuota"
+
+	errListUsages       = "cannot list the usages of the provider configuration"
+	errFmtQuotaExceeded = "ProviderConfig %s is already used by its maximum of %d managed resources"
+	msgFmtQuotaExceeded = "ProviderConfig %s is already used by %d managed resources, its maximum is %d."
+)
+
+// QuotaExceeded returns a condition that indicates that the ProviderConfig
+// with the given name is used by the given number of managed resources,
+// which is not below its given maximum.
+func QuotaExceeded(name string, used, max int) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeQuotaExceeded,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonMaxResourcesReached,
+		Message:            fmt.Sprintf(msgFmtQuotaExceeded, name, used, max),
+	}
+}
+
+// WithinQuota returns a condition that indicates that the managed resource
+// is within the quota of its ProviderConfig.
+func WithinQuota() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeQuotaExceeded,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonWithinQuota,
+	}
+}
+
+// A quota enforces the quotas of the provider configurations. The check of a
+// quota and the tracking of the usage of the checked managed resource are
+// done one managed resource at a time, so that concurrent reconciles cannot
+// exceed a quota as long as the usages are read from the API server rather
+// than the cache.
+type quota struct {
+	mu sync.Mutex
+}
+
+// track tracks the usage of the provider configuration with the given spec
+// by the given managed resource with the given tracker, unless it does not
+// use the provider configuration yet and the provider configuration is
+// already used by its maximum number of managed resources according to the
+// usages read from the given reader. The managed resources over the quota
+// are not tracked, so they do not reach Terraform.
+func (q *quota) track(ctx context.Context, r client.Reader, t resource.Tracker, mg resource.Managed, spec *v1alpha1.ProviderConfigSpec) error {
+	// The managed resources being deleted are let through, since their
+	// deletion would be blocked otherwise.
+	if spec.MaxResources == nil || meta.WasDeleted(mg) {
+		clearQuotaExceeded(mg)
+		return errors.Wrap(t.Track(ctx, mg), errTrackUsage)
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	users, err := usersOf(ctx, r, mg)
+	if err != nil {
+		return err
+	}
+	if !users[string(mg.GetUID())] && len(users) >= *spec.MaxResources {
+		name := mg.GetProviderConfigReference().Name
+		mg.SetConditions(QuotaExceeded(name, len(users), *spec.MaxResources))
+		return errors.Errorf(errFmtQuotaExceeded, name, *spec.MaxResources)
+	}
+	clearQuotaExceeded(mg)
+	return errors.Wrap(t.Track(ctx, mg), errTrackUsage)
+}
+
+// usersOf returns the UIDs of the managed resources that use the provider
+// configuration of the given managed resource, which are the names of its
+// usages. The usages of NamespacedProviderConfigs are in the namespace of
+// the managed resource.
+func usersOf(ctx context.Context, r client.Reader, mg resource.Managed) (map[string]bool, error) {
+	labels := client.MatchingLabels{xpv1.LabelKeyProviderName: mg.GetProviderConfigReference().Name}
+	users := map[string]bool{}
+	if ns := mg.GetNamespace(); ns != "" {
+		l := &v1alpha1.NamespacedProviderConfigUsageList{}
+		if err := r.List(ctx, l, client.InNamespace(ns), labels); err != nil {
+			return nil, errors.Wrap(err, errListUsages)
+		}
+		for _, u := range l.Items {
+			users[u.GetName()] = true
+		}
+		return users, nil
+	}
+	l := &v1alpha1.ProviderConfigUsageList{}
+	if err := r.List(ctx, l, labels); err != nil {
+		return nil, errors.Wrap(err, errListUsages)
+	}
+	for _, u := range l.Items {
+		users[u.GetName()] = true
+	}
+	return users, nil
+}
+
+func clearQuotaExceeded(mg resource.Managed) {
+	if mg.GetCondition(TypeQuotaExceeded).Status == corev1.ConditionTrue {
+		mg.SetConditions(WithinQuota())
+	}
+}
@@ -0,0 +1,153 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clients
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane-contrib/provider-jet-template/apis/null/v1alpha1"
+	apisv1alpha1 "github.com/crossplane-contrib/provider-jet-template/apis/v1alpha1"
+)
+
+// usages is a reader of the usages of the provider configurations, which
+// tracks the usages of the managed resources like the API server.
+type usages struct {
+	test.MockClient
+
+	mu         sync.Mutex
+	cluster    []string
+	namespaced map[string][]string
+}
+
+func (u *usages) List(_ context.Context, l client.ObjectList, opts ...client.ListOption) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	o := &client.ListOptions{}
+	o.ApplyOptions(opts)
+	switch l := l.(type) {
+	case *apisv1alpha1.ProviderConfigUsageList:
+		for _, name := range u.cluster {
+			l.Items = append(l.Items, apisv1alpha1.ProviderConfigUsage{ObjectMeta: metav1.ObjectMeta{Name: name}})
+		}
+	case *apisv1alpha1.NamespacedProviderConfigUsageList:
+		for _, name := range u.namespaced[o.Namespace] {
+			l.Items = append(l.Items, apisv1alpha1.NamespacedProviderConfigUsage{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: o.Namespace}})
+		}
+	}
+	return nil
+}
+
+func (u *usages) track(_ context.Context, mg resource.Managed) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	users := u.cluster
+	if ns := mg.GetNamespace(); ns != "" {
+		users = u.namespaced[ns]
+	}
+	for _, name := range users {
+		if name == string(mg.GetUID()) {
+			return nil
+		}
+	}
+	if ns := mg.GetNamespace(); ns != "" {
+		u.namespaced[ns] = append(users, string(mg.GetUID()))
+		return nil
+	}
+	u.cluster = append(users, string(mg.GetUID()))
+	return nil
+}
+
+func managedResource(namespace, uid string) resource.Managed {
+	mg := &v1alpha1.Resource{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, UID: types.UID(uid)}}
+	mg.SetProviderConfigReference(&xpv1.Reference{Name: "example"})
+	return mg
+}
+
+func TestQuotaTrack(t *testing.T) {
+	limit := 1
+	spec := &apisv1alpha1.ProviderConfigSpec{MaxResources: &limit}
+	deleted := managedResource("", "deleted")
+	now := metav1.Now()
+	deleted.SetDeletionTimestamp(&now)
+	cases := map[string]struct {
+		reason string
+		mg     resource.Managed
+		want   error
+	}{
+		"User": {
+			reason: "A managed resource that already uses the ProviderConfig should be let through.",
+			mg:     managedResource("", "user"),
+		},
+		"OverQuota": {
+			reason: "A new managed resource should not use a ProviderConfig that is used by its maximum of managed resources.",
+			mg:     managedResource("", "new"),
+			want:   errors.Errorf(errFmtQuotaExceeded, "example", limit),
+		},
+		"Deleted": {
+			reason: "A managed resource being deleted should be let through.",
+			mg:     deleted,
+		},
+		"Namespaced": {
+			reason: "The quota of a NamespacedProviderConfig should count the managed resources in its namespace.",
+			mg:     managedResource("team-b", "new"),
+		},
+		"NamespacedOverQuota": {
+			reason: "The quota of a NamespacedProviderConfig should be enforced.",
+			mg:     managedResource("team-a", "new"),
+			want:   errors.Errorf(errFmtQuotaExceeded, "example", limit),
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			u := &usages{cluster: []string{"user"}, namespaced: map[string][]string{"team-a": {"user"}}}
+			err := (&quota{}).track(context.Background(), u, resource.TrackerFn(u.track), tc.mg, spec)
+			if diff := cmp.Diff(tc.want, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ntrack(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestQuotaTrackConcurrently(t *testing.T) {
+	limit := 3
+	spec := &apisv1alpha1.ProviderConfigSpec{MaxResources: &limit}
+	u := &usages{namespaced: map[string][]string{}}
+	q := &quota{}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(uid string) {
+			defer wg.Done()
+			_ = q.track(context.Background(), u, resource.TrackerFn(u.track), managedResource("", uid), spec)
+		}(string(rune('a' + i)))
+	}
+	wg.Wait()
+	if len(u.cluster) != limit {
+		t.Errorf("track(...): %d managed resources use the ProviderConfig, want its maximum of %d", len(u.cluster), limit)
+	}
+}
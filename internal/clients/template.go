@@ -19,29 +19,75 @@ package clients
 import (
 	"context"
 	"encoding/json"
+	"strings"
 
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
 	"github.com/crossplane/crossplane-runtime/pkg/resource"
 	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	tjresource "github.com/crossplane/terrajet/pkg/resource"
 	"github.com/crossplane/terrajet/pkg/terraform"
 
 	"github.com/crossplane-contrib/provider-jet-template/apis/v1alpha1"
+	"github.com/crossplane-contrib/provider-jet-template/config"
 )
 
 const (
 	// error messages
 	errNoProviderConfig     = "no providerConfigRef provided"
 	errGetProviderConfig    = "cannot get referenced ProviderConfig"
+	errGetNamespacedConfig  = "cannot get referenced NamespacedProviderConfig"
+	errCrossNamespaceSecret = "credentials secret of a NamespacedProviderConfig must be in the namespace of the managed resource"
+	errNamespacedSource     = "credentials of a NamespacedProviderConfig must be read from a Secret"
 	errTrackUsage           = "cannot track ProviderConfig usage"
 	errExtractCredentials   = "cannot extract credentials"
 	errUnmarshalCredentials = "cannot unmarshal template credentials as JSON"
+	errFmtNoUpstream        = "no upstream Terraform provider found for resource type %s"
+	errFmtVersionNotFound   = "version %s of provider %s is not present in the filesystem mirror, available versions: %s"
 )
 
+// A SetupOption configures the terraform.SetupFn built by
+// TerraformSetupBuilder.
+type SetupOption func(*setupBuilder)
+
+// WithProviderMirror validates the provider versions pinned by the
+// ProviderConfigs against the given filesystem mirror.
+func WithProviderMirror(m *ProviderMirror) SetupOption {
+	return func(b *setupBuilder) {
+		b.mirror = m
+	}
+}
+
+// WithUsageReader counts the usages of the provider configurations whose
+// quota is enforced with the given reader, which should read from the API
+// server rather than the cache, so that concurrent reconciles cannot exceed
+// the quotas. The usages are read from the client of the managed resources
+// by default.
+func WithUsageReader(r client.Reader) SetupOption {
+	return func(b *setupBuilder) {
+		b.usages = r
+	}
+}
+
+type setupBuilder struct {
+	mirror *ProviderMirror
+	usages client.Reader
+	quota  *quota
+}
+
 // TerraformSetupBuilder builds Terraform a terraform.SetupFn function which
-// returns Terraform provider setup configuration
-func TerraformSetupBuilder(version, providerSource, providerVersion string) terraform.SetupFn {
+// returns Terraform provider setup configuration. The given provider
+// requirement is used for the resources whose upstream Terraform provider
+// does not declare its own. The provider version can be pinned per
+// ProviderConfig.
+func TerraformSetupBuilder(version, providerSource, providerVersion string, opts ...SetupOption) terraform.SetupFn {
+	b := &setupBuilder{quota: &quota{}}
+	for _, o := range opts {
+		o(b)
+	}
 	return func(ctx context.Context, client client.Client, mg resource.Managed) (terraform.Setup, error) {
 		ps := terraform.Setup{
 			Version: version,
@@ -55,17 +101,12 @@ func TerraformSetupBuilder(version, providerSource, providerVersion string) terr
 		if configRef == nil {
 			return ps, errors.New(errNoProviderConfig)
 		}
-		pc := &v1alpha1.ProviderConfig{}
-		if err := client.Get(ctx, types.NamespacedName{Name: configRef.Name}, pc); err != nil {
-			return ps, errors.Wrap(err, errGetProviderConfig)
-		}
-
-		t := resource.NewProviderConfigUsageTracker(client, &v1alpha1.ProviderConfigUsage{})
-		if err := t.Track(ctx, mg); err != nil {
-			return ps, errors.Wrap(err, errTrackUsage)
+		spec, err := b.providerConfigSpec(ctx, client, mg)
+		if err != nil {
+			return ps, err
 		}
 
-		data, err := resource.CommonCredentialExtractor(ctx, pc.Spec.Credentials.Source, client, pc.Spec.Credentials.CommonCredentialSelectors)
+		data, err := resource.CommonCredentialExtractor(ctx, spec.Credentials.Source, client, spec.Credentials.CommonCredentialSelectors)
 		if err != nil {
 			return ps, errors.Wrap(err, errExtractCredentials)
 		}
@@ -82,11 +123,108 @@ func TerraformSetupBuilder(version, providerSource, providerVersion string) terr
 			fmt.Sprintf("%s=%s", "HASHICUPS_USERNAME", templateCreds["username"]),
 			fmt.Sprintf("%s=%s", "HASHICUPS_PASSWORD", templateCreds["password"]),
 		}*/
-		// set credentials in Terraform provider configuration
-		/*ps.Configuration = map[string]interface{}{
-			"username": templateCreds["username"],
-			"password": templateCreds["password"],
-		}*/
-		return ps, nil
+		// Pick the requirement and the provider configuration of the
+		// upstream Terraform provider of the resource.
+		tr, ok := mg.(tjresource.Terraformed)
+		if !ok {
+			return ps, nil
+		}
+		u, ok := config.UpstreamOf(tr.GetTerraformResourceType())
+		if !ok {
+			return ps, errors.Errorf(errFmtNoUpstream, tr.GetTerraformResourceType())
+		}
+		if u.Source != "" {
+			ps.Requirement = terraform.ProviderRequirement{
+				Source:  u.Source,
+				Version: u.Version,
+			}
+		}
+		if u.Configure != nil {
+			ps.Configuration = u.Configure(templateCreds)
+		}
+		return ps, b.pinVersion(&ps.Requirement, spec.ProviderVersion)
+	}
+}
+
+// pinVersion sets the version of the given requirement to the given version
+// if it's not empty and present in the mirror.
+func (b *setupBuilder) pinVersion(req *terraform.ProviderRequirement, version string) error {
+	if version == "" || version == req.Version {
+		return nil
+	}
+	if b.mirror != nil {
+		versions, err := b.mirror.Versions(req.Source)
+		if err != nil {
+			return err
+		}
+		if !contains(versions, version) {
+			return errors.Errorf(errFmtVersionNotFound, version, req.Source, strings.Join(versions, ", "))
+		}
+	}
+	req.Version = version
+	return nil
+}
+
+func contains(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+// providerConfigSpec returns the spec of the provider configuration referenced
+// by the given managed resource and tracks its usage within its quota. The
+// usages of NamespacedProviderConfigs are tracked with
+// NamespacedProviderConfigUsages in the namespace of the managed resource,
+// and those of ProviderConfigs with ProviderConfigUsages.
+func (b *setupBuilder) providerConfigSpec(ctx context.Context, kube client.Client, mg resource.Managed) (*v1alpha1.ProviderConfigSpec, error) {
+	spec, err := GetProviderConfigSpec(ctx, kube, mg)
+	if err != nil {
+		return nil, err
+	}
+	var usage resource.ProviderConfigUsage = &v1alpha1.ProviderConfigUsage{}
+	if ns := mg.GetNamespace(); ns != "" {
+		usage = &v1alpha1.NamespacedProviderConfigUsage{ObjectMeta: metav1.ObjectMeta{Namespace: ns}}
+	}
+	var usages client.Reader = kube
+	if b.usages != nil {
+		usages = b.usages
+	}
+	if err := b.quota.track(ctx, usages, resource.NewProviderConfigUsageTracker(kube, usage), mg, spec); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// GetProviderConfigSpec returns the spec of the provider configuration
+// referenced by the given managed resource. Namespace-scoped managed resources
+// reference a NamespacedProviderConfig in their own namespace whose
+// credentials can only be read from a Secret in that namespace, so that they
+// cannot read the environment or the filesystem of the provider. Cluster-scoped
+// managed resources reference a ProviderConfig.
+func GetProviderConfigSpec(ctx context.Context, client client.Client, mg resource.Managed) (*v1alpha1.ProviderConfigSpec, error) {
+	configRef := mg.GetProviderConfigReference()
+	if configRef == nil {
+		return nil, errors.New(errNoProviderConfig)
+	}
+	if ns := mg.GetNamespace(); ns != "" {
+		pc := &v1alpha1.NamespacedProviderConfig{}
+		if err := client.Get(ctx, types.NamespacedName{Namespace: ns, Name: configRef.Name}, pc); err != nil {
+			return nil, errors.Wrap(err, errGetNamespacedConfig)
+		}
+		if pc.Spec.Credentials.Source != xpv1.CredentialsSourceSecret {
+			return nil, errors.New(errNamespacedSource)
+		}
+		if ref := pc.Spec.Credentials.SecretRef; ref != nil && ref.Namespace != ns {
+			return nil, errors.New(errCrossNamespaceSecret)
+		}
+		return &pc.Spec, nil
+	}
+	pc := &v1alpha1.ProviderConfig{}
+	if err := client.Get(ctx, types.NamespacedName{Name: configRef.Name}, pc); err != nil {
+		return nil, errors.Wrap(err, errGetProviderConfig)
 	}
+	return &pc.Spec, nil
 }
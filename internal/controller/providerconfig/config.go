@@ -17,20 +17,26 @@ limitations under the License.
 package providerconfig
 
 import (
+	"context"
+	"reflect"
+
+	"github.com/pkg/errors"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"github.com/crossplane/crossplane-runtime/pkg/event"
 	"github.com/crossplane/crossplane-runtime/pkg/reconciler/providerconfig"
 	"github.com/crossplane/crossplane-runtime/pkg/resource"
-	"github.com/crossplane/terrajet/pkg/controller"
 
 	"github.com/crossplane-contrib/provider-jet-template/apis/v1alpha1"
+	"github.com/crossplane-contrib/provider-jet-template/internal/jet"
 )
 
-// Setup adds a controller that reconciles ProviderConfigs by accounting for
-// their current usage.
-func Setup(mgr ctrl.Manager, o controller.Options) error {
+// Setup adds the controllers that reconcile ProviderConfigs and
+// NamespacedProviderConfigs by accounting for their current usage and quota.
+func Setup(mgr ctrl.Manager, o jet.Options) error {
 	name := providerconfig.ControllerName(v1alpha1.ProviderConfigGroupKind)
 
 	of := resource.ProviderConfigKinds{
@@ -38,12 +44,47 @@ func Setup(mgr ctrl.Manager, o controller.Options) error {
 		UsageList: v1alpha1.ProviderConfigUsageListGroupVersionKind,
 	}
 
-	return ctrl.NewControllerManagedBy(mgr).
+	err := ctrl.NewControllerManagedBy(mgr).
 		Named(name).
 		WithOptions(o.ForControllerRuntime()).
 		For(&v1alpha1.ProviderConfig{}).
 		Watches(&source.Kind{Type: &v1alpha1.ProviderConfigUsage{}}, &resource.EnqueueRequestForProviderConfig{}).
-		Complete(providerconfig.NewReconciler(mgr, of,
-			providerconfig.WithLogger(o.Logger.WithValues("controller", name)),
-			providerconfig.WithRecorder(event.NewAPIRecorder(mgr.GetEventRecorderFor(name)))))
+		Complete(&quotaReconciler{
+			client: mgr.GetClient(),
+			inner: providerconfig.NewReconciler(mgr, of,
+				providerconfig.WithLogger(o.Logger.WithValues("controller", name)),
+				providerconfig.WithRecorder(event.NewAPIRecorder(mgr.GetEventRecorderFor(name)))),
+		})
+	if err != nil {
+		return err
+	}
+	return setupNamespaced(mgr, o)
+}
+
+const (
+	errGetProviderConfig = "cannot get ProviderConfig"
+	errUpdateStatus      = "cannot update ProviderConfig status"
+)
+
+// quotaReconciler reports the quota of a ProviderConfig in its status, next
+// to its usage accounted for by the inner reconciler.
+type quotaReconciler struct {
+	client client.Client
+	inner  reconcile.Reconciler
+}
+
+func (r *quotaReconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	result, err := r.inner.Reconcile(ctx, req)
+	if err != nil {
+		return result, err
+	}
+	pc := &v1alpha1.ProviderConfig{}
+	if err := r.client.Get(ctx, req.NamespacedName, pc); err != nil {
+		return result, errors.Wrap(resource.IgnoreNotFound(err), errGetProviderConfig)
+	}
+	if reflect.DeepEqual(pc.Status.MaxResources, pc.Spec.MaxResources) {
+		return result, nil
+	}
+	pc.Status.MaxResources = pc.Spec.MaxResources
+	return result, errors.Wrap(r.client.Status().Update(ctx, pc), errUpdateStatus)
 }
@@ -0,0 +1,129 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package providerconfig
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/reconciler/providerconfig"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+
+	"github.com/crossplane-contrib/provider-jet-template/apis/v1alpha1"
+	"github.com/crossplane-contrib/provider-jet-template/internal/jet"
+)
+
+const (
+	// finalizerInUse is the finalizer of the provider configurations that
+	// blocks their deletion while they are used, as in crossplane-runtime.
+	finalizerInUse = "in-use.crossplane.io"
+
+	shortWait = 30 * time.Second
+
+	errGetNamespacedConfig = "cannot get NamespacedProviderConfig"
+	errListNamespacedUsage = "cannot list NamespacedProviderConfigUsages"
+	errUpdateNamespaced    = "cannot update NamespacedProviderConfig"
+	errUpdateNamespacedSt  = "cannot update NamespacedProviderConfig status"
+	msgBlockDeletion       = "Blocking deletion while usages still exist"
+
+	reasonAccount event.Reason = "UsageAccounting"
+)
+
+// setupNamespaced adds a controller that reconciles NamespacedProviderConfigs
+// by accounting for their current usage. The reconciler of crossplane-runtime
+// lists the usages of all namespaces, so NamespacedProviderConfigUsages are
+// accounted for within the namespace of their NamespacedProviderConfig.
+func setupNamespaced(mgr ctrl.Manager, o jet.Options) error {
+	name := providerconfig.ControllerName(v1alpha1.NamespacedProviderConfigGroupKind)
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(name).
+		WithOptions(o.ForControllerRuntime()).
+		For(&v1alpha1.NamespacedProviderConfig{}).
+		Watches(&source.Kind{Type: &v1alpha1.NamespacedProviderConfigUsage{}}, handler.EnqueueRequestsFromMapFunc(usedConfig)).
+		Complete(&namespacedReconciler{
+			client: mgr.GetClient(),
+			log:    o.Logger.WithValues("controller", name),
+			record: event.NewAPIRecorder(mgr.GetEventRecorderFor(name)),
+		})
+}
+
+// usedConfig returns the request of the NamespacedProviderConfig used by the
+// given NamespacedProviderConfigUsage.
+func usedConfig(o client.Object) []reconcile.Request {
+	u, ok := o.(*v1alpha1.NamespacedProviderConfigUsage)
+	if !ok {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: u.GetNamespace(), Name: u.ProviderConfigReference.Name}}}
+}
+
+// namespacedReconciler reports the number of usages of a
+// NamespacedProviderConfig in its status and blocks its deletion while it's
+// used.
+type namespacedReconciler struct {
+	client client.Client
+	log    logging.Logger
+	record event.Recorder
+}
+
+func (r *namespacedReconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	log := r.log.WithValues("request", req)
+	log.Debug("Reconciling")
+
+	pc := &v1alpha1.NamespacedProviderConfig{}
+	if err := r.client.Get(ctx, req.NamespacedName, pc); err != nil {
+		return reconcile.Result{}, errors.Wrap(resource.IgnoreNotFound(err), errGetNamespacedConfig)
+	}
+	l := &v1alpha1.NamespacedProviderConfigUsageList{}
+	if err := r.client.List(ctx, l, client.InNamespace(pc.GetNamespace()), client.MatchingLabels{xpv1.LabelKeyProviderName: pc.GetName()}); err != nil {
+		r.record.Event(pc, event.Warning(reasonAccount, errors.Wrap(err, errListNamespacedUsage)))
+		return reconcile.Result{RequeueAfter: shortWait}, nil
+	}
+	users := int64(len(l.Items))
+
+	if meta.WasDeleted(pc) {
+		if users > 0 {
+			// We're watching the usages, so we'll be requeued when they go.
+			r.record.Event(pc, event.Warning(reasonAccount, errors.New(msgBlockDeletion)))
+			pc.SetUsers(users)
+			pc.SetConditions(providerconfig.Terminating().WithMessage(msgBlockDeletion))
+			return reconcile.Result{}, errors.Wrap(r.client.Status().Update(ctx, pc), errUpdateNamespacedSt)
+		}
+		meta.RemoveFinalizer(pc, finalizerInUse)
+		return reconcile.Result{}, errors.Wrap(r.client.Update(ctx, pc), errUpdateNamespaced)
+	}
+
+	meta.AddFinalizer(pc, finalizerInUse)
+	if err := r.client.Update(ctx, pc); err != nil {
+		return reconcile.Result{}, errors.Wrap(err, errUpdateNamespaced)
+	}
+	pc.SetUsers(users)
+	pc.Status.MaxResources = pc.Spec.MaxResources
+	return reconcile.Result{}, errors.Wrap(r.client.Status().Update(ctx, pc), errUpdateNamespacedSt)
+}
@@ -19,7 +19,7 @@ package controller
 import (
 	ctrl "sigs.k8s.io/controller-runtime"
 
-	"github.com/crossplane/terrajet/pkg/controller"
+	"github.com/crossplane-contrib/provider-jet-template/internal/jet"
 
 	resource "github.com/crossplane-contrib/provider-jet-template/internal/controller/null/resource"
 	providerconfig "github.com/crossplane-contrib/provider-jet-template/internal/controller/providerconfig"
@@ -27,8 +27,8 @@ import (
 
 // Setup creates all controllers with the supplied logger and adds them to
 // the supplied manager.
-func Setup(mgr ctrl.Manager, o controller.Options) error {
-	for _, setup := range []func(ctrl.Manager, controller.Options) error{
+func Setup(mgr ctrl.Manager, o jet.Options) error {
+	for _, setup := range []func(ctrl.Manager, jet.Options) error{
 		resource.Setup,
 		providerconfig.Setup,
 	} {
@@ -38,3 +38,16 @@ func Setup(mgr ctrl.Manager, o controller.Options) error {
 	}
 	return nil
 }
+
+// SetupWebhooks adds the defaulting and validating webhooks of all kinds to
+// the supplied manager.
+func SetupWebhooks(mgr ctrl.Manager, o jet.Options) error {
+	for _, setup := range []func(ctrl.Manager, jet.Options) error{
+		resource.SetupWebhook,
+	} {
+		if err := setup(mgr, o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package convert converts the managed resources between their API versions.
+package convert
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	errToUnstructured   = "cannot convert object to unstructured"
+	errFromUnstructured = "cannot convert unstructured to object"
+	errFmtMove          = "cannot move field %s to %s"
+)
+
+// A Move maps a field of an API version to a field of the storage version.
+// Fields are given as dot-separated JSON paths, e.g. spec.forProvider.name.
+// Lists along the paths are traversed item by item if they are shared by
+// both paths, and must have at most one item otherwise.
+type Move struct {
+	// From is the path of the field in the API version.
+	From string
+	// To is the path of the field in the storage version.
+	To string
+}
+
+// ToHub converts the given API version of a managed resource to the given
+// storage version by applying the given moves.
+func ToHub(spoke, hub runtime.Object, moves []Move) error {
+	return convert(spoke, hub, moves)
+}
+
+// FromHub converts the given storage version of a managed resource to the
+// given API version by reverting the given moves.
+func FromHub(hub, spoke runtime.Object, moves []Move) error {
+	reverted := make([]Move, len(moves))
+	for i, m := range moves {
+		reverted[len(moves)-1-i] = Move{From: m.To, To: m.From}
+	}
+	return convert(hub, spoke, reverted)
+}
+
+func convert(src, dst runtime.Object, moves []Move) error {
+	// The source object is copied since unstructured objects would be
+	// modified in place.
+	u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(src.DeepCopyObject())
+	if err != nil {
+		return errors.Wrap(err, errToUnstructured)
+	}
+	for _, m := range moves {
+		if err := move(u, strings.Split(m.From, "."), strings.Split(m.To, ".")); err != nil {
+			return errors.Wrapf(err, errFmtMove, m.From, m.To)
+		}
+	}
+	// The type of the destination object is kept as is.
+	gvk := dst.GetObjectKind().GroupVersionKind()
+	u["apiVersion"], u["kind"] = gvk.ToAPIVersionAndKind()
+	if gvk.Empty() {
+		delete(u, "apiVersion")
+		delete(u, "kind")
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u, dst); err != nil {
+		return errors.Wrap(err, errFromUnstructured)
+	}
+	dst.GetObjectKind().SetGroupVersionKind(gvk)
+	return nil
+}
+
+// move moves the field at the given path of the given object to the other
+// given path. It does nothing if the field does not exist.
+func move(obj map[string]interface{}, from, to []string) error {
+	n := 0
+	for n < len(from)-1 && n < len(to)-1 && from[n] == to[n] {
+		n++
+	}
+	return walk(obj, from[:n], func(m map[string]interface{}) error {
+		v, ok, err := remove(m, from[n:])
+		if err != nil || !ok {
+			return err
+		}
+		return set(m, to[n:], v)
+	})
+}
+
+// walk calls the given function with every object at the given path of the
+// given value.
+func walk(v interface{}, path []string, fn func(map[string]interface{}) error) error {
+	switch t := v.(type) {
+	case []interface{}:
+		for _, e := range t {
+			if err := walk(e, path, fn); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		if len(path) == 0 {
+			return fn(t)
+		}
+		return walk(t[path[0]], path[1:], fn)
+	}
+	return nil
+}
+
+// remove removes the field at the given path of the given object and
+// returns its value. Lists left empty by the removal are removed as well.
+func remove(m map[string]interface{}, path []string) (interface{}, bool, error) {
+	v, ok := m[path[0]]
+	if !ok {
+		return nil, false, nil
+	}
+	if len(path) == 1 {
+		delete(m, path[0])
+		return v, true, nil
+	}
+	nested, err := single(v, path[0])
+	if err != nil || nested == nil {
+		return nil, false, err
+	}
+	v, ok, err = remove(nested, path[1:])
+	if len(nested) == 0 {
+		delete(m, path[0])
+	}
+	return v, ok, err
+}
+
+// set sets the field at the given path of the given object to the given
+// value. Missing lists along the path are created with a single item.
+func set(m map[string]interface{}, path []string, v interface{}) error {
+	if len(path) == 1 {
+		m[path[0]] = v
+		return nil
+	}
+	nested, err := single(m[path[0]], path[0])
+	if err != nil {
+		return err
+	}
+	if nested == nil {
+		nested = map[string]interface{}{}
+		m[path[0]] = []interface{}{nested}
+	}
+	return set(nested, path[1:], v)
+}
+
+// single returns the only item of the given list, or nil if it's empty.
+func single(v interface{}, name string) (map[string]interface{}, error) {
+	switch t := v.(type) {
+	case nil:
+		return nil, nil
+	case map[string]interface{}:
+		return t, nil
+	case []interface{}:
+		if len(t) == 0 {
+			return nil, nil
+		}
+		if len(t) > 1 {
+			return nil, errors.Errorf("field %s has more than one item", name)
+		}
+		if m, ok := t[0].(map[string]interface{}); ok {
+			return m, nil
+		}
+	}
+	return nil, errors.Errorf("field %s is not an object", name)
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package convert
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	fuzz "github.com/google/gofuzz"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/rand"
+
+	"github.com/crossplane-contrib/provider-jet-template/apis/null/v1alpha1"
+)
+
+// moves are the moves of a sample API version of the null Resource that
+// keeps the triggers in a nested block with at most one item under another
+// name.
+var moves = []Move{{From: "spec.forProvider.settings.keepers", To: "spec.forProvider.triggers"}}
+
+type spokeSettings struct {
+	Keepers map[string]*string `json:"keepers,omitempty"`
+}
+
+type spokeParameters struct {
+	Settings []spokeSettings `json:"settings,omitempty"`
+}
+
+type spokeSpec struct {
+	xpv1.ResourceSpec `json:",inline"`
+	ForProvider       spokeParameters `json:"forProvider"`
+}
+
+// spoke is the sample API version of the null Resource.
+type spoke struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              spokeSpec               `json:"spec"`
+	Status            v1alpha1.ResourceStatus `json:"status,omitempty"`
+}
+
+func (s *spoke) DeepCopyObject() runtime.Object {
+	out := &spoke{}
+	b, err := json.Marshal(s)
+	if err != nil {
+		panic(err)
+	}
+	if err := json.Unmarshal(b, out); err != nil {
+		panic(err)
+	}
+	return out
+}
+
+func TestRoundTrip(t *testing.T) {
+	f := fuzz.New().NilChance(0.2).NumElements(0, 3).Funcs(
+		// Timestamps are serialized with a precision of seconds.
+		func(tm *metav1.Time, c fuzz.Continue) {
+			*tm = metav1.NewTime(time.Unix(c.Int63n(1<<32), 0))
+		},
+		// Unstructured objects cannot hold the raw extensions of the
+		// managed fields.
+		func(fs *[]metav1.ManagedFieldsEntry, c fuzz.Continue) {},
+		func(s *string, c fuzz.Continue) {
+			*s = rand.String(c.Intn(8) + 1)
+		},
+	)
+	for i := 0; i < 1000; i++ {
+		hub := &v1alpha1.Resource{}
+		f.Fuzz(hub)
+		hub.TypeMeta = metav1.TypeMeta{}
+
+		sp := &spoke{}
+		if err := FromHub(hub, sp, moves); err != nil {
+			t.Fatalf("FromHub(...): %s", err)
+		}
+		got := &v1alpha1.Resource{}
+		if err := ToHub(sp, got, moves); err != nil {
+			t.Fatalf("ToHub(...): %s", err)
+		}
+		if diff := cmp.Diff(hub, got, cmpopts.EquateEmpty()); diff != "" {
+			t.Fatalf("ToHub(FromHub(...)): -want, +got:\n%s", diff)
+		}
+	}
+}
+
+func TestFromHub(t *testing.T) {
+	value := "value"
+	hub := &v1alpha1.Resource{
+		ObjectMeta: metav1.ObjectMeta{Name: "example"},
+		Spec: v1alpha1.ResourceSpec{
+			ForProvider: v1alpha1.ResourceParameters{Triggers: map[string]*string{"key": &value}},
+		},
+	}
+	sp := &spoke{TypeMeta: metav1.TypeMeta{APIVersion: "null.template.jet.crossplane.io/v1alpha2", Kind: "Resource"}}
+	if err := FromHub(hub, sp, moves); err != nil {
+		t.Fatalf("FromHub(...): %s", err)
+	}
+	want := &spoke{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "null.template.jet.crossplane.io/v1alpha2", Kind: "Resource"},
+		ObjectMeta: metav1.ObjectMeta{Name: "example"},
+		Spec: spokeSpec{
+			ForProvider: spokeParameters{Settings: []spokeSettings{{Keepers: map[string]*string{"key": &value}}}},
+		},
+	}
+	if diff := cmp.Diff(want, sp); diff != "" {
+		t.Errorf("FromHub(...): -want, +got:\n%s", diff)
+	}
+
+	// A spoke with more than one item in a block that is moved out of
+	// cannot be converted.
+	sp.Spec.ForProvider.Settings = append(sp.Spec.ForProvider.Settings, spokeSettings{})
+	if err := ToHub(sp, &v1alpha1.Resource{}, moves); err == nil {
+		t.Error("ToHub(...): want error, got nil")
+	}
+}
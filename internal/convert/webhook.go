@@ -0,0 +1,65 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package convert
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+const (
+	errFmtSetupWebhook = "cannot setup conversion webhook of %s"
+)
+
+// SetupWebhooks registers the conversion webhook of every kind in the scheme
+// of the given manager that is served in several API versions, i.e. whose
+// storage version is a conversion hub.
+func SetupWebhooks(mgr ctrl.Manager) error {
+	s := mgr.GetScheme()
+	for _, gvk := range hubs(s) {
+		obj, err := s.New(gvk)
+		if err != nil {
+			return errors.Wrapf(err, errFmtSetupWebhook, gvk)
+		}
+		if err := ctrl.NewWebhookManagedBy(mgr).For(obj.(client.Object)).Complete(); err != nil {
+			return errors.Wrapf(err, errFmtSetupWebhook, gvk)
+		}
+	}
+	return nil
+}
+
+// hubs returns the sorted kinds of the given scheme that are conversion hubs.
+func hubs(s *runtime.Scheme) []schema.GroupVersionKind {
+	hubType := reflect.TypeOf((*conversion.Hub)(nil)).Elem()
+	var result []schema.GroupVersionKind
+	for gvk, t := range s.AllKnownTypes() {
+		if reflect.PtrTo(t).Implements(hubType) {
+			result = append(result, gvk)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].String() < result[j].String()
+	})
+	return result
+}
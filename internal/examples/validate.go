@@ -0,0 +1,174 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package examples validates the example manifests against the generated CRDs.
+package examples
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	v1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	structuralschema "k8s.io/apiextensions-apiserver/pkg/apiserver/schema"
+	"k8s.io/apiextensions-apiserver/pkg/apiserver/schema/pruning"
+	apiservervalidation "k8s.io/apiextensions-apiserver/pkg/apiserver/validation"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/kube-openapi/pkg/validation/validate"
+)
+
+type crdSchema struct {
+	validator  *validate.SchemaValidator
+	structural *structuralschema.Structural
+}
+
+// ValidateExamples validates the example manifests in the examples directory
+// of the given root directory against the CRDs in package/crds. Manifests of
+// kinds that have no CRD there are skipped unless they are generated.
+func ValidateExamples(rootDir string) error {
+	schemas, err := loadCRDSchemas(filepath.Join(rootDir, "package", "crds"))
+	if err != nil {
+		return err
+	}
+	var errs []string
+	err = filepath.WalkDir(filepath.Join(rootDir, "examples"), func(p string, e fs.DirEntry, err error) error {
+		if err != nil || e.IsDir() || filepath.Ext(p) != ".yaml" {
+			return err
+		}
+		docs, err := readYAMLDocuments(p)
+		if err != nil {
+			return err
+		}
+		for _, doc := range docs {
+			for _, msg := range validateDocument(schemas, doc, generated(e.Name())) {
+				errs = append(errs, p+": "+msg)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "cannot read examples")
+	}
+	if len(errs) > 0 {
+		return errors.Errorf("invalid examples:\n%s", strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// generated returns whether the file with the given name is produced by the
+// code generation pipeline. It mirrors pipeline.IsGenerated so that this
+// package does not have to import the generation pipeline.
+func generated(name string) bool {
+	return strings.HasPrefix(name, "zz_") && !strings.HasPrefix(name, "zz_generated.")
+}
+
+func validateDocument(schemas map[schema.GroupVersionKind]crdSchema, doc map[string]interface{}, generated bool) []string {
+	apiVersion, _ := doc["apiVersion"].(string)
+	kind, _ := doc["kind"].(string)
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	s, ok := schemas[gv.WithKind(kind)]
+	if !ok {
+		if generated {
+			return []string{"no CRD found for " + gv.WithKind(kind).String()}
+		}
+		return nil
+	}
+	var result []string
+	for _, e := range apiservervalidation.ValidateCustomResource(nil, doc, s.validator) {
+		result = append(result, e.Error())
+	}
+	pruned := pruning.PruneWithOptions(doc, s.structural, true, pruning.PruneOptions{ReturnPruned: true})
+	for _, p := range pruned {
+		result = append(result, "unknown field "+p)
+	}
+	return result
+}
+
+func loadCRDSchemas(dir string) (map[schema.GroupVersionKind]crdSchema, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot list CRDs")
+	}
+	result := map[schema.GroupVersionKind]crdSchema{}
+	for _, f := range files {
+		b, err := os.ReadFile(filepath.Clean(f))
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot read CRD %s", f)
+		}
+		crd := &v1.CustomResourceDefinition{}
+		if err := yaml.Unmarshal(b, crd); err != nil {
+			return nil, errors.Wrapf(err, "cannot unmarshal CRD %s", f)
+		}
+		for _, v := range crd.Spec.Versions {
+			if v.Schema == nil {
+				continue
+			}
+			s, err := newCRDSchema(v.Schema)
+			if err != nil {
+				return nil, errors.Wrapf(err, "cannot build schema of CRD %s version %s", crd.Name, v.Name)
+			}
+			result[schema.GroupVersionKind{Group: crd.Spec.Group, Version: v.Name, Kind: crd.Spec.Names.Kind}] = s
+		}
+	}
+	return result, nil
+}
+
+func newCRDSchema(in *v1.CustomResourceValidation) (crdSchema, error) {
+	out := &apiextensions.CustomResourceValidation{}
+	if err := v1.Convert_v1_CustomResourceValidation_To_apiextensions_CustomResourceValidation(in, out, nil); err != nil {
+		return crdSchema{}, err
+	}
+	validator, _, err := apiservervalidation.NewSchemaValidator(out)
+	if err != nil {
+		return crdSchema{}, err
+	}
+	structural, err := structuralschema.NewStructural(out.OpenAPIV3Schema)
+	if err != nil {
+		return crdSchema{}, err
+	}
+	return crdSchema{validator: validator, structural: structural}, nil
+}
+
+func readYAMLDocuments(p string) ([]map[string]interface{}, error) {
+	b, err := os.ReadFile(filepath.Clean(p))
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read %s", p)
+	}
+	dec := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(b), 4096)
+	var docs []map[string]interface{}
+	for {
+		doc := map[string]interface{}{}
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot decode %s", p)
+		}
+		if len(doc) > 0 {
+			docs = append(docs, doc)
+		}
+	}
+}
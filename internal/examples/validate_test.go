@@ -0,0 +1,96 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package examples
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// rootDir is the root directory of the repository relative to this package.
+var rootDir = filepath.Join("..", "..")
+
+func TestValidateExamples(t *testing.T) {
+	if err := ValidateExamples(rootDir); err != nil {
+		t.Errorf("ValidateExamples(...): %s", err)
+	}
+}
+
+func TestValidateDocument(t *testing.T) {
+	schemas, err := loadCRDSchemas(filepath.Join(rootDir, "package", "crds"))
+	if err != nil {
+		t.Fatalf("loadCRDSchemas(...): %s", err)
+	}
+	providerConfig := func(spec map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"apiVersion": "template.jet.crossplane.io/v1alpha1",
+			"kind":       "ProviderConfig",
+			"metadata":   map[string]interface{}{"name": "example"},
+			"spec":       spec,
+		}
+	}
+	cases := map[string]struct {
+		reason    string
+		doc       map[string]interface{}
+		generated bool
+		want      []string
+	}{
+		"Valid": {
+			reason: "A manifest that conforms to its CRD should be valid.",
+			doc:    providerConfig(map[string]interface{}{"credentials": map[string]interface{}{"source": "None"}}),
+		},
+		"UnknownField": {
+			reason: "Fields that the CRD does not declare should be reported.",
+			doc: providerConfig(map[string]interface{}{
+				"credentials": map[string]interface{}{"source": "None"},
+				"unknown":     true,
+			}),
+			want: []string{"unknown field spec.unknown"},
+		},
+		"InvalidValue": {
+			reason: "Values that violate the CRD should be reported.",
+			doc:    providerConfig(map[string]interface{}{"credentials": map[string]interface{}{"source": "Unknown"}}),
+			want:   []string{`spec.credentials.source: Unsupported value: "Unknown": supported values: "None", "Secret", "InjectedIdentity", "Environment", "Filesystem"`},
+		},
+		"NoCRDGenerated": {
+			reason: "Generated manifests of kinds without a CRD should be reported.",
+			doc: map[string]interface{}{
+				"apiVersion": "template.jet.crossplane.io/v1alpha1",
+				"kind":       "Unknown",
+			},
+			generated: true,
+			want:      []string{"no CRD found for template.jet.crossplane.io/v1alpha1, Kind=Unknown"},
+		},
+		"NoCRDHandWritten": {
+			reason: "Hand-written manifests of kinds without a CRD, such as Secrets, should be skipped.",
+			doc: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "Secret",
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := validateDocument(schemas, tc.doc, tc.generated)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nvalidateDocument(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
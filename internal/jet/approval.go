@@ -0,0 +1,250 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jet
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"reflect"
+	"sort"
+	"strings"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/terrajet/pkg/resource/json"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crossplane-contrib/provider-jet-template/apis/v1alpha1"
+	"github.com/crossplane-contrib/provider-jet-template/internal/workspace"
+)
+
+const (
+	// AnnotationKeyApprovePlan is the annotation that approves the pending
+	// Terraform plan of a managed resource when set to its hash.
+	AnnotationKeyApprovePlan = "template.jet.crossplane.io/approve-plan"
+
+	// TypePlanApproval is the condition that reports whether a change of a
+	// managed resource waits for the approval of its Terraform plan.
+	TypePlanApproval xpv1.ConditionType = "PlanApproval"
+
+	// ReasonWaitingForApproval means that a plan waits for approval.
+	ReasonWaitingForApproval xpv1.ConditionReason = "WaitingForApproval"
+	// ReasonNoPendingPlan means that no plan waits for approval anymore.
+	ReasonNoPendingPlan xpv1.ConditionReason = "NoPendingPlan"
+
+	errFmtPlan               = "cannot plan the changes: %s"
+	errShowPlan              = "cannot show the planned changes"
+	errUnmarshalPlan         = "cannot unmarshal the planned changes"
+	errFmtApplyPlan          = "cannot apply the approved plan: %s"
+	errDiscardPlan           = "cannot remove the saved plan"
+	errFmtWaitingForApproval = "waiting for the approval of plan %s"
+	errFmtPlanChanged        = "the changes of the approved plan %s changed before they were applied, waiting for approval again"
+)
+
+// WaitingForApproval returns a condition that indicates that the given plan
+// waits for approval.
+func WaitingForApproval(p *plan) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypePlanApproval,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonWaitingForApproval,
+		Message: fmt.Sprintf("Plan %s waits for approval: %s. Annotate the resource with %s: %q to apply it.",
+			p.hash, strings.Join(p.summary, "; "), AnnotationKeyApprovePlan, p.hash),
+	}
+}
+
+// NoPendingPlan returns a condition that indicates that no plan waits for
+// approval anymore.
+func NoPendingPlan() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypePlanApproval,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonNoPendingPlan,
+	}
+}
+
+// A plan is the summary of the changes that Terraform plans to apply to the
+// resources of a workspace.
+type plan struct {
+	// hash identifies the planned changes.
+	hash string
+	// summary lists the planned actions and the changed attributes.
+	summary []string
+	// destructive is true if the plan destroys or replaces a resource.
+	destructive bool
+	// replace is true if the plan replaces a resource.
+	replace bool
+	// changes is true if the plan changes any resource.
+	changes bool
+	// destroy is true if the plan destroys the resources of the workspace
+	// because the managed resource is deleted.
+	destroy bool
+}
+
+// requiresApproval returns whether the plan has to be approved in the given
+// mode.
+func (p *plan) requiresApproval(mode v1alpha1.ApprovalMode) bool {
+	switch mode {
+	case v1alpha1.ApprovalModeAlways:
+		return p.changes
+	case v1alpha1.ApprovalModeDestructive:
+		return p.destructive
+	default:
+		return false
+	}
+}
+
+// resourceChange is a change of a resource in the JSON representation of a
+// Terraform plan.
+type resourceChange struct {
+	Address string `json:"address"`
+	Change  struct {
+		Actions      []string               `json:"actions"`
+		Before       map[string]interface{} `json:"before"`
+		After        map[string]interface{} `json:"after"`
+		AfterUnknown map[string]interface{} `json:"after_unknown"`
+	} `json:"change"`
+}
+
+// planChanges plans the changes of the Terraform workspace in the given
+// directory against its state, which is refreshed by the observation, or the
+// destruction of its resources if destroy is true, with the given
+// environment variables of its Terraform setup. The plan is saved in the
+// workspace, so that it can be applied once it's approved, and has to be
+// discarded otherwise.
+func planChanges(ctx context.Context, dir string, env []string, destroy bool) (*plan, error) {
+	args := []string{"plan", "-refresh=false", "-input=false", "-lock=false", "-out=" + workspace.FilePlan}
+	if destroy {
+		args = append(args, "-destroy")
+	}
+	cmd := exec.CommandContext(ctx, "terraform", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return nil, errors.Wrapf(err, errFmtPlan, stderr.String())
+	}
+	cmd = exec.CommandContext(ctx, "terraform", "show", "-json", workspace.FilePlan)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Wrap(err, errShowPlan)
+	}
+	p, err := parsePlan(out)
+	if err != nil {
+		return nil, err
+	}
+	p.destroy = destroy
+	return p, nil
+}
+
+// applyPlan applies the plan saved in the Terraform workspace in the given
+// directory with the given environment variables of its Terraform setup,
+// which applies exactly the approved changes.
+func applyPlan(ctx context.Context, dir string, env []string) error {
+	cmd := exec.CommandContext(ctx, "terraform", "apply", "-input=false", workspace.FilePlan)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	return errors.Wrapf(cmd.Run(), errFmtApplyPlan, stderr.String())
+}
+
+// discardPlan removes the plan saved in the Terraform workspace in the given
+// directory, since it may contain sensitive values.
+func discardPlan(dir string) error {
+	return errors.Wrap(workspace.RemovePlan(dir), errDiscardPlan)
+}
+
+// parsePlan summarizes the given JSON representation of a Terraform plan.
+// The hash covers the planned changes only, so that planning the same
+// changes again results in the same hash.
+func parsePlan(raw []byte) (*plan, error) {
+	out := struct {
+		ResourceChanges []resourceChange `json:"resource_changes"`
+	}{}
+	if err := json.JSParser.Unmarshal(raw, &out); err != nil {
+		return nil, errors.Wrap(err, errUnmarshalPlan)
+	}
+	b, err := json.JSParser.Marshal(out.ResourceChanges)
+	if err != nil {
+		return nil, errors.Wrap(err, errUnmarshalPlan)
+	}
+	sum := sha256.Sum256(b)
+	p := &plan{hash: hex.EncodeToString(sum[:])}
+	for _, rc := range out.ResourceChanges {
+		action := actionOf(rc.Change.Actions)
+		if action == "no-op" || action == "read" {
+			continue
+		}
+		p.changes = true
+		p.destructive = p.destructive || action == "delete" || action == "replace"
+		p.replace = p.replace || action == "replace"
+		p.summary = append(p.summary, fmt.Sprintf("%s %s (%s)", action, rc.Address, strings.Join(changedAttributes(rc), ", ")))
+	}
+	return p, nil
+}
+
+// actionOf returns the action of a resource change with the given actions.
+// Terraform represents replacements as a delete and a create action.
+func actionOf(actions []string) string {
+	if len(actions) == 2 {
+		return "replace"
+	}
+	if len(actions) == 1 {
+		return actions[0]
+	}
+	return strings.Join(actions, ",")
+}
+
+// changedAttributes returns the sorted names of the top-level attributes
+// changed by the given resource change. Their values are omitted since they
+// may be sensitive.
+func changedAttributes(rc resourceChange) []string {
+	seen := map[string]bool{}
+	for k, v := range rc.Change.After {
+		if !reflect.DeepEqual(v, rc.Change.Before[k]) {
+			seen[k] = true
+		}
+	}
+	for k := range rc.Change.Before {
+		if _, ok := rc.Change.After[k]; !ok {
+			seen[k] = true
+		}
+	}
+	for k, v := range rc.Change.AfterUnknown {
+		if v == true {
+			seen[k] = true
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for k := range seen {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jet
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/crossplane-contrib/provider-jet-template/apis/v1alpha1"
+)
+
+func TestParsePlan(t *testing.T) {
+	change := func(actions string) string {
+		return `{"resource_changes":[{"address":"null_resource.example","change":{"actions":` + actions + `,"before":{"id":"1","triggers":{"a":"b"}},"after":{"id":"1","triggers":{"a":"c"}},"after_unknown":{}}}]}`
+	}
+	type want struct {
+		summary     []string
+		changes     bool
+		destructive bool
+		replace     bool
+		approval    map[v1alpha1.ApprovalMode]bool
+	}
+	cases := map[string]struct {
+		reason string
+		raw    string
+		want   want
+	}{
+		"NoOp": {
+			reason: "A plan without changes should require no approval.",
+			raw:    change(`["no-op"]`),
+			want: want{
+				approval: map[v1alpha1.ApprovalMode]bool{v1alpha1.ApprovalModeAlways: false, v1alpha1.ApprovalModeDestructive: false},
+			},
+		},
+		"Update": {
+			reason: "An update should only require approval in the Always mode.",
+			raw:    change(`["update"]`),
+			want: want{
+				summary:  []string{"update null_resource.example (triggers)"},
+				changes:  true,
+				approval: map[v1alpha1.ApprovalMode]bool{v1alpha1.ApprovalModeAlways: true, v1alpha1.ApprovalModeDestructive: false},
+			},
+		},
+		"Replace": {
+			reason: "A replacement should be destructive.",
+			raw:    change(`["delete","create"]`),
+			want: want{
+				summary:     []string{"replace null_resource.example (triggers)"},
+				changes:     true,
+				destructive: true,
+				replace:     true,
+				approval:    map[v1alpha1.ApprovalMode]bool{v1alpha1.ApprovalModeAlways: true, v1alpha1.ApprovalModeDestructive: true, v1alpha1.ApprovalModeNone: false},
+			},
+		},
+		"Delete": {
+			reason: "The destruction of a deleted resource should require approval in both modes.",
+			raw:    `{"resource_changes":[{"address":"null_resource.example","change":{"actions":["delete"],"before":{"id":"1"},"after":null}}]}`,
+			want: want{
+				summary:     []string{"delete null_resource.example (id)"},
+				changes:     true,
+				destructive: true,
+				approval:    map[v1alpha1.ApprovalMode]bool{v1alpha1.ApprovalModeAlways: true, v1alpha1.ApprovalModeDestructive: true},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			p, err := parsePlan([]byte(tc.raw))
+			if err != nil {
+				t.Fatalf("parsePlan(...): %s", err)
+			}
+			got := want{summary: p.summary, changes: p.changes, destructive: p.destructive, replace: p.replace, approval: map[v1alpha1.ApprovalMode]bool{}}
+			for mode := range tc.want.approval {
+				got.approval[mode] = p.requiresApproval(mode)
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\nparsePlan(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestParsePlanHash(t *testing.T) {
+	a, err := parsePlan([]byte(`{"format_version":"1.0","resource_changes":[{"address":"null_resource.example","change":{"actions":["update"]}}]}`))
+	if err != nil {
+		t.Fatalf("parsePlan(...): %s", err)
+	}
+	b, err := parsePlan([]byte(`{"format_version":"1.1","resource_changes":[{"address":"null_resource.example","change":{"actions":["update"]}}]}`))
+	if err != nil {
+		t.Fatalf("parsePlan(...): %s", err)
+	}
+	if a.hash != b.hash {
+		t.Errorf("parsePlan(...): the hashes of the same changes differ: %s, %s", a.hash, b.hash)
+	}
+	c, err := parsePlan([]byte(`{"resource_changes":[{"address":"null_resource.example","change":{"actions":["delete"]}}]}`))
+	if err != nil {
+		t.Fatalf("parsePlan(...): %s", err)
+	}
+	if a.hash == c.hash {
+		t.Errorf("parsePlan(...): the hashes of different changes are the same: %s", a.hash)
+	}
+}
@@ -0,0 +1,428 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jet
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	xpresource "github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/terrajet/pkg/resource/json"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane-contrib/provider-jet-template/internal/backend"
+	"github.com/crossplane-contrib/provider-jet-template/internal/workspace"
+)
+
+const (
+	// TypeOperation is the condition that reports the progress of the
+	// async Terraform operation of a managed resource.
+	TypeOperation xpv1.ConditionType = "Operation"
+
+	// ReasonPlanning is the phase of an operation that plans the changes.
+	ReasonPlanning xpv1.ConditionReason = "Planning"
+	// ReasonApplying is the phase of an apply that changes the resource.
+	ReasonApplying xpv1.ConditionReason = "Applying"
+	// ReasonDestroying is the phase of a destroy that deletes the resource.
+	ReasonDestroying xpv1.ConditionReason = "Destroying"
+	// ReasonProvisioning is the phase of an operation that runs the
+	// provisioners of the resource.
+	ReasonProvisioning xpv1.ConditionReason = "Provisioning"
+	// ReasonSucceeded is the reason of an operation that succeeded.
+	ReasonSucceeded xpv1.ConditionReason = "Succeeded"
+	// ReasonFailed is the reason of an operation that failed.
+	ReasonFailed xpv1.ConditionReason = "Failed"
+	// ReasonInterrupted is the reason of an operation that was interrupted
+	// by a restart of the provider.
+	ReasonInterrupted xpv1.ConditionReason = "Interrupted"
+
+	operationApply   = "apply"
+	operationDestroy = "destroy"
+
+	fileOperation = ".operation.json"
+
+	// asyncTimeout is the timeout of the async operations, which is the one
+	// of the Terrajet async operations.
+	asyncTimeout = time.Hour
+	// progressInterval is the minimum interval between the progress reports
+	// of an operation within a phase.
+	progressInterval = 15 * time.Second
+
+	errFmtOperationRunning = "%s operation that started at %s is still running"
+	errStartOperation      = "cannot start the async operation"
+	errWriteOperation      = "cannot write the progress of the async operation"
+	errReadOperation       = "cannot read the progress of the async operation"
+	errReportProgress      = "cannot report the progress of the async operation"
+	errReleaseState        = "cannot release the state of the async operation"
+)
+
+// An Operation is the progress of an async Terraform operation. It's kept in
+// the workspace while the operation runs, so that an operation interrupted
+// by a restart of the provider can be told.
+type Operation struct {
+	// Type of the operation, apply or destroy.
+	Type string `json:"type"`
+	// StartedAt is the time the operation started at.
+	StartedAt metav1.Time `json:"startedAt"`
+	// Phase of the operation.
+	Phase xpv1.ConditionReason `json:"phase"`
+	// LastOutput is the last line of the output of the operation.
+	LastOutput string `json:"lastOutput,omitempty"`
+	// StateLock is the lock of the state in the state backend that the
+	// operation holds, if the resource has a state backend.
+	StateLock *backend.LockInfo `json:"stateLock,omitempty"`
+
+	key      client.ObjectKey
+	object   xpresource.Managed
+	reported time.Time
+	errors   []string
+	state    *stateLock
+	// held are the functions that release the slots the operation holds
+	// until it finishes.
+	held []func()
+}
+
+// OperationRunning returns a condition that indicates the given async
+// operation is running, with its phase and last output line.
+func OperationRunning(op *Operation) xpv1.Condition {
+	msg := fmt.Sprintf("%s started at %s", op.Type, op.StartedAt.UTC().Format(time.RFC3339))
+	if op.LastOutput != "" {
+		msg += ": " + op.LastOutput
+	}
+	return xpv1.Condition{
+		Type:               TypeOperation,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: op.StartedAt,
+		Reason:             op.Phase,
+		Message:            msg,
+	}
+}
+
+// OperationSucceeded returns a condition that indicates the given async
+// operation succeeded.
+func OperationSucceeded(op *Operation) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeOperation,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonSucceeded,
+		Message:            fmt.Sprintf("%s that started at %s succeeded", op.Type, op.StartedAt.UTC().Format(time.RFC3339)),
+	}
+}
+
+// OperationFailed returns a condition that indicates the given async
+// operation failed with the given error.
+func OperationFailed(op *Operation, err error) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeOperation,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonFailed,
+		Message:            fmt.Sprintf("%s that started at %s failed: %s", op.Type, op.StartedAt.UTC().Format(time.RFC3339), err),
+	}
+}
+
+// OperationInterrupted returns a condition that indicates the given async
+// operation was interrupted by a restart of the provider.
+func OperationInterrupted(op *Operation) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeOperation,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonInterrupted,
+		Message:            fmt.Sprintf("%s that started at %s was interrupted by a restart of the provider in phase %s, last output: %s", op.Type, op.StartedAt.UTC().Format(time.RFC3339), op.Phase, op.LastOutput),
+	}
+}
+
+// Operations runs the async Terraform operations of the managed resources
+// whose Terrajet configuration enables async operations. Terrajet only
+// reads the output of its async operations once they finish, so they are
+// run here instead, so that their progress can be reported in the
+// Operation condition of the managed resources while they run. The status
+// update that reports the end of an operation triggers the next reconcile of
+// the managed resource.
+type Operations struct {
+	kube   client.Client
+	logger logging.Logger
+
+	mu      sync.Mutex
+	running map[types.UID]*Operation
+	// succeeded are the resources whose last apply succeeded and was not
+	// observed since.
+	succeeded map[types.UID]bool
+}
+
+// NewOperations returns a new Operations that reports the progress of the
+// operations with the given client.
+func NewOperations(kube client.Client, log logging.Logger) *Operations {
+	return &Operations{kube: kube, logger: log, running: map[types.UID]*Operation{}, succeeded: map[types.UID]bool{}}
+}
+
+// Running returns the running operation of the given resource, or nil if
+// there is none.
+func (o *Operations) Running(mg xpresource.Managed) *Operation {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	op, ok := o.running[mg.GetUID()]
+	if !ok {
+		return nil
+	}
+	c := *op
+	return &c
+}
+
+// Succeeded returns whether the last apply of the given resource succeeded
+// since this was last called for it.
+func (o *Operations) Succeeded(mg xpresource.Managed) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	ok := o.succeeded[mg.GetUID()]
+	delete(o.succeeded, mg.GetUID())
+	return ok
+}
+
+// Interrupted returns the operation of the given resource that was running
+// when the provider restarted, if any, and forgets it.
+func (o *Operations) Interrupted(mg xpresource.Managed) (*Operation, error) {
+	path := filepath.Join(workspace.Dir(mg), fileOperation)
+	raw, err := os.ReadFile(filepath.Clean(path))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, errReadOperation)
+	}
+	op := &Operation{}
+	if err := json.JSParser.Unmarshal(raw, op); err != nil {
+		// A damaged progress file still means an interrupted operation.
+		op = &Operation{Type: operationApply, Phase: ReasonApplying}
+	}
+	return op, errors.Wrap(os.Remove(path), errReadOperation)
+}
+
+// Start starts the given type of async operation on the given resource,
+// whose workspace is ready, with the given environment variables of its
+// Terraform setup. The operation applies the given saved plan instead of
+// planning the changes again unless it's empty. The given lock of the state
+// of the resource is held until the operation finishes, and is released
+// then, after the state is pushed to the backend. The lock is not released
+// if the operation cannot be started.
+func (o *Operations) Start(mg xpresource.Managed, typ, planFile string, env []string, state *stateLock) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if op, ok := o.running[mg.GetUID()]; ok {
+		return errors.Errorf(errFmtOperationRunning, op.Type, op.StartedAt.UTC().Format(time.RFC3339))
+	}
+	delete(o.succeeded, mg.GetUID())
+	op := &Operation{
+		Type:      typ,
+		StartedAt: metav1.Now(),
+		Phase:     ReasonPlanning,
+		key:       client.ObjectKeyFromObject(mg),
+		object:    mg.DeepCopyObject().(xpresource.Managed),
+		state:     state,
+	}
+	if state != nil {
+		op.StateLock = state.info
+	}
+	dir := workspace.Dir(mg)
+	ctx, cancel := context.WithTimeout(context.Background(), asyncTimeout)
+	cmd := exec.CommandContext(ctx, "terraform", operationArgs(typ, planFile)...) //nolint:gosec
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		return errors.Wrap(err, errStartOperation)
+	}
+	cmd.Stderr = cmd.Stdout
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return errors.Wrap(err, errStartOperation)
+	}
+	// The progress file marks the operation as interrupted if the provider
+	// restarts before it finishes, so it's written only once the operation
+	// started. The operation reports its progress to it again as it runs.
+	if err := writeOperation(dir, op); err != nil {
+		o.logger.Info(errWriteOperation, "uid", mg.GetUID(), "error", err.Error())
+	}
+	o.running[mg.GetUID()] = op
+	go func() {
+		defer cancel()
+		o.follow(ctx, mg.GetUID(), op, out)
+		err := cmd.Wait()
+		o.finish(mg.GetUID(), dir, op, err)
+	}()
+	return nil
+}
+
+// operationArgs returns the arguments of the Terraform command that runs the
+// given type of operation, or applies the given saved plan unless it's empty.
+// Terraform locks the state file of the workspace while the operation runs.
+func operationArgs(typ, planFile string) []string {
+	if planFile != "" {
+		return []string{operationApply, "-input=false", "-json", planFile}
+	}
+	return []string{typ, "-auto-approve", "-input=false", "-json"}
+}
+
+// Hold keeps the slot with the given release function until the running
+// operation of the resource with the given UID finishes. It returns false
+// without keeping the slot if no operation runs, e.g. because it already
+// finished, or if the Operations is nil.
+func (o *Operations) Hold(uid types.UID, release func()) bool {
+	if o == nil {
+		return false
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	op, ok := o.running[uid]
+	if !ok {
+		return false
+	}
+	op.held = append(op.held, release)
+	return true
+}
+
+// follow reports the progress of the given operation from its output.
+func (o *Operations) follow(ctx context.Context, uid types.UID, op *Operation, out io.Reader) {
+	s := bufio.NewScanner(out)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	for s.Scan() {
+		o.mu.Lock()
+		phase := op.Phase
+		op.observe(s.Text())
+		report := op.Phase != phase || time.Since(op.reported) >= progressInterval
+		if report {
+			op.reported = time.Now()
+		}
+		c := *op
+		o.mu.Unlock()
+		if !report {
+			continue
+		}
+		if err := writeOperation(workspace.Dir(op.object), &c); err != nil {
+			o.logger.Info(errWriteOperation, "uid", uid, "error", err.Error())
+		}
+		if err := o.report(ctx, &c, OperationRunning(&c)); err != nil {
+			o.logger.Info(errReportProgress, "uid", uid, "error", err.Error())
+		}
+	}
+}
+
+// finish reports the end of the given operation, which returned the given
+// error, and releases the lock of the state and the slots it holds.
+func (o *Operations) finish(uid types.UID, dir string, op *Operation, err error) {
+	o.mu.Lock()
+	delete(o.running, uid)
+	if err == nil && op.Type == operationApply {
+		o.succeeded[uid] = true
+	}
+	held := op.held
+	o.mu.Unlock()
+	// The deadline of the operation may have passed, but its state has to be
+	// pushed and its end has to be reported nonetheless.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	// Terraform writes the state of the partial changes of the failed
+	// operations as well.
+	if pushErr := op.state.release(ctx, nil); pushErr != nil {
+		o.logger.Info(errReleaseState, "uid", uid, "error", pushErr.Error())
+	}
+	for _, release := range held {
+		release()
+	}
+	if rmErr := os.Remove(filepath.Join(dir, fileOperation)); rmErr != nil && !os.IsNotExist(rmErr) {
+		o.logger.Info(errWriteOperation, "uid", uid, "error", rmErr.Error())
+	}
+	c := OperationSucceeded(op)
+	if err != nil {
+		if len(op.errors) > 0 {
+			err = errors.New(strings.Join(op.errors, "; "))
+		}
+		c = OperationFailed(op, err)
+	}
+	o.logger.Debug("Async operation ended", "uid", uid, "operation", op.Type, "reason", c.Reason)
+	if err := o.report(ctx, op, c); err != nil {
+		o.logger.Info(errReportProgress, "uid", uid, "error", err.Error())
+	}
+}
+
+// report sets the given condition on the managed resource of the given
+// operation.
+func (o *Operations) report(ctx context.Context, op *Operation, c xpv1.Condition) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		mg := op.object.DeepCopyObject().(xpresource.Managed)
+		if err := o.kube.Get(ctx, op.key, mg); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		mg.SetConditions(c)
+		return client.IgnoreNotFound(o.kube.Status().Update(ctx, mg))
+	})
+}
+
+// observe updates the phase and the last output line of the operation with
+// the given line of the machine-readable output of Terraform.
+func (op *Operation) observe(line string) {
+	msg := struct {
+		Message string `json:"@message"`
+		Level   string `json:"@level"`
+		Type    string `json:"type"`
+	}{}
+	if err := json.JSParser.Unmarshal([]byte(line), &msg); err != nil {
+		msg.Message = line
+	}
+	if msg.Message = strings.TrimSpace(msg.Message); msg.Message != "" {
+		op.LastOutput = msg.Message
+	}
+	if msg.Level == "error" {
+		op.errors = append(op.errors, msg.Message)
+	}
+	switch {
+	case strings.HasPrefix(msg.Type, "provision_"):
+		op.Phase = ReasonProvisioning
+	case strings.HasPrefix(msg.Type, "apply_") && op.Type == operationDestroy:
+		op.Phase = ReasonDestroying
+	case strings.HasPrefix(msg.Type, "apply_"):
+		op.Phase = ReasonApplying
+	}
+}
+
+// writeOperation writes the progress of the given operation to the
+// workspace in the given directory.
+func writeOperation(dir string, op *Operation) error {
+	raw, err := json.JSParser.Marshal(op)
+	if err != nil {
+		return errors.Wrap(err, errWriteOperation)
+	}
+	return errors.Wrap(os.WriteFile(filepath.Join(dir, fileOperation), raw, 0600), errWriteOperation)
+}
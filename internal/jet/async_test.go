@@ -0,0 +1,340 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jet
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	xpresource "github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/crossplane/terrajet/pkg/resource/json"
+	"github.com/crossplane/terrajet/pkg/terraform"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane-contrib/provider-jet-template/apis/null/v1alpha1"
+	"github.com/crossplane-contrib/provider-jet-template/internal/backend"
+	"github.com/crossplane-contrib/provider-jet-template/internal/workspace"
+)
+
+// fakeApply is a terraform executable that starts applying, waits for the
+// release file in the workspace and then writes the state and completes the
+// apply, or fails if FAIL is set. It records its arguments in args.
+const fakeApply = `#!/bin/sh
+echo "$*" > args
+echo '{"@level":"info","@message":"null_resource.example: Creating...","type":"apply_start"}'
+while [ ! -f release ]; do sleep 0.05; done
+echo '{"version":4,"serial":2}' > terraform.tfstate
+[ -n "$FAIL" ] && { echo '{"@level":"error","@message":"Error: boom","type":"diagnostic"}'; exit 1; }
+echo '{"@level":"info","@message":"Apply complete! Resources: 1 added, 0 changed, 0 destroyed.","type":"apply_complete"}'
+`
+
+// setupFakeApply puts fakeApply on the PATH.
+func setupFakeApply(t *testing.T) {
+	t.Helper()
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "terraform"), []byte(fakeApply), 0700); err != nil { //nolint:gosec
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// reportedClient returns a client that sends the Operation conditions
+// reported on the managed resources to the returned channel.
+func reportedClient() (client.Client, <-chan xpv1.Condition) {
+	reported := make(chan xpv1.Condition, 16)
+	return &test.MockClient{
+		MockGet: test.NewMockGetFn(nil),
+		MockStatusUpdate: func(_ context.Context, obj client.Object, _ ...client.UpdateOption) error {
+			reported <- obj.(xpresource.Managed).GetCondition(TypeOperation)
+			return nil
+		},
+	}, reported
+}
+
+// waitReported returns the first reported condition with the given reason.
+func waitReported(t *testing.T, reported <-chan xpv1.Condition, reason xpv1.ConditionReason) xpv1.Condition {
+	t.Helper()
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case c := <-reported:
+			if c.Reason == reason {
+				return c
+			}
+		case <-timeout:
+			t.Fatalf("no %s operation was reported", reason)
+		}
+	}
+}
+
+func TestOperationsSucceeded(t *testing.T) {
+	kube := &test.MockClient{MockGet: test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{}, ""))}
+	type args struct {
+		typ string
+		err error
+	}
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   []bool
+	}{
+		"ApplySucceeded": {
+			reason: "A succeeded apply should be reported once.",
+			args:   args{typ: operationApply},
+			want:   []bool{true, false},
+		},
+		"ApplyFailed": {
+			reason: "A failed apply should not be reported.",
+			args:   args{typ: operationApply, err: errors.New("boom")},
+			want:   []bool{false, false},
+		},
+		"DestroySucceeded": {
+			reason: "A destroy should not be reported.",
+			args:   args{typ: operationDestroy},
+			want:   []bool{false, false},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			o := NewOperations(kube, logging.NewNopLogger())
+			mg := &v1alpha1.Resource{}
+			mg.SetUID("uid")
+			op := &Operation{Type: tc.args.typ, object: mg}
+			o.running[mg.GetUID()] = op
+			o.finish(mg.GetUID(), t.TempDir(), op, tc.args.err)
+			got := []bool{o.Succeeded(mg), o.Succeeded(mg)}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nSucceeded(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestOperationsStart(t *testing.T) {
+	setupFakeApply(t)
+
+	type want struct {
+		reason    xpv1.ConditionReason
+		message   string
+		succeeded bool
+	}
+	cases := map[string]struct {
+		reason string
+		env    []string
+		want   want
+	}{
+		"Succeeded": {
+			reason: "A succeeded apply should be reported, and its state should be pushed to the backend.",
+			want: want{
+				reason:    ReasonSucceeded,
+				message:   "succeeded",
+				succeeded: true,
+			},
+		},
+		"Failed": {
+			reason: "A failed apply should be reported with the errors in its output, and its state should be pushed to the backend.",
+			env:    []string{"FAIL=true"},
+			want: want{
+				reason:  ReasonFailed,
+				message: "failed: Error: boom",
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			mg := &v1alpha1.Resource{}
+			mg.SetName("example")
+			mg.SetUID(uuid.NewUUID())
+			dir := workspace.Dir(mg)
+			if err := os.MkdirAll(dir, 0700); err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir) //nolint:errcheck
+			kube, reported := reportedClient()
+			b := &memoryBackend{}
+			e := &external{
+				backend:    b,
+				logger:     logging.NewNopLogger(),
+				operations: NewOperations(kube, logging.NewNopLogger()),
+				setup: func(_ context.Context, _ client.Client, _ xpresource.Managed) (terraform.Setup, error) {
+					return terraform.Setup{Env: tc.env}, nil
+				},
+			}
+
+			if err := e.startAsync(context.Background(), mg, "Create", operationApply); err != nil {
+				t.Fatalf("\n%s\nstartAsync(...): %v", tc.reason, err)
+			}
+			if err := e.startAsync(context.Background(), mg, "Update", operationApply); err == nil {
+				t.Errorf("\n%s\nstartAsync(...): started an operation while another one runs", tc.reason)
+			}
+			running := waitReported(t, reported, ReasonApplying)
+			if !strings.Contains(running.Message, "null_resource.example: Creating...") {
+				t.Errorf("\n%s\nfollow(...): reported %q without the last output", tc.reason, running.Message)
+			}
+			saved, err := os.ReadFile(filepath.Join(dir, fileOperation))
+			if err != nil {
+				t.Fatalf("\n%s\nStart(...): did not write the progress of the running operation: %v", tc.reason, err)
+			}
+			op := &Operation{}
+			if err := json.JSParser.Unmarshal(saved, op); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(ReasonApplying, op.Phase); diff != "" {
+				t.Errorf("\n%s\nfollow(...): -want saved phase, +got saved phase:\n%s", tc.reason, diff)
+			}
+			if b.lock == nil || op.StateLock == nil || op.StateLock.ID != b.lock.ID {
+				t.Errorf("\n%s\nStart(...): saved lock %+v, want the held lock %+v", tc.reason, op.StateLock, b.lock)
+			}
+
+			if err := os.WriteFile(filepath.Join(dir, "release"), nil, 0600); err != nil {
+				t.Fatal(err)
+			}
+			ended := waitReported(t, reported, tc.want.reason)
+			if !strings.HasSuffix(ended.Message, tc.want.message) {
+				t.Errorf("\n%s\nfinish(...): reported %q, want it to end with %q", tc.reason, ended.Message, tc.want.message)
+			}
+			if e.operations.Running(mg) != nil {
+				t.Errorf("\n%s\nRunning(...): the operation still runs once it ended", tc.reason)
+			}
+			if diff := cmp.Diff(tc.want.succeeded, e.operations.Succeeded(mg)); diff != "" {
+				t.Errorf("\n%s\nSucceeded(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if _, err := os.Stat(filepath.Join(dir, fileOperation)); !os.IsNotExist(err) {
+				t.Errorf("\n%s\nfinish(...): did not remove the progress of the ended operation: %v", tc.reason, err)
+			}
+			if diff := cmp.Diff(`{"version":4,"serial":2}`, strings.TrimSpace(string(b.state))); diff != "" {
+				t.Errorf("\n%s\nfinish(...): -want pushed state, +got pushed state:\n%s", tc.reason, diff)
+			}
+			if b.lock != nil {
+				t.Errorf("\n%s\nfinish(...): did not unlock the state once the operation ended", tc.reason)
+			}
+			args, err := os.ReadFile(filepath.Join(dir, "args"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff("apply -auto-approve -input=false -json", strings.TrimSpace(string(args))); diff != "" {
+				t.Errorf("\n%s\nStart(...): -want arguments, +got arguments:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestOperationsStartFails(t *testing.T) {
+	// There is no terraform executable to start.
+	t.Setenv("PATH", t.TempDir())
+	mg := &v1alpha1.Resource{}
+	mg.SetName("example")
+	mg.SetUID(uuid.NewUUID())
+	dir := workspace.Dir(mg)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) //nolint:errcheck
+	kube, _ := reportedClient()
+	b := &memoryBackend{}
+	o := NewOperations(kube, logging.NewNopLogger())
+	e := &external{backend: b, logger: logging.NewNopLogger(), operations: o}
+
+	if err := e.startAsync(context.Background(), mg, "Create", operationApply); err == nil {
+		t.Fatal("startAsync(...): started an operation without a terraform executable")
+	}
+	if o.Running(mg) != nil {
+		t.Error("Running(...): an operation that did not start runs")
+	}
+	if _, err := os.Stat(filepath.Join(dir, fileOperation)); !os.IsNotExist(err) {
+		t.Errorf("Start(...): wrote the progress of an operation that did not start: %v", err)
+	}
+	if b.lock != nil {
+		t.Error("startAsync(...): did not unlock the state of an operation that did not start")
+	}
+	interrupted, err := o.Interrupted(mg)
+	if err != nil || interrupted != nil {
+		t.Errorf("Interrupted(...): got %+v, %v, want no interrupted operation", interrupted, err)
+	}
+}
+
+func TestOperationsInterrupted(t *testing.T) {
+	startedAt := metav1.NewTime(time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC))
+	cases := map[string]struct {
+		reason string
+		saved  string
+		want   *Operation
+	}{
+		"NoOperation": {
+			reason: "Nothing was interrupted if no progress was saved.",
+		},
+		"Interrupted": {
+			reason: "The saved progress of the operation should be returned.",
+			saved:  `{"type":"destroy","startedAt":"2022-03-01T10:00:00Z","phase":"Destroying","lastOutput":"null_resource.example: Destroying...","stateLock":{"ID":"lock"}}`,
+			want: &Operation{
+				Type:       operationDestroy,
+				StartedAt:  startedAt,
+				Phase:      ReasonDestroying,
+				LastOutput: "null_resource.example: Destroying...",
+				StateLock:  &backend.LockInfo{ID: "lock"},
+			},
+		},
+		"Damaged": {
+			reason: "A damaged progress file should be reported as an interrupted apply.",
+			saved:  `{"type":"destr`,
+			want:   &Operation{Type: operationApply, Phase: ReasonApplying},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			mg := &v1alpha1.Resource{}
+			mg.SetName("example")
+			mg.SetUID(uuid.NewUUID())
+			dir := workspace.Dir(mg)
+			if err := os.MkdirAll(dir, 0700); err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir) //nolint:errcheck
+			if tc.saved != "" {
+				if err := os.WriteFile(filepath.Join(dir, fileOperation), []byte(tc.saved), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			o := NewOperations(&test.MockClient{}, logging.NewNopLogger())
+
+			got, err := o.Interrupted(mg)
+			if err != nil {
+				t.Fatalf("\n%s\nInterrupted(...): %v", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, got, cmpopts.IgnoreUnexported(Operation{})); diff != "" {
+				t.Errorf("\n%s\nInterrupted(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if again, err := o.Interrupted(mg); err != nil || again != nil {
+				t.Errorf("\n%s\nInterrupted(...): got %+v, %v once the operation was recovered, want nothing", tc.reason, again, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,581 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jet
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
+	xpresource "github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/terrajet/pkg/config"
+	"github.com/crossplane/terrajet/pkg/resource"
+	"github.com/crossplane/terrajet/pkg/terraform"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane-contrib/provider-jet-template/apis/v1alpha1"
+	providerconfig "github.com/crossplane-contrib/provider-jet-template/config"
+	"github.com/crossplane-contrib/provider-jet-template/internal/backend"
+	"github.com/crossplane-contrib/provider-jet-template/internal/clients"
+	"github.com/crossplane-contrib/provider-jet-template/internal/tfstate"
+	"github.com/crossplane-contrib/provider-jet-template/internal/workspace"
+)
+
+const (
+	errExportState = "cannot export workspace state"
+)
+
+// NewConnector returns a new Connector that extends the external clients
+// produced by the given Terrajet connector with the behaviour configured in
+// the given Options. The given client is used to read the ProviderConfigs of
+// the managed resources.
+func NewConnector(kube client.Client, c managed.ExternalConnecter, o Options, cfg *config.Resource) *Connector {
+	return &Connector{
+		ExternalConnecter: c,
+		kube:              kube,
+		logger:            o.Logger,
+		exporter:          o.StateExporter,
+		history:           o.StateHistory,
+		backends:          o.StateBackends,
+		encrypter:         o.Encrypter,
+		limiter:           o.ProcessLimiter,
+		operations:        o.Operations,
+		deferrals:         o.Deferrals,
+		native:            o.NativeProviders,
+		setup:             o.SetupFn,
+		config:            cfg,
+		guard: &replacementGuard{
+			schema:   cfg.TerraformResource,
+			policies: providerconfig.ReplacementPoliciesOf(cfg.Name),
+		},
+	}
+}
+
+// Connector wraps a Terrajet connector.
+type Connector struct {
+	managed.ExternalConnecter
+
+	kube       client.Client
+	logger     logging.Logger
+	exporter   *tfstate.Exporter
+	history    *tfstate.History
+	backends   *backend.Factory
+	encrypter  *workspace.Encrypter
+	limiter    *ProcessLimiter
+	operations *Operations
+	deferrals  *Deferrals
+	native     *NativeProviders
+	setup      terraform.SetupFn
+	config     *config.Resource
+	guard      *replacementGuard
+}
+
+// Connect returns the external client of the wrapped connector decorated
+// with the provider-specific behaviour.
+func (c *Connector) Connect(ctx context.Context, mg xpresource.Managed) (managed.ExternalClient, error) {
+	if err := checkNamespace(mg, c.config); err != nil {
+		return nil, err
+	}
+	if c.native != nil {
+		ec, err := c.connectNative(ctx, mg)
+		if err != nil {
+			return nil, err
+		}
+		return c.decorate(ctx, mg, ec)
+	}
+	if err := workspace.Prepare(mg); err != nil {
+		return nil, err
+	}
+	release, err := c.acquireInit(ctx, mg)
+	if err != nil {
+		return nil, err
+	}
+	ec, err := c.ExternalConnecter.Connect(ctx, mg)
+	release()
+	if err == nil {
+		ec, err = c.decorate(ctx, mg, ec)
+	}
+	if err != nil && c.encrypter != nil {
+		// Terrajet writes the configuration of the workspace even if it
+		// fails to connect.
+		if encErr := c.encrypter.Encrypt(ctx, workspace.Dir(mg)); encErr != nil {
+			c.logger.Info(errEncryptWorkspace, "error", encErr.Error())
+		}
+	}
+	return ec, err
+}
+
+// acquireInit waits for a process slot if the workspace of the given
+// resource has to be initialised while connecting, and returns the function
+// that releases it.
+func (c *Connector) acquireInit(ctx context.Context, mg xpresource.Managed) (func(), error) {
+	if _, err := os.Stat(filepath.Join(workspace.Dir(mg), lockFile)); err == nil {
+		return func() {}, nil
+	}
+	return c.limiter.Acquire(ctx, c.limiter.PriorityOf(mg, "Connect"))
+}
+
+// decorate returns the given external client of the given resource decorated
+// with the provider-specific behaviour.
+func (c *Connector) decorate(ctx context.Context, mg xpresource.Managed, ec managed.ExternalClient) (managed.ExternalClient, error) {
+	spec, err := clients.GetProviderConfigSpec(ctx, c.kube, mg)
+	if err != nil {
+		return nil, err
+	}
+	b, err := c.backends.For(ctx, mg, spec)
+	if err != nil {
+		return nil, err
+	}
+	var deferUntil time.Time
+	if mg.GetAnnotations()[AnnotationKeyBypassMaintenanceWindows] != "true" {
+		if deferUntil, err = nextMaintenanceWindow(spec.MaintenanceWindows, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+	// The native provider runs the operations of async resources
+	// synchronously, since no Terraform process is started for them.
+	async := c.config.UseAsync && c.native == nil
+	e := &external{
+		ExternalClient: ec,
+		useAsync:       async,
+		spec:           spec,
+		deferUntil:     deferUntil,
+		deferrals:      c.deferrals,
+		logger:         c.logger.WithValues("uid", mg.GetUID(), "name", mg.GetName()),
+		exporter:       c.exporter,
+		history:        c.history,
+		backend:        b,
+		config:         c.config,
+		guard:          c.guard,
+		operations:     c.operations,
+		kube:           c.kube,
+		setup:          c.setup,
+	}
+	var decorated managed.ExternalClient = e
+	if c.encrypter != nil {
+		decorated = &encrypted{ExternalClient: e, encrypter: c.encrypter, async: async, operations: c.operations, logger: e.logger}
+	}
+	if c.limiter == nil {
+		return decorated, nil
+	}
+	// The process slot is acquired first, so that it covers the decryption
+	// and the state sync as well.
+	return &limited{ExternalClient: decorated, limiter: c.limiter, operations: c.operations, async: e.async()}, nil
+}
+
+type external struct {
+	managed.ExternalClient
+
+	spec     *v1alpha1.ProviderConfigSpec
+	logger   logging.Logger
+	exporter *tfstate.Exporter
+	history  *tfstate.History
+	backend  backend.Backend
+	config   *config.Resource
+	guard    *replacementGuard
+	kube     client.Client
+	setup    terraform.SetupFn
+
+	// useAsync is whether the operations of the resource run
+	// asynchronously.
+	useAsync bool
+	// operations runs the async operations if the resource uses them.
+	operations *Operations
+
+	// blocked are the changes found by Observe that the replacement
+	// policies of the resource do not allow to be applied.
+	blocked []string
+	// pending is the plan found by Observe that waits for approval.
+	pending *plan
+	// approved is the plan found by Observe that was approved. It's applied
+	// instead of planning the changes again.
+	approved *plan
+	// deferUntil is the start of the next maintenance window if the
+	// changes have to be deferred to it.
+	deferUntil time.Time
+	// deferrals requeues the reconcile of the resource when the window
+	// starts.
+	deferrals *Deferrals
+}
+
+func (e *external) Observe(ctx context.Context, mg xpresource.Managed) (managed.ExternalObservation, error) {
+	running, err := e.operation(mg)
+	if err != nil {
+		return managed.ExternalObservation{}, err
+	}
+	if running {
+		// The workspace must not be touched while its operation runs.
+		return managed.ExternalObservation{ResourceExists: true, ResourceUpToDate: true}, nil
+	}
+	var o managed.ExternalObservation
+	err = e.syncState(ctx, mg, "Observe", func() error {
+		var err error
+		o, err = e.ExternalClient.Observe(ctx, mg)
+		return err
+	})
+	if err != nil {
+		return o, err
+	}
+	if err := e.deleteState(ctx, mg, o); err != nil {
+		return o, err
+	}
+	if rollingBack(mg) {
+		return e.rollback(ctx, mg, o)
+	}
+	switch {
+	case meta.WasDeleted(mg) && o.ResourceExists:
+		err = e.checkApproval(ctx, mg, true)
+	case o.ResourceExists && !o.ResourceUpToDate:
+		err = e.checkChanges(ctx, mg)
+	}
+	if err != nil {
+		return o, err
+	}
+	e.observed(ctx, mg, o)
+	return o, nil
+}
+
+// observed reports the given observation of the given resource in its
+// conditions, and exports its state and takes a snapshot of it once an async
+// apply is observed to have succeeded.
+func (e *external) observed(ctx context.Context, mg xpresource.Managed, o managed.ExternalObservation) {
+	e.reportChanges(mg)
+	if settled(mg, o) {
+		e.clearDeferral(mg)
+	}
+	if e.useAsync && o.ResourceExists && o.ResourceUpToDate && e.applied(mg) {
+		e.exportState(ctx, mg)
+		e.snapshotState(ctx, mg)
+	}
+}
+
+// applied returns whether an async apply of the given resource succeeded
+// since it was last observed. Terrajet does not tell when its own async
+// operations finish, so the resources whose operations it runs are assumed
+// to have been applied whenever they are observed to be up-to-date.
+func (e *external) applied(mg xpresource.Managed) bool {
+	if !e.async() {
+		return true
+	}
+	return e.operations.Succeeded(mg)
+}
+
+func (e *external) Create(ctx context.Context, mg xpresource.Managed) (managed.ExternalCreation, error) {
+	if e.deferred(mg) {
+		return managed.ExternalCreation{}, nil
+	}
+	if e.async() {
+		return managed.ExternalCreation{}, e.startAsync(ctx, mg, "Create", operationApply)
+	}
+	var c managed.ExternalCreation
+	err := e.syncState(ctx, mg, "Create", func() error {
+		var err error
+		c, err = e.ExternalClient.Create(ctx, mg)
+		return err
+	})
+	if err == nil && !e.useAsync {
+		e.exportState(ctx, mg)
+		e.snapshotState(ctx, mg)
+	}
+	return c, err
+}
+
+func (e *external) Update(ctx context.Context, mg xpresource.Managed) (managed.ExternalUpdate, error) {
+	if len(e.blocked) > 0 {
+		return managed.ExternalUpdate{}, errors.Errorf(errFmtReplacementBlocked, strings.Join(e.blocked, "; "))
+	}
+	if e.pending != nil {
+		return managed.ExternalUpdate{}, errors.Errorf(errFmtWaitingForApproval, e.pending.hash)
+	}
+	if e.deferred(mg) {
+		return managed.ExternalUpdate{}, nil
+	}
+	if e.async() {
+		return managed.ExternalUpdate{}, e.startAsync(ctx, mg, "Update", operationApply)
+	}
+	var u managed.ExternalUpdate
+	err := e.syncState(ctx, mg, "Update", func() error {
+		if e.approved != nil {
+			return e.applyApproved(ctx, mg)
+		}
+		var err error
+		u, err = e.ExternalClient.Update(ctx, mg)
+		return err
+	})
+	if err == nil && !e.useAsync {
+		e.exportState(ctx, mg)
+		e.snapshotState(ctx, mg)
+	}
+	return u, err
+}
+
+func (e *external) Delete(ctx context.Context, mg xpresource.Managed) error {
+	if e.pending != nil {
+		return errors.Errorf(errFmtWaitingForApproval, e.pending.hash)
+	}
+	if e.deferred(mg) {
+		return nil
+	}
+	if e.async() {
+		return e.startAsync(ctx, mg, "Delete", operationDestroy)
+	}
+	return e.syncState(ctx, mg, "Delete", func() error {
+		if e.approved != nil {
+			return e.applyApproved(ctx, mg)
+		}
+		return e.ExternalClient.Delete(ctx, mg)
+	})
+}
+
+// async returns whether the operations of the resource run asynchronously
+// with the progress reported in its conditions.
+func (e *external) async() bool {
+	return e.useAsync && e.operations != nil
+}
+
+// startAsync starts the given type of async operation for the given
+// operation on the given resource. The operation holds the lock of the state
+// of the resource in the state backend until it finishes. A destroy is not
+// started again while one is running.
+func (e *external) startAsync(ctx context.Context, mg xpresource.Managed, operation, typ string) error {
+	// Neither the setup nor the state of the workspace is touched while an
+	// operation runs.
+	if op := e.operations.Running(mg); op != nil {
+		if op.Type == operationDestroy && typ == operationDestroy {
+			return nil
+		}
+		return errors.Errorf(errFmtOperationRunning, op.Type, op.StartedAt.UTC().Format(time.RFC3339))
+	}
+	env, err := e.terraformEnv(ctx, mg)
+	if err != nil {
+		return err
+	}
+	l, err := e.lockState(ctx, mg, operation)
+	if err != nil {
+		return err
+	}
+	if err := e.operations.Start(mg, typ, e.planFile(), env, l); err != nil {
+		return l.release(ctx, err)
+	}
+	return nil
+}
+
+// terraformEnv returns the environment variables of the Terraform setup of
+// the given resource, with which Terrajet runs the Terraform CLI as well.
+func (e *external) terraformEnv(ctx context.Context, mg xpresource.Managed) ([]string, error) {
+	if e.setup == nil {
+		return nil, nil
+	}
+	ts, err := e.setup(ctx, e.kube, mg)
+	return ts.Env, errors.Wrap(err, errGetSetup)
+}
+
+// operation reports the progress of the async operation of the given
+// resource in its conditions, or that its last operation was interrupted by
+// a restart of the provider, and returns whether an operation is running.
+// The interrupted operations are run again by the reconciles that follow.
+func (e *external) operation(mg xpresource.Managed) (bool, error) {
+	if !e.async() {
+		return false, nil
+	}
+	if op := e.operations.Running(mg); op != nil {
+		mg.SetConditions(OperationRunning(op))
+		return true, nil
+	}
+	op, err := e.operations.Interrupted(mg)
+	if err != nil || op == nil {
+		return false, err
+	}
+	e.unlockStale(mg, op.StateLock)
+	mg.SetConditions(OperationInterrupted(op))
+	return false, nil
+}
+
+// deferred returns whether the changes of the given resource have to be
+// deferred to the next maintenance window. The deferral is reported in the
+// conditions of the resource, and its reconcile is requeued when the window
+// starts.
+func (e *external) deferred(mg xpresource.Managed) bool {
+	if e.deferUntil.IsZero() {
+		e.clearDeferral(mg)
+		return false
+	}
+	mg.SetConditions(Deferred(e.deferUntil))
+	e.deferrals.deferTo(mg.GetUID(), e.deferUntil)
+	return true
+}
+
+// clearDeferral clears the deferral reported before in the conditions of
+// the given resource.
+func (e *external) clearDeferral(mg xpresource.Managed) {
+	if mg.GetCondition(TypeDeferred).Status == corev1.ConditionTrue {
+		mg.SetConditions(NotDeferred())
+	}
+}
+
+// settled returns whether the given observation of the given resource leaves
+// nothing to apply.
+func settled(mg xpresource.Managed, o managed.ExternalObservation) bool {
+	if meta.WasDeleted(mg) {
+		return !o.ResourceExists
+	}
+	return o.ResourceExists && o.ResourceUpToDate
+}
+
+// checkChanges finds the changes of the given resource that are blocked by
+// its replacement policies, or else the plan of its changes that has to be
+// approved before it's applied.
+func (e *external) checkChanges(ctx context.Context, mg xpresource.Managed) error {
+	var err error
+	if e.blocked, err = e.blockedChanges(ctx, mg); err != nil || len(e.blocked) > 0 {
+		return err
+	}
+	return e.checkApproval(ctx, mg, false)
+}
+
+// checkApproval plans the changes of the given resource, or its destruction
+// if destroy is true, and finds whether the plan waits for approval or was
+// approved. The approved plan is kept to be applied by Update or Delete.
+func (e *external) checkApproval(ctx context.Context, mg xpresource.Managed, destroy bool) error {
+	if e.spec.ApprovalMode == "" || e.spec.ApprovalMode == v1alpha1.ApprovalModeNone {
+		return nil
+	}
+	p, err := e.plan(ctx, mg, destroy)
+	if err != nil {
+		return err
+	}
+	switch {
+	case !p.requiresApproval(e.spec.ApprovalMode):
+	case mg.GetAnnotations()[AnnotationKeyApprovePlan] == p.hash:
+		e.approved = p
+		return nil
+	default:
+		e.pending = p
+	}
+	return discardPlan(workspace.Dir(mg))
+}
+
+// reportChanges reports the blocked changes and the pending plan found by
+// Observe in the conditions of the given resource, and clears the
+// conditions that were reported before.
+func (e *external) reportChanges(mg xpresource.Managed) {
+	switch {
+	case len(e.blocked) > 0:
+		mg.SetConditions(ReplacementBlocked(e.blocked))
+	case mg.GetCondition(TypeReplacement).Status == corev1.ConditionTrue:
+		mg.SetConditions(NoReplacementBlocked())
+	}
+	switch {
+	case e.pending != nil:
+		mg.SetConditions(WaitingForApproval(e.pending))
+	case mg.GetCondition(TypePlanApproval).Status == corev1.ConditionTrue:
+		mg.SetConditions(NoPendingPlan())
+	}
+}
+
+// blockedChanges returns the changes of the given resource that would
+// replace its external resource but are not allowed by its replacement
+// policies.
+func (e *external) blockedChanges(ctx context.Context, mg xpresource.Managed) ([]string, error) {
+	tr, ok := mg.(resource.Terraformed)
+	if !ok {
+		return nil, nil
+	}
+	params, err := tr.GetParameters()
+	if err != nil {
+		return nil, errors.Wrap(err, errGetParameters)
+	}
+	return e.guard.blocked(mg, params, workspace.Dir(mg), func() (bool, error) {
+		p, err := e.plan(ctx, mg, false)
+		if err != nil {
+			return false, err
+		}
+		return p.replace, discardPlan(workspace.Dir(mg))
+	})
+}
+
+// planner plans the changes of managed resources without applying them, and
+// applies the plans that were approved.
+type planner interface {
+	planChanges(ctx context.Context, mg xpresource.Managed, destroy bool) (*plan, error)
+	applyPlan(ctx context.Context, mg xpresource.Managed, p *plan) error
+}
+
+// plan plans the changes of the given resource, or its destruction if
+// destroy is true, with the native provider if it's used, or else with the
+// Terraform CLI.
+func (e *external) plan(ctx context.Context, mg xpresource.Managed, destroy bool) (*plan, error) {
+	if p, ok := e.ExternalClient.(planner); ok {
+		return p.planChanges(ctx, mg, destroy)
+	}
+	env, err := e.terraformEnv(ctx, mg)
+	if err != nil {
+		return nil, err
+	}
+	return planChanges(ctx, workspace.Dir(mg), env, destroy)
+}
+
+// applyApproved applies the approved plan of the given resource with the
+// native provider if it's used, or else applies the plan saved by the
+// Terraform CLI.
+func (e *external) applyApproved(ctx context.Context, mg xpresource.Managed) error {
+	if p, ok := e.ExternalClient.(planner); ok {
+		return p.applyPlan(ctx, mg, e.approved)
+	}
+	defer discardPlan(workspace.Dir(mg)) //nolint:errcheck
+	env, err := e.terraformEnv(ctx, mg)
+	if err != nil {
+		return err
+	}
+	return applyPlan(ctx, workspace.Dir(mg), env)
+}
+
+// planFile returns the name of the saved plan that the async operation of
+// the resource applies, which is empty unless a plan was approved.
+func (e *external) planFile() string {
+	if e.approved == nil {
+		return ""
+	}
+	return workspace.FilePlan
+}
+
+// exportState exports the workspace state of the given resource if it's
+// enabled. Failures are only logged because returning an error after a
+// successful apply would prevent the critical annotations from being stored.
+func (e *external) exportState(ctx context.Context, mg xpresource.Managed) {
+	if e.exporter == nil || !e.exporter.Enabled(mg) {
+		return
+	}
+	tr, ok := mg.(resource.Terraformed)
+	if !ok {
+		return
+	}
+	var block map[string]interface{}
+	if e.backend != nil {
+		block = e.backend.Block(string(mg.GetUID()))
+	}
+	if err := e.exporter.Export(ctx, tr, e.config, workspace.Dir(mg), block); err != nil {
+		e.logger.Info(errExportState, "error", err.Error())
+	}
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jet
+
+import (
+	"context"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
+	xpresource "github.com/crossplane/crossplane-runtime/pkg/resource"
+
+	"github.com/crossplane-contrib/provider-jet-template/internal/workspace"
+)
+
+const (
+	errEncryptWorkspace = "cannot encrypt the workspace"
+)
+
+// encrypted is an external client that keeps the files of the workspaces
+// decrypted only while its operations run. Async operations keep running
+// after they return, so their files are encrypted by the first observation
+// after they finish. The files are not touched at all while an async
+// operation runs, since Terraform writes them meanwhile.
+type encrypted struct {
+	managed.ExternalClient
+
+	encrypter  *workspace.Encrypter
+	async      bool
+	operations *Operations
+	logger     logging.Logger
+}
+
+func (e *encrypted) Observe(ctx context.Context, mg xpresource.Managed) (managed.ExternalObservation, error) {
+	if e.running(mg) {
+		return e.ExternalClient.Observe(ctx, mg)
+	}
+	if err := e.encrypter.Decrypt(ctx, workspace.Dir(mg)); err != nil {
+		return managed.ExternalObservation{}, err
+	}
+	o, err := e.ExternalClient.Observe(ctx, mg)
+	if encErr := e.encrypter.Encrypt(ctx, workspace.Dir(mg)); encErr != nil && err == nil {
+		return o, encErr
+	}
+	return o, err
+}
+
+func (e *encrypted) Create(ctx context.Context, mg xpresource.Managed) (managed.ExternalCreation, error) {
+	if e.running(mg) {
+		return e.ExternalClient.Create(ctx, mg)
+	}
+	if err := e.encrypter.Decrypt(ctx, workspace.Dir(mg)); err != nil {
+		return managed.ExternalCreation{}, err
+	}
+	c, err := e.ExternalClient.Create(ctx, mg)
+	e.encrypt(ctx, mg)
+	return c, err
+}
+
+func (e *encrypted) Update(ctx context.Context, mg xpresource.Managed) (managed.ExternalUpdate, error) {
+	if e.running(mg) {
+		return e.ExternalClient.Update(ctx, mg)
+	}
+	if err := e.encrypter.Decrypt(ctx, workspace.Dir(mg)); err != nil {
+		return managed.ExternalUpdate{}, err
+	}
+	u, err := e.ExternalClient.Update(ctx, mg)
+	e.encrypt(ctx, mg)
+	return u, err
+}
+
+func (e *encrypted) Delete(ctx context.Context, mg xpresource.Managed) error {
+	if e.running(mg) {
+		return e.ExternalClient.Delete(ctx, mg)
+	}
+	if err := e.encrypter.Decrypt(ctx, workspace.Dir(mg)); err != nil {
+		return err
+	}
+	err := e.ExternalClient.Delete(ctx, mg)
+	e.encrypt(ctx, mg)
+	return err
+}
+
+// running returns whether an async operation of the given resource runs.
+func (e *encrypted) running(mg xpresource.Managed) bool {
+	return e.operations != nil && e.operations.Running(mg) != nil
+}
+
+// encrypt encrypts the workspace of the given resource after a synchronous
+// operation. Failures are only logged because returning an error after a
+// successful apply would prevent the critical annotations from being stored,
+// and the workspace is encrypted again by the next observation.
+func (e *encrypted) encrypt(ctx context.Context, mg xpresource.Managed) {
+	if e.async {
+		return
+	}
+	if err := e.encrypter.Encrypt(ctx, workspace.Dir(mg)); err != nil {
+		e.logger.Info(errEncryptWorkspace, "error", err.Error())
+	}
+}
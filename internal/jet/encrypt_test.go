@@ -0,0 +1,86 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jet
+
+import (
+	"context"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
+	xpresource "github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/crossplane-contrib/provider-jet-template/apis/null/v1alpha1"
+	"github.com/crossplane-contrib/provider-jet-template/internal/workspace"
+)
+
+func TestEncryptedRunningOperation(t *testing.T) {
+	mg := &v1alpha1.Resource{}
+	mg.SetUID("uid")
+	kube := &test.MockClient{MockGet: test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{}, ""))}
+	o := NewOperations(kube, logging.NewNopLogger())
+	o.running[mg.GetUID()] = &Operation{Type: operationApply, object: mg}
+
+	calls := 0
+	e := &encrypted{
+		ExternalClient: managed.ExternalClientFns{
+			ObserveFn: func(context.Context, xpresource.Managed) (managed.ExternalObservation, error) {
+				calls++
+				return managed.ExternalObservation{}, nil
+			},
+			CreateFn: func(context.Context, xpresource.Managed) (managed.ExternalCreation, error) {
+				calls++
+				return managed.ExternalCreation{}, nil
+			},
+			UpdateFn: func(context.Context, xpresource.Managed) (managed.ExternalUpdate, error) {
+				calls++
+				return managed.ExternalUpdate{}, nil
+			},
+			DeleteFn: func(context.Context, xpresource.Managed) error {
+				calls++
+				return nil
+			},
+		},
+		encrypter: workspace.NewEncrypter(workspace.KeySourceFn(func(context.Context) (*workspace.KeyRing, error) {
+			t.Error("the workspace was decrypted or encrypted while its async operation runs")
+			return nil, errors.New("unexpected")
+		})),
+		async:      true,
+		operations: o,
+		logger:     logging.NewNopLogger(),
+	}
+	ctx := context.Background()
+	if _, err := e.Observe(ctx, mg); err != nil {
+		t.Errorf("Observe(...): %s", err)
+	}
+	if _, err := e.Create(ctx, mg); err != nil {
+		t.Errorf("Create(...): %s", err)
+	}
+	if _, err := e.Update(ctx, mg); err != nil {
+		t.Errorf("Update(...): %s", err)
+	}
+	if err := e.Delete(ctx, mg); err != nil {
+		t.Errorf("Delete(...): %s", err)
+	}
+	if calls != 4 {
+		t.Errorf("encrypted: got %d calls of the wrapped client, want 4", calls)
+	}
+}
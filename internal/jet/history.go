@@ -0,0 +1,111 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jet
+
+import (
+	"context"
+
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
+	xpresource "github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/terrajet/pkg/resource"
+	"github.com/pkg/errors"
+
+	"github.com/crossplane-contrib/provider-jet-template/internal/tfstate"
+	"github.com/crossplane-contrib/provider-jet-template/internal/workspace"
+)
+
+const (
+	errSnapshotState    = "cannot take a snapshot of the workspace state"
+	errNoStateHistory   = "cannot roll back, the state history is not enabled"
+	errFmtRollback      = "cannot roll back to state snapshot %s"
+	errFmtSetParameters = "cannot set the parameters of state snapshot %s"
+)
+
+// snapshotState takes a snapshot of the workspace state of the given
+// resource if the state history is enabled by its ProviderConfig. Failures
+// are only logged because returning an error after a successful apply would
+// prevent the critical annotations from being stored.
+func (e *external) snapshotState(ctx context.Context, mg xpresource.Managed) {
+	if e.history == nil || e.spec.StateHistoryLimit == nil || *e.spec.StateHistoryLimit == 0 {
+		return
+	}
+	tr, ok := mg.(resource.Terraformed)
+	if !ok {
+		return
+	}
+	if err := e.history.Record(ctx, tr, e.config, workspace.Dir(mg), *e.spec.StateHistoryLimit); err != nil {
+		e.logger.Info(errSnapshotState, "error", err.Error())
+	}
+}
+
+// rollingBack returns whether the given resource has to be rolled back to
+// one of its state snapshots.
+func rollingBack(mg xpresource.Managed) bool {
+	return mg.GetAnnotations()[tfstate.AnnotationKeyRollbackTo] != "" && !meta.WasDeleted(mg)
+}
+
+// rollback restores the parameters of the given resource from the state
+// snapshot given by its rollback annotation, and removes the annotation.
+// The workspace was configured with the parameters before the rollback, so
+// the resource is reported as up-to-date and late-initialized for the
+// reconciler to store the restored parameters, which are applied by the
+// next reconcile.
+func (e *external) rollback(ctx context.Context, mg xpresource.Managed, o managed.ExternalObservation) (managed.ExternalObservation, error) {
+	id := mg.GetAnnotations()[tfstate.AnnotationKeyRollbackTo]
+	tr, ok := mg.(resource.Terraformed)
+	if !ok {
+		return o, nil
+	}
+	if e.history == nil {
+		return o, errors.New(errNoStateHistory)
+	}
+	s, err := e.history.Get(ctx, mg, id)
+	if err != nil {
+		return o, errors.Wrapf(err, errFmtRollback, id)
+	}
+	current, err := tr.GetParameters()
+	if err != nil {
+		return o, errors.Wrap(err, errGetParameters)
+	}
+	params, err := s.Parameters(e.config, current)
+	if err != nil {
+		return o, errors.Wrapf(err, errFmtRollback, id)
+	}
+	// Setting the parameters merges maps into the existing ones, so all the
+	// parameters are reset first, including the ones that the snapshot
+	// does not restore.
+	reset := make(map[string]interface{}, len(current)+len(params))
+	for k := range current {
+		reset[k] = nil
+	}
+	for k := range params {
+		reset[k] = nil
+	}
+	if err := tr.SetParameters(reset); err != nil {
+		return o, errors.Wrapf(err, errFmtSetParameters, id)
+	}
+	if err := tr.SetParameters(params); err != nil {
+		return o, errors.Wrapf(err, errFmtSetParameters, id)
+	}
+	meta.RemoveAnnotations(mg, tfstate.AnnotationKeyRollbackTo)
+	e.logger.Debug("Rolled back to state snapshot", "snapshot", id, "generation", s.Generation)
+	o.ResourceExists = true
+	o.ResourceUpToDate = true
+	o.ResourceLateInitialized = true
+	return o, nil
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jet
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	xpresource "github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/terrajet/pkg/terraform"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsimple"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane-contrib/provider-jet-template/internal/clients"
+	"github.com/crossplane-contrib/provider-jet-template/internal/workspace"
+)
+
+const (
+	lockFile = ".terraform.lock.hcl"
+
+	errReadLock   = "cannot read the dependency lock file of the workspace"
+	errRemoveLock = "cannot remove the dependency lock file of the workspace"
+)
+
+type dependencyLocks struct {
+	Providers []struct {
+		Address string   `hcl:"address,label"`
+		Version string   `hcl:"version"`
+		Remain  hcl.Body `hcl:",remain"`
+	} `hcl:"provider,block"`
+}
+
+// ReinitOnVersionChange returns a terraform.SetupFn that removes the
+// dependency lock file of the workspace of a managed resource if it locks
+// a provider version other than the one returned by the given function.
+// The workspace store initialises the workspaces without a lock file again,
+// so they pick up the provider versions pinned by their ProviderConfigs.
+func ReinitOnVersionChange(fn terraform.SetupFn) terraform.SetupFn {
+	return func(ctx context.Context, client client.Client, mg xpresource.Managed) (terraform.Setup, error) {
+		ps, err := fn(ctx, client, mg)
+		if err != nil {
+			return ps, err
+		}
+		return ps, reinit(filepath.Join(workspace.Dir(mg), lockFile), ps.Requirement)
+	}
+}
+
+func reinit(path string, req terraform.ProviderRequirement) error {
+	src, err := os.ReadFile(filepath.Clean(path))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, errReadLock)
+	}
+	locks := &dependencyLocks{}
+	if err := hclsimple.Decode(lockFile, src, nil, locks); err != nil {
+		return errors.Wrap(err, errReadLock)
+	}
+	addr := clients.ProviderAddress(req.Source)
+	for _, p := range locks.Providers {
+		if p.Address == addr && p.Version == req.Version {
+			return nil
+		}
+	}
+	return errors.Wrap(os.Remove(path), errRemoveLock)
+}
+
+// InitFromSharedWorkspace returns a terraform.SetupFn that initialises the
+// workspace of a managed resource from the shared workspace of its provider
+// requirement with the given Initializer, so that the workspace store does
+// not run terraform init for it. The plugin cache of the Initializer is added
+// to the environment of the setup, with which Terraform is run.
+func InitFromSharedWorkspace(fn terraform.SetupFn, i *workspace.Initializer) terraform.SetupFn {
+	return func(ctx context.Context, client client.Client, mg xpresource.Managed) (terraform.Setup, error) {
+		ps, err := fn(ctx, client, mg)
+		if err != nil {
+			return ps, err
+		}
+		ps.Env = append(ps.Env, i.Env()...)
+		return ps, i.Prepare(ctx, workspace.Dir(mg), ps.Requirement, ps.Env)
+	}
+}
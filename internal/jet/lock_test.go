@@ -0,0 +1,100 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jet
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/crossplane/terrajet/pkg/terraform"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReinit(t *testing.T) {
+	lock := func(address, version string) string {
+		return `provider "` + address + `" {
+  version     = "` + version + `"
+  constraints = "` + version + `"
+  hashes = [
+    "h1:abc=",
+  ]
+}
+`
+	}
+	type want struct {
+		kept bool
+		err  bool
+	}
+	cases := map[string]struct {
+		reason string
+		lock   string
+		req    terraform.ProviderRequirement
+		want   want
+	}{
+		"NoLockFile": {
+			reason: "A workspace without a lock file should be left alone.",
+			req:    terraform.ProviderRequirement{Source: "hashicorp/null", Version: "3.1.1"},
+		},
+		"SameVersion": {
+			reason: "The lock file should be kept if it locks the required version.",
+			lock:   lock("registry.terraform.io/hashicorp/null", "3.1.1"),
+			req:    terraform.ProviderRequirement{Source: "hashicorp/null", Version: "3.1.1"},
+			want:   want{kept: true},
+		},
+		"SameVersionWithHostname": {
+			reason: "The lock file should be kept if it locks the required version of a source with a hostname.",
+			lock:   lock("registry.terraform.io/hashicorp/null", "3.1.1"),
+			req:    terraform.ProviderRequirement{Source: "registry.terraform.io/hashicorp/null", Version: "3.1.1"},
+			want:   want{kept: true},
+		},
+		"OtherVersion": {
+			reason: "The lock file should be removed if it locks another version.",
+			lock:   lock("registry.terraform.io/hashicorp/null", "3.1.0"),
+			req:    terraform.ProviderRequirement{Source: "hashicorp/null", Version: "3.1.1"},
+		},
+		"OtherProvider": {
+			reason: "The lock file should be removed if it does not lock the required provider.",
+			lock:   lock("registry.terraform.io/hashicorp/random", "3.1.1"),
+			req:    terraform.ProviderRequirement{Source: "hashicorp/null", Version: "3.1.1"},
+		},
+		"InvalidLockFile": {
+			reason: "An error should be returned if the lock file cannot be decoded.",
+			lock:   `provider {`,
+			req:    terraform.ProviderRequirement{Source: "hashicorp/null", Version: "3.1.1"},
+			want:   want{kept: true, err: true},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), lockFile)
+			if tc.lock != "" {
+				if err := os.WriteFile(path, []byte(tc.lock), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := reinit(path, tc.req)
+			if diff := cmp.Diff(tc.want.err, err != nil); diff != "" {
+				t.Errorf("\n%s\nreinit(...): -want error, +got error:\n%s\n%v", tc.reason, diff, err)
+			}
+			_, statErr := os.Stat(path)
+			if diff := cmp.Diff(tc.want.kept, statErr == nil); diff != "" {
+				t.Errorf("\n%s\nreinit(...): -want kept, +got kept:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
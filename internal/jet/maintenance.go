@@ -0,0 +1,248 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jet
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+	// The time zone database is embedded since the provider image may not
+	// have one.
+	_ "time/tzdata"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	xpresource "github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane-contrib/provider-jet-template/apis/v1alpha1"
+)
+
+const (
+	// AnnotationKeyBypassMaintenanceWindows is the annotation that lets the
+	// changes of a managed resource be applied outside of the maintenance
+	// windows of its ProviderConfig when set to "true", e.g. in emergencies.
+	AnnotationKeyBypassMaintenanceWindows = "template.jet.crossplane.io/bypass-maintenance-windows"
+
+	// TypeDeferred is the condition that reports whether the changes of a
+	// managed resource are deferred to the next maintenance window.
+	TypeDeferred xpv1.ConditionType = "Deferred"
+
+	// ReasonOutsideMaintenanceWindow means that the changes are deferred.
+	ReasonOutsideMaintenanceWindow xpv1.ConditionReason = "OutsideMaintenanceWindow"
+	// ReasonNotDeferred means that the changes are not deferred anymore.
+	ReasonNotDeferred xpv1.ConditionReason = "NotDeferred"
+
+	week = 7 * 24 * time.Hour
+
+	errFmtStart             = "cannot parse start %q of maintenance window, must be in HH:MM format"
+	errFmtTimeZone          = "cannot load time zone %q of maintenance window"
+	errFmtDuration          = "duration %s of maintenance window must be positive and not exceed a week"
+	errFmtWeekday           = "unknown weekday %q of maintenance window"
+	errNoMaintenanceWindows = "no maintenance window starts within a week"
+)
+
+// Deferred returns a condition that indicates that the changes of a managed
+// resource are deferred to the maintenance window starting at the given
+// time.
+func Deferred(next time.Time) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeDeferred,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonOutsideMaintenanceWindow,
+		Message: fmt.Sprintf("Changes are deferred to the next maintenance window starting at %s. Annotate the resource with %s: \"true\" to apply them now.",
+			next.UTC().Format(time.RFC3339), AnnotationKeyBypassMaintenanceWindows),
+	}
+}
+
+// NotDeferred returns a condition that indicates that the changes of a
+// managed resource are not deferred anymore.
+func NotDeferred() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeDeferred,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonNotDeferred,
+	}
+}
+
+// Deferrals requeues the reconciles of the managed resources whose changes
+// are deferred to the next maintenance window when the window starts, rather
+// than retrying them with backoff. It's shared by all controllers.
+type Deferrals struct {
+	mu    sync.Mutex
+	until map[types.UID]time.Time
+}
+
+// NewDeferrals returns a new Deferrals.
+func NewDeferrals() *Deferrals {
+	return &Deferrals{until: map[types.UID]time.Time{}}
+}
+
+// Reconciler returns a reconciler that runs the given reconciler for the
+// given kind of managed resources, and requeues the reconciles that deferred
+// the changes of their resource when the next maintenance window starts.
+// The resources are reconciled before if they change, e.g. when they are
+// annotated to bypass the maintenance windows. The given reconciler is
+// returned as is if the Deferrals is nil.
+func (d *Deferrals) Reconciler(kube client.Client, of xpresource.ManagedKind, r reconcile.Reconciler) reconcile.Reconciler {
+	if d == nil {
+		return r
+	}
+	return &deferredReconciler{deferrals: d, kube: kube, of: of, inner: r}
+}
+
+// deferTo records that the changes of the managed resource with the given
+// UID are deferred to the given time. It's a no-op if the Deferrals is nil.
+func (d *Deferrals) deferTo(uid types.UID, t time.Time) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.until[uid] = t
+}
+
+// take returns the time the changes of the managed resource with the given
+// UID were deferred to, if they were, and forgets it.
+func (d *Deferrals) take(uid types.UID) (time.Time, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	t, ok := d.until[uid]
+	delete(d.until, uid)
+	return t, ok
+}
+
+type deferredReconciler struct {
+	deferrals *Deferrals
+	kube      client.Client
+	of        xpresource.ManagedKind
+	inner     reconcile.Reconciler
+}
+
+func (r *deferredReconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	obj, err := r.kube.Scheme().New(schema.GroupVersionKind(r.of))
+	if err != nil {
+		return r.inner.Reconcile(ctx, req)
+	}
+	mg, ok := obj.(xpresource.Managed)
+	if !ok || r.kube.Get(ctx, req.NamespacedName, mg) != nil {
+		// The managed reconciler reports the missing resources.
+		return r.inner.Reconcile(ctx, req)
+	}
+	res, err := r.inner.Reconcile(ctx, req)
+	until, deferred := r.deferrals.take(mg.GetUID())
+	if err != nil || !deferred {
+		return res, err
+	}
+	if after := time.Until(until); after > 0 {
+		return reconcile.Result{RequeueAfter: after}, nil
+	}
+	return res, nil
+}
+
+var weekdays = map[v1alpha1.Weekday]time.Weekday{
+	"Sunday":    time.Sunday,
+	"Monday":    time.Monday,
+	"Tuesday":   time.Tuesday,
+	"Wednesday": time.Wednesday,
+	"Thursday":  time.Thursday,
+	"Friday":    time.Friday,
+	"Saturday":  time.Saturday,
+}
+
+// nextMaintenanceWindow returns the zero time if one of the given windows is
+// open at the given time, or the start of the next window otherwise. The zero
+// time is returned as well if there's no window.
+func nextMaintenanceWindow(windows []v1alpha1.MaintenanceWindow, now time.Time) (time.Time, error) {
+	var next time.Time
+	for _, mw := range windows {
+		w, err := parseWindow(mw)
+		if err != nil {
+			return time.Time{}, err
+		}
+		open, start := w.next(now)
+		if open {
+			return time.Time{}, nil
+		}
+		if next.IsZero() || start.Before(next) {
+			next = start
+		}
+	}
+	if len(windows) > 0 && next.IsZero() {
+		return time.Time{}, errors.New(errNoMaintenanceWindows)
+	}
+	return next, nil
+}
+
+// window is a parsed maintenance window.
+type window struct {
+	loc      *time.Location
+	hour     int
+	minute   int
+	duration time.Duration
+	days     map[time.Weekday]bool
+}
+
+func parseWindow(mw v1alpha1.MaintenanceWindow) (*window, error) {
+	w := &window{duration: mw.Duration.Duration, days: map[time.Weekday]bool{}}
+	var err error
+	if w.loc, err = time.LoadLocation(mw.TimeZone); err != nil {
+		return nil, errors.Wrapf(err, errFmtTimeZone, mw.TimeZone)
+	}
+	if _, err := fmt.Sscanf(mw.Start, "%d:%d", &w.hour, &w.minute); err != nil {
+		return nil, errors.Wrapf(err, errFmtStart, mw.Start)
+	}
+	if w.duration <= 0 || w.duration > week {
+		return nil, errors.Errorf(errFmtDuration, w.duration)
+	}
+	for _, d := range mw.Days {
+		wd, ok := weekdays[d]
+		if !ok {
+			return nil, errors.Errorf(errFmtWeekday, d)
+		}
+		w.days[wd] = true
+	}
+	return w, nil
+}
+
+// next returns whether the window is open at the given time, and its next
+// start otherwise.
+func (w *window) next(now time.Time) (bool, time.Time) {
+	local := now.In(w.loc)
+	// A window that started up to a week ago may still be open.
+	for offset := -7; offset <= 7; offset++ {
+		start := time.Date(local.Year(), local.Month(), local.Day()+offset, w.hour, w.minute, 0, 0, w.loc)
+		if len(w.days) > 0 && !w.days[start.Weekday()] {
+			continue
+		}
+		if !now.Before(start) && now.Before(start.Add(w.duration)) {
+			return true, time.Time{}
+		}
+		if start.After(now) {
+			return false, start
+		}
+	}
+	return false, time.Time{}
+}
@@ -0,0 +1,306 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jet
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane-contrib/provider-jet-template/apis/null/v1alpha1"
+	apisv1alpha1 "github.com/crossplane-contrib/provider-jet-template/apis/v1alpha1"
+)
+
+func TestNextMaintenanceWindow(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2022-05-16 is a Monday.
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2022, time.May, day, hour, minute, 0, 0, time.UTC)
+	}
+	hours := func(h int) metav1.Duration {
+		return metav1.Duration{Duration: time.Duration(h) * time.Hour}
+	}
+	_, errTimeZone := time.LoadLocation("Mars/Olympus")
+	var hour, minute int
+	_, errStart := fmt.Sscanf("2am", "%d:%d", &hour, &minute)
+
+	type args struct {
+		windows []apisv1alpha1.MaintenanceWindow
+		now     time.Time
+	}
+	type want struct {
+		next time.Time
+		err  error
+	}
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NoWindows": {
+			reason: "The changes should not be deferred if there is no window.",
+			args:   args{now: at(16, 12, 0)},
+		},
+		"Inside": {
+			reason: "The changes should not be deferred inside a window.",
+			args: args{
+				windows: []apisv1alpha1.MaintenanceWindow{{Start: "02:00", Duration: hours(2)}},
+				now:     at(16, 3, 0),
+			},
+		},
+		"AtStart": {
+			reason: "A window should be open at its start.",
+			args: args{
+				windows: []apisv1alpha1.MaintenanceWindow{{Start: "02:00", Duration: hours(2)}},
+				now:     at(16, 2, 0),
+			},
+		},
+		"BeforeStart": {
+			reason: "The changes should be deferred to the window starting later on the same day.",
+			args: args{
+				windows: []apisv1alpha1.MaintenanceWindow{{Start: "02:00", Duration: hours(2)}},
+				now:     at(16, 1, 0),
+			},
+			want: want{next: at(16, 2, 0)},
+		},
+		"AtEnd": {
+			reason: "A window should be closed at its end, and the changes deferred to the next day.",
+			args: args{
+				windows: []apisv1alpha1.MaintenanceWindow{{Start: "02:00", Duration: hours(2)}},
+				now:     at(16, 4, 0),
+			},
+			want: want{next: at(17, 2, 0)},
+		},
+		"Days": {
+			reason: "Only the days of the window should be considered.",
+			args: args{
+				windows: []apisv1alpha1.MaintenanceWindow{{Days: []apisv1alpha1.Weekday{"Wednesday", "Friday"}, Start: "02:00", Duration: hours(2)}},
+				now:     at(16, 3, 0),
+			},
+			want: want{next: at(18, 2, 0)},
+		},
+		"CrossingMidnightInside": {
+			reason: "A window that started the day before should be open after midnight, even if it does not start on this day.",
+			args: args{
+				windows: []apisv1alpha1.MaintenanceWindow{{Days: []apisv1alpha1.Weekday{"Sunday"}, Start: "23:00", Duration: hours(3)}},
+				now:     at(16, 1, 0),
+			},
+		},
+		"CrossingMidnightOutside": {
+			reason: "The changes should be deferred to the next week once a window crossing midnight closed.",
+			args: args{
+				windows: []apisv1alpha1.MaintenanceWindow{{Days: []apisv1alpha1.Weekday{"Sunday"}, Start: "23:00", Duration: hours(3)}},
+				now:     at(16, 2, 30),
+			},
+			want: want{next: at(22, 23, 0)},
+		},
+		"TimeZoneInside": {
+			reason: "The start of a window should be in its time zone.",
+			args: args{
+				windows: []apisv1alpha1.MaintenanceWindow{{Start: "02:00", Duration: hours(2), TimeZone: berlin.String()}},
+				now:     at(16, 0, 30),
+			},
+		},
+		"TimeZoneOutside": {
+			reason: "The next start of a window should be in its time zone.",
+			args: args{
+				windows: []apisv1alpha1.MaintenanceWindow{{Start: "02:00", Duration: hours(2), TimeZone: berlin.String()}},
+				now:     at(16, 2, 30),
+			},
+			want: want{next: at(17, 0, 0)},
+		},
+		"TimeZoneDays": {
+			reason: "The days of a window should be in its time zone.",
+			args: args{
+				windows: []apisv1alpha1.MaintenanceWindow{{Days: []apisv1alpha1.Weekday{"Tuesday"}, Start: "01:00", Duration: hours(1), TimeZone: newYork.String()}},
+				// It's still Monday in New York.
+				now: at(17, 3, 0),
+			},
+			want: want{next: at(17, 5, 0)},
+		},
+		"Earliest": {
+			reason: "The changes should be deferred to the window that starts first.",
+			args: args{
+				windows: []apisv1alpha1.MaintenanceWindow{
+					{Start: "22:00", Duration: hours(1)},
+					{Start: "20:00", Duration: hours(1)},
+				},
+				now: at(16, 12, 0),
+			},
+			want: want{next: at(16, 20, 0)},
+		},
+		"AnyOpen": {
+			reason: "The changes should not be deferred if any window is open.",
+			args: args{
+				windows: []apisv1alpha1.MaintenanceWindow{
+					{Start: "22:00", Duration: hours(1)},
+					{Start: "11:00", Duration: hours(2)},
+				},
+				now: at(16, 12, 0),
+			},
+		},
+		"InvalidTimeZone": {
+			reason: "An unknown time zone should be reported.",
+			args: args{
+				windows: []apisv1alpha1.MaintenanceWindow{{Start: "02:00", Duration: hours(2), TimeZone: "Mars/Olympus"}},
+				now:     at(16, 12, 0),
+			},
+			want: want{err: errors.Wrapf(errTimeZone, errFmtTimeZone, "Mars/Olympus")},
+		},
+		"InvalidStart": {
+			reason: "A start not in HH:MM format should be reported.",
+			args: args{
+				windows: []apisv1alpha1.MaintenanceWindow{{Start: "2am", Duration: hours(2)}},
+				now:     at(16, 12, 0),
+			},
+			want: want{err: errors.Wrapf(errStart, errFmtStart, "2am")},
+		},
+		"NoDuration": {
+			reason: "A window without a duration should be reported.",
+			args: args{
+				windows: []apisv1alpha1.MaintenanceWindow{{Start: "02:00"}},
+				now:     at(16, 12, 0),
+			},
+			want: want{err: errors.Errorf(errFmtDuration, time.Duration(0))},
+		},
+		"LongerThanWeek": {
+			reason: "A window longer than a week should be reported.",
+			args: args{
+				windows: []apisv1alpha1.MaintenanceWindow{{Start: "02:00", Duration: hours(7*24 + 1)}},
+				now:     at(16, 12, 0),
+			},
+			want: want{err: errors.Errorf(errFmtDuration, 169*time.Hour)},
+		},
+		"InvalidWeekday": {
+			reason: "An unknown weekday should be reported.",
+			args: args{
+				windows: []apisv1alpha1.MaintenanceWindow{{Days: []apisv1alpha1.Weekday{"Caturday"}, Start: "02:00", Duration: hours(2)}},
+				now:     at(16, 12, 0),
+			},
+			want: want{err: errors.Errorf(errFmtWeekday, "Caturday")},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := nextMaintenanceWindow(tc.args.windows, tc.args.now)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nnextMaintenanceWindow(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.next, got); diff != "" {
+				t.Errorf("\n%s\nnextMaintenanceWindow(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestDeferralsReconciler(t *testing.T) {
+	s := runtime.NewScheme()
+	if err := v1alpha1.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	uid := types.UID("uid")
+	kube := &test.MockClient{
+		MockGet: test.NewMockGetFn(nil, func(obj client.Object) error {
+			obj.SetUID(uid)
+			return nil
+		}),
+		MockScheme: test.NewMockSchemeFn(s),
+	}
+	errBoom := errors.New("boom")
+
+	type want struct {
+		result reconcile.Result
+		err    error
+		// requeued is whether the reconcile should be requeued about
+		// an hour later.
+		requeued bool
+	}
+	cases := map[string]struct {
+		reason string
+		until  time.Time
+		result reconcile.Result
+		err    error
+		want   want
+	}{
+		"NotDeferred": {
+			reason: "The result of a reconcile that did not defer the changes should be kept.",
+			result: reconcile.Result{RequeueAfter: time.Minute},
+			want:   want{result: reconcile.Result{RequeueAfter: time.Minute}},
+		},
+		"Deferred": {
+			reason: "A reconcile that deferred the changes should be requeued when the window starts.",
+			until:  time.Now().Add(time.Hour),
+			result: reconcile.Result{Requeue: true},
+			want:   want{requeued: true},
+		},
+		"WindowStarted": {
+			reason: "The result of a reconcile should be kept if the window started meanwhile.",
+			until:  time.Now().Add(-time.Second),
+			result: reconcile.Result{Requeue: true},
+			want:   want{result: reconcile.Result{Requeue: true}},
+		},
+		"Error": {
+			reason: "A reconcile that failed should be retried with backoff.",
+			until:  time.Now().Add(time.Hour),
+			err:    errBoom,
+			want:   want{err: errBoom},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			d := NewDeferrals()
+			r := d.Reconciler(kube, resource.ManagedKind(v1alpha1.Resource_GroupVersionKind), reconcile.Func(func(context.Context, reconcile.Request) (reconcile.Result, error) {
+				if !tc.until.IsZero() {
+					d.deferTo(uid, tc.until)
+				}
+				return tc.result, tc.err
+			}))
+			got, err := r.Reconcile(context.Background(), reconcile.Request{})
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nReconcile(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if tc.want.requeued {
+				if got.Requeue || got.RequeueAfter <= 59*time.Minute || got.RequeueAfter > time.Hour {
+					t.Errorf("\n%s\nReconcile(...): got %+v, want to be requeued after an hour", tc.reason, got)
+				}
+			} else if diff := cmp.Diff(tc.want.result, got); diff != "" {
+				t.Errorf("\n%s\nReconcile(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if _, ok := d.take(uid); ok {
+				t.Errorf("\n%s\nReconcile(...): the deferral was not forgotten", tc.reason)
+			}
+		})
+	}
+}
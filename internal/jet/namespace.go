@@ -0,0 +1,88 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jet
+
+import (
+	"strings"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	xpresource "github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/terrajet/pkg/config"
+	"github.com/pkg/errors"
+)
+
+const (
+	errCrossNamespaceConnSecret = "connection secret of a namespaced resource must be written to its own namespace"
+	errFmtCrossNamespaceRef     = "secret reference at %s of a namespaced resource must be in its own namespace"
+	errFmtExpandSensitive       = "cannot expand sensitive field path %s"
+	errPaveObject               = "cannot pave managed resource"
+)
+
+// checkNamespace returns an error if the given namespace-scoped managed
+// resource refers to Secrets in other namespaces, either to write its
+// connection details or to read its sensitive parameters. Cluster-scoped
+// managed resources are not checked.
+func checkNamespace(mg xpresource.Managed, cfg *config.Resource) error {
+	ns := mg.GetNamespace()
+	if ns == "" {
+		return nil
+	}
+	if ref := mg.GetWriteConnectionSecretToReference(); ref != nil && ref.Namespace != ns {
+		return errors.New(errCrossNamespaceConnSecret)
+	}
+	paved, err := fieldpath.PaveObject(mg)
+	if err != nil {
+		return errors.Wrap(err, errPaveObject)
+	}
+	for _, xp := range cfg.Sensitive.GetFieldPaths() {
+		if !strings.HasPrefix(xp, "spec.forProvider.") {
+			continue
+		}
+		paths, err := paved.ExpandWildcards(xp)
+		if err != nil {
+			return errors.Wrapf(err, errFmtExpandSensitive, xp)
+		}
+		for _, p := range paths {
+			v, err := paved.GetValue(p)
+			if err != nil {
+				continue
+			}
+			if !inNamespace(v, ns) {
+				return errors.Errorf(errFmtCrossNamespaceRef, p)
+			}
+		}
+	}
+	return nil
+}
+
+// inNamespace reports whether the given secret key selector, or map of
+// secret key selectors, only refers to Secrets in the given namespace.
+func inNamespace(v interface{}, ns string) bool {
+	sel, ok := v.(map[string]interface{})
+	if !ok {
+		return true
+	}
+	if n, ok := sel["namespace"].(string); ok {
+		return n == ns
+	}
+	for _, e := range sel {
+		if !inNamespace(e, ns) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jet
+
+import (
+	"testing"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/crossplane/terrajet/pkg/config"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+
+	"github.com/crossplane-contrib/provider-jet-template/apis/null/v1alpha1"
+)
+
+// sensitiveResource is a managed resource with sensitive parameters, whose
+// spec is the one that is paved.
+type sensitiveResource struct {
+	v1alpha1.Resource `json:",inline"`
+
+	Spec struct {
+		ForProvider map[string]interface{} `json:"forProvider"`
+	} `json:"spec"`
+}
+
+func TestCheckNamespace(t *testing.T) {
+	cfg := &config.Resource{}
+	cfg.Sensitive.AddFieldPath("password", "spec.forProvider.passwordSecretRef")
+	cfg.Sensitive.AddFieldPath("tokens", "spec.forProvider.tokenSecretRefs")
+	cfg.Sensitive.AddFieldPath("status_secret", "status.atProvider.secret")
+	selector := func(ns string) map[string]interface{} {
+		return map[string]interface{}{"name": "secret", "namespace": ns, "key": "key"}
+	}
+
+	type args struct {
+		namespace  string
+		connSecret *xpv1.SecretReference
+		params     map[string]interface{}
+	}
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   error
+	}{
+		"ClusterScoped": {
+			reason: "Cluster-scoped resources should not be checked.",
+			args: args{
+				connSecret: &xpv1.SecretReference{Name: "conn", Namespace: "other"},
+				params:     map[string]interface{}{"passwordSecretRef": selector("other")},
+			},
+		},
+		"SameNamespace": {
+			reason: "Namespaced resources should refer to Secrets in their own namespace.",
+			args: args{
+				namespace:  "team",
+				connSecret: &xpv1.SecretReference{Name: "conn", Namespace: "team"},
+				params: map[string]interface{}{
+					"passwordSecretRef": selector("team"),
+					"tokenSecretRefs":   map[string]interface{}{"a": selector("team")},
+				},
+			},
+		},
+		"CrossNamespaceConnectionSecret": {
+			reason: "Namespaced resources should not write their connection details to other namespaces.",
+			args: args{
+				namespace:  "team",
+				connSecret: &xpv1.SecretReference{Name: "conn", Namespace: "other"},
+			},
+			want: errors.New(errCrossNamespaceConnSecret),
+		},
+		"CrossNamespaceSensitiveParameter": {
+			reason: "Namespaced resources should not read sensitive parameters from other namespaces.",
+			args: args{
+				namespace: "team",
+				params:    map[string]interface{}{"passwordSecretRef": selector("other")},
+			},
+			want: errors.Errorf(errFmtCrossNamespaceRef, "spec.forProvider.passwordSecretRef"),
+		},
+		"CrossNamespaceSensitiveMap": {
+			reason: "Namespaced resources should not read any of a map of sensitive parameters from other namespaces.",
+			args: args{
+				namespace: "team",
+				params:    map[string]interface{}{"tokenSecretRefs": map[string]interface{}{"a": selector("team"), "b": selector("other")}},
+			},
+			want: errors.Errorf(errFmtCrossNamespaceRef, "spec.forProvider.tokenSecretRefs"),
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			mg := &sensitiveResource{}
+			mg.SetNamespace(tc.args.namespace)
+			mg.SetWriteConnectionSecretToReference(tc.args.connSecret)
+			mg.Spec.ForProvider = tc.args.params
+			err := checkNamespace(mg, cfg)
+			if diff := cmp.Diff(tc.want, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ncheckNamespace(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
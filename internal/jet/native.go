@@ -0,0 +1,234 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jet
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/terrajet/pkg/resource/json"
+	"github.com/crossplane/terrajet/pkg/terraform"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/pkg/errors"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane-contrib/provider-jet-template/internal/tfplugin"
+)
+
+const (
+	nativeIdleTimeout   = 30 * time.Minute
+	nativeSweepInterval = 5 * time.Minute
+
+	errNativeConfig = "cannot convert the configuration of the native provider"
+	errNativeKey    = "cannot hash the setup of the native provider"
+	errGetSecret    = "cannot get secret"
+)
+
+// NativeProviders runs the native Terraform provider in plugin processes
+// that the operations of the managed resources talk to over gRPC, instead of
+// running the Terraform CLI in the workspaces. A process is started for
+// every distinct provider setup, i.e. configuration and environment, and
+// stopped once it has not been used for a while, e.g. because the
+// credentials of its ProviderConfig were rotated.
+type NativeProviders struct {
+	binary string
+	logger logging.Logger
+
+	mu        sync.Mutex
+	providers map[string]*nativeProvider
+}
+
+// nativeProvider is a provider process for a provider setup.
+type nativeProvider struct {
+	// mu serialises the starts of the process.
+	mu sync.Mutex
+
+	// The fields below are guarded by the mutex of NativeProviders.
+	provider *tfplugin.Provider
+	schema   *tfjson.ProviderSchema
+	inflight int
+	lastUsed time.Time
+}
+
+// NewNativeProviders returns a new NativeProviders that runs the provider
+// binary at the given path.
+func NewNativeProviders(binaryPath string, log logging.Logger) *NativeProviders {
+	return &NativeProviders{binary: binaryPath, logger: log, providers: map[string]*nativeProvider{}}
+}
+
+// Start stops the provider processes that are idle or that exited
+// periodically, and all of them once the given context is done.
+func (n *NativeProviders) Start(ctx context.Context) error {
+	t := time.NewTicker(nativeSweepInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			n.stop(func(*nativeProvider) bool { return true })
+			return nil
+		case <-t.C:
+			n.stop(func(p *nativeProvider) bool {
+				return p.inflight == 0 && (time.Since(p.lastUsed) > nativeIdleTimeout || p.provider == nil || p.provider.Exited())
+			})
+		}
+	}
+}
+
+// stop stops and forgets the provider processes for which the given
+// function returns true.
+func (n *NativeProviders) stop(fn func(p *nativeProvider) bool) {
+	n.mu.Lock()
+	var stopped []*tfplugin.Provider
+	for key, p := range n.providers {
+		if !fn(p) {
+			continue
+		}
+		delete(n.providers, key)
+		if p.provider != nil {
+			stopped = append(stopped, p.provider)
+		}
+	}
+	n.mu.Unlock()
+	for _, tp := range stopped {
+		tp.Close()
+	}
+	if len(stopped) > 0 {
+		n.logger.Debug("Stopped native provider processes", "count", len(stopped))
+	}
+}
+
+// acquire returns the configured provider process for the given setup and
+// its schema, starting it if needed, and the function that has to be called
+// once it's no longer used.
+func (n *NativeProviders) acquire(ctx context.Context, ts terraform.Setup) (*tfplugin.Provider, *tfjson.ProviderSchema, func(), error) {
+	key, err := setupKey(ts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	n.mu.Lock()
+	p, ok := n.providers[key]
+	if !ok {
+		p = &nativeProvider{}
+		n.providers[key] = p
+	}
+	p.inflight++
+	n.mu.Unlock()
+
+	done := func() {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		p.inflight--
+		p.lastUsed = time.Now()
+	}
+	tp, schema, err := n.start(ctx, p, ts)
+	if err != nil {
+		done()
+		return nil, nil, nil, err
+	}
+	return tp, schema, done, nil
+}
+
+// start starts and configures the given provider process for the given
+// setup unless it's running, and returns it with its schema. The process is
+// not stopped by the sweeps while it starts, since it's in use.
+func (n *NativeProviders) start(ctx context.Context, p *nativeProvider, ts terraform.Setup) (*tfplugin.Provider, *tfjson.ProviderSchema, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n.mu.Lock()
+	tp, schema := p.provider, p.schema
+	n.mu.Unlock()
+	if tp != nil && !tp.Exited() {
+		return tp, schema, nil
+	}
+	if tp != nil {
+		n.logger.Info("Restarting native provider process that exited")
+		tp.Close()
+	}
+	tp, err := tfplugin.Start(n.binary, ts.Env)
+	if err != nil {
+		return nil, nil, err
+	}
+	schema, err = tp.GetSchema(ctx)
+	if err == nil {
+		err = configure(ctx, tp, schema, ts)
+	}
+	if err != nil {
+		tp.Close()
+		return nil, nil, err
+	}
+	n.mu.Lock()
+	p.provider, p.schema = tp, schema
+	n.mu.Unlock()
+	return tp, schema, nil
+}
+
+// configure configures the given provider process with the given schema
+// with the configuration of the given setup.
+func configure(ctx context.Context, tp *tfplugin.Provider, schema *tfjson.ProviderSchema, ts terraform.Setup) error {
+	raw, err := json.JSParser.Marshal(ts.Configuration)
+	if err != nil {
+		return errors.Wrap(err, errNativeConfig)
+	}
+	if ts.Configuration == nil {
+		raw = []byte("{}")
+	}
+	config, err := ctyjson.Unmarshal(raw, tfplugin.ImpliedType(schema.ConfigSchema.Block))
+	if err != nil {
+		return errors.Wrap(err, errNativeConfig)
+	}
+	return tp.Configure(ctx, ts.Version, config)
+}
+
+// setupKey returns the key of the provider process for the given setup.
+func setupKey(ts terraform.Setup) (string, error) {
+	raw, err := json.JSParser.Marshal(ts)
+	if err != nil {
+		return "", errors.Wrap(err, errNativeKey)
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// secretClient reads the Secrets that the sensitive parameters of the
+// managed resources are read from.
+type secretClient struct {
+	kube client.Client
+}
+
+func (c *secretClient) GetSecretData(ctx context.Context, ref *xpv1.SecretReference) (map[string][]byte, error) {
+	s := &corev1.Secret{}
+	if err := c.kube.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, s); err != nil {
+		return nil, errors.Wrap(err, errGetSecret)
+	}
+	return s.Data, nil
+}
+
+func (c *secretClient) GetSecretValue(ctx context.Context, sel xpv1.SecretKeySelector) ([]byte, error) {
+	d, err := c.GetSecretData(ctx, &sel.SecretReference)
+	if err != nil {
+		return nil, err
+	}
+	return d[sel.Key], nil
+}
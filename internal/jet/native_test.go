@@ -0,0 +1,159 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jet
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/crossplane/terrajet/pkg/terraform"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/crossplane-contrib/provider-jet-template/apis/null/v1alpha1"
+	providerconfig "github.com/crossplane-contrib/provider-jet-template/config"
+)
+
+// buildNull builds the null provider in the testdata of the tfplugin
+// package and returns the path of its binary.
+func buildNull(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("the null provider cannot be built without the go command")
+	}
+	bin := filepath.Join(t.TempDir(), "terraform-provider-null")
+	if out, err := exec.Command("go", "build", "-o", bin, "../tfplugin/testdata/nullprovider").CombinedOutput(); err != nil {
+		t.Fatalf("cannot build the null provider: %v\n%s", err, out)
+	}
+	return bin
+}
+
+func TestNativeExternalNullResource(t *testing.T) {
+	ctx := context.Background()
+	providers := NewNativeProviders(buildNull(t), logging.NewNopLogger())
+	defer providers.stop(func(*nativeProvider) bool { return true })
+	n := &nativeExternal{
+		providers: providers,
+		secrets:   &secretClient{kube: &test.MockClient{}},
+		config:    providerconfig.GetProvider().Resources["null_resource"],
+		dir:       t.TempDir(),
+	}
+	trigger := func(v string) map[string]*string {
+		return map[string]*string{"key": &v}
+	}
+	mg := &v1alpha1.Resource{}
+	mg.Spec.ForProvider.Triggers = trigger("a")
+
+	// observe observes the resource like the reconciles that follow each
+	// other until it's late-initialized and available.
+	observe := func() managed.ExternalObservation {
+		t.Helper()
+		var o managed.ExternalObservation
+		for i := 0; i < 3; i++ {
+			var err error
+			if o, err = n.Observe(ctx, mg); err != nil {
+				t.Fatalf("Observe(...): %v", err)
+			}
+		}
+		return o
+	}
+
+	if diff := cmp.Diff(managed.ExternalObservation{}, observe()); diff != "" {
+		t.Errorf("\nA resource that is not created should not exist.\nObserve(...): -want, +got:\n%s", diff)
+	}
+	if _, err := n.Create(ctx, mg); err != nil {
+		t.Fatalf("Create(...): %v", err)
+	}
+	id := meta.GetExternalName(mg)
+	if id == "" {
+		t.Fatal("Create(...): no external name was set")
+	}
+	want := managed.ExternalObservation{ResourceExists: true, ResourceUpToDate: true}
+	if diff := cmp.Diff(want, observe()); diff != "" {
+		t.Errorf("\nA created resource should be up to date.\nObserve(...): -want, +got:\n%s", diff)
+	}
+	if diff := cmp.Diff(id, *mg.Status.AtProvider.ID); diff != "" {
+		t.Errorf("\nThe ID of a created resource should be observed.\nObserve(...): -want, +got:\n%s", diff)
+	}
+
+	mg.Spec.ForProvider.Triggers = trigger("b")
+	want.ResourceUpToDate = false
+	if diff := cmp.Diff(want, observe()); diff != "" {
+		t.Errorf("\nA resource whose triggers changed should not be up to date.\nObserve(...): -want, +got:\n%s", diff)
+	}
+	if _, err := n.Update(ctx, mg); err != nil {
+		t.Fatalf("Update(...): %v", err)
+	}
+	if *mg.Status.AtProvider.ID == id {
+		t.Error("Update(...): a resource whose triggers changed should be replaced")
+	}
+
+	if err := n.Delete(ctx, mg); err != nil {
+		t.Fatalf("Delete(...): %v", err)
+	}
+	if diff := cmp.Diff(managed.ExternalObservation{}, observe()); diff != "" {
+		t.Errorf("\nA deleted resource should not exist.\nObserve(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestNativeProvidersSweep(t *testing.T) {
+	ctx := context.Background()
+	providers := NewNativeProviders(buildNull(t), logging.NewNopLogger())
+	defer providers.stop(func(*nativeProvider) bool { return true })
+
+	// The sweeps run while the provider processes are started and used,
+	// which the race detector checks.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			tp, schema, done, err := providers.acquire(ctx, terraform.Setup{})
+			if err != nil {
+				t.Errorf("acquire(...): %v", err)
+				return
+			}
+			defer done()
+			if tp.Exited() || schema.ResourceSchemas["null_resource"] == nil {
+				t.Error("acquire(...): got a provider process that is not running")
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			providers.stop(func(p *nativeProvider) bool {
+				return p.inflight == 0 && (p.provider == nil || p.provider.Exited())
+			})
+		}()
+	}
+	wg.Wait()
+
+	tp, _, done, err := providers.acquire(ctx, terraform.Setup{})
+	if err != nil {
+		t.Fatalf("acquire(...): %v", err)
+	}
+	done()
+	providers.stop(func(p *nativeProvider) bool { return p.inflight == 0 })
+	if !tp.Exited() {
+		t.Error("stop(...): the idle provider process was not stopped")
+	}
+}
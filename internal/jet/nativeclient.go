@@ -0,0 +1,589 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jet
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
+	xpresource "github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/terrajet/pkg/config"
+	"github.com/crossplane/terrajet/pkg/resource"
+	"github.com/crossplane/terrajet/pkg/resource/json"
+	"github.com/crossplane/terrajet/pkg/terraform"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/pkg/errors"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+	ctymsgpack "github.com/zclconf/go-cty/cty/msgpack"
+
+	"github.com/crossplane-contrib/provider-jet-template/internal/tfplugin"
+	"github.com/crossplane-contrib/provider-jet-template/internal/workspace"
+)
+
+const (
+	errUnexpectedObject  = "managed resource is not a Terraformed resource"
+	errGetSetup          = "cannot get the Terraform setup of the managed resource"
+	errCreateWorkspace   = "cannot create the workspace"
+	errGetID             = "cannot get the ID of the managed resource"
+	errGetObservation    = "cannot get the observation of the managed resource"
+	errSetObservation    = "cannot set the observation of the managed resource"
+	errConvertParameters = "cannot convert the parameters of the managed resource"
+	errConvertState      = "cannot convert the Terraform state"
+	errProduceState      = "cannot produce the Terraform state of the managed resource"
+	errSetAnnotations    = "cannot set critical annotations"
+	errConnDetails       = "cannot get connection details"
+	errLateInit          = "cannot late initialize parameters"
+	errFmtNoSchema       = "native provider has no schema for resource %s"
+)
+
+// connectNative returns an external client that runs the operations on the
+// given resource with the native provider instead of the Terraform CLI.
+func (c *Connector) connectNative(ctx context.Context, mg xpresource.Managed) (managed.ExternalClient, error) {
+	if _, ok := mg.(resource.Terraformed); !ok {
+		return nil, errors.New(errUnexpectedObject)
+	}
+	ts, err := c.setup(ctx, c.kube, mg)
+	if err != nil {
+		return nil, errors.Wrap(err, errGetSetup)
+	}
+	// The state is kept in the workspace like the Terraform CLI keeps it, so
+	// that the state backends, the state exports and the encryption of the
+	// workspaces work the same.
+	if err := os.MkdirAll(workspace.Dir(mg), 0700); err != nil {
+		return nil, errors.Wrap(err, errCreateWorkspace)
+	}
+	return &nativeExternal{
+		providers: c.native,
+		secrets:   &secretClient{kube: c.kube},
+		setup:     ts,
+		config:    c.config,
+		dir:       workspace.Dir(mg),
+	}, nil
+}
+
+// nativeExternal is an external client that reads, plans and applies the
+// Terraform resources of the managed resources with the native provider over
+// gRPC. The state of a resource is held in memory while an operation runs,
+// and is read from and written to the state file of its workspace in the
+// format of the Terraform CLI.
+type nativeExternal struct {
+	providers *NativeProviders
+	secrets   resource.SecretClient
+	setup     terraform.Setup
+	config    *config.Resource
+	dir       string
+}
+
+// nativeResource is the desired Terraform resource of a managed resource.
+type nativeResource struct {
+	tr       resource.Terraformed
+	provider *tfplugin.Provider
+	block    *tfjson.SchemaBlock
+	ty       cty.Type
+	// id is the ID of the resource in the Terraform state, which is empty
+	// if it's not created yet.
+	id     string
+	config cty.Value
+}
+
+// desired returns the desired Terraform resource of the given managed
+// resource with the given provider process with the given schema.
+func (n *nativeExternal) desired(ctx context.Context, tp *tfplugin.Provider, schema *tfjson.ProviderSchema, mg xpresource.Managed) (*nativeResource, error) {
+	tr, ok := mg.(resource.Terraformed)
+	if !ok {
+		return nil, errors.New(errUnexpectedObject)
+	}
+	s, ok := schema.ResourceSchemas[tr.GetTerraformResourceType()]
+	if !ok {
+		return nil, errors.Errorf(errFmtNoSchema, tr.GetTerraformResourceType())
+	}
+	params, err := tr.GetParameters()
+	if err != nil {
+		return nil, errors.Wrap(err, errGetParameters)
+	}
+	if err := resource.GetSensitiveParameters(ctx, n.secrets, tr, params, tr.GetConnectionDetailsMapping()); err != nil {
+		return nil, errors.Wrap(err, errGetParameters)
+	}
+	n.config.ExternalName.SetIdentifierArgumentFn(params, meta.GetExternalName(tr))
+	r := &nativeResource{tr: tr, provider: tp, block: s.Block, ty: tfplugin.ImpliedType(s.Block)}
+	if meta.GetExternalName(tr) != "" {
+		if r.id, err = n.config.ExternalName.GetIDFn(ctx, meta.GetExternalName(tr), params, n.setup.Configuration); err != nil {
+			return nil, errors.Wrap(err, errGetID)
+		}
+	}
+	raw, err := json.JSParser.Marshal(params)
+	if err != nil {
+		return nil, errors.Wrap(err, errConvertParameters)
+	}
+	r.config, err = ctyjson.Unmarshal(raw, r.ty)
+	return r, errors.Wrap(err, errConvertParameters)
+}
+
+// connect returns the desired Terraform resource of the given managed
+// resource with a provider process, and the function that has to be called
+// once the process is no longer used.
+func (n *nativeExternal) connect(ctx context.Context, mg xpresource.Managed) (*nativeResource, func(), error) {
+	tp, schema, done, err := n.providers.acquire(ctx, n.setup)
+	if err != nil {
+		return nil, nil, err
+	}
+	r, err := n.desired(ctx, tp, schema, mg)
+	if err != nil {
+		done()
+		return nil, nil, err
+	}
+	return r, done, nil
+}
+
+func (n *nativeExternal) Observe(ctx context.Context, mg xpresource.Managed) (managed.ExternalObservation, error) {
+	r, done, err := n.connect(ctx, mg)
+	if err != nil {
+		return managed.ExternalObservation{}, err
+	}
+	defer done()
+	prior, err := n.prior(ctx, r)
+	if err != nil || prior.Value.IsNull() {
+		return managed.ExternalObservation{}, err
+	}
+	s, err := r.provider.ReadResource(ctx, r.tr.GetTerraformResourceType(), prior)
+	if err != nil {
+		return managed.ExternalObservation{}, err
+	}
+	if err := n.writeState(r, s); err != nil || s.Value.IsNull() {
+		return managed.ExternalObservation{}, err
+	}
+	return n.observe(ctx, r, s)
+}
+
+// observe returns the observation of the given resource with the given
+// state, which follows the one of the Terrajet external client: the critical
+// annotations, the status and the late-initialized parameters are stored
+// before the resource is planned.
+func (n *nativeExternal) observe(ctx context.Context, r *nativeResource, s tfplugin.State) (managed.ExternalObservation, error) {
+	attrs, raw, err := attributesOf(s, r.ty)
+	if err != nil {
+		return managed.ExternalObservation{}, err
+	}
+	if err := r.tr.SetObservation(attrs); err != nil {
+		return managed.ExternalObservation{}, errors.Wrap(err, errSetObservation)
+	}
+	annotationsUpdated, err := resource.SetCriticalAnnotations(r.tr, n.config, attrs, string(s.Private))
+	if err != nil {
+		return managed.ExternalObservation{}, errors.Wrap(err, errSetAnnotations)
+	}
+	conn, err := resource.GetConnectionDetails(attrs, r.tr, n.config)
+	if err != nil {
+		return managed.ExternalObservation{}, errors.Wrap(err, errConnDetails)
+	}
+	lateInited, err := r.tr.LateInitialize(raw)
+	if err != nil {
+		return managed.ExternalObservation{}, errors.Wrap(err, errLateInit)
+	}
+	o := managed.ExternalObservation{ResourceExists: true, ResourceUpToDate: true, ConnectionDetails: conn}
+	switch {
+	case annotationsUpdated:
+		o.ResourceLateInitialized = true
+	case !r.tr.GetCondition(xpv1.TypeReady).Equal(xpv1.Available()):
+		r.tr.SetConditions(xpv1.Available())
+	case lateInited:
+		o.ResourceLateInitialized = true
+	default:
+		pl, err := n.plan(ctx, r, s)
+		if err != nil {
+			return o, err
+		}
+		o.ResourceUpToDate = !pl.RequiresReplace && pl.Value.RawEquals(s.Value)
+	}
+	return o, nil
+}
+
+func (n *nativeExternal) Create(ctx context.Context, mg xpresource.Managed) (managed.ExternalCreation, error) {
+	r, done, err := n.connect(ctx, mg)
+	if err != nil {
+		return managed.ExternalCreation{}, err
+	}
+	defer done()
+	s, err := n.apply(ctx, r, tfplugin.State{Value: cty.NullVal(r.ty)})
+	if err != nil {
+		return managed.ExternalCreation{}, err
+	}
+	attrs, _, err := attributesOf(s, r.ty)
+	if err != nil {
+		return managed.ExternalCreation{}, err
+	}
+	conn, err := resource.GetConnectionDetails(attrs, r.tr, n.config)
+	if err != nil {
+		return managed.ExternalCreation{}, errors.Wrap(err, errConnDetails)
+	}
+	// Only the spec and the metadata are stored after Create.
+	_, err = resource.SetCriticalAnnotations(r.tr, n.config, attrs, string(s.Private))
+	return managed.ExternalCreation{ConnectionDetails: conn}, errors.Wrap(err, errSetAnnotations)
+}
+
+func (n *nativeExternal) Update(ctx context.Context, mg xpresource.Managed) (managed.ExternalUpdate, error) {
+	r, done, err := n.connect(ctx, mg)
+	if err != nil {
+		return managed.ExternalUpdate{}, err
+	}
+	defer done()
+	prior, err := n.prior(ctx, r)
+	if err != nil {
+		return managed.ExternalUpdate{}, err
+	}
+	s, err := n.apply(ctx, r, prior)
+	if err != nil {
+		return managed.ExternalUpdate{}, err
+	}
+	attrs, _, err := attributesOf(s, r.ty)
+	if err != nil {
+		return managed.ExternalUpdate{}, err
+	}
+	return managed.ExternalUpdate{}, errors.Wrap(r.tr.SetObservation(attrs), errSetObservation)
+}
+
+func (n *nativeExternal) Delete(ctx context.Context, mg xpresource.Managed) error {
+	r, done, err := n.connect(ctx, mg)
+	if err != nil {
+		return err
+	}
+	defer done()
+	prior, err := n.prior(ctx, r)
+	if err != nil || prior.Value.IsNull() {
+		return err
+	}
+	_, err = n.destroy(ctx, r, prior)
+	return err
+}
+
+// plan plans the change of the given resource from the given prior state to
+// its desired configuration.
+func (n *nativeExternal) plan(ctx context.Context, r *nativeResource, prior tfplugin.State) (tfplugin.Plan, error) {
+	proposed := tfplugin.ProposedNew(r.block, prior.Value, r.config)
+	return r.provider.PlanResourceChange(ctx, r.tr.GetTerraformResourceType(), prior, proposed, r.config)
+}
+
+// planChanges plans the changes of the given resource against its state in
+// the workspace, which is refreshed by the observation, or its destruction
+// if destroy is true, without applying them.
+func (n *nativeExternal) planChanges(ctx context.Context, mg xpresource.Managed, destroy bool) (*plan, error) {
+	r, done, err := n.connect(ctx, mg)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	prior, err := n.prior(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return n.planOf(ctx, r, prior, destroy)
+}
+
+// applyPlan applies the given approved plan of the given resource. The
+// provider plans the changes again, since it cannot save them, so they are
+// only applied if they are still the approved ones.
+func (n *nativeExternal) applyPlan(ctx context.Context, mg xpresource.Managed, approved *plan) error {
+	r, done, err := n.connect(ctx, mg)
+	if err != nil {
+		return err
+	}
+	defer done()
+	prior, err := n.prior(ctx, r)
+	if err != nil {
+		return err
+	}
+	p, err := n.planOf(ctx, r, prior, approved.destroy)
+	if err != nil {
+		return err
+	}
+	if p.hash != approved.hash {
+		return errors.Errorf(errFmtPlanChanged, approved.hash)
+	}
+	if approved.destroy {
+		_, err = n.destroy(ctx, r, prior)
+		return err
+	}
+	s, err := n.apply(ctx, r, prior)
+	if err != nil {
+		return err
+	}
+	attrs, _, err := attributesOf(s, r.ty)
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(r.tr.SetObservation(attrs), errSetObservation)
+}
+
+// planOf plans the change of the given resource from the given prior state
+// to its desired configuration, or its destruction if destroy is true, and
+// summarizes it.
+func (n *nativeExternal) planOf(ctx context.Context, r *nativeResource, prior tfplugin.State, destroy bool) (*plan, error) {
+	var pl tfplugin.Plan
+	var err error
+	if destroy {
+		null := cty.NullVal(r.ty)
+		pl, err = r.provider.PlanResourceChange(ctx, r.tr.GetTerraformResourceType(), prior, null, null)
+	} else {
+		pl, err = n.plan(ctx, r, prior)
+	}
+	if err != nil {
+		return nil, err
+	}
+	p, err := nativePlan(r, prior, pl)
+	if err != nil {
+		return nil, err
+	}
+	p.destroy = destroy
+	return p, nil
+}
+
+// nativePlan summarizes the given plan of the given resource from the given
+// prior state like parsePlan summarizes the plans of the Terraform CLI.
+func nativePlan(r *nativeResource, prior tfplugin.State, pl tfplugin.Plan) (*plan, error) {
+	action := "update"
+	switch {
+	case prior.Value.IsNull():
+		action = "create"
+	case pl.Value.IsNull():
+		action = "delete"
+	case pl.RequiresReplace:
+		action = "replace"
+	case pl.Value.RawEquals(prior.Value):
+		action = "no-op"
+	}
+	raw, err := ctymsgpack.Marshal(pl.Value, r.ty)
+	if err != nil {
+		return nil, errors.Wrap(err, errConvertState)
+	}
+	sum := sha256.Sum256(append([]byte(action), raw...))
+	p := &plan{hash: hex.EncodeToString(sum[:])}
+	if action == "no-op" {
+		return p, nil
+	}
+	p.changes = true
+	p.replace = action == "replace"
+	p.destructive = p.replace || action == "delete"
+	p.summary = []string{fmt.Sprintf("%s %s.%s (%s)", action, r.tr.GetTerraformResourceType(), r.tr.GetName(), strings.Join(changedValues(prior.Value, pl.Value), ", "))}
+	return p, nil
+}
+
+// changedValues returns the sorted names of the top-level attributes that
+// the given planned value of a resource changes from the given prior value.
+func changedValues(prior, planned cty.Value) []string {
+	if planned.IsNull() {
+		// The attributes of destroyed resources are all removed.
+		return changedValues(planned, prior)
+	}
+	var names []string
+	for name := range planned.Type().AttributeTypes() {
+		v := planned.GetAttr(name)
+		if prior.IsNull() {
+			if !v.IsNull() {
+				names = append(names, name)
+			}
+			continue
+		}
+		if !prior.GetAttr(name).RawEquals(v) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// apply applies the desired configuration of the given resource to its given
+// prior state, and writes the new state. Like Terraform, it destroys the
+// resource first if it has to be replaced.
+func (n *nativeExternal) apply(ctx context.Context, r *nativeResource, prior tfplugin.State) (tfplugin.State, error) {
+	pl, err := n.plan(ctx, r, prior)
+	if err != nil {
+		return tfplugin.State{}, err
+	}
+	if pl.RequiresReplace && !prior.Value.IsNull() {
+		if prior, err = n.destroy(ctx, r, prior); err != nil {
+			return tfplugin.State{}, err
+		}
+		if pl, err = n.plan(ctx, r, prior); err != nil {
+			return tfplugin.State{}, err
+		}
+	}
+	s, err := r.provider.ApplyResourceChange(ctx, r.tr.GetTerraformResourceType(), prior, pl, r.config)
+	// The state of the partial changes of the failed applies is written as
+	// well.
+	if s.Value.Type() == cty.NilType {
+		return s, err
+	}
+	if wErr := n.writeState(r, s); wErr != nil && err == nil {
+		return s, wErr
+	}
+	return s, err
+}
+
+// destroy destroys the given resource with the given prior state, and
+// writes and returns the state without it.
+func (n *nativeExternal) destroy(ctx context.Context, r *nativeResource, prior tfplugin.State) (tfplugin.State, error) {
+	null := cty.NullVal(r.ty)
+	pl, err := r.provider.PlanResourceChange(ctx, r.tr.GetTerraformResourceType(), prior, null, null)
+	if err != nil {
+		return tfplugin.State{}, err
+	}
+	s, err := r.provider.ApplyResourceChange(ctx, r.tr.GetTerraformResourceType(), prior, pl, null)
+	if err != nil {
+		return tfplugin.State{}, err
+	}
+	return s, n.writeState(r, s)
+}
+
+// prior returns the prior state of the given resource. Like the Terrajet
+// workspace store, it's the state in the workspace if there is one, and
+// otherwise the state produced from the managed resource. The resources
+// that were never observed, e.g. because they were created outside of
+// Crossplane, are imported. The returned state is null if the resource is
+// not created yet or was deleted.
+func (n *nativeExternal) prior(ctx context.Context, r *nativeResource) (tfplugin.State, error) {
+	st, err := n.readState()
+	if err != nil {
+		return tfplugin.State{}, err
+	}
+	if st == nil && r.id != "" {
+		if st, err = n.produceState(ctx, r); err != nil || st == nil {
+			return n.importState(ctx, r, err)
+		}
+	}
+	if len(st.GetAttributes()) == 0 {
+		return tfplugin.State{Value: cty.NullVal(r.ty)}, nil
+	}
+	v, err := r.provider.UpgradeResourceState(ctx, r.tr.GetTerraformResourceType(), st.Resources[0].Instances[0].SchemaVersion, st.GetAttributes(), r.ty)
+	return tfplugin.State{Value: v, Private: st.GetPrivateRaw()}, err
+}
+
+// produceState writes the Terraform state produced from the given managed
+// resource to the workspace and returns it, or returns nil if the resource
+// was never observed.
+func (n *nativeExternal) produceState(ctx context.Context, r *nativeResource) (*json.StateV4, error) {
+	obs, err := r.tr.GetObservation()
+	if err != nil {
+		return nil, errors.Wrap(err, errGetObservation)
+	}
+	if len(obs) == 0 {
+		return nil, nil
+	}
+	fp, err := terraform.NewFileProducer(ctx, n.secrets, n.dir, r.tr, n.setup, n.config)
+	if err != nil {
+		return nil, errors.Wrap(err, errProduceState)
+	}
+	if err := fp.WriteTFState(ctx); err != nil {
+		return nil, errors.Wrap(err, errProduceState)
+	}
+	return n.readState()
+}
+
+// importState imports the given resource unless the given error occurred.
+func (n *nativeExternal) importState(ctx context.Context, r *nativeResource, err error) (tfplugin.State, error) {
+	if err != nil {
+		return tfplugin.State{}, err
+	}
+	return r.provider.ImportResourceState(ctx, r.tr.GetTerraformResourceType(), r.id, r.ty)
+}
+
+// readState returns the Terraform state in the workspace, or nil if there
+// is none.
+func (n *nativeExternal) readState() (*json.StateV4, error) {
+	raw, err := os.ReadFile(filepath.Join(n.dir, fileState))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, errReadState)
+	}
+	st := &json.StateV4{}
+	return st, errors.Wrap(json.JSParser.Unmarshal(raw, st), errUnmarshalState)
+}
+
+// writeState writes the given state of the given resource to the workspace
+// in the format of the Terraform CLI unless it did not change. The serial of
+// the state is incremented, so that the state backends tell it apart from
+// the older states. A null state is written as a state without resources.
+func (n *nativeExternal) writeState(r *nativeResource, s tfplugin.State) error {
+	prev, err := n.readState()
+	if err != nil {
+		return err
+	}
+	st := json.NewStateV4()
+	st.TerraformVersion = n.setup.Version
+	st.Lineage = string(r.tr.GetUID())
+	st.RootOutputs = map[string]json.OutputStateV4{}
+	st.Resources = []json.ResourceStateV4{}
+	switch {
+	case prev == nil && s.Value.IsNull():
+		return nil
+	case prev != nil:
+		st.Serial = prev.Serial + 1
+	}
+	if !s.Value.IsNull() {
+		_, attrs, err := attributesOf(s, r.ty)
+		if err != nil {
+			return err
+		}
+		if unchanged(prev, attrs, s.Private) {
+			return nil
+		}
+		st.Resources = append(st.Resources, json.ResourceStateV4{
+			Mode:           "managed",
+			Type:           r.tr.GetTerraformResourceType(),
+			Name:           r.tr.GetName(),
+			ProviderConfig: fmt.Sprintf(`provider["registry.terraform.io/%s"]`, n.setup.Requirement.Source),
+			Instances: []json.InstanceObjectStateV4{{
+				SchemaVersion: uint64(r.tr.GetTerraformSchemaVersion()),
+				PrivateRaw:    s.Private,
+				AttributesRaw: attrs,
+			}},
+		})
+	}
+	raw, err := json.JSParser.Marshal(st)
+	if err != nil {
+		return errors.Wrap(err, errConvertState)
+	}
+	return errors.Wrap(os.WriteFile(filepath.Join(n.dir, fileState), raw, 0600), errWriteState)
+}
+
+// unchanged returns true if the given state has the given attributes and
+// private data of a resource.
+func unchanged(st *json.StateV4, attrs, private []byte) bool {
+	return st != nil && bytes.Equal(st.GetAttributes(), attrs) && bytes.Equal(st.GetPrivateRaw(), private)
+}
+
+// attributesOf returns the attributes of the given state of a resource of
+// the given type, decoded and JSON encoded.
+func attributesOf(s tfplugin.State, ty cty.Type) (map[string]interface{}, []byte, error) {
+	raw, err := ctyjson.Marshal(s.Value, ty)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, errConvertState)
+	}
+	attrs := map[string]interface{}{}
+	return attrs, raw, errors.Wrap(json.JSParser.Unmarshal(raw, &attrs), errConvertState)
+}
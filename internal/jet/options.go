@@ -0,0 +1,74 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package jet contains the provider-specific extensions of the Terrajet
+// controller machinery that all generated controllers are wired to.
+package jet
+
+import (
+	tjcontroller "github.com/crossplane/terrajet/pkg/controller"
+
+	"github.com/crossplane-contrib/provider-jet-template/internal/backend"
+	"github.com/crossplane-contrib/provider-jet-template/internal/tfstate"
+	"github.com/crossplane-contrib/provider-jet-template/internal/workspace"
+)
+
+// Options contains the configuration of the controllers of this provider.
+type Options struct {
+	tjcontroller.Options
+
+	// StateExporter mirrors the Terraform state of the managed resources into
+	// Secrets after every successful apply. The state is not exported if it
+	// is nil.
+	StateExporter *tfstate.Exporter
+
+	// StateHistory keeps the state snapshots of the managed resources whose
+	// ProviderConfigs enable it. No snapshots are kept if it is nil.
+	StateHistory *tfstate.History
+
+	// StateBackends returns the state backends configured by the
+	// ProviderConfigs. The state is kept only in the workspaces if it is
+	// nil.
+	StateBackends *backend.Factory
+
+	// Encrypter encrypts the files of the workspaces at rest. They are not
+	// encrypted if it is nil.
+	Encrypter *workspace.Encrypter
+
+	// ProcessLimiter caps the number of Terraform operations that run at the
+	// same time. They are not limited if it is nil.
+	ProcessLimiter *ProcessLimiter
+
+	// Operations runs the async operations of the managed resources and
+	// reports their progress, and holds their process slots until they
+	// finish. Terrajet runs them without progress reports, and without
+	// holding their process slots, if it is nil.
+	Operations *Operations
+
+	// NativeProviders runs the operations of the managed resources with the
+	// native Terraform provider over gRPC instead of the Terraform CLI. The
+	// Terraform CLI is used if it is nil.
+	NativeProviders *NativeProviders
+
+	// Deferrals requeues the reconciles that deferred the changes of their
+	// managed resources when the next maintenance window starts. They are
+	// requeued as if the changes were applied if it is nil.
+	Deferrals *Deferrals
+
+	// Throttler enforces the rate and concurrency limits of the
+	// ProviderConfigs. The reconciles are not throttled if it is nil.
+	Throttler *Throttler
+}
@@ -0,0 +1,301 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jet
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
+	xpresource "github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	errWaitForProcess = "cannot wait for a free Terraform process slot"
+)
+
+// A Priority is the priority of a Terraform operation waiting for a process
+// slot.
+type Priority int
+
+// Priorities of the Terraform operations, from the lowest to the highest.
+const (
+	// PriorityDriftCheck is the priority of the periodic observations of
+	// the managed resources whose spec did not change.
+	PriorityDriftCheck Priority = iota
+	// PriorityChange is the priority of the observations, creations and
+	// updates of the managed resources whose spec changed.
+	PriorityChange
+	// PriorityDelete is the priority of the operations on the managed
+	// resources that are being deleted.
+	PriorityDelete
+)
+
+var priorityLabels = map[Priority]string{
+	PriorityDriftCheck: "drift_check",
+	PriorityChange:     "change",
+	PriorityDelete:     "delete",
+}
+
+var (
+	terraformQueueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "template_jet_terraform_queue_depth",
+		Help: "Number of Terraform operations waiting for a process slot, by priority.",
+	}, []string{"priority"})
+	terraformRunning = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "template_jet_terraform_running_operations",
+		Help: "Number of Terraform operations holding a process slot.",
+	})
+	terraformQueueWait = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "template_jet_terraform_queue_wait_seconds",
+		Help:    "Time Terraform operations waited for a process slot, by priority.",
+		Buckets: prometheus.ExponentialBuckets(0.01, 4, 8),
+	}, []string{"priority"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(terraformQueueDepth, terraformRunning, terraformQueueWait)
+}
+
+// A ProcessLimiter caps the number of Terraform operations that run at the
+// same time across all workspaces, so that the provider does not run out of
+// memory with many kinds of managed resources. The operations waiting for a
+// slot are run by priority, and in the order they arrived within a priority.
+// Async operations hold their slot until they finish.
+type ProcessLimiter struct {
+	mu      sync.Mutex
+	size    int
+	running int
+	seq     uint64
+	queue   waiters
+	// generations are the last observed generations of the managed
+	// resources, by which their spec changes are told from drift checks.
+	generations map[types.UID]int64
+}
+
+// NewProcessLimiter returns a new ProcessLimiter that lets the given number
+// of Terraform operations run at the same time. It returns nil, which does
+// not limit the operations, if the given number is not positive.
+func NewProcessLimiter(size int) *ProcessLimiter {
+	if size <= 0 {
+		return nil
+	}
+	return &ProcessLimiter{size: size, generations: map[types.UID]int64{}}
+}
+
+// Acquire waits for a process slot for an operation with the given priority
+// and returns the function that releases it, which must be called once the
+// operation finished. It returns an error if the given context is done
+// first.
+func (l *ProcessLimiter) Acquire(ctx context.Context, p Priority) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	start := time.Now()
+	l.mu.Lock()
+	if l.running < l.size && l.queue.Len() == 0 {
+		l.running++
+		terraformRunning.Inc()
+		l.mu.Unlock()
+		terraformQueueWait.WithLabelValues(priorityLabels[p]).Observe(0)
+		return l.releaser(), nil
+	}
+	w := &waiter{priority: p, seq: l.seq, ready: make(chan struct{})}
+	l.seq++
+	heap.Push(&l.queue, w)
+	terraformQueueDepth.WithLabelValues(priorityLabels[p]).Inc()
+	l.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		terraformQueueWait.WithLabelValues(priorityLabels[p]).Observe(time.Since(start).Seconds())
+		return l.releaser(), nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if w.index < 0 {
+			// The slot was handed over while the context was done.
+			l.release()
+		} else {
+			heap.Remove(&l.queue, w.index)
+			terraformQueueDepth.WithLabelValues(priorityLabels[p]).Dec()
+		}
+		return nil, errors.Wrap(ctx.Err(), errWaitForProcess)
+	}
+}
+
+// releaser returns a function that releases a process slot once.
+func (l *ProcessLimiter) releaser() func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.release()
+		})
+	}
+}
+
+// release hands the released slot over to the first waiting operation.
+func (l *ProcessLimiter) release() {
+	if l.queue.Len() == 0 {
+		l.running--
+		terraformRunning.Dec()
+		return
+	}
+	w := heap.Pop(&l.queue).(*waiter)
+	terraformQueueDepth.WithLabelValues(priorityLabels[w.priority]).Dec()
+	close(w.ready)
+}
+
+// PriorityOf returns the priority of the given operation on the given
+// managed resource. The observations of the resources whose generation did
+// not change since their last observation, or that are ready if they were
+// not observed since the provider started, are drift checks.
+func (l *ProcessLimiter) PriorityOf(mg xpresource.Managed, operation string) Priority {
+	if l == nil {
+		return PriorityDriftCheck
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if meta.WasDeleted(mg) || operation == "Delete" {
+		delete(l.generations, mg.GetUID())
+		return PriorityDelete
+	}
+	if operation != "Observe" {
+		return PriorityChange
+	}
+	gen, ok := l.generations[mg.GetUID()]
+	l.generations[mg.GetUID()] = mg.GetGeneration()
+	switch {
+	case ok && gen == mg.GetGeneration():
+		return PriorityDriftCheck
+	case !ok && mg.GetCondition(xpv1.TypeReady).Status == corev1.ConditionTrue:
+		return PriorityDriftCheck
+	}
+	return PriorityChange
+}
+
+// waiter is an operation waiting for a process slot.
+type waiter struct {
+	priority Priority
+	seq      uint64
+	ready    chan struct{}
+	index    int
+}
+
+// waiters is a priority queue of the waiting operations.
+type waiters []*waiter
+
+func (q waiters) Len() int { return len(q) }
+
+func (q waiters) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority > q[j].priority
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q waiters) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *waiters) Push(x interface{}) {
+	w := x.(*waiter)
+	w.index = len(*q)
+	*q = append(*q, w)
+}
+
+func (q *waiters) Pop() interface{} {
+	old := *q
+	w := old[len(old)-1]
+	old[len(old)-1] = nil
+	w.index = -1
+	*q = old[:len(old)-1]
+	return w
+}
+
+// limited is an external client whose operations wait for a process slot of
+// the ProcessLimiter. The slots of async operations are held by Operations
+// until they finish.
+type limited struct {
+	managed.ExternalClient
+
+	limiter    *ProcessLimiter
+	operations *Operations
+	async      bool
+}
+
+func (l *limited) Observe(ctx context.Context, mg xpresource.Managed) (managed.ExternalObservation, error) {
+	release, err := l.limiter.Acquire(ctx, l.limiter.PriorityOf(mg, "Observe"))
+	if err != nil {
+		return managed.ExternalObservation{}, err
+	}
+	defer release()
+	return l.ExternalClient.Observe(ctx, mg)
+}
+
+func (l *limited) Create(ctx context.Context, mg xpresource.Managed) (managed.ExternalCreation, error) {
+	release, err := l.limiter.Acquire(ctx, l.limiter.PriorityOf(mg, "Create"))
+	if err != nil {
+		return managed.ExternalCreation{}, err
+	}
+	c, err := l.ExternalClient.Create(ctx, mg)
+	l.done(mg, release, err)
+	return c, err
+}
+
+func (l *limited) Update(ctx context.Context, mg xpresource.Managed) (managed.ExternalUpdate, error) {
+	release, err := l.limiter.Acquire(ctx, l.limiter.PriorityOf(mg, "Update"))
+	if err != nil {
+		return managed.ExternalUpdate{}, err
+	}
+	u, err := l.ExternalClient.Update(ctx, mg)
+	l.done(mg, release, err)
+	return u, err
+}
+
+func (l *limited) Delete(ctx context.Context, mg xpresource.Managed) error {
+	release, err := l.limiter.Acquire(ctx, l.limiter.PriorityOf(mg, "Delete"))
+	if err != nil {
+		return err
+	}
+	err = l.ExternalClient.Delete(ctx, mg)
+	l.done(mg, release, err)
+	return err
+}
+
+// done releases the slot of an operation on the given resource that
+// returned the given error, unless it started an async operation that is
+// still running, which then releases the slot once it finishes.
+func (l *limited) done(mg xpresource.Managed, release func(), err error) {
+	if l.async && err == nil && l.operations.Hold(mg.GetUID(), release) {
+		return
+	}
+	release()
+}
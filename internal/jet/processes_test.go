@@ -0,0 +1,124 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jet
+
+import (
+	"context"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
+	xpresource "github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/crossplane-contrib/provider-jet-template/apis/null/v1alpha1"
+)
+
+func TestLimitedHoldsAsyncOperations(t *testing.T) {
+	kube := &test.MockClient{MockGet: test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{}, ""))}
+	resource := func(namespace string, uid types.UID) xpresource.Managed {
+		mg := &v1alpha1.Resource{}
+		mg.SetNamespace(namespace)
+		mg.SetName("example")
+		mg.SetUID(uid)
+		return mg
+	}
+
+	type want struct {
+		free    int
+		running []types.UID
+	}
+	cases := map[string]struct {
+		reason  string
+		running []types.UID
+		create  []xpresource.Managed
+		finish  []types.UID
+		want    want
+	}{
+		"Running": {
+			reason:  "The slots of async operations that still run should be held.",
+			running: []types.UID{"a", "b"},
+			create:  []xpresource.Managed{resource("a", "a"), resource("b", "b")},
+			want:    want{free: 0, running: []types.UID{"a", "b"}},
+		},
+		"Finished": {
+			reason:  "The slot of an async operation that finished before it was held should be released.",
+			running: []types.UID{"a"},
+			create:  []xpresource.Managed{resource("a", "a"), resource("b", "b")},
+			want:    want{free: 1, running: []types.UID{"a"}},
+		},
+		"SameName": {
+			reason:  "The slot of an operation should be released when it finishes, but not the one of a resource with the same name in another namespace.",
+			running: []types.UID{"a", "b"},
+			create:  []xpresource.Managed{resource("a", "a"), resource("b", "b")},
+			finish:  []types.UID{"a"},
+			want:    want{free: 1, running: []types.UID{"b"}},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			o := NewOperations(kube, logging.NewNopLogger())
+			ops := map[types.UID]*Operation{}
+			for _, uid := range tc.running {
+				ops[uid] = &Operation{Type: operationApply, object: &v1alpha1.Resource{}}
+				o.running[uid] = ops[uid]
+			}
+			l := &limited{
+				ExternalClient: managed.ExternalClientFns{
+					CreateFn: func(context.Context, xpresource.Managed) (managed.ExternalCreation, error) {
+						return managed.ExternalCreation{}, nil
+					},
+				},
+				limiter:    NewProcessLimiter(2),
+				operations: o,
+				async:      true,
+			}
+			for _, mg := range tc.create {
+				if _, err := l.Create(context.Background(), mg); err != nil {
+					t.Fatalf("Create(...): %v", err)
+				}
+			}
+			for _, uid := range tc.finish {
+				o.finish(uid, t.TempDir(), ops[uid], nil)
+			}
+			l.limiter.mu.Lock()
+			free := l.limiter.size - l.limiter.running
+			l.limiter.mu.Unlock()
+			if diff := cmp.Diff(tc.want.free, free); diff != "" {
+				t.Errorf("\n%s\nfree slots: -want, +got:\n%s", tc.reason, diff)
+			}
+			running := []types.UID{}
+			for _, uid := range tc.running {
+				if o.Running(resource("", uid)) != nil {
+					running = append(running, uid)
+				}
+			}
+			for _, uid := range tc.finish {
+				if o.Hold(uid, func() {}) {
+					t.Errorf("\n%s\nHold(...): kept a slot for an operation that finished", tc.reason)
+				}
+			}
+			if diff := cmp.Diff(tc.want.running, running); diff != "" {
+				t.Errorf("\n%s\nRunning(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jet
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	xpresource "github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/terrajet/pkg/resource/json"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	providerconfig "github.com/crossplane-contrib/provider-jet-template/config"
+)
+
+const (
+	// AnnotationKeyApproveReplacement is the annotation that approves the
+	// replacement of the external resource of a managed resource when set
+	// to "true". It's required to change the arguments with the
+	// ReplaceWithApproval policy.
+	AnnotationKeyApproveReplacement = "template.jet.crossplane.io/approve-replacement"
+
+	// TypeReplacement is the condition that reports whether a change of a
+	// managed resource is blocked because it would replace its external
+	// resource.
+	TypeReplacement xpv1.ConditionType = "Replacement"
+
+	// ReasonReplacementBlocked means that a change is blocked.
+	ReasonReplacementBlocked xpv1.ConditionReason = "ReplacementBlocked"
+	// ReasonNoReplacementBlocked means that no change is blocked anymore.
+	ReasonNoReplacementBlocked xpv1.ConditionReason = "NoReplacementBlocked"
+
+	fileState = "terraform.tfstate"
+
+	errReadState             = "cannot read Terraform state"
+	errUnmarshalState        = "cannot unmarshal Terraform state"
+	errGetParameters         = "cannot get the parameters of the managed resource"
+	errFmtReplacementBlocked = "refusing to replace the external resource: %s"
+)
+
+// ReplacementBlocked returns a condition that indicates that the given
+// changes are blocked since they would replace the external resource.
+func ReplacementBlocked(changes []string) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeReplacement,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonReplacementBlocked,
+		Message: fmt.Sprintf("Changing these arguments would replace the external resource: %s. Annotate the resource with %s: \"true\" to approve the replacement of the ReplaceWithApproval arguments.",
+			strings.Join(changes, "; "), AnnotationKeyApproveReplacement),
+	}
+}
+
+// NoReplacementBlocked returns a condition that indicates that no change is
+// blocked anymore.
+func NoReplacementBlocked() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeReplacement,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonNoReplacementBlocked,
+	}
+}
+
+// replacementGuard finds the changes of managed resources that would replace
+// their external resources against their replacement policies.
+type replacementGuard struct {
+	schema   *schema.Resource
+	policies providerconfig.ReplacementPolicies
+}
+
+// argumentChange is a changed argument of a managed resource and its
+// replacement policy, which is empty if it has none.
+type argumentChange struct {
+	path     string
+	policy   providerconfig.ReplacementPolicy
+	old, new interface{}
+}
+
+// blocked returns the blocked changes of the parameters of the given
+// managed resource against the state of its workspace in the given
+// directory, which is refreshed by the observation. The JSON schemas of the
+// providers do not tell which arguments force the replacement, so the
+// changed arguments without a replacement policy get the default policy if
+// the plan made by the given function replaces the external resource. The
+// plan is only made if no argument with a policy changed.
+func (g *replacementGuard) blocked(mg xpresource.Managed, params map[string]interface{}, dir string, replaces func() (bool, error)) ([]string, error) {
+	attrs, err := stateAttributes(filepath.Join(dir, fileState))
+	if err != nil || attrs == nil {
+		return nil, err
+	}
+	changes := g.changes(g.schema, params, attrs, "")
+	if err := defaultPolicies(changes, replaces); err != nil {
+		return nil, err
+	}
+	approved := mg.GetAnnotations()[AnnotationKeyApproveReplacement] == "true"
+	var result []string
+	for _, c := range changes {
+		if c.policy == providerconfig.Immutable || (c.policy == providerconfig.ReplaceWithApproval && !approved) {
+			result = append(result, fmt.Sprintf("%s (%s) from %s to %s", c.path, c.policy, toJSON(c.old), toJSON(c.new)))
+		}
+	}
+	return result, nil
+}
+
+// defaultPolicies sets the default replacement policy on the given changes
+// without a policy if none of the changes has a policy, which would explain
+// a replacement, and the given function reports that the external resource
+// is replaced.
+func defaultPolicies(changes []argumentChange, replaces func() (bool, error)) error {
+	var unlisted []int
+	for i, c := range changes {
+		if c.policy != "" {
+			return nil
+		}
+		unlisted = append(unlisted, i)
+	}
+	if len(unlisted) == 0 {
+		return nil
+	}
+	r, err := replaces()
+	if err != nil || !r {
+		return err
+	}
+	for _, i := range unlisted {
+		changes[i].policy = providerconfig.DefaultReplacementPolicy
+	}
+	return nil
+}
+
+// changes returns the changed arguments of the given parameters of a block,
+// or of the resource itself, against its given state attributes. The items
+// of the blocks that are not blocked as a whole are compared one by one.
+func (g *replacementGuard) changes(res *schema.Resource, params, attrs map[string]interface{}, prefix string) []argumentChange {
+	var result []argumentChange
+	for _, k := range sortedKeys(res.Schema) {
+		sch := res.Schema[k]
+		v, old := params[k], attrs[k]
+		if v == nil || old == nil {
+			continue
+		}
+		path := prefix + k
+		rp := g.policies.Of(path, sch)
+		changed := !reflect.DeepEqual(v, old)
+		if rp == providerconfig.Immutable || rp == providerconfig.ReplaceWithApproval {
+			if changed {
+				result = append(result, argumentChange{path: path, policy: rp, old: old, new: v})
+			}
+			continue
+		}
+		items, ok := g.itemChanges(sch, v, old, path)
+		result = append(result, items...)
+		if changed && (rp != "" || !ok) {
+			result = append(result, argumentChange{path: path, policy: rp, old: old, new: v})
+		}
+	}
+	return result
+}
+
+// itemChanges returns the changed arguments of the items of the given block
+// that are in both its parameters and its state attributes, and whether
+// these are all of its changes.
+func (g *replacementGuard) itemChanges(sch *schema.Schema, v, old interface{}, path string) ([]argumentChange, bool) {
+	nested, ok := sch.Elem.(*schema.Resource)
+	items, isList := v.([]interface{})
+	oldItems, oldIsList := old.([]interface{})
+	if !ok || !isList || !oldIsList {
+		return nil, false
+	}
+	var result []argumentChange
+	for i := 0; i < len(items) && i < len(oldItems); i++ {
+		n, _ := items[i].(map[string]interface{})
+		o, _ := oldItems[i].(map[string]interface{})
+		result = append(result, g.changes(nested, n, o, path+".")...)
+	}
+	return result, len(items) == len(oldItems)
+}
+
+// stateAttributes returns the attributes of the resource in the Terraform
+// state file at the given path, or nil if there's no state yet.
+func stateAttributes(path string) (map[string]interface{}, error) {
+	raw, err := os.ReadFile(filepath.Clean(path))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, errReadState)
+	}
+	st := &json.StateV4{}
+	if err := json.JSParser.Unmarshal(raw, st); err != nil {
+		return nil, errors.Wrap(err, errUnmarshalState)
+	}
+	if len(st.GetAttributes()) == 0 {
+		return nil, nil
+	}
+	attrs := map[string]interface{}{}
+	return attrs, errors.Wrap(json.JSParser.Unmarshal(st.GetAttributes(), &attrs), errUnmarshalState)
+}
+
+func toJSON(v interface{}) string {
+	b, err := json.JSParser.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+func sortedKeys(m map[string]*schema.Schema) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jet
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/pkg/errors"
+
+	"github.com/crossplane-contrib/provider-jet-template/apis/null/v1alpha1"
+	providerconfig "github.com/crossplane-contrib/provider-jet-template/config"
+)
+
+func TestReplacementGuardBlocked(t *testing.T) {
+	guard := &replacementGuard{
+		schema: &schema.Resource{Schema: map[string]*schema.Schema{
+			"name":  {Type: schema.TypeString, Optional: true},
+			"zone":  {Type: schema.TypeString, Optional: true},
+			"size":  {Type: schema.TypeInt, Optional: true},
+			"label": {Type: schema.TypeString, Optional: true},
+			"setting": {Type: schema.TypeList, Optional: true, Elem: &schema.Resource{Schema: map[string]*schema.Schema{
+				"mode": {Type: schema.TypeString, Optional: true},
+			}}},
+		}},
+		policies: providerconfig.ReplacementPolicies{
+			"zone":  providerconfig.Immutable,
+			"size":  providerconfig.ReplaceOnChange,
+			"label": providerconfig.ReplaceWithApproval,
+		},
+	}
+	state := `{"version":4,"terraform_version":"1.1.6","serial":1,"lineage":"l","outputs":{},"resources":[{"mode":"managed","type":"test_resource","name":"example","provider":"provider[\"registry.terraform.io/hashicorp/test\"]","instances":[{"schema_version":0,"attributes":{"name":"a","zone":"z","size":1,"label":"l","setting":[{"mode":"m"}]}}]}]}`
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, fileState), []byte(state), 0600); err != nil {
+		t.Fatal(err)
+	}
+	params := func(overrides map[string]interface{}) map[string]interface{} {
+		p := map[string]interface{}{"name": "a", "zone": "z", "size": 1.0, "label": "l", "setting": []interface{}{map[string]interface{}{"mode": "m"}}}
+		for k, v := range overrides {
+			p[k] = v
+		}
+		return p
+	}
+	replaces := func(r bool) func() (bool, error) {
+		return func() (bool, error) { return r, nil }
+	}
+	noPlan := func() (bool, error) {
+		return false, errors.New("the changes should not be planned")
+	}
+
+	type args struct {
+		params   map[string]interface{}
+		approved bool
+		replaces func() (bool, error)
+	}
+	type want struct {
+		blocked []string
+		err     error
+	}
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Unchanged": {
+			reason: "Nothing should be blocked or planned if nothing changed.",
+			args:   args{params: params(nil), replaces: noPlan},
+		},
+		"Immutable": {
+			reason: "Changes of Immutable arguments should be blocked even if approved, without a plan.",
+			args:   args{params: params(map[string]interface{}{"zone": "y"}), approved: true, replaces: noPlan},
+			want:   want{blocked: []string{`zone (Immutable) from "z" to "y"`}},
+		},
+		"NotApproved": {
+			reason: "Changes of ReplaceWithApproval arguments should be blocked unless approved.",
+			args:   args{params: params(map[string]interface{}{"label": "k"}), replaces: noPlan},
+			want:   want{blocked: []string{`label (ReplaceWithApproval) from "l" to "k"`}},
+		},
+		"Approved": {
+			reason: "Approved changes of ReplaceWithApproval arguments should not be blocked.",
+			args:   args{params: params(map[string]interface{}{"label": "k"}), approved: true, replaces: noPlan},
+		},
+		"ExplainedByPolicy": {
+			reason: "Changes without a policy should not be planned if an argument with a policy changed too.",
+			args:   args{params: params(map[string]interface{}{"size": 2.0, "name": "b"}), replaces: noPlan},
+		},
+		"UnlistedUpdated": {
+			reason: "Changes without a policy should not be blocked if the plan does not replace the resource.",
+			args:   args{params: params(map[string]interface{}{"name": "b"}), replaces: replaces(false)},
+		},
+		"UnlistedReplaced": {
+			reason: "Changes without a policy should get the default policy if the plan replaces the resource.",
+			args: args{
+				params:   params(map[string]interface{}{"name": "b", "setting": []interface{}{map[string]interface{}{"mode": "n"}}}),
+				replaces: replaces(true),
+			},
+			want: want{blocked: []string{
+				`name (ReplaceWithApproval) from "a" to "b"`,
+				`setting.mode (ReplaceWithApproval) from "m" to "n"`,
+			}},
+		},
+		"PlanFailed": {
+			reason: "Errors of the plan should be returned.",
+			args:   args{params: params(map[string]interface{}{"name": "b"}), replaces: noPlan},
+			want:   want{err: errors.New("the changes should not be planned")},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			mg := &v1alpha1.Resource{}
+			if tc.args.approved {
+				mg.SetAnnotations(map[string]string{AnnotationKeyApproveReplacement: "true"})
+			}
+			got, err := guard.blocked(mg, tc.args.params, dir, tc.args.replaces)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nblocked(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.blocked, got); diff != "" {
+				t.Errorf("\n%s\nblocked(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
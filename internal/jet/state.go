@@ -0,0 +1,251 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jet
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
+	xpresource "github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/terrajet/pkg/resource/json"
+	"github.com/pkg/errors"
+
+	"github.com/crossplane-contrib/provider-jet-template/internal/backend"
+	"github.com/crossplane-contrib/provider-jet-template/internal/workspace"
+)
+
+const (
+	unlockTimeout = 30 * time.Second
+
+	// fileStateLock keeps the info of the lock of the state while it's held,
+	// so that the lock can be released if the provider is killed before it
+	// releases the lock itself.
+	fileStateLock = ".state-lock.json"
+
+	errLockState       = "cannot lock the state in the state backend"
+	errUnlockState     = "cannot unlock the state in the state backend"
+	errPullState       = "cannot pull the state from the state backend"
+	errPushState       = "cannot push the state to the state backend"
+	errDeleteState     = "cannot delete the state from the state backend"
+	errWriteState      = "cannot write Terraform state"
+	errSaveLock        = "cannot save the lock of the state in the workspace"
+	errReadSavedLock   = "cannot read the saved lock of the state from the workspace"
+	errRemoveSavedLock = "cannot remove the saved lock of the state from the workspace"
+)
+
+// syncState runs the given Terraform operation on the workspace of the given
+// resource under the lock of its state in the state backend. The backend is
+// not configured in the workspace itself, since Terrajet writes main.tf.json
+// from a Terraform setup that has no backend settings, and reads the results
+// of its operations from the state file of the workspace, which Terraform
+// does not write once a backend is configured.
+func (e *external) syncState(ctx context.Context, mg xpresource.Managed, operation string, fn func() error) error {
+	l, err := e.lockState(ctx, mg, operation)
+	if err != nil {
+		return err
+	}
+	// Terraform writes the state of the partial changes of the failed
+	// operations as well.
+	return l.release(ctx, fn())
+}
+
+// A stateLock is the lock of the state of a managed resource in the state
+// backend, which is held while Terraform changes the state in its workspace.
+// A nil stateLock is the one of a resource without a state backend.
+type stateLock struct {
+	backend backend.Backend
+	logger  logging.Logger
+	key     string
+	path    string
+	// file is where the info of the lock is saved while it's held.
+	file string
+	info *backend.LockInfo
+	// remote is the state pulled from the backend when it was locked.
+	remote []byte
+}
+
+// lockState locks the state of the given resource in the state backend for
+// the given operation, and replaces the state of its workspace by the state
+// in the backend unless it's newer. It returns nil if the resource has no
+// state backend.
+func (e *external) lockState(ctx context.Context, mg xpresource.Managed, operation string) (*stateLock, error) {
+	if e.backend == nil {
+		return nil, nil
+	}
+	// The lock saved by an async operation that still runs is not stale.
+	if e.operations == nil || e.operations.Running(mg) == nil {
+		stale, err := savedLock(mg)
+		if err != nil {
+			return nil, err
+		}
+		e.unlockStale(mg, stale)
+	}
+	l := &stateLock{
+		backend: e.backend,
+		logger:  e.logger,
+		key:     string(mg.GetUID()),
+		path:    filepath.Join(workspace.Dir(mg), fileState),
+		file:    filepath.Join(workspace.Dir(mg), fileStateLock),
+		info:    backend.NewLockInfo(operation, mg),
+	}
+	if err := l.backend.Lock(ctx, l.key, l.info); err != nil {
+		return nil, errors.Wrap(err, errLockState)
+	}
+	if err := l.save(); err != nil {
+		l.unlock()
+		return nil, err
+	}
+	if err := l.pull(ctx); err != nil {
+		l.unlock()
+		return nil, err
+	}
+	return l, nil
+}
+
+// release pushes the state of the workspace to the backend if it changed,
+// and unlocks it. It returns the given error of the operation that ran
+// under the lock, or else the error of the push.
+func (l *stateLock) release(ctx context.Context, opErr error) error {
+	if l == nil {
+		return opErr
+	}
+	defer l.unlock()
+	if err := l.push(ctx); err != nil && opErr == nil {
+		return err
+	}
+	return opErr
+}
+
+// unlock unlocks the state in the backend, and removes the saved info of the
+// lock once it's released. Failures are only logged, since the operation
+// under the lock already ended. The saved lock is released again by the
+// next operation if it's not released now.
+func (l *stateLock) unlock() {
+	// The lock is released even if the reconcile timed out.
+	ctx, cancel := context.WithTimeout(context.Background(), unlockTimeout)
+	defer cancel()
+	if err := l.backend.Unlock(ctx, l.key, l.info); err != nil {
+		l.logger.Info(errUnlockState, "error", err.Error())
+		return
+	}
+	if err := os.Remove(l.file); err != nil && !os.IsNotExist(err) {
+		l.logger.Info(errRemoveSavedLock, "error", err.Error())
+	}
+}
+
+// save saves the info of the lock in the workspace, replacing the info of a
+// stale lock that could not be released.
+func (l *stateLock) save() error {
+	raw, err := json.JSParser.Marshal(l.info)
+	if err != nil {
+		return errors.Wrap(err, errSaveLock)
+	}
+	return errors.Wrap(os.WriteFile(l.file, raw, 0600), errSaveLock)
+}
+
+// savedLock returns the info of the lock of the state of the given resource
+// saved in its workspace, if any. A damaged file is ignored, since it's
+// replaced by the next lock.
+func savedLock(mg xpresource.Managed) (*backend.LockInfo, error) {
+	raw, err := os.ReadFile(filepath.Join(workspace.Dir(mg), fileStateLock))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, errReadSavedLock)
+	}
+	info := &backend.LockInfo{}
+	if err := json.JSParser.Unmarshal(raw, info); err != nil {
+		return nil, nil
+	}
+	return info, nil
+}
+
+// pull replaces the state file of the workspace with the state in the
+// backend unless the state file is newer, and keeps the state in the
+// backend.
+func (l *stateLock) pull(ctx context.Context) error {
+	remote, err := l.backend.Get(ctx, l.key)
+	if err != nil || remote == nil {
+		return errors.Wrap(err, errPullState)
+	}
+	l.remote = remote
+	local, err := os.ReadFile(filepath.Clean(l.path))
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, errReadState)
+	}
+	if local != nil && serialOf(local) > serialOf(remote) {
+		return nil
+	}
+	return errors.Wrap(os.WriteFile(l.path, remote, 0600), errWriteState)
+}
+
+// push pushes the state file of the workspace to the backend unless it's
+// missing or equal to the state pulled from the backend.
+func (l *stateLock) push(ctx context.Context) error {
+	local, err := os.ReadFile(filepath.Clean(l.path))
+	if os.IsNotExist(err) || (err == nil && bytes.Equal(local, l.remote)) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, errReadState)
+	}
+	return errors.Wrap(l.backend.Put(ctx, l.key, local, l.info.ID), errPushState)
+}
+
+// unlockStale unlocks the state of the given resource that was locked with
+// the given info by a provider process that was restarted or killed before
+// it released the lock, e.g. during an async operation.
+func (e *external) unlockStale(mg xpresource.Managed, info *backend.LockInfo) {
+	if e.backend == nil || info == nil {
+		return
+	}
+	l := &stateLock{
+		backend: e.backend,
+		logger:  e.logger,
+		key:     string(mg.GetUID()),
+		file:    filepath.Join(workspace.Dir(mg), fileStateLock),
+		info:    info,
+	}
+	l.unlock()
+}
+
+// deleteState deletes the state of the given resource from the state
+// backend once the given observation confirms that its external resource is
+// deleted.
+func (e *external) deleteState(ctx context.Context, mg xpresource.Managed, o managed.ExternalObservation) error {
+	if e.backend == nil || !meta.WasDeleted(mg) || o.ResourceExists {
+		return nil
+	}
+	return errors.Wrap(e.backend.Delete(ctx, string(mg.GetUID())), errDeleteState)
+}
+
+// serialOf returns the serial of the given Terraform state, which Terraform
+// increments whenever it changes the state, or zero if it cannot be parsed.
+func serialOf(raw []byte) uint64 {
+	st := &json.StateV4{}
+	if err := json.JSParser.Unmarshal(raw, st); err != nil {
+		return 0
+	}
+	return st.Serial
+}
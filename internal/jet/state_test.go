@@ -0,0 +1,160 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jet
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/uuid"
+
+	"github.com/crossplane-contrib/provider-jet-template/apis/null/v1alpha1"
+	"github.com/crossplane-contrib/provider-jet-template/internal/backend"
+	"github.com/crossplane-contrib/provider-jet-template/internal/workspace"
+)
+
+// memoryBackend is a state backend that keeps a single state in memory.
+type memoryBackend struct {
+	state []byte
+	lock  *backend.LockInfo
+}
+
+func (b *memoryBackend) Get(_ context.Context, _ string) ([]byte, error) {
+	return b.state, nil
+}
+
+func (b *memoryBackend) Put(_ context.Context, _ string, state []byte, lockID string) error {
+	if b.lock == nil || b.lock.ID != lockID {
+		return &backend.LockedError{Info: b.lock}
+	}
+	b.state = state
+	return nil
+}
+
+func (b *memoryBackend) Delete(_ context.Context, _ string) error {
+	b.state = nil
+	return nil
+}
+
+func (b *memoryBackend) Lock(_ context.Context, _ string, info *backend.LockInfo) error {
+	if b.lock != nil {
+		return &backend.LockedError{Info: b.lock}
+	}
+	b.lock = info
+	return nil
+}
+
+func (b *memoryBackend) Unlock(_ context.Context, _ string, info *backend.LockInfo) error {
+	if b.lock != nil && b.lock.ID == info.ID {
+		b.lock = nil
+	}
+	return nil
+}
+
+func (b *memoryBackend) Block(_ string) map[string]interface{} {
+	return nil
+}
+
+func TestAsyncStateLock(t *testing.T) {
+	mg := &v1alpha1.Resource{}
+	mg.SetName("example")
+	mg.SetUID(uuid.NewUUID())
+	if err := os.MkdirAll(workspace.Dir(mg), 0700); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(workspace.Dir(mg)) //nolint:errcheck
+	b := &memoryBackend{state: []byte(`{"version":4,"serial":1}`)}
+	kube := &test.MockClient{MockGet: test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{}, ""))}
+	o := NewOperations(kube, logging.NewNopLogger())
+	e := &external{backend: b, logger: logging.NewNopLogger(), operations: o}
+
+	l, err := e.lockState(context.Background(), mg, "Create")
+	if err != nil {
+		t.Fatalf("lockState(...): %v", err)
+	}
+	op := &Operation{Type: operationApply, object: mg, state: l, StateLock: l.info}
+	o.running[mg.GetUID()] = op
+	if _, err := e.lockState(context.Background(), mg, "Observe"); err == nil {
+		t.Error("lockState(...): locked the state while the async operation holds its lock")
+	}
+	applied := []byte(`{"version":4,"serial":2}`)
+	if err := os.WriteFile(filepath.Join(workspace.Dir(mg), fileState), applied, 0600); err != nil {
+		t.Fatal(err)
+	}
+	o.finish(mg.GetUID(), workspace.Dir(mg), op, nil)
+	if diff := cmp.Diff(string(applied), string(b.state)); diff != "" {
+		t.Errorf("\nThe state should be pushed once the async operation finishes.\nfinish(...): -want, +got:\n%s", diff)
+	}
+	if b.lock != nil {
+		t.Error("finish(...): did not unlock the state once the async operation finished")
+	}
+
+	l, err = e.lockState(context.Background(), mg, "Update")
+	if err != nil {
+		t.Fatalf("lockState(...): %v", err)
+	}
+	e.unlockStale(mg, l.info)
+	if b.lock != nil {
+		t.Error("unlockStale(...): did not unlock the state of the interrupted async operation")
+	}
+}
+
+func TestStaleStateLock(t *testing.T) {
+	mg := &v1alpha1.Resource{}
+	mg.SetName("example")
+	mg.SetUID(uuid.NewUUID())
+	if err := os.MkdirAll(workspace.Dir(mg), 0700); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(workspace.Dir(mg)) //nolint:errcheck
+	b := &memoryBackend{}
+	saved := filepath.Join(workspace.Dir(mg), fileStateLock)
+
+	// The process that took the lock is killed before it releases it.
+	killed := &external{backend: b, logger: logging.NewNopLogger()}
+	stale, err := killed.lockState(context.Background(), mg, "Observe")
+	if err != nil {
+		t.Fatalf("lockState(...): %v", err)
+	}
+	if _, err := os.Stat(saved); err != nil {
+		t.Errorf("lockState(...): did not save the lock in the workspace: %v", err)
+	}
+
+	e := &external{backend: b, logger: logging.NewNopLogger()}
+	err = e.syncState(context.Background(), mg, "Update", func() error {
+		if b.lock == nil || b.lock.ID == stale.info.ID {
+			t.Errorf("syncState(...): got lock %+v while the operation runs", b.lock)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("syncState(...): did not release the stale lock: %v", err)
+	}
+	if b.lock != nil {
+		t.Error("syncState(...): did not unlock the state once the operation finished")
+	}
+	if _, err := os.Stat(saved); !os.IsNotExist(err) {
+		t.Errorf("syncState(...): did not remove the saved lock once it was released: %v", err)
+	}
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jet
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	xpresource "github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane-contrib/provider-jet-template/apis/v1alpha1"
+	"github.com/crossplane-contrib/provider-jet-template/internal/clients"
+)
+
+const (
+	reasonRateLimit               = "RateLimit"
+	reasonMaxConcurrentOperations = "MaxConcurrentOperations"
+
+	// pruneInterval is the interval after which the limits of the
+	// ProviderConfigs that are not used anymore, e.g. because they were
+	// deleted, are forgotten.
+	pruneInterval = 10 * time.Minute
+)
+
+var throttledReconciles = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "template_jet_throttled_reconciles_total",
+	Help: "Number of reconciles of managed resources throttled by the limits of their ProviderConfig.",
+}, []string{"provider_config", "reason"})
+
+func init() {
+	metrics.Registry.MustRegister(throttledReconciles)
+}
+
+// A Throttler enforces the rate limits and the concurrency limits of the
+// ProviderConfigs on the reconciles of the managed resources that reference
+// them. It's shared by all controllers, since a ProviderConfig can be
+// referenced by managed resources of any kind.
+type Throttler struct {
+	operations *Operations
+
+	mu     sync.Mutex
+	limits map[string]*configLimits
+	pruned time.Time
+}
+
+// configLimits are the limits of a ProviderConfig.
+type configLimits struct {
+	limiter *rate.Limiter
+	running int
+	used    time.Time
+}
+
+// NewThrottler returns a new Throttler. The async operations that the given
+// Operations run count against the concurrency limits until they finish.
+func NewThrottler(o *Operations) *Throttler {
+	return &Throttler{operations: o, limits: map[string]*configLimits{}, pruned: time.Now()}
+}
+
+// Reconciler returns a reconciler that throttles the reconciles of the given
+// kind of managed resources before running the given reconciler. The
+// throttled reconciles are requeued with backoff. The given reconciler is
+// returned as is if the Throttler is nil.
+func (t *Throttler) Reconciler(kube client.Client, of xpresource.ManagedKind, r reconcile.Reconciler) reconcile.Reconciler {
+	if t == nil {
+		return r
+	}
+	return &throttledReconciler{throttler: t, kube: kube, of: of, inner: r}
+}
+
+// acquire returns whether a reconcile of a managed resource referencing the
+// ProviderConfig with the given key and spec can proceed, or the reason it
+// cannot. A release function is returned if it can, which must be called
+// when the reconcile is done.
+func (t *Throttler) acquire(key string, spec *v1alpha1.ProviderConfigSpec) (func(), string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	t.prune(now)
+	l, ok := t.limits[key]
+	if !ok {
+		l = &configLimits{}
+		t.limits[key] = l
+	}
+	l.used = now
+	// The limiter is replaced as the ProviderConfig changes.
+	limit, burst := rate.Inf, 0
+	if spec.RateLimit != nil {
+		limit, burst = rate.Limit(*spec.RateLimit), *spec.RateLimit
+	}
+	if l.limiter == nil || l.limiter.Limit() != limit || l.limiter.Burst() != burst {
+		l.limiter = rate.NewLimiter(limit, burst)
+	}
+	if spec.MaxConcurrentOperations != nil && l.running >= *spec.MaxConcurrentOperations {
+		return nil, reasonMaxConcurrentOperations
+	}
+	if !l.limiter.Allow() {
+		return nil, reasonRateLimit
+	}
+	l.running++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			l.running--
+			l.used = time.Now()
+		})
+	}, ""
+}
+
+// prune forgets the limits of the ProviderConfigs that were not used for
+// the prune interval, at most once per interval. Their limiters would be
+// full again by now, so nothing is lost if they are used again.
+func (t *Throttler) prune(now time.Time) {
+	if now.Sub(t.pruned) < pruneInterval {
+		return
+	}
+	t.pruned = now
+	for key, l := range t.limits {
+		if l.running == 0 && now.Sub(l.used) >= pruneInterval {
+			delete(t.limits, key)
+		}
+	}
+}
+
+type throttledReconciler struct {
+	throttler *Throttler
+	kube      client.Client
+	of        xpresource.ManagedKind
+	inner     reconcile.Reconciler
+}
+
+func (r *throttledReconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	uid, key, spec := r.providerConfig(ctx, req)
+	if spec == nil {
+		// The managed reconciler reports the missing resources and
+		// ProviderConfigs.
+		return r.inner.Reconcile(ctx, req)
+	}
+	release, reason := r.throttler.acquire(key, spec)
+	if release == nil {
+		throttledReconciles.WithLabelValues(key, reason).Inc()
+		return reconcile.Result{Requeue: true}, nil
+	}
+	res, err := r.inner.Reconcile(ctx, req)
+	// The async operation the reconcile started keeps the slot until it
+	// finishes.
+	if r.throttler.operations == nil || !r.throttler.operations.Hold(uid, release) {
+		release()
+	}
+	return res, err
+}
+
+// providerConfig returns the UID of the managed resource of the given
+// request, and the key and the spec of its ProviderConfig, or a nil spec if
+// they cannot be read.
+func (r *throttledReconciler) providerConfig(ctx context.Context, req reconcile.Request) (types.UID, string, *v1alpha1.ProviderConfigSpec) {
+	obj, err := r.kube.Scheme().New(schema.GroupVersionKind(r.of))
+	if err != nil {
+		return "", "", nil
+	}
+	mg, ok := obj.(xpresource.Managed)
+	if !ok || r.kube.Get(ctx, req.NamespacedName, mg) != nil || mg.GetProviderConfigReference() == nil {
+		return "", "", nil
+	}
+	spec, err := clients.GetProviderConfigSpec(ctx, r.kube, mg)
+	if err != nil {
+		return "", "", nil
+	}
+	key := v1alpha1.ProviderConfigKind + "/" + mg.GetProviderConfigReference().Name
+	if ns := mg.GetNamespace(); ns != "" {
+		key = v1alpha1.NamespacedProviderConfigKind + "/" + ns + "/" + mg.GetProviderConfigReference().Name
+	}
+	return mg.GetUID(), key, spec
+}
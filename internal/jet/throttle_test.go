@@ -0,0 +1,90 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/crossplane-contrib/provider-jet-template/apis/null/v1alpha1"
+	apisv1alpha1 "github.com/crossplane-contrib/provider-jet-template/apis/v1alpha1"
+)
+
+func TestThrottlerHoldsAsyncOperations(t *testing.T) {
+	kube := &test.MockClient{MockGet: test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{}, ""))}
+	o := NewOperations(kube, logging.NewNopLogger())
+	th := NewThrottler(o)
+	limit := 1
+	spec := &apisv1alpha1.ProviderConfigSpec{MaxConcurrentOperations: &limit}
+
+	release, reason := th.acquire("ProviderConfig/example", spec)
+	if release == nil {
+		t.Fatalf("acquire(...): throttled with reason %s", reason)
+	}
+	uid := types.UID("uid")
+	op := &Operation{Type: operationApply, object: &v1alpha1.Resource{}}
+	o.running[uid] = op
+	if !o.Hold(uid, release) {
+		t.Fatal("Hold(...): no running operation found")
+	}
+	if _, reason := th.acquire("ProviderConfig/example", spec); reason != reasonMaxConcurrentOperations {
+		t.Errorf("acquire(...): got reason %q while the async operation runs, want %q", reason, reasonMaxConcurrentOperations)
+	}
+	o.finish(uid, t.TempDir(), op, nil)
+	if _, reason := th.acquire("ProviderConfig/example", spec); reason != "" {
+		t.Errorf("acquire(...): got reason %q after the async operation finished, want none", reason)
+	}
+	if o.Hold(uid, func() {}) {
+		t.Error("Hold(...): kept a slot for an operation that finished")
+	}
+}
+
+func TestThrottlerPrune(t *testing.T) {
+	th := NewThrottler(nil)
+	spec := &apisv1alpha1.ProviderConfigSpec{}
+	idle, _ := th.acquire("ProviderConfig/deleted", spec)
+	idle()
+	busy, _ := th.acquire("ProviderConfig/busy", spec)
+	defer busy()
+
+	th.mu.Lock()
+	past := time.Now().Add(-2 * pruneInterval)
+	th.pruned = past
+	for _, l := range th.limits {
+		l.used = past
+	}
+	th.mu.Unlock()
+	used, _ := th.acquire("ProviderConfig/used", spec)
+	used()
+
+	got := make([]string, 0, len(th.limits))
+	for key := range th.limits {
+		got = append(got, key)
+	}
+	want := []string{"ProviderConfig/busy", "ProviderConfig/used"}
+	if diff := cmp.Diff(want, got, cmpopts.SortSlices(func(a, b string) bool { return a < b })); diff != "" {
+		t.Errorf("\nThe limits of the ProviderConfigs that are not used anymore should be pruned.\nacquire(...): -want, +got:\n%s", diff)
+	}
+}
@@ -0,0 +1,271 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipeline
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+)
+
+// ChangeType is the type of a change in a generated file.
+type ChangeType string
+
+// Types of changes in generated files.
+const (
+	ChangeAdded    ChangeType = "added"
+	ChangeRemoved  ChangeType = "removed"
+	ChangeModified ChangeType = "modified"
+)
+
+// A Change is a difference between a generated file in the repository and
+// its freshly generated counterpart.
+type Change struct {
+	// Path of the file relative to the root directory.
+	Path string
+	Type ChangeType
+}
+
+// scratchDirs and scratchFiles are the parts of the root directory that the
+// pipeline and the tools run after it read from or write into.
+var (
+	scratchDirs  = []string{"apis", "cmd", "config", "internal", "hack", "examples", "docs", "package"}
+	scratchFiles = []string{"go.mod", "go.sum"}
+)
+
+// outputDirs are the directories that the pipeline and the tools run after it
+// write into.
+var outputDirs = []string{"apis", "internal", "examples", "docs", "package"}
+
+// toolOutputDirs are the directories that are entirely written by the tools
+// run after the pipeline.
+var toolOutputDirs = []string{filepath.Join("package", "crds"), filepath.Join("package", "webhookconfigurations")}
+
+// A FileFilter selects files by their paths relative to the root directory.
+type FileFilter func(rel string) bool
+
+// IsGenerated returns whether the file with the given name is produced by the
+// code generation pipeline. The zz_generated.* files are produced later by
+// controller-gen and angryjet, hence they are not.
+func IsGenerated(name string) bool {
+	return strings.HasPrefix(name, "zz_") && !strings.HasPrefix(name, "zz_generated.")
+}
+
+// PipelineFiles selects the files produced by the code generation pipeline.
+func PipelineFiles(rel string) bool {
+	return IsGenerated(filepath.Base(rel))
+}
+
+// GeneratedFiles selects the files produced by go generate, i.e. by the code
+// generation pipeline and by the tools run after it: the deepcopy functions
+// and the CRDs of controller-gen, the method sets of angryjet and the webhook
+// configurations.
+func GeneratedFiles(rel string) bool {
+	if strings.HasPrefix(filepath.Base(rel), "zz_") {
+		return true
+	}
+	for _, d := range toolOutputDirs {
+		if strings.HasPrefix(rel, d+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewScratchDir copies the parts of the given root directory that the
+// pipeline and the tools run after it need into a new temporary directory,
+// leaving out the files that they generate, and returns its path. The caller
+// is responsible for removing the returned directory.
+func NewScratchDir(rootDir string) (string, error) {
+	dir, err := os.MkdirTemp("", "generator-")
+	if err != nil {
+		return "", errors.Wrap(err, "cannot create scratch directory")
+	}
+	for _, d := range scratchDirs {
+		if err := copyTree(rootDir, dir, d); err != nil {
+			_ = os.RemoveAll(dir)
+			return "", errors.Wrapf(err, "cannot copy %s into scratch directory", d)
+		}
+	}
+	for _, f := range scratchFiles {
+		if err := copyFile(filepath.Join(rootDir, f), filepath.Join(dir, f)); err != nil {
+			_ = os.RemoveAll(dir)
+			return "", errors.Wrapf(err, "cannot copy %s into scratch directory", f)
+		}
+	}
+	return dir, nil
+}
+
+// Diff returns the changes between the files of the given root directory and
+// of the given scratch directory that the generation has been run against,
+// sorted by their paths. Only the files selected by the given filter are
+// compared.
+func Diff(rootDir, scratchDir string, generated FileFilter) ([]Change, error) {
+	current, err := generatedFiles(rootDir, generated)
+	if err != nil {
+		return nil, err
+	}
+	desired, err := generatedFiles(scratchDir, generated)
+	if err != nil {
+		return nil, err
+	}
+	var changes []Change
+	for p := range desired {
+		if _, ok := current[p]; !ok {
+			changes = append(changes, Change{Path: p, Type: ChangeAdded})
+			continue
+		}
+		eq, err := sameContent(filepath.Join(rootDir, p), filepath.Join(scratchDir, p))
+		if err != nil {
+			return nil, err
+		}
+		if !eq {
+			changes = append(changes, Change{Path: p, Type: ChangeModified})
+		}
+	}
+	for p := range current {
+		if _, ok := desired[p]; !ok {
+			changes = append(changes, Change{Path: p, Type: ChangeRemoved})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
+
+// Sync applies the given changes of the files of the given scratch directory
+// to the given root directory, and removes the directories that are left
+// empty.
+func Sync(rootDir, scratchDir string, changes []Change) error {
+	for _, c := range changes {
+		target := filepath.Join(rootDir, c.Path)
+		if c.Type == ChangeRemoved {
+			if err := os.Remove(target); err != nil {
+				return errors.Wrapf(err, "cannot remove %s", c.Path)
+			}
+			removeEmptyDirs(rootDir, filepath.Dir(target))
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0750); err != nil {
+			return errors.Wrapf(err, "cannot create directory of %s", c.Path)
+		}
+		if err := copyFile(filepath.Join(scratchDir, c.Path), target); err != nil {
+			return errors.Wrapf(err, "cannot write %s", c.Path)
+		}
+	}
+	return nil
+}
+
+// Without returns the given changes except the ones of the given files.
+func Without(changes []Change, files map[string]bool) []Change {
+	result := make([]Change, 0, len(changes))
+	for _, c := range changes {
+		if !files[c.Path] {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// removeEmptyDirs removes the given directory and its parents up to the given
+// root directory as long as they are empty.
+func removeEmptyDirs(rootDir, dir string) {
+	for dir != rootDir && strings.HasPrefix(dir, rootDir) {
+		if os.Remove(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+func generatedFiles(rootDir string, generated FileFilter) (map[string]struct{}, error) {
+	result := map[string]struct{}{}
+	for _, d := range outputDirs {
+		dir := filepath.Join(rootDir, d)
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			continue
+		}
+		err := filepath.WalkDir(dir, func(p string, e fs.DirEntry, err error) error {
+			if err != nil || e.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(rootDir, p)
+			if err != nil || !generated(rel) {
+				return err
+			}
+			result[rel] = struct{}{}
+			return nil
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot list generated files in %s", d)
+		}
+	}
+	return result, nil
+}
+
+func sameContent(a, b string) (bool, error) {
+	ca, err := os.ReadFile(filepath.Clean(a))
+	if err != nil {
+		return false, errors.Wrapf(err, "cannot read %s", a)
+	}
+	cb, err := os.ReadFile(filepath.Clean(b))
+	if err != nil {
+		return false, errors.Wrapf(err, "cannot read %s", b)
+	}
+	return bytes.Equal(ca, cb), nil
+}
+
+// copyTree copies the given directory of the given source root directory
+// into the given destination root directory, leaving out the generated
+// files.
+func copyTree(srcRoot, dstRoot, dir string) error {
+	src := filepath.Join(srcRoot, dir)
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return nil
+	}
+	return filepath.WalkDir(src, func(p string, e fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(srcRoot, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dstRoot, rel)
+		if e.IsDir() {
+			return os.MkdirAll(target, 0750)
+		}
+		if GeneratedFiles(rel) {
+			return nil
+		}
+		return copyFile(p, target)
+	})
+}
+
+func copyFile(src, dst string) error {
+	b, err := os.ReadFile(filepath.Clean(src))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, b, 0600)
+}
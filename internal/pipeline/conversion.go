@@ -0,0 +1,274 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipeline
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/terrajet/pkg/config"
+	tjpipeline "github.com/crossplane/terrajet/pkg/pipeline"
+	"github.com/crossplane/terrajet/pkg/types/name"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/muvaf/typewriter/pkg/wrapper"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane-contrib/provider-jet-template/internal/pipeline/scope"
+	"github.com/crossplane-contrib/provider-jet-template/internal/pipeline/templates"
+)
+
+const (
+	storageVersionMarker = "// +kubebuilder:storageversion\n"
+
+	pathForProvider = "spec.forProvider"
+	pathAtProvider  = "status.atProvider"
+)
+
+// A Spoke is an API version of a resource that is converted to and from the
+// storage version of the resource, i.e. the conversion hub.
+type Spoke struct {
+	// Hub is the key of the storage version in the provider configuration.
+	Hub string
+	// Moves are the fields of this version that are named or nested
+	// differently in the storage version.
+	Moves []Move
+}
+
+// A Move maps the dot-separated Terraform attribute path of a field in an
+// API version to its path in the storage version.
+type Move struct {
+	From string
+	To   string
+}
+
+// jsonMove is a move of a field between the JSON representations of two API
+// versions of a resource.
+type jsonMove struct {
+	From string
+	To   string
+}
+
+// NewConversionGenerator returns a new ConversionGenerator.
+func NewConversionGenerator(rootDir, modulePath, group string) *ConversionGenerator {
+	return &ConversionGenerator{
+		RootDir:           rootDir,
+		Group:             group,
+		ModulePath:        modulePath,
+		LicenseHeaderPath: filepath.Join(rootDir, "hack", "boilerplate.go.txt"),
+	}
+}
+
+// ConversionGenerator generates the conversion functions of the kinds that
+// are served in several API versions.
+type ConversionGenerator struct {
+	RootDir           string
+	Group             string
+	ModulePath        string
+	LicenseHeaderPath string
+}
+
+// GenerateHub marks the given resource as the storage version and the
+// conversion hub of its kind.
+func (cg *ConversionGenerator) GenerateHub(r *config.Resource) error {
+	if err := markStorageVersion(scope.TypesFilePath(cg.RootDir, cg.Group, r), r.Kind); err != nil {
+		return err
+	}
+	return cg.write(r, map[string]interface{}{
+		"Hub": true,
+	})
+}
+
+// GenerateSpoke writes the functions converting the given resource to and
+// from the given storage version of its kind.
+func (cg *ConversionGenerator) GenerateSpoke(r, hub *config.Resource, moves []Move) error {
+	jsonMoves := make([]jsonMove, 0, len(moves))
+	for _, m := range moves {
+		jm, err := toJSONMoves(r, hub, m)
+		if err != nil {
+			return errors.Wrapf(err, "cannot convert move of %s to %s", m.From, m.To)
+		}
+		jsonMoves = append(jsonMoves, jm...)
+	}
+	return cg.write(r, map[string]interface{}{
+		"Hub":      false,
+		"MovesVar": name.NewFromCamel(r.Kind).LowerCamelComputed + "Moves",
+		"Moves":    jsonMoves,
+	})
+}
+
+func (cg *ConversionGenerator) write(r *config.Resource, vars map[string]interface{}) error {
+	shortGroup := strings.ToLower(strings.Split(cg.Group, ".")[0])
+	pkgPath := filepath.Join(cg.ModulePath, "apis", shortGroup, r.Version)
+	file := wrapper.NewFile(pkgPath, r.Version, templates.ConversionTemplate,
+		wrapper.WithGenStatement(tjpipeline.GenStatement),
+		wrapper.WithHeaderPath(cg.LicenseHeaderPath),
+	)
+	vars["Package"] = r.Version
+	vars["CRD"] = map[string]string{
+		"Kind": r.Kind,
+	}
+	filePath := filepath.Join(cg.RootDir, "apis", shortGroup, r.Version, fmt.Sprintf("zz_%s_conversion.go", strings.ToLower(r.Kind)))
+	return errors.Wrap(file.Write(filePath, vars, os.ModePerm), "cannot write conversion file")
+}
+
+// markStorageVersion adds the storage version marker to the given kind in the
+// given generated types file.
+func markStorageVersion(filePath, kind string) error {
+	b, err := os.ReadFile(filepath.Clean(filePath))
+	if err != nil {
+		return errors.Wrapf(err, "cannot read types file %s", filePath)
+	}
+	decl := []byte(fmt.Sprintf("type %s struct {", kind))
+	if !bytes.Contains(b, decl) {
+		return errors.Errorf("cannot find the declaration of %s in types file %s", kind, filePath)
+	}
+	if bytes.Contains(b, []byte(storageVersionMarker)) {
+		return nil
+	}
+	b = bytes.Replace(b, decl, append([]byte(storageVersionMarker), decl...), 1)
+	return errors.Wrapf(os.WriteFile(filePath, b, 0600), "cannot write types file %s", filePath)
+}
+
+// toJSONMoves returns the moves of the JSON fields that the Terrajet type
+// builder generates for the field moved by the given move, in the
+// parameters and the observation of the resource.
+func toJSONMoves(r, hub *config.Resource, m Move) ([]jsonMove, error) {
+	fromPath := strings.Split(m.From, ".")
+	toPath := strings.Split(m.To, ".")
+	from, sch, err := jsonPath(r.TerraformResource, fromPath)
+	if err != nil {
+		return nil, err
+	}
+	to, _, err := jsonPath(hub.TerraformResource, toPath)
+	if err != nil {
+		return nil, err
+	}
+	if sch.Computed && !sch.Optional {
+		if sch.Sensitive {
+			// Sensitive observations are published as connection details.
+			return nil, nil
+		}
+		return []jsonMove{{From: pathAtProvider + "." + from, To: pathAtProvider + "." + to}}, nil
+	}
+	result := []jsonMove{{From: pathForProvider + "." + from, To: pathForProvider + "." + to}}
+	ref, ok := r.References[referencePath(fromPath)]
+	if !ok {
+		return result, nil
+	}
+	fromParent, fromName := splitLast(from)
+	toParent, toName := splitLast(to)
+	fromField := name.NewFromCamel(fromName).Camel
+	toField := name.NewFromCamel(toName).Camel
+	hubRef := hub.References[referencePath(toPath)]
+	return append(result,
+		jsonMove{
+			From: pathForProvider + "." + fromParent + referenceFieldName(fromField, sch, ref),
+			To:   pathForProvider + "." + toParent + referenceFieldName(toField, sch, hubRef),
+		},
+		jsonMove{
+			From: pathForProvider + "." + fromParent + selectorFieldName(fromField, ref),
+			To:   pathForProvider + "." + toParent + selectorFieldName(toField, hubRef),
+		},
+	), nil
+}
+
+// jsonPath returns the dot-separated JSON path of the field at the given
+// Terraform attribute path and its schema.
+func jsonPath(res *schema.Resource, tfPath []string) (string, *schema.Schema, error) {
+	var path []string
+	var sch *schema.Schema
+	for i, k := range tfPath {
+		s, ok := res.Schema[k]
+		if !ok {
+			return "", nil, errors.Errorf("no field named %s", strings.Join(tfPath[:i+1], "."))
+		}
+		fieldName := name.NewFromSnake(k)
+		jsonName := fieldName.LowerCamelComputed
+		if s.Sensitive {
+			jsonName = name.NewFromCamel(fieldName.Camel + "SecretRef").LowerCamelComputed
+		}
+		path = append(path, jsonName)
+		sch = s
+		if i == len(tfPath)-1 {
+			break
+		}
+		nested, ok := s.Elem.(*schema.Resource)
+		if !ok {
+			return "", nil, errors.Errorf("field %s is not a block", strings.Join(tfPath[:i+1], "."))
+		}
+		res = nested
+	}
+	return strings.Join(path, "."), sch, nil
+}
+
+// splitLast splits the given dot-separated path into its parent path,
+// including the trailing dot, and its last segment.
+func splitLast(path string) (string, string) {
+	i := strings.LastIndex(path, ".")
+	return path[:i+1], path[i+1:]
+}
+
+// EnableConversionWebhooks configures the CRDs in the given directory that
+// are served in several API versions to be converted by the conversion
+// webhook of the provider. The Crossplane package manager fills in the
+// client configuration of the webhook.
+func EnableConversionWebhooks(crdDir string) error {
+	files, err := filepath.Glob(filepath.Join(crdDir, "*.yaml"))
+	if err != nil {
+		return errors.Wrap(err, "cannot list CRD files")
+	}
+	for _, f := range files {
+		if err := enableConversionWebhook(f); err != nil {
+			return errors.Wrapf(err, "cannot enable conversion webhook of CRD file %s", f)
+		}
+	}
+	return nil
+}
+
+func enableConversionWebhook(filePath string) error {
+	b, err := os.ReadFile(filepath.Clean(filePath))
+	if err != nil {
+		return err
+	}
+	crd := map[string]interface{}{}
+	if err := yaml.Unmarshal(b, &crd); err != nil {
+		return err
+	}
+	versions, _, err := unstructured.NestedSlice(crd, "spec", "versions")
+	if err != nil || len(versions) < 2 {
+		return err
+	}
+	conversion := map[string]interface{}{
+		"strategy": "Webhook",
+		"webhook": map[string]interface{}{
+			"conversionReviewVersions": []interface{}{"v1"},
+		},
+	}
+	if err := unstructured.SetNestedMap(crd, conversion, "spec", "conversion"); err != nil {
+		return err
+	}
+	out, err := yaml.Marshal(crd)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, append([]byte("---\n"), out...), 0600)
+}
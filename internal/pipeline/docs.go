@@ -0,0 +1,276 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipeline
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/terrajet/pkg/config"
+	"github.com/crossplane/terrajet/pkg/types/name"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	providerconfig "github.com/crossplane-contrib/provider-jet-template/config"
+)
+
+const (
+	docsGenStatement = "<!-- Code generated by terrajet. DO NOT EDIT. -->"
+	registryURL      = "https://registry.terraform.io/providers"
+)
+
+// A DocResource is a resource to document with its scope.
+type DocResource struct {
+	Config     *config.Resource
+	Namespaced bool
+}
+
+// NewDocsGenerator returns a new DocsGenerator. The links to the upstream
+// Terraform documentation are omitted if providerSource is empty.
+func NewDocsGenerator(rootDir, providerSource, providerVersion string) *DocsGenerator {
+	return &DocsGenerator{
+		LocalDirectoryPath: filepath.Join(rootDir, "docs", "api"),
+		ProviderSource:     providerSource,
+		ProviderVersion:    providerVersion,
+	}
+}
+
+// DocsGenerator generates the markdown API reference of an API group version
+// out of the Terraform schemas of its resources.
+type DocsGenerator struct {
+	LocalDirectoryPath string
+	ProviderSource     string
+	ProviderVersion    string
+}
+
+// Generate writes the API reference of the given resources of the given API
+// group and version into docs/api/<short group>/zz_<version>.md.
+func (dg *DocsGenerator) Generate(group, version string, resources []DocResource) error {
+	sort.Slice(resources, func(i, j int) bool {
+		return resources[i].Config.Kind < resources[j].Config.Kind
+	})
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "%s\n\n# %s/%s\n\n", docsGenStatement, group, version)
+	for _, r := range resources {
+		dg.writeResource(buf, r)
+	}
+	dir := filepath.Join(dg.LocalDirectoryPath, strings.ToLower(strings.Split(group, ".")[0]))
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return errors.Wrapf(err, "cannot create directory %s", dir)
+	}
+	filePath := filepath.Join(dir, fmt.Sprintf("zz_%s.md", version))
+	return errors.Wrapf(os.WriteFile(filePath, buf.Bytes(), 0600), "cannot write API reference %s", filePath)
+}
+
+func (dg *DocsGenerator) writeResource(buf *bytes.Buffer, r DocResource) {
+	scope := "Cluster"
+	if r.Namespaced {
+		scope = "Namespaced"
+	}
+	fmt.Fprintf(buf, "## %s\n\n", r.Config.Kind)
+	if d := strings.TrimSpace(r.Config.TerraformResource.Description); d != "" {
+		fmt.Fprintf(buf, "%s\n\n", d)
+	}
+	fmt.Fprintf(buf, "- Terraform resource: `%s`\n- Scope: %s\n\n", r.Config.Name, scope)
+
+	params := &docTable{}
+	obs := &docTable{}
+	dg.collectFields(r.Config, r.Config.TerraformResource, nil, nil, params, obs)
+	params.write(buf, "spec.forProvider")
+	obs.write(buf, "status.atProvider")
+}
+
+// collectFields adds the fields of the given Terraform resource schema to the
+// parameter and observation tables, following the naming rules of the
+// Terrajet type builder.
+func (dg *DocsGenerator) collectFields(r *config.Resource, res *schema.Resource, tfPath, xpPath []string, params, obs *docTable) {
+	keys := make([]string, 0, len(res.Schema))
+	for k := range res.Schema {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	policies := providerconfig.ReplacementPoliciesOf(r.Name)
+	for _, k := range keys {
+		sch := res.Schema[k]
+		observation := sch.Computed && !sch.Optional
+		if observation && sch.Sensitive {
+			// Sensitive observations are published as connection details.
+			continue
+		}
+		fieldName := name.NewFromSnake(k)
+		jsonName := fieldName.LowerCamelComputed
+		if sch.Sensitive {
+			jsonName = name.NewFromCamel(fieldName.Camel + "SecretRef").LowerCamelComputed
+		}
+		tfp := append(append([]string{}, tfPath...), k)
+		xpp := append(append([]string{}, xpPath...), jsonName)
+		_, isRef := r.References[referencePath(tfp)]
+		row := docRow{
+			Path:        strings.Join(xpp, "."),
+			Type:        docType(sch),
+			Required:    !observation && !sch.Optional && !isRef,
+			Replacement: string(policies.Of(policyPath(tfp), sch)),
+			Sensitive:   sch.Sensitive,
+			Description: sch.Description,
+			Link:        dg.link(r.Name, k),
+		}
+		if sch.Default != nil {
+			row.Default = fmt.Sprint(sch.Default)
+		}
+		t := params
+		if observation {
+			t = obs
+		}
+		t.rows = append(t.rows, row)
+		if nested, ok := sch.Elem.(*schema.Resource); ok {
+			sfx := ""
+			if sch.Type != schema.TypeMap {
+				sfx = "[]"
+			}
+			xpp[len(xpp)-1] += sfx
+			np, no := params, obs
+			if observation {
+				np = obs
+			}
+			dg.collectFields(r, nested, append(tfp, wildcard), xpp, np, no)
+		}
+	}
+}
+
+// policyPath returns the dot-separated path of the field at the given
+// Terraform schema path, as keyed in the replacement policies.
+func policyPath(tfPath []string) string {
+	parts := make([]string, 0, len(tfPath))
+	for _, p := range tfPath {
+		if p != wildcard {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// link returns the link to the documentation of the given argument of the
+// given resource in the Terraform registry.
+func (dg *DocsGenerator) link(resource, argument string) string {
+	if dg.ProviderSource == "" {
+		return ""
+	}
+	parts := strings.Split(dg.ProviderSource, "/")
+	short := strings.TrimPrefix(resource, parts[len(parts)-1]+"_")
+	version := dg.ProviderVersion
+	if version == "" {
+		version = "latest"
+	}
+	return fmt.Sprintf("%s/%s/%s/docs/resources/%s#%s", registryURL, dg.ProviderSource, version, short, argument)
+}
+
+func docType(sch *schema.Schema) string {
+	if sch.Sensitive {
+		switch sch.Type {
+		case schema.TypeMap:
+			return "map[string]SecretKeySelector"
+		case schema.TypeList, schema.TypeSet:
+			return "[]SecretKeySelector"
+		default:
+			return "SecretKeySelector"
+		}
+	}
+	switch sch.Type {
+	case schema.TypeBool:
+		return "boolean"
+	case schema.TypeInt:
+		return "integer"
+	case schema.TypeFloat:
+		return "number"
+	case schema.TypeString:
+		return "string"
+	case schema.TypeMap, schema.TypeList, schema.TypeSet:
+		elem := "string"
+		switch et := sch.Elem.(type) {
+		case *schema.Resource:
+			elem = "object"
+		case *schema.Schema:
+			elem = docType(et)
+		case schema.ValueType:
+			elem = docType(&schema.Schema{Type: et})
+		}
+		if sch.Type == schema.TypeMap {
+			return "map[string]" + elem
+		}
+		return "[]" + elem
+	default:
+		return sch.Type.String()
+	}
+}
+
+type docRow struct {
+	Path        string
+	Type        string
+	Required    bool
+	Default     string
+	Replacement string
+	Sensitive   bool
+	Description string
+	Link        string
+}
+
+type docTable struct {
+	rows []docRow
+}
+
+func (t *docTable) write(buf *bytes.Buffer, title string) {
+	fmt.Fprintf(buf, "### %s\n\n", title)
+	if len(t.rows) == 0 {
+		buf.WriteString("No fields.\n\n")
+		return
+	}
+	buf.WriteString("| Field | Type | Required | Default | Replacement | Sensitive | Description |\n")
+	buf.WriteString("|-------|------|----------|---------|-------------|-----------|-------------|\n")
+	for _, r := range t.rows {
+		field := fmt.Sprintf("`%s`", r.Path)
+		if r.Link != "" {
+			field = fmt.Sprintf("[`%s`](%s)", r.Path, r.Link)
+		}
+		def := "-"
+		if r.Default != "" {
+			def = fmt.Sprintf("`%s`", r.Default)
+		}
+		replacement := "-"
+		if r.Replacement != "" {
+			replacement = r.Replacement
+		}
+		fmt.Fprintf(buf, "| %s | `%s` | %s | %s | %s | %s | %s |\n",
+			field, r.Type, yesNo(r.Required), def, replacement, yesNo(r.Sensitive), escapeCell(r.Description))
+	}
+	buf.WriteString("\n")
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "Yes"
+	}
+	return "No"
+}
+
+func escapeCell(s string) string {
+	s = strings.ReplaceAll(strings.TrimSpace(s), "|", "\\|")
+	return strings.ReplaceAll(s, "\n", " ")
+}
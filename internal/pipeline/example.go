@@ -0,0 +1,267 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipeline
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	"github.com/crossplane/terrajet/pkg/config"
+	"github.com/crossplane/terrajet/pkg/types/name"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	exampleGenStatement = "# Code generated by terrajet. DO NOT EDIT."
+	exampleNamespace    = "default"
+	wildcard            = "*"
+)
+
+// NewExampleGenerator returns a new ExampleGenerator.
+func NewExampleGenerator(rootDir string) *ExampleGenerator {
+	return &ExampleGenerator{
+		LocalDirectoryPath: filepath.Join(rootDir, "examples"),
+	}
+}
+
+// ExampleGenerator generates a minimal and a maximal example manifest for
+// managed resources out of their Terraform schemas. The minimal example sets
+// only the required arguments whereas the maximal one sets all of them.
+type ExampleGenerator struct {
+	LocalDirectoryPath string
+}
+
+// Generate writes the example manifests of the given resource of the given
+// API group and version into examples/<short group>.
+func (eg *ExampleGenerator) Generate(group, version string, r *config.Resource, namespaced bool) error {
+	dir := filepath.Join(eg.LocalDirectoryPath, strings.ToLower(strings.Split(group, ".")[0]))
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return errors.Wrapf(err, "cannot create directory %s", dir)
+	}
+	for _, variant := range []string{"minimal", "maximal"} {
+		doc := exampleManifest(group, version, r, variant, namespaced)
+		buf := &bytes.Buffer{}
+		buf.WriteString(exampleGenStatement + "\n\n")
+		enc := yaml.NewEncoder(buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(doc); err != nil {
+			return errors.Wrapf(err, "cannot encode %s example", variant)
+		}
+		filePath := filepath.Join(dir, fmt.Sprintf("zz_%s_%s.yaml", strings.ToLower(r.Kind), variant))
+		if err := os.WriteFile(filePath, buf.Bytes(), 0600); err != nil {
+			return errors.Wrapf(err, "cannot write example file %s", filePath)
+		}
+	}
+	return nil
+}
+
+func exampleManifest(group, version string, r *config.Resource, variant string, namespaced bool) *yaml.Node {
+	meta := mappingNode()
+	addField(meta, "name", "", scalarNode(fmt.Sprintf("example-%s-%s", strings.ToLower(r.Kind), variant)))
+	if namespaced {
+		addField(meta, "namespace", "", scalarNode(exampleNamespace))
+	}
+	pcRef := mappingNode()
+	addField(pcRef, "name", "", scalarNode("default"))
+	spec := mappingNode()
+	b := &exampleBuilder{config: r, all: variant == "maximal", secretNamespace: "crossplane-system"}
+	if namespaced {
+		b.secretNamespace = exampleNamespace
+	}
+	addField(spec, "forProvider", "", b.parameters(r.TerraformResource, nil))
+	addField(spec, "providerConfigRef", "", pcRef)
+
+	doc := mappingNode()
+	addField(doc, "apiVersion", "", scalarNode(group+"/"+version))
+	addField(doc, "kind", "", scalarNode(r.Kind))
+	addField(doc, "metadata", "", meta)
+	addField(doc, "spec", "", spec)
+	return doc
+}
+
+// exampleBuilder builds the example parameters of a resource.
+type exampleBuilder struct {
+	config *config.Resource
+	// all is true if the optional arguments should be set as well.
+	all bool
+	// secretNamespace is the namespace of the referenced Secrets.
+	secretNamespace string
+}
+
+// parameters returns an example of the parameters of the given Terraform
+// resource schema, following the naming rules of the Terrajet type builder.
+func (b *exampleBuilder) parameters(res *schema.Resource, tfPath []string) *yaml.Node {
+	n := mappingNode()
+	keys := make([]string, 0, len(res.Schema))
+	for k := range res.Schema {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		sch := res.Schema[k]
+		if sch.Computed && !sch.Optional {
+			continue
+		}
+		fieldName := name.NewFromSnake(k)
+		path := append(append([]string{}, tfPath...), k)
+		ref, isRef := b.config.References[referencePath(path)]
+		if !b.all && (sch.Optional || isRef) {
+			continue
+		}
+		camel := fieldName.Camel
+		jsonName := fieldName.LowerCamelComputed
+		if sch.Sensitive {
+			camel += "SecretRef"
+			jsonName = name.NewFromCamel(camel).LowerCamelComputed
+		}
+		if isRef {
+			addField(n, referenceFieldName(camel, sch, ref), "Reference to the resource that sets "+jsonName+".", exampleReference(sch, ref))
+			continue
+		}
+		addField(n, jsonName, sch.Description, b.value(sch, path))
+	}
+	return n
+}
+
+func (b *exampleBuilder) value(sch *schema.Schema, tfPath []string) *yaml.Node {
+	if sch.Sensitive {
+		return b.sensitive(sch)
+	}
+	switch sch.Type {
+	case schema.TypeBool:
+		return typedScalarNode("!!bool", "true")
+	case schema.TypeInt:
+		return typedScalarNode("!!int", "1")
+	case schema.TypeFloat:
+		return typedScalarNode("!!float", "1.5")
+	case schema.TypeMap, schema.TypeList, schema.TypeSet:
+		var elem *yaml.Node
+		switch et := sch.Elem.(type) {
+		case *schema.Resource:
+			elem = b.parameters(et, append(tfPath, wildcard))
+		case *schema.Schema:
+			elem = b.value(et, append(tfPath, wildcard))
+		case schema.ValueType:
+			elem = b.value(&schema.Schema{Type: et}, tfPath)
+		default:
+			elem = scalarNode("example")
+		}
+		if sch.Type == schema.TypeMap {
+			m := mappingNode()
+			addField(m, "example-key", "", elem)
+			return m
+		}
+		return sequenceNode(elem)
+	default:
+		return scalarNode("example")
+	}
+}
+
+func (b *exampleBuilder) sensitive(sch *schema.Schema) *yaml.Node {
+	sel := mappingNode()
+	addField(sel, "key", "", scalarNode("example-key"))
+	addField(sel, "name", "", scalarNode("example-secret"))
+	addField(sel, "namespace", "", scalarNode(b.secretNamespace))
+	switch sch.Type {
+	case schema.TypeMap:
+		m := mappingNode()
+		addField(m, "example-key", "", sel)
+		return m
+	case schema.TypeList, schema.TypeSet:
+		return sequenceNode(sel)
+	default:
+		return sel
+	}
+}
+
+func exampleReference(sch *schema.Schema, ref config.Reference) *yaml.Node {
+	kind := ref.Type[strings.LastIndex(ref.Type, ".")+1:]
+	r := mappingNode()
+	addField(r, "name", "", scalarNode("example-"+strings.ToLower(kind)))
+	if sch.Type == schema.TypeList || sch.Type == schema.TypeSet {
+		return sequenceNode(r)
+	}
+	return r
+}
+
+// referenceFieldName returns the JSON name of the reference field that the
+// Terrajet type builder generates for the given field.
+func referenceFieldName(fieldName string, sch *schema.Schema, ref config.Reference) string {
+	rfn := ref.RefFieldName
+	if rfn == "" {
+		rfn = fieldName + "Ref"
+		if sch.Type == schema.TypeList || sch.Type == schema.TypeSet {
+			rfn += "s"
+		}
+	}
+	return name.NewFromCamel(rfn).LowerCamelComputed
+}
+
+// selectorFieldName returns the JSON name of the selector field that the
+// Terrajet type builder generates for the given field.
+func selectorFieldName(fieldName string, ref config.Reference) string {
+	sfn := ref.SelectorFieldName
+	if sfn == "" {
+		sfn = fieldName + "Selector"
+	}
+	return name.NewFromCamel(sfn).LowerCamelComputed
+}
+
+// referencePath returns the key of the field at the given Terraform path in
+// the references of a resource configuration, the same way as the Terrajet
+// type builder does.
+func referencePath(parts []string) string {
+	seg := make(fieldpath.Segments, len(parts))
+	for i, p := range parts {
+		if p == wildcard {
+			continue
+		}
+		seg[i] = fieldpath.Field(p)
+	}
+	return seg.String()
+}
+
+func mappingNode() *yaml.Node {
+	return &yaml.Node{Kind: yaml.MappingNode}
+}
+
+func sequenceNode(elem *yaml.Node) *yaml.Node {
+	return &yaml.Node{Kind: yaml.SequenceNode, Content: []*yaml.Node{elem}}
+}
+
+func scalarNode(v string) *yaml.Node {
+	return typedScalarNode("!!str", v)
+}
+
+func typedScalarNode(tag, v string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: v}
+}
+
+func addField(m *yaml.Node, key, description string, v *yaml.Node) {
+	k := scalarNode(key)
+	if description != "" {
+		k.HeadComment = strings.TrimSpace(description)
+	}
+	m.Content = append(m.Content, k, v)
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipeline
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/terrajet/pkg/config"
+)
+
+// NewResourceFilter returns a predicate that selects the resources whose
+// Terraform names match any of the include globs, and none of the exclude
+// globs. All resources are included if no include globs are given. Globs
+// follow the syntax of path.Match.
+func NewResourceFilter(include, exclude []string) (func(name string) bool, error) {
+	for _, g := range append(append([]string{}, include...), exclude...) {
+		if _, err := path.Match(g, ""); err != nil {
+			return nil, errors.Wrapf(err, "invalid resource glob %q", g)
+		}
+	}
+	return func(name string) bool {
+		return matchAny(include, name, true) && !matchAny(exclude, name, false)
+	}, nil
+}
+
+// ResourceFiles returns the files that the pipeline generates for the
+// resources of the given provider configuration that satisfy the given
+// predicate, relative to the root directory. The files shared by several
+// resources, such as the setup of the controllers, are not returned.
+func ResourceFiles(pc *config.Provider, fn func(name string) bool) map[string]bool {
+	files := map[string]bool{}
+	for _, r := range pc.Resources {
+		if !fn(r.Name) {
+			continue
+		}
+		shortGroup := strings.ToLower(strings.Split(resourceGroup(r), ".")[0])
+		kind := strings.ToLower(r.Kind)
+		for _, f := range []string{
+			filepath.Join("apis", shortGroup, r.Version, fmt.Sprintf("zz_%s_types.go", kind)),
+			filepath.Join("apis", shortGroup, r.Version, fmt.Sprintf("zz_%s_conversion.go", kind)),
+			filepath.Join("internal", "controller", shortGroup, kind, "zz_controller.go"),
+			filepath.Join("internal", "controller", shortGroup, kind, "zz_webhook.go"),
+			filepath.Join("examples", shortGroup, fmt.Sprintf("zz_%s_minimal.yaml", kind)),
+			filepath.Join("examples", shortGroup, fmt.Sprintf("zz_%s_maximal.yaml", kind)),
+		} {
+			files[f] = true
+		}
+	}
+	return files
+}
+
+func matchAny(globs []string, name string, empty bool) bool {
+	if len(globs) == 0 {
+		return empty
+	}
+	for _, g := range globs {
+		if ok, _ := path.Match(g, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,240 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipeline
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/terrajet/pkg/config"
+	tjpipeline "github.com/crossplane/terrajet/pkg/pipeline"
+
+	providerconfig "github.com/crossplane-contrib/provider-jet-template/config"
+	"github.com/crossplane-contrib/provider-jet-template/internal/pipeline/scope"
+)
+
+// A RunOption configures the code generation pipeline.
+type RunOption func(*runOptions)
+
+type runOptions struct {
+	namespaced      func(name string) bool
+	spokes          func(name string) (Spoke, bool)
+	providerSource  string
+	providerVersion string
+}
+
+// WithNamespaced configures the pipeline to generate namespace-scoped CRDs
+// for the resources whose keys in the provider configuration satisfy the
+// given predicate.
+func WithNamespaced(fn func(name string) bool) RunOption {
+	return func(o *runOptions) {
+		o.namespaced = fn
+	}
+}
+
+// WithSpokes configures the pipeline to generate the resources whose keys in
+// the provider configuration satisfy the given function as API versions that
+// are converted to and from the storage versions of their kinds. Only the
+// storage versions are reconciled by controllers.
+func WithSpokes(fn func(name string) (Spoke, bool)) RunOption {
+	return func(o *runOptions) {
+		o.spokes = fn
+	}
+}
+
+// WithProviderSource configures the source and version of the Terraform
+// provider that the API reference links to.
+func WithProviderSource(source, version string) RunOption {
+	return func(o *runOptions) {
+		o.providerSource = source
+		o.providerVersion = version
+	}
+}
+
+// Run runs the Terrajet code generation pipeline and then regenerates the
+// controllers and their setup file using the templates of this provider, so
+// that they are wired to the provider-specific controller options.
+func Run(pc *config.Provider, rootDir string, opts ...RunOption) {
+	o := &runOptions{
+		namespaced: func(string) bool { return false },
+		spokes:     func(string) (Spoke, bool) { return Spoke{}, false },
+	}
+	for _, f := range opts {
+		f(o)
+	}
+	tjpipeline.Run(pc, rootDir)
+
+	// Add ProviderConfig controller package to the list of controller packages.
+	controllerPkgList := make([]string, 0)
+	for _, p := range pc.BasePackages.Controller {
+		controllerPkgList = append(controllerPkgList, filepath.Join(pc.ModulePath, p))
+	}
+	// Every generated controller package sets up the webhooks of its kind.
+	webhookPkgList, docs, spokes, err := generateResources(pc, rootDir, o)
+	if err != nil {
+		panic(err)
+	}
+	controllerPkgList = append(controllerPkgList, webhookPkgList...)
+	if err := generateConversions(pc, rootDir, spokes); err != nil {
+		panic(errors.Wrap(err, "cannot generate conversion functions"))
+	}
+	if err := generateDocs(rootDir, o, docs); err != nil {
+		panic(errors.Wrap(err, "cannot generate API reference"))
+	}
+	if err := NewSetupGenerator(rootDir, pc.ModulePath).Generate(controllerPkgList, webhookPkgList); err != nil {
+		panic(errors.Wrap(err, "cannot generate setup file"))
+	}
+
+	// NOTE(muvaf): gosec linter requires that the whole command is hard-coded.
+	// So, we set the directory of the command instead of passing in the directory
+	// as an argument to "find".
+	internalCmd := exec.Command("bash", "-c", "goimports -w $(find . -iname 'zz_*')")
+	internalCmd.Dir = filepath.Clean(filepath.Join(rootDir, "internal"))
+	if out, err := internalCmd.CombinedOutput(); err != nil {
+		panic(errors.Wrap(err, "cannot run goimports for internal folder: "+string(out)))
+	}
+	fmt.Printf("Regenerated %d controllers!\n", len(pc.Resources))
+}
+
+// generateResources generates the provider-specific files of all resources.
+// It returns the package paths of their controllers, the resources to
+// document keyed by API group and version, and the API versions that are
+// converted to and from the storage versions of their kinds.
+func generateResources(pc *config.Provider, rootDir string, o *runOptions) ([]string, map[string]map[string][]DocResource, map[string]Spoke, error) {
+	var pkgs []string
+	docs := map[string]map[string][]DocResource{}
+	spokes := map[string]Spoke{}
+	for _, name := range sortedResources(pc.Resources) {
+		r := pc.Resources[name]
+		group := resourceGroup(r)
+		namespaced := o.namespaced(name)
+		if docs[group] == nil {
+			docs[group] = map[string][]DocResource{}
+		}
+		docs[group][r.Version] = append(docs[group][r.Version], DocResource{Config: r, Namespaced: namespaced})
+		spoke, isSpoke := o.spokes(name)
+		if isSpoke {
+			spokes[name] = spoke
+		}
+		ctrlPkgPath, err := generateResource(pc, rootDir, group, r, namespaced, isSpoke)
+		if err != nil {
+			return nil, nil, nil, errors.Wrapf(err, "cannot generate resource %s", name)
+		}
+		if ctrlPkgPath != "" {
+			pkgs = append(pkgs, ctrlPkgPath)
+		}
+	}
+	return pkgs, docs, spokes, nil
+}
+
+// generateResource generates the provider-specific files of the given
+// resource and returns the package path of its controller. API versions
+// other than the storage version are neither reconciled nor exemplified, so
+// no package path is returned for them.
+func generateResource(pc *config.Provider, rootDir, group string, r *config.Resource, namespaced, spoke bool) (string, error) {
+	if namespaced {
+		if err := scope.MakeNamespaced(rootDir, group, r); err != nil {
+			return "", errors.Wrap(err, "cannot make resource namespace-scoped")
+		}
+	}
+	if spoke {
+		return "", nil
+	}
+	if err := NewExampleGenerator(rootDir).Generate(group, r.Version, r, namespaced); err != nil {
+		return "", errors.Wrap(err, "cannot generate examples")
+	}
+	versionGen := tjpipeline.NewVersionGenerator(rootDir, pc.ModulePath, group, r.Version)
+	cg := NewControllerGenerator(rootDir, pc.ModulePath, group)
+	ctrlPkgPath, err := cg.Generate(r, versionGen.Package().Path())
+	if err != nil {
+		return "", errors.Wrap(err, "cannot generate controller")
+	}
+	return ctrlPkgPath, errors.Wrap(cg.GenerateWebhook(r, versionGen.Package().Path(), r.Version), "cannot generate webhook")
+}
+
+// generateDocs generates the API reference of the given resources, keyed by
+// API group and version.
+func generateDocs(rootDir string, o *runOptions, docs map[string]map[string][]DocResource) error {
+	dg := NewDocsGenerator(rootDir, o.providerSource, o.providerVersion)
+	for group, versions := range docs {
+		for version, resources := range versions {
+			if err := dg.Generate(group, version, resources); err != nil {
+				return errors.Wrapf(err, "cannot generate API reference for %s/%s", group, version)
+			}
+		}
+	}
+	return nil
+}
+
+// generateConversions generates the conversion functions of the given API
+// versions, keyed by the keys of the resources in the provider configuration,
+// and marks the storage versions of their kinds.
+func generateConversions(pc *config.Provider, rootDir string, spokes map[string]Spoke) error {
+	hubs := map[string]bool{}
+	for _, name := range sortedSpokes(spokes) {
+		spoke := spokes[name]
+		r, hub := pc.Resources[name], pc.Resources[spoke.Hub]
+		if hub == nil {
+			return errors.Errorf("no storage version %s of resource %s", spoke.Hub, name)
+		}
+		if err := NewConversionGenerator(rootDir, pc.ModulePath, resourceGroup(r)).GenerateSpoke(r, hub, spoke.Moves); err != nil {
+			return errors.Wrapf(err, "cannot generate conversion functions of resource %s", name)
+		}
+		if hubs[spoke.Hub] {
+			continue
+		}
+		hubs[spoke.Hub] = true
+		if err := NewConversionGenerator(rootDir, pc.ModulePath, resourceGroup(hub)).GenerateHub(hub); err != nil {
+			return errors.Wrapf(err, "cannot generate conversion hub of resource %s", spoke.Hub)
+		}
+	}
+	return nil
+}
+
+// resourceGroup returns the API group of the given resource, which is based
+// on the root group of its upstream rather than the one of the provider.
+func resourceGroup(r *config.Resource) string {
+	root := providerconfig.RootGroupOf(r.Name)
+	if r.ShortGroup != "" {
+		return strings.ToLower(r.ShortGroup) + "." + root
+	}
+	return root
+}
+
+func sortedSpokes(m map[string]Spoke) []string {
+	result := make([]string, 0, len(m))
+	for k := range m {
+		result = append(result, k)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func sortedResources(m map[string]*config.Resource) []string {
+	result := make([]string, len(m))
+	i := 0
+	for g := range m {
+		result[i] = g
+		i++
+	}
+	sort.Strings(result)
+	return result
+}
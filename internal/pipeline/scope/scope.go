@@ -0,0 +1,58 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package scope sets the scope of the CRDs that the generation pipeline
+// generates. It does not import the generation pipeline of Terrajet, so that
+// it can be tested without it.
+package scope
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/terrajet/pkg/config"
+)
+
+const (
+	clusterScopeMarker    = "+kubebuilder:resource:scope=Cluster,"
+	namespacedScopeMarker = "+kubebuilder:resource:scope=Namespaced,"
+)
+
+// MakeNamespaced rewrites the scope marker of the generated types file of the
+// given resource in the given API group so that its CRD is namespace-scoped.
+// Terrajet always generates cluster-scoped CRDs.
+func MakeNamespaced(rootDir, group string, r *config.Resource) error {
+	filePath := TypesFilePath(rootDir, group, r)
+	b, err := os.ReadFile(filepath.Clean(filePath))
+	if err != nil {
+		return errors.Wrapf(err, "cannot read types file %s", filePath)
+	}
+	if !bytes.Contains(b, []byte(clusterScopeMarker)) {
+		return errors.Errorf("cannot find the scope marker in types file %s", filePath)
+	}
+	b = bytes.Replace(b, []byte(clusterScopeMarker), []byte(namespacedScopeMarker), 1)
+	return errors.Wrapf(os.WriteFile(filePath, b, 0600), "cannot write types file %s", filePath)
+}
+
+// TypesFilePath returns the path of the generated types file of the given
+// resource in the given API group.
+func TypesFilePath(rootDir, group string, r *config.Resource) string {
+	return filepath.Join(rootDir, "apis", strings.ToLower(strings.Split(group, ".")[0]), r.Version, fmt.Sprintf("zz_%s_types.go", strings.ToLower(r.Kind)))
+}
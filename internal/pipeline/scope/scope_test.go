@@ -0,0 +1,92 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scope
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/crossplane/terrajet/pkg/config"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMakeNamespaced(t *testing.T) {
+	r := &config.Resource{Kind: "Resource", Version: "v1alpha1"}
+	types := `// +kubebuilder:object:root=true
+
+// Resource is the Schema for the Resources API
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster,categories={crossplane,managed,null}
+type Resource struct {}
+`
+	type want struct {
+		types string
+		err   bool
+	}
+	cases := map[string]struct {
+		reason string
+		types  *string
+		want   want
+	}{
+		"ClusterScoped": {
+			reason: "The scope marker of the types file should be made namespaced.",
+			types:  &types,
+			want: want{types: `// +kubebuilder:object:root=true
+
+// Resource is the Schema for the Resources API
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Namespaced,categories={crossplane,managed,null}
+type Resource struct {}
+`},
+		},
+		"NoMarker": {
+			reason: "A types file without a cluster scope marker should be an error.",
+			types:  func() *string { s := "type Resource struct {}\n"; return &s }(),
+			want:   want{types: "type Resource struct {}\n", err: true},
+		},
+		"NoTypesFile": {
+			reason: "A missing types file should be an error.",
+			want:   want{err: true},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			root := t.TempDir()
+			path := TypesFilePath(root, "null.template.jet.crossplane.io", r)
+			if diff := cmp.Diff(filepath.Join(root, "apis", "null", "v1alpha1", "zz_resource_types.go"), path); diff != "" {
+				t.Errorf("\n%s\nTypesFilePath(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if tc.types != nil {
+				if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(*tc.types), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := MakeNamespaced(root, "null.template.jet.crossplane.io", r)
+			if diff := cmp.Diff(tc.want.err, err != nil); diff != "" {
+				t.Errorf("\n%s\nMakeNamespaced(...): -want error, +got error:\n%s\n%v", tc.reason, diff, err)
+			}
+			got, _ := os.ReadFile(filepath.Clean(path))
+			if diff := cmp.Diff(tc.want.types, string(got)); diff != "" {
+				t.Errorf("\n%s\nMakeNamespaced(...): -want types, +got types:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templates
+
+import _ "embed" // nolint:golint
+
+// ControllerTemplate is populated with controller setup functions. It differs
+// from the Terrajet template in that the generated controllers are wired to
+// the provider-specific options and external connector of this provider.
+//
+//go:embed controller.go.tmpl
+var ControllerTemplate string
+
+// SetupTemplate is populated with controller setup calls.
+//
+//go:embed setup.go.tmpl
+var SetupTemplate string
+
+// ConversionTemplate is populated with the conversion functions of an API
+// version of a kind, or with the hub marker of its storage version.
+//
+//go:embed conversion.go.tmpl
+var ConversionTemplate string
+
+// WebhookTemplate is populated with the setup function of the defaulting
+// and validating webhooks of a kind.
+//
+//go:embed webhook.go.tmpl
+var WebhookTemplate string
@@ -0,0 +1,56 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipeline
+
+import (
+	"os/exec"
+	"path/filepath"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+)
+
+// RunTools runs the tools that go generate runs after the code generation
+// pipeline in the given root directory, as in apis/generate.go: controller-gen
+// writes the deepcopy functions, the CRDs and the webhook configurations, the
+// CRDs served in several API versions are configured for the conversion
+// webhook, and angryjet writes the method sets of the managed resources.
+func RunTools(rootDir string) error {
+	apisDir := filepath.Join(rootDir, "apis")
+	controllerGen := exec.Command("go", "run", "-tags", "generate", "sigs.k8s.io/controller-tools/cmd/controller-gen",
+		"object:headerFile=../hack/boilerplate.go.txt", "paths=./...", "crd:allowDangerousTypes=true,crdVersions=v1", "output:artifacts:config=../package/crds")
+	webhookGen := exec.Command("go", "run", "-tags", "generate", "sigs.k8s.io/controller-tools/cmd/controller-gen",
+		"webhook", "paths=../internal/controller/...", "output:webhook:artifacts:config=../package/webhookconfigurations")
+	for _, cmd := range []*exec.Cmd{controllerGen, webhookGen} {
+		if err := run(cmd, apisDir); err != nil {
+			return err
+		}
+	}
+	if err := EnableConversionWebhooks(filepath.Join(rootDir, "package", "crds")); err != nil {
+		return errors.Wrap(err, "cannot enable conversion webhooks")
+	}
+	angryjet := exec.Command("go", "run", "-tags", "generate", "github.com/crossplane/crossplane-tools/cmd/angryjet",
+		"generate-methodsets", "--header-file=../hack/boilerplate.go.txt", "./...")
+	return run(angryjet, apisDir)
+}
+
+func run(cmd *exec.Cmd, dir string) error {
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return errors.Wrapf(err, "cannot run %s: %s", filepath.Base(cmd.Args[3]), string(out))
+	}
+	return nil
+}
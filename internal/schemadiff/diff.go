@@ -0,0 +1,187 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schemadiff
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ChangeType is the type of a schema change.
+type ChangeType string
+
+// Types of schema changes.
+const (
+	ResourceAdded         ChangeType = "ResourceAdded"
+	ResourceRemoved       ChangeType = "ResourceRemoved"
+	VersionAdded          ChangeType = "VersionAdded"
+	VersionRemoved        ChangeType = "VersionRemoved"
+	OptionalFieldAdded    ChangeType = "OptionalFieldAdded"
+	RequiredFieldAdded    ChangeType = "RequiredFieldAdded"
+	FieldRemoved          ChangeType = "FieldRemoved"
+	TypeChanged           ChangeType = "TypeChanged"
+	FieldBecameRequired   ChangeType = "FieldBecameRequired"
+	FieldBecameOptional   ChangeType = "FieldBecameOptional"
+	FieldBecameForceNew   ChangeType = "FieldBecameForceNew"
+	FieldNoLongerForceNew ChangeType = "FieldNoLongerForceNew"
+)
+
+// breaking are the types of changes that break existing users of the API.
+var breaking = map[ChangeType]bool{
+	ResourceRemoved:     true,
+	VersionRemoved:      true,
+	RequiredFieldAdded:  true,
+	FieldRemoved:        true,
+	TypeChanged:         true,
+	FieldBecameRequired: true,
+	FieldBecameForceNew: true,
+}
+
+// A Change is a classified difference between two schemas.
+type Change struct {
+	Type     ChangeType `json:"type"`
+	Resource string     `json:"resource"`
+	Version  string     `json:"version,omitempty"`
+	Field    string     `json:"field,omitempty"`
+	Detail   string     `json:"detail,omitempty"`
+	Breaking bool       `json:"breaking"`
+	// Mitigated is true if the change is breaking but the resource has a
+	// new API version that the users can migrate to.
+	Mitigated bool `json:"mitigated,omitempty"`
+}
+
+// A Report is the machine-readable result of a schema comparison.
+type Report struct {
+	// Breaking is true if there is any breaking change that is not
+	// mitigated by a new API version.
+	Breaking bool     `json:"breaking"`
+	Changes  []Change `json:"changes"`
+}
+
+// Compare returns the report of the changes between the given schemas.
+// Breaking changes of a resource are mitigated if a new API version of the
+// resource is introduced.
+func Compare(from, to *Schema) *Report { //nolint:gocyclo
+	// NOTE: The cyclomatic complexity is due to the symmetric handling of
+	// the resources that exist in only one of the schemas.
+	oldNames := resourceNames(from)
+	newNames := resourceNames(to)
+	var changes []Change
+	for _, k := range sortedKeys(from.Resources) {
+		o := from.Resources[k]
+		n, ok := to.Resources[k]
+		switch {
+		case ok:
+			changes = append(changes, compareFields(o, n)...)
+		case o.Version != "" && newNames[o.Name]:
+			changes = append(changes, newChange(VersionRemoved, o, "", ""))
+		default:
+			changes = append(changes, newChange(ResourceRemoved, o, "", ""))
+		}
+	}
+	newVersion := map[string]bool{}
+	for _, k := range sortedKeys(to.Resources) {
+		n := to.Resources[k]
+		if _, ok := from.Resources[k]; ok {
+			continue
+		}
+		if n.Version != "" && oldNames[n.Name] {
+			newVersion[n.Name] = true
+			changes = append(changes, newChange(VersionAdded, n, "", ""))
+			continue
+		}
+		changes = append(changes, newChange(ResourceAdded, n, "", ""))
+	}
+	r := &Report{Changes: changes}
+	for i := range r.Changes {
+		c := &r.Changes[i]
+		c.Mitigated = c.Breaking && newVersion[c.Resource]
+		r.Breaking = r.Breaking || (c.Breaking && !c.Mitigated)
+	}
+	if r.Changes == nil {
+		r.Changes = []Change{}
+	}
+	return r
+}
+
+func compareFields(o, n *Resource) []Change {
+	paths := make([]string, 0, len(o.Fields)+len(n.Fields))
+	for p := range o.Fields {
+		paths = append(paths, p)
+	}
+	for p := range n.Fields {
+		if _, ok := o.Fields[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+	var changes []Change
+	for _, p := range paths {
+		of, inOld := o.Fields[p]
+		nf, inNew := n.Fields[p]
+		switch {
+		case !inNew:
+			changes = append(changes, newChange(FieldRemoved, o, p, ""))
+		case !inOld && nf.Required:
+			changes = append(changes, newChange(RequiredFieldAdded, n, p, ""))
+		case !inOld:
+			changes = append(changes, newChange(OptionalFieldAdded, n, p, ""))
+		default:
+			changes = append(changes, compareField(n, p, of, nf)...)
+		}
+	}
+	return changes
+}
+
+func compareField(r *Resource, path string, o, n Field) []Change {
+	var changes []Change
+	if o.Type != n.Type {
+		changes = append(changes, newChange(TypeChanged, r, path, fmt.Sprintf("%s -> %s", o.Type, n.Type)))
+	}
+	if !o.Required && n.Required {
+		changes = append(changes, newChange(FieldBecameRequired, r, path, ""))
+	}
+	if o.Required && !n.Required {
+		changes = append(changes, newChange(FieldBecameOptional, r, path, ""))
+	}
+	if !o.ForceNew && n.ForceNew {
+		changes = append(changes, newChange(FieldBecameForceNew, r, path, ""))
+	}
+	if o.ForceNew && !n.ForceNew {
+		changes = append(changes, newChange(FieldNoLongerForceNew, r, path, ""))
+	}
+	return changes
+}
+
+func newChange(t ChangeType, r *Resource, field, detail string) Change {
+	return Change{
+		Type:     t,
+		Resource: r.Name,
+		Version:  r.Version,
+		Field:    field,
+		Detail:   detail,
+		Breaking: breaking[t],
+	}
+}
+
+func resourceNames(s *Schema) map[string]bool {
+	result := map[string]bool{}
+	for _, r := range s.Resources {
+		result[r.Name] = true
+	}
+	return result
+}
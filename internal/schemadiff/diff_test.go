@@ -0,0 +1,141 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schemadiff
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCompare(t *testing.T) {
+	resource := func(version string, fields map[string]Field) *Resource {
+		return &Resource{Name: "null_resource", Version: version, Fields: fields}
+	}
+	schema := func(rs ...*Resource) *Schema {
+		s := &Schema{Resources: map[string]*Resource{}}
+		for _, r := range rs {
+			s.Resources[r.key()] = r
+		}
+		return s
+	}
+	change := func(t ChangeType, version, field, detail string) Change {
+		return Change{Type: t, Resource: "null_resource", Version: version, Field: field, Detail: detail, Breaking: breaking[t]}
+	}
+	cases := map[string]struct {
+		reason string
+		from   *Schema
+		to     *Schema
+		want   *Report
+	}{
+		"NoChanges": {
+			reason: "Identical schemas should have no changes.",
+			from:   schema(resource("", map[string]Field{"id": {Type: "string"}})),
+			to:     schema(resource("", map[string]Field{"id": {Type: "string"}})),
+			want:   &Report{Changes: []Change{}},
+		},
+		"Fields": {
+			reason: "Every kind of field change should be classified, and the breaking ones should break the API.",
+			from: schema(resource("", map[string]Field{
+				"removed":   {Type: "string"},
+				"retyped":   {Type: "string"},
+				"required":  {Type: "string"},
+				"optional":  {Type: "string", Required: true},
+				"forcenew":  {Type: "string"},
+				"updatable": {Type: "string", ForceNew: true},
+			})),
+			to: schema(resource("", map[string]Field{
+				"retyped":   {Type: "number"},
+				"required":  {Type: "string", Required: true},
+				"optional":  {Type: "string"},
+				"forcenew":  {Type: "string", ForceNew: true},
+				"updatable": {Type: "string"},
+				"added":     {Type: "string"},
+				"needed":    {Type: "string", Required: true},
+			})),
+			want: &Report{
+				Breaking: true,
+				Changes: []Change{
+					change(OptionalFieldAdded, "", "added", ""),
+					change(FieldBecameForceNew, "", "forcenew", ""),
+					change(RequiredFieldAdded, "", "needed", ""),
+					change(FieldBecameOptional, "", "optional", ""),
+					change(FieldRemoved, "", "removed", ""),
+					change(FieldBecameRequired, "", "required", ""),
+					change(TypeChanged, "", "retyped", "string -> number"),
+					change(FieldNoLongerForceNew, "", "updatable", ""),
+				},
+			},
+		},
+		"NonBreaking": {
+			reason: "Changes that do not break the users of the API should not break it.",
+			from:   schema(resource("", map[string]Field{"id": {Type: "string", ForceNew: true}})),
+			to:     schema(resource("", map[string]Field{"id": {Type: "string"}, "added": {Type: "string"}})),
+			want: &Report{Changes: []Change{
+				change(OptionalFieldAdded, "", "added", ""),
+				change(FieldNoLongerForceNew, "", "id", ""),
+			}},
+		},
+		"Resources": {
+			reason: "Removed resources should break the API and added ones should not.",
+			from:   schema(&Resource{Name: "removed", Fields: map[string]Field{}}),
+			to:     schema(&Resource{Name: "added", Fields: map[string]Field{}}),
+			want: &Report{
+				Breaking: true,
+				Changes: []Change{
+					{Type: ResourceRemoved, Resource: "removed", Breaking: true},
+					{Type: ResourceAdded, Resource: "added"},
+				},
+			},
+		},
+		"MitigatedByNewVersion": {
+			reason: "Breaking changes of a resource should be mitigated if the resource gets a new API version.",
+			from: schema(
+				resource("v1alpha1", map[string]Field{"spec.forProvider.id": {Type: "string"}}),
+			),
+			to: schema(
+				resource("v1alpha1", map[string]Field{"spec.forProvider.id": {Type: "string", ForceNew: true}}),
+				resource("v1alpha2", map[string]Field{"spec.forProvider.id": {Type: "string", ForceNew: true}}),
+			),
+			want: &Report{Changes: []Change{
+				func() Change {
+					c := change(FieldBecameForceNew, "v1alpha1", "spec.forProvider.id", "")
+					c.Mitigated = true
+					return c
+				}(),
+				change(VersionAdded, "v1alpha2", "", ""),
+			}},
+		},
+		"VersionRemoved": {
+			reason: "Removing an API version of a resource should break the API.",
+			from:   schema(resource("v1alpha1", map[string]Field{}), resource("v1alpha2", map[string]Field{})),
+			to:     schema(resource("v1alpha2", map[string]Field{})),
+			want: &Report{
+				Breaking: true,
+				Changes:  []Change{change(VersionRemoved, "v1alpha1", "", "")},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := Compare(tc.from, tc.to)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nCompare(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,239 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package schemadiff compares the schemas of two versions of the provider,
+// either as Terraform provider schemas or as generated CRDs, and classifies
+// the changes by their impact on the users of the API.
+package schemadiff
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/pkg/errors"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// immutableRule is the CEL rule that marks a CRD field as immutable.
+const immutableRule = "self == oldSelf"
+
+// A Schema is the flattened schema of a set of resources.
+type Schema struct {
+	// Resources are keyed by their names, suffixed with their API versions
+	// if they have one.
+	Resources map[string]*Resource
+}
+
+// A Resource is the flattened schema of a resource.
+type Resource struct {
+	// Name of the resource, i.e. the Terraform resource type or the CRD
+	// name.
+	Name string
+	// Version is the API version of the resource. Terraform resources have
+	// no API version.
+	Version string
+	// Fields are keyed by their dot-separated paths.
+	Fields map[string]Field
+}
+
+// A Field is a field of a resource.
+type Field struct {
+	Type     string
+	Required bool
+	// ForceNew is true if changing the field recreates the external
+	// resource, which makes it immutable in the API.
+	ForceNew bool
+}
+
+func (r *Resource) key() string {
+	if r.Version == "" {
+		return r.Name
+	}
+	return r.Name + "/" + r.Version
+}
+
+// Load loads the schema at the given path. Directories are read as sets of
+// CRD manifests and files as Terraform provider schemas in the format of
+// "terraform providers schema -json".
+func Load(path string) (*Schema, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot stat %s", path)
+	}
+	if fi.IsDir() {
+		return LoadCRDs(path)
+	}
+	return LoadTerraformSchema(path)
+}
+
+// LoadTerraformSchema loads the resource schemas of the Terraform provider
+// schema file at the given path.
+func LoadTerraformSchema(path string) (*Schema, error) {
+	b, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read %s", path)
+	}
+	ps := &tfjson.ProviderSchemas{}
+	if err := ps.UnmarshalJSON(b); err != nil {
+		return nil, errors.Wrapf(err, "cannot unmarshal provider schema %s", path)
+	}
+	s := &Schema{Resources: map[string]*Resource{}}
+	for _, p := range ps.Schemas {
+		for name, rs := range p.ResourceSchemas {
+			r := &Resource{Name: name, Fields: map[string]Field{}}
+			if rs.Block != nil {
+				flattenBlock(rs.Block, "", r.Fields)
+			}
+			s.Resources[r.key()] = r
+		}
+	}
+	return s, nil
+}
+
+// MarkForceNew marks the fields at the given dot-separated paths of the
+// Terraform resources with the given names as ForceNew. The provider schemas
+// do not tell which arguments force the replacement of a resource, so they
+// are read from the replacement policies of resources.yaml instead. Paths
+// that do not exist in the schema are ignored.
+func (s *Schema) MarkForceNew(fields map[string][]string) {
+	for name, paths := range fields {
+		r, ok := s.Resources[name]
+		if !ok || r.Version != "" {
+			continue
+		}
+		for _, p := range paths {
+			if f, ok := r.Fields[p]; ok {
+				f.ForceNew = true
+				r.Fields[p] = f
+			}
+		}
+	}
+}
+
+func flattenBlock(b *tfjson.SchemaBlock, prefix string, into map[string]Field) {
+	for name, a := range b.Attributes {
+		into[prefix+name] = Field{
+			Type:     a.AttributeType.FriendlyName(),
+			Required: a.Required,
+		}
+	}
+	for name, nb := range b.NestedBlocks {
+		into[prefix+name] = Field{
+			Type:     string(nb.NestingMode) + " of object",
+			Required: nb.MinItems > 0,
+		}
+		if nb.Block != nil {
+			flattenBlock(nb.Block, prefix+name+".", into)
+		}
+	}
+}
+
+// LoadCRDs loads the schemas of all versions of the CRDs in the given
+// directory.
+func LoadCRDs(dir string) (*Schema, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot list CRDs in %s", dir)
+	}
+	s := &Schema{Resources: map[string]*Resource{}}
+	for _, f := range files {
+		b, err := os.ReadFile(filepath.Clean(f))
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot read %s", f)
+		}
+		crd := &extv1.CustomResourceDefinition{}
+		if err := yaml.Unmarshal(b, crd); err != nil {
+			return nil, errors.Wrapf(err, "cannot unmarshal CRD %s", f)
+		}
+		for _, v := range crd.Spec.Versions {
+			r := &Resource{Name: crd.Name, Version: v.Name, Fields: map[string]Field{}}
+			if v.Schema != nil && v.Schema.OpenAPIV3Schema != nil {
+				root := v.Schema.OpenAPIV3Schema
+				for _, top := range []string{"spec", "status"} {
+					if p, ok := root.Properties[top]; ok {
+						flattenProps(&p, top, false, r.Fields)
+					}
+				}
+			}
+			s.Resources[r.key()] = r
+		}
+	}
+	return s, nil
+}
+
+func flattenProps(p *extv1.JSONSchemaProps, path string, required bool, into map[string]Field) {
+	into[path] = Field{
+		Type:     propsType(p),
+		Required: required,
+		ForceNew: isImmutable(p),
+	}
+	req := map[string]bool{}
+	for _, r := range p.Required {
+		req[r] = true
+	}
+	for name, c := range p.Properties {
+		c := c
+		flattenProps(&c, path+"."+name, req[name], into)
+	}
+	if p.Items != nil && p.Items.Schema != nil {
+		for name, c := range p.Items.Schema.Properties {
+			c := c
+			flattenProps(&c, path+"[]."+name, contains(p.Items.Schema.Required, name), into)
+		}
+	}
+}
+
+func propsType(p *extv1.JSONSchemaProps) string {
+	switch {
+	case p.Type == "array" && p.Items != nil && p.Items.Schema != nil:
+		return "array of " + propsType(p.Items.Schema)
+	case p.Type == "object" && p.AdditionalProperties != nil && p.AdditionalProperties.Schema != nil:
+		return "map of " + propsType(p.AdditionalProperties.Schema)
+	default:
+		return p.Type
+	}
+}
+
+func isImmutable(p *extv1.JSONSchemaProps) bool {
+	for _, v := range p.XValidations {
+		if strings.TrimSpace(v.Rule) == immutableRule {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(l []string, s string) bool {
+	for _, e := range l {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]*Resource) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
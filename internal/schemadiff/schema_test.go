@@ -0,0 +1,138 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schemadiff
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const providerSchema = `{
+  "format_version": "1.0",
+  "provider_schemas": {
+    "registry.terraform.io/hashicorp/null": {
+      "resource_schemas": {
+        "null_resource": {
+          "version": 0,
+          "block": {
+            "attributes": {
+              "id": {"type": "string", "computed": true},
+              "triggers": {"type": ["map", "string"], "optional": true}
+            },
+            "block_types": {
+              "setting": {
+                "nesting_mode": "list",
+                "min_items": 1,
+                "block": {"attributes": {"name": {"type": "string", "required": true}}}
+              }
+            }
+          }
+        }
+      }
+    }
+  }
+}`
+
+const crd = `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: resources.null.template.jet.crossplane.io
+spec:
+  versions:
+  - name: v1alpha1
+    schema:
+      openAPIV3Schema:
+        type: object
+        properties:
+          spec:
+            type: object
+            required:
+            - forProvider
+            properties:
+              forProvider:
+                type: object
+                properties:
+                  name:
+                    type: string
+                    x-kubernetes-validations:
+                    - rule: self == oldSelf
+                  tags:
+                    type: array
+                    items:
+                      type: string
+`
+
+func TestLoadTerraformSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema.json")
+	if err := os.WriteFile(path, []byte(providerSchema), 0600); err != nil {
+		t.Fatal(err)
+	}
+	s, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load(...): %s", err)
+	}
+	s.MarkForceNew(map[string][]string{
+		"null_resource": {"triggers", "setting.name", "unknown"},
+		"null_unknown":  {"triggers"},
+	})
+	want := &Schema{Resources: map[string]*Resource{
+		"null_resource": {
+			Name: "null_resource",
+			Fields: map[string]Field{
+				"id":           {Type: "string"},
+				"triggers":     {Type: "map of string", ForceNew: true},
+				"setting":      {Type: "list of object", Required: true},
+				"setting.name": {Type: "string", Required: true, ForceNew: true},
+			},
+		},
+	}}
+	if diff := cmp.Diff(want, s); diff != "" {
+		t.Errorf("Load(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestLoadCRDs(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "crd.yaml"), []byte(crd), 0600); err != nil {
+		t.Fatal(err)
+	}
+	s, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load(...): %s", err)
+	}
+	// Only Terraform resources get their ForceNew fields from the
+	// replacement policies.
+	s.MarkForceNew(map[string][]string{"resources.null.template.jet.crossplane.io": {"spec.forProvider.tags"}})
+	want := &Schema{Resources: map[string]*Resource{
+		"resources.null.template.jet.crossplane.io/v1alpha1": {
+			Name:    "resources.null.template.jet.crossplane.io",
+			Version: "v1alpha1",
+			Fields: map[string]Field{
+				"spec":                  {Type: "object"},
+				"spec.forProvider":      {Type: "object", Required: true},
+				"spec.forProvider.name": {Type: "string", ForceNew: true},
+				"spec.forProvider.tags": {Type: "array of string"},
+			},
+		},
+	}}
+	if diff := cmp.Diff(want, s); diff != "" {
+		t.Errorf("Load(...): -want, +got:\n%s", diff)
+	}
+}
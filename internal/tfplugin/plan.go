@@ -0,0 +1,84 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tfplugin
+
+import (
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// ProposedNew returns the proposed new state of a resource with the given
+// schema block, prior state and configuration that is planned with
+// PlanResourceChange. Like Terraform, it takes the values of the computed
+// attributes that are not configured from the prior state. The elements of
+// the nested set and map blocks are taken from the configuration as they
+// are, and the provider plans their computed attributes.
+func ProposedNew(b *tfjson.SchemaBlock, prior, config cty.Value) cty.Value {
+	if config.IsNull() || !config.IsKnown() {
+		return config
+	}
+	vals := make(map[string]cty.Value, len(b.Attributes)+len(b.NestedBlocks))
+	for name, a := range b.Attributes {
+		v := config.GetAttr(name)
+		if a.Computed && v.IsNull() {
+			v = attributeOf(prior, name)
+		}
+		vals[name] = v
+	}
+	for name, nb := range b.NestedBlocks {
+		vals[name] = proposedNewNested(nb, attributeOf(prior, name), config.GetAttr(name))
+	}
+	return cty.ObjectVal(vals)
+}
+
+func proposedNewNested(nb *tfjson.SchemaBlockType, prior, config cty.Value) cty.Value {
+	switch nb.NestingMode {
+	case tfjson.SchemaNestingModeSingle, tfjson.SchemaNestingModeGroup:
+		return ProposedNew(nb.Block, prior, config)
+	case tfjson.SchemaNestingModeList:
+		// The elements of lists are matched by their index.
+		if !sameLength(prior, config) {
+			return config
+		}
+		priors, configs := prior.AsValueSlice(), config.AsValueSlice()
+		elems := make([]cty.Value, len(configs))
+		for i := range configs {
+			elems[i] = ProposedNew(nb.Block, priors[i], configs[i])
+		}
+		return cty.ListVal(elems)
+	case tfjson.SchemaNestingModeSet, tfjson.SchemaNestingModeMap:
+	}
+	return config
+}
+
+// sameLength returns true if the given lists are known, not null and have
+// the same number of elements, at least one.
+func sameLength(a, b cty.Value) bool {
+	if a.IsNull() || !a.IsKnown() || b.IsNull() || !b.IsKnown() {
+		return false
+	}
+	return b.LengthInt() != 0 && a.LengthInt() == b.LengthInt()
+}
+
+// attributeOf returns the attribute with the given name of the given object,
+// which is null if the object is null.
+func attributeOf(o cty.Value, name string) cty.Value {
+	if o.IsNull() || !o.IsKnown() {
+		return cty.NullVal(o.Type().AttributeType(name))
+	}
+	return o.GetAttr(name)
+}
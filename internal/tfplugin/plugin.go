@@ -0,0 +1,149 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package tfplugin talks to the native binaries of Terraform providers over
+// the plugin gRPC protocol version 5, without the Terraform CLI.
+package tfplugin
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-plugin"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+)
+
+const (
+	// protocolVersion is the version of the plugin protocol that the
+	// providers are talked to with.
+	protocolVersion = 5
+	// pluginName is the name that Terraform dispenses providers with.
+	pluginName = "provider"
+
+	errStartPlugin = "cannot start provider plugin"
+	errDispense    = "cannot dispense provider plugin"
+)
+
+// handshake is the handshake configuration of Terraform provider plugins.
+var handshake = plugin.HandshakeConfig{
+	MagicCookieKey:   "TF_PLUGIN_MAGIC_COOKIE",
+	MagicCookieValue: "d602bf8f470bc67ca7faa0386276bbdd4330efaf76d1a219cb4d6991ca9872b2",
+}
+
+// A Provider is a running provider plugin.
+type Provider struct {
+	client *plugin.Client
+	conn   *grpc.ClientConn
+}
+
+// Start launches the provider binary at the given path with the given
+// environment variables in addition to the ones of this process, and
+// connects to it. The plugin runs until it's closed.
+func Start(binaryPath string, env []string) (*Provider, error) {
+	cmd := exec.Command(binaryPath) // #nosec G204 the binary is given by the user
+	cmd.Env = append(os.Environ(), env...)
+	client := plugin.NewClient(&plugin.ClientConfig{
+		HandshakeConfig: handshake,
+		VersionedPlugins: map[int]plugin.PluginSet{
+			protocolVersion: {pluginName: &grpcProvider{}},
+		},
+		Cmd:              cmd,
+		AllowedProtocols: []plugin.Protocol{plugin.ProtocolGRPC},
+		AutoMTLS:         true,
+		Managed:          true,
+		Logger:           hclog.New(&hclog.LoggerOptions{Output: io.Discard, Level: hclog.Off}),
+	})
+	rpc, err := client.Client()
+	if err != nil {
+		client.Kill()
+		return nil, errors.Wrap(err, errStartPlugin)
+	}
+	raw, err := rpc.Dispense(pluginName)
+	if err != nil {
+		client.Kill()
+		return nil, errors.Wrap(err, errDispense)
+	}
+	conn, ok := raw.(*grpc.ClientConn)
+	if !ok {
+		client.Kill()
+		return nil, errors.New(errDispense)
+	}
+	return &Provider{client: client, conn: conn}, nil
+}
+
+// Close stops the provider plugin.
+func (p *Provider) Close() {
+	p.client.Kill()
+}
+
+// Exited returns whether the provider plugin exited, e.g. because it
+// crashed.
+func (p *Provider) Exited() bool {
+	return p.client.Exited()
+}
+
+// invoke calls the given method of the provider with the given encoded
+// request message and returns the encoded response message.
+func (p *Provider) invoke(ctx context.Context, method string, req []byte) ([]byte, error) {
+	var resp []byte
+	if err := p.conn.Invoke(ctx, method, req, &resp, grpc.ForceCodec(rawCodec{})); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// grpcProvider is a client-only plugin that hands out the gRPC connection to
+// the provider.
+type grpcProvider struct {
+	plugin.NetRPCUnsupportedPlugin
+}
+
+func (p *grpcProvider) GRPCServer(*plugin.GRPCBroker, *grpc.Server) error {
+	return errors.New("provider plugins cannot be served")
+}
+
+func (p *grpcProvider) GRPCClient(_ context.Context, _ *plugin.GRPCBroker, c *grpc.ClientConn) (interface{}, error) {
+	return c, nil
+}
+
+// rawCodec passes the already encoded messages through so that they can be
+// encoded and decoded without the generated types of the protocol.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, errors.Errorf("unexpected message type %T", v)
+	}
+	return b, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return errors.Errorf("unexpected message type %T", v)
+	}
+	*b = append((*b)[:0], data...)
+	return nil
+}
+
+func (rawCodec) Name() string {
+	return "proto"
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tfplugin
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// startNull builds the null provider in testdata and starts it.
+func startNull(t *testing.T) *Provider {
+	t.Helper()
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("the null provider cannot be built without the go command")
+	}
+	bin := filepath.Join(t.TempDir(), "terraform-provider-null")
+	if out, err := exec.Command("go", "build", "-o", bin, "./testdata/nullprovider").CombinedOutput(); err != nil {
+		t.Fatalf("cannot build the null provider: %v\n%s", err, out)
+	}
+	p, err := Start(bin, nil)
+	if err != nil {
+		t.Fatalf("Start(...): %v", err)
+	}
+	t.Cleanup(p.Close)
+	return p
+}
+
+func TestProviderNullResource(t *testing.T) { //nolint:gocyclo
+	ctx := context.Background()
+	p := startNull(t)
+
+	s, err := p.GetSchema(ctx)
+	if err != nil {
+		t.Fatalf("GetSchema(...): %v", err)
+	}
+	rs, ok := s.ResourceSchemas["null_resource"]
+	if !ok {
+		t.Fatal("GetSchema(...): no schema for null_resource")
+	}
+	ty := ImpliedType(rs.Block)
+	want := cty.Object(map[string]cty.Type{"id": cty.String, "triggers": cty.Map(cty.String)})
+	if !ty.Equals(want) {
+		t.Errorf("ImpliedType(...): got %s, want %s", ty.FriendlyName(), want.FriendlyName())
+	}
+	if err := p.Configure(ctx, "1.1.6", cty.EmptyObjectVal); err != nil {
+		t.Fatalf("Configure(...): %v", err)
+	}
+
+	config := func(trigger string) cty.Value {
+		return cty.ObjectVal(map[string]cty.Value{
+			"id":       cty.NullVal(cty.String),
+			"triggers": cty.MapVal(map[string]cty.Value{"key": cty.StringVal(trigger)}),
+		})
+	}
+	null := State{Value: cty.NullVal(ty)}
+	created := config("a")
+	pl, err := p.PlanResourceChange(ctx, "null_resource", null, ProposedNew(rs.Block, null.Value, created), created)
+	if err != nil {
+		t.Fatalf("PlanResourceChange(...): %v", err)
+	}
+	if pl.Value.GetAttr("id").IsKnown() {
+		t.Error("PlanResourceChange(...): the ID of a resource to be created should be unknown")
+	}
+	st, err := p.ApplyResourceChange(ctx, "null_resource", null, pl, created)
+	if err != nil {
+		t.Fatalf("ApplyResourceChange(...): %v", err)
+	}
+	id := st.Value.GetAttr("id").AsString()
+
+	read, err := p.ReadResource(ctx, "null_resource", st)
+	if err != nil {
+		t.Fatalf("ReadResource(...): %v", err)
+	}
+	if !read.Value.RawEquals(st.Value) {
+		t.Errorf("ReadResource(...): got %#v, want %#v", read.Value, st.Value)
+	}
+
+	changed := config("b")
+	pl, err = p.PlanResourceChange(ctx, "null_resource", st, ProposedNew(rs.Block, st.Value, changed), changed)
+	if err != nil {
+		t.Fatalf("PlanResourceChange(...): %v", err)
+	}
+	if !pl.RequiresReplace {
+		t.Error("PlanResourceChange(...): a change of the triggers should require a replacement")
+	}
+
+	raw := []byte(`{"id":"` + id + `","triggers":{"key":"a"}}`)
+	upgraded, err := p.UpgradeResourceState(ctx, "null_resource", 0, raw, ty)
+	if err != nil {
+		t.Fatalf("UpgradeResourceState(...): %v", err)
+	}
+	if !upgraded.RawEquals(st.Value) {
+		t.Errorf("UpgradeResourceState(...): got %#v, want %#v", upgraded, st.Value)
+	}
+
+	imported, err := p.ImportResourceState(ctx, "null_resource", id, ty)
+	if err != nil {
+		t.Fatalf("ImportResourceState(...): %v", err)
+	}
+	if diff := cmp.Diff(id, imported.Value.GetAttr("id").AsString()); diff != "" {
+		t.Errorf("ImportResourceState(...): -want, +got:\n%s", diff)
+	}
+
+	pl, err = p.PlanResourceChange(ctx, "null_resource", st, cty.NullVal(ty), cty.NullVal(ty))
+	if err != nil {
+		t.Fatalf("PlanResourceChange(...): %v", err)
+	}
+	deleted, err := p.ApplyResourceChange(ctx, "null_resource", st, pl, cty.NullVal(ty))
+	if err != nil {
+		t.Fatalf("ApplyResourceChange(...): %v", err)
+	}
+	if !deleted.Value.IsNull() {
+		t.Errorf("ApplyResourceChange(...): got %#v for a deleted resource, want null", deleted.Value)
+	}
+}
+
+func TestProviderExited(t *testing.T) {
+	p := startNull(t)
+	if p.Exited() {
+		t.Fatal("Exited(): the provider exited right after it started")
+	}
+	p.Close()
+	if !p.Exited() {
+		t.Error("Exited(): the provider did not exit once it was closed")
+	}
+}
@@ -0,0 +1,356 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tfplugin
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/zclconf/go-cty/cty"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The full names of the RPCs of the plugin protocol version 5.
+const (
+	methodPrepareProviderConfig = "/tfplugin5.Provider/PrepareProviderConfig"
+	methodConfigure             = "/tfplugin5.Provider/Configure"
+	methodUpgradeResourceState  = "/tfplugin5.Provider/UpgradeResourceState"
+	methodReadResource          = "/tfplugin5.Provider/ReadResource"
+	methodPlanResourceChange    = "/tfplugin5.Provider/PlanResourceChange"
+	methodApplyResourceChange   = "/tfplugin5.Provider/ApplyResourceChange"
+	methodImportResourceState   = "/tfplugin5.Provider/ImportResourceState"
+)
+
+const (
+	errConfigure      = "cannot configure provider"
+	errUpgradeState   = "cannot upgrade resource state"
+	errReadResource   = "cannot read resource"
+	errPlanChange     = "cannot plan resource change"
+	errApplyChange    = "cannot apply resource change"
+	errImportResource = "cannot import resource"
+	errFmtNotImported = "provider did not import a resource of type %s"
+)
+
+// PrepareProviderConfig
+const (
+	fieldPrepareRequestConfig          protowire.Number = 1
+	fieldPrepareResponsePreparedConfig protowire.Number = 1
+	fieldPrepareResponseDiagnostics    protowire.Number = 2
+)
+
+// Configure
+const (
+	fieldConfigureRequestTerraformVersion protowire.Number = 1
+	fieldConfigureRequestConfig           protowire.Number = 2
+	fieldConfigureResponseDiagnostics     protowire.Number = 1
+)
+
+// UpgradeResourceState
+const (
+	fieldUpgradeRequestTypeName       protowire.Number = 1
+	fieldUpgradeRequestVersion        protowire.Number = 2
+	fieldUpgradeRequestRawState       protowire.Number = 3
+	fieldUpgradeResponseUpgradedState protowire.Number = 1
+	fieldUpgradeResponseDiagnostics   protowire.Number = 2
+)
+
+// RawState
+const (
+	fieldRawStateJSON protowire.Number = 1
+)
+
+// ReadResource
+const (
+	fieldReadRequestTypeName     protowire.Number = 1
+	fieldReadRequestCurrentState protowire.Number = 2
+	fieldReadRequestPrivate      protowire.Number = 3
+	fieldReadResponseNewState    protowire.Number = 1
+	fieldReadResponseDiagnostics protowire.Number = 2
+	fieldReadResponsePrivate     protowire.Number = 3
+)
+
+// PlanResourceChange
+const (
+	fieldPlanRequestTypeName         protowire.Number = 1
+	fieldPlanRequestPriorState       protowire.Number = 2
+	fieldPlanRequestProposedNewState protowire.Number = 3
+	fieldPlanRequestConfig           protowire.Number = 4
+	fieldPlanRequestPriorPrivate     protowire.Number = 5
+	fieldPlanResponsePlannedState    protowire.Number = 1
+	fieldPlanResponseRequiresReplace protowire.Number = 2
+	fieldPlanResponsePlannedPrivate  protowire.Number = 3
+	fieldPlanResponseDiagnostics     protowire.Number = 4
+)
+
+// ApplyResourceChange
+const (
+	fieldApplyRequestTypeName       protowire.Number = 1
+	fieldApplyRequestPriorState     protowire.Number = 2
+	fieldApplyRequestPlannedState   protowire.Number = 3
+	fieldApplyRequestConfig         protowire.Number = 4
+	fieldApplyRequestPlannedPrivate protowire.Number = 5
+	fieldApplyResponseNewState      protowire.Number = 1
+	fieldApplyResponsePrivate       protowire.Number = 2
+	fieldApplyResponseDiagnostics   protowire.Number = 3
+)
+
+// ImportResourceState
+const (
+	fieldImportRequestTypeName     protowire.Number = 1
+	fieldImportRequestID           protowire.Number = 2
+	fieldImportResponseResources   protowire.Number = 1
+	fieldImportResponseDiagnostics protowire.Number = 2
+	fieldImportedResourceTypeName  protowire.Number = 1
+	fieldImportedResourceState     protowire.Number = 2
+	fieldImportedResourcePrivate   protowire.Number = 3
+)
+
+// A State is the state of a resource along with the private data the
+// provider keeps with it.
+type State struct {
+	Value   cty.Value
+	Private []byte
+}
+
+// A Plan is the planned state of a resource.
+type Plan struct {
+	State
+
+	// RequiresReplace is whether the resource has to be replaced to apply
+	// the plan.
+	RequiresReplace bool
+}
+
+// Configure prepares the given configuration of the provider, which fills in
+// its defaults, and configures the provider with it. The provider has to be
+// configured before its resources are read, planned or applied.
+func (p *Provider) Configure(ctx context.Context, terraformVersion string, config cty.Value) error {
+	req, err := appendDynamicValue(nil, fieldPrepareRequestConfig, config)
+	if err != nil {
+		return errors.Wrap(err, errConfigure)
+	}
+	resp, err := p.invoke(ctx, methodPrepareProviderConfig, req)
+	if err != nil {
+		return errors.Wrap(err, errConfigure)
+	}
+	prepared := config
+	err = decodeDiagnostics(resp, fieldPrepareResponseDiagnostics, func(f field) error {
+		if f.num != fieldPrepareResponsePreparedConfig {
+			return nil
+		}
+		var err error
+		prepared, err = decodeDynamicValue(f.bytes, config.Type())
+		return err
+	})
+	if err != nil {
+		return errors.Wrap(err, errConfigure)
+	}
+	req = appendBytes(nil, fieldConfigureRequestTerraformVersion, []byte(terraformVersion))
+	if req, err = appendDynamicValue(req, fieldConfigureRequestConfig, prepared); err != nil {
+		return errors.Wrap(err, errConfigure)
+	}
+	if resp, err = p.invoke(ctx, methodConfigure, req); err != nil {
+		return errors.Wrap(err, errConfigure)
+	}
+	return errors.Wrap(decodeDiagnostics(resp, fieldConfigureResponseDiagnostics, ignoreField), errConfigure)
+}
+
+// UpgradeResourceState converts the given JSON encoded attributes of a
+// resource of the given type, which were written with the given schema
+// version, into a value of the given type of the current schema.
+func (p *Provider) UpgradeResourceState(ctx context.Context, typeName string, version uint64, attrs []byte, ty cty.Type) (cty.Value, error) {
+	req := appendBytes(nil, fieldUpgradeRequestTypeName, []byte(typeName))
+	req = protowire.AppendTag(req, fieldUpgradeRequestVersion, protowire.VarintType)
+	req = protowire.AppendVarint(req, version)
+	req = appendBytes(req, fieldUpgradeRequestRawState, appendBytes(nil, fieldRawStateJSON, attrs))
+	resp, err := p.invoke(ctx, methodUpgradeResourceState, req)
+	if err != nil {
+		return cty.NilVal, errors.Wrap(err, errUpgradeState)
+	}
+	v := cty.NullVal(ty)
+	err = decodeDiagnostics(resp, fieldUpgradeResponseDiagnostics, func(f field) error {
+		if f.num != fieldUpgradeResponseUpgradedState {
+			return nil
+		}
+		var err error
+		v, err = decodeDynamicValue(f.bytes, ty)
+		return err
+	})
+	return v, errors.Wrap(err, errUpgradeState)
+}
+
+// ReadResource returns the current state of the given resource of the given
+// type. The value of the returned state is null if the resource no longer
+// exists.
+func (p *Provider) ReadResource(ctx context.Context, typeName string, current State) (State, error) {
+	req := appendBytes(nil, fieldReadRequestTypeName, []byte(typeName))
+	req, err := appendDynamicValue(req, fieldReadRequestCurrentState, current.Value)
+	if err != nil {
+		return State{}, errors.Wrap(err, errReadResource)
+	}
+	req = appendBytes(req, fieldReadRequestPrivate, current.Private)
+	resp, err := p.invoke(ctx, methodReadResource, req)
+	if err != nil {
+		return State{}, errors.Wrap(err, errReadResource)
+	}
+	s := State{Value: cty.NullVal(current.Value.Type())}
+	err = decodeDiagnostics(resp, fieldReadResponseDiagnostics, func(f field) error {
+		var err error
+		switch f.num {
+		case fieldReadResponseNewState:
+			s.Value, err = decodeDynamicValue(f.bytes, current.Value.Type())
+		case fieldReadResponsePrivate:
+			s.Private = f.bytes
+		}
+		return err
+	})
+	return s, errors.Wrap(err, errReadResource)
+}
+
+// PlanResourceChange plans the change of the given prior state of a resource
+// of the given type to the given proposed new state for the given
+// configuration. The prior state is null for resources to be created, and
+// the proposed new state and the configuration are null for resources to
+// be deleted.
+func (p *Provider) PlanResourceChange(ctx context.Context, typeName string, prior State, proposed, config cty.Value) (Plan, error) {
+	req := appendBytes(nil, fieldPlanRequestTypeName, []byte(typeName))
+	req, err := appendDynamicValues(req, fieldPlanRequestPriorState, prior.Value, proposed, config)
+	if err != nil {
+		return Plan{}, errors.Wrap(err, errPlanChange)
+	}
+	req = appendBytes(req, fieldPlanRequestPriorPrivate, prior.Private)
+	resp, err := p.invoke(ctx, methodPlanResourceChange, req)
+	if err != nil {
+		return Plan{}, errors.Wrap(err, errPlanChange)
+	}
+	pl := Plan{State: State{Value: cty.NullVal(prior.Value.Type())}}
+	err = decodeDiagnostics(resp, fieldPlanResponseDiagnostics, func(f field) error {
+		var err error
+		switch f.num {
+		case fieldPlanResponsePlannedState:
+			pl.Value, err = decodeDynamicValue(f.bytes, prior.Value.Type())
+		case fieldPlanResponseRequiresReplace:
+			pl.RequiresReplace = true
+		case fieldPlanResponsePlannedPrivate:
+			pl.Private = f.bytes
+		}
+		return err
+	})
+	return pl, errors.Wrap(err, errPlanChange)
+}
+
+// ApplyResourceChange applies the given plan of the change of the given
+// prior state of a resource of the given type for the given configuration,
+// and returns the new state. The value of the new state is null if the
+// resource was deleted.
+func (p *Provider) ApplyResourceChange(ctx context.Context, typeName string, prior State, planned Plan, config cty.Value) (State, error) {
+	req := appendBytes(nil, fieldApplyRequestTypeName, []byte(typeName))
+	req, err := appendDynamicValues(req, fieldApplyRequestPriorState, prior.Value, planned.Value, config)
+	if err != nil {
+		return State{}, errors.Wrap(err, errApplyChange)
+	}
+	req = appendBytes(req, fieldApplyRequestPlannedPrivate, planned.Private)
+	resp, err := p.invoke(ctx, methodApplyResourceChange, req)
+	if err != nil {
+		return State{}, errors.Wrap(err, errApplyChange)
+	}
+	s := State{Value: cty.NullVal(prior.Value.Type())}
+	err = decodeDiagnostics(resp, fieldApplyResponseDiagnostics, func(f field) error {
+		var err error
+		switch f.num {
+		case fieldApplyResponseNewState:
+			s.Value, err = decodeDynamicValue(f.bytes, prior.Value.Type())
+		case fieldApplyResponsePrivate:
+			s.Private = f.bytes
+		}
+		return err
+	})
+	// The new state holds the partial changes of the failed applies.
+	return s, errors.Wrap(err, errApplyChange)
+}
+
+// ImportResourceState imports the existing resource of the given type with
+// the given ID, and returns its state of the given type. The state has to be
+// read before it's used.
+func (p *Provider) ImportResourceState(ctx context.Context, typeName, id string, ty cty.Type) (State, error) {
+	req := appendBytes(nil, fieldImportRequestTypeName, []byte(typeName))
+	req = appendBytes(req, fieldImportRequestID, []byte(id))
+	resp, err := p.invoke(ctx, methodImportResourceState, req)
+	if err != nil {
+		return State{}, errors.Wrap(err, errImportResource)
+	}
+	var imported []State
+	err = decodeDiagnostics(resp, fieldImportResponseDiagnostics, func(f field) error {
+		if f.num != fieldImportResponseResources {
+			return nil
+		}
+		s, ok, err := decodeImportedResource(f.bytes, typeName, ty)
+		if ok {
+			imported = append(imported, s)
+		}
+		return err
+	})
+	if err != nil {
+		return State{}, errors.Wrap(err, errImportResource)
+	}
+	// Some resources import other resources along with them, which are not
+	// managed here.
+	if len(imported) == 0 {
+		return State{}, errors.Errorf(errFmtNotImported, typeName)
+	}
+	return imported[0], nil
+}
+
+// decodeImportedResource decodes the given ImportedResource message if it's
+// a resource of the given type.
+func decodeImportedResource(b []byte, typeName string, ty cty.Type) (State, bool, error) {
+	fs, err := fields(b)
+	if err != nil {
+		return State{}, false, errors.Wrap(err, "cannot decode imported resource")
+	}
+	s := State{Value: cty.NullVal(ty)}
+	ok := false
+	for _, f := range fs {
+		switch f.num {
+		case fieldImportedResourceTypeName:
+			ok = string(f.bytes) == typeName
+		case fieldImportedResourceState:
+			if s.Value, err = decodeDynamicValue(f.bytes, ty); err != nil {
+				return State{}, false, err
+			}
+		case fieldImportedResourcePrivate:
+			s.Private = f.bytes
+		}
+	}
+	return s, ok, nil
+}
+
+// appendDynamicValues appends the given values encoded as DynamicValue
+// messages as the fields with consecutive numbers from the given one.
+func appendDynamicValues(b []byte, num protowire.Number, values ...cty.Value) ([]byte, error) {
+	for i, v := range values {
+		var err error
+		if b, err = appendDynamicValue(b, num+protowire.Number(i), v); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+func ignoreField(field) error {
+	return nil
+}
@@ -0,0 +1,325 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tfplugin
+
+import (
+	"context"
+	"strings"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/pkg/errors"
+	"github.com/zclconf/go-cty/cty"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	// methodGetSchema is the full name of the GetProviderSchema RPC of the
+	// plugin protocol version 5.
+	methodGetSchema = "/tfplugin5.Provider/GetSchema"
+
+	errGetSchema = "cannot get provider schema"
+)
+
+// The field numbers below are the ones of the messages in tfplugin5.proto
+// that describe the schema of a provider.
+
+// GetProviderSchema.Response
+const (
+	fieldResponseProvider     protowire.Number = 1
+	fieldResponseResources    protowire.Number = 2
+	fieldResponseDataSources  protowire.Number = 3
+	fieldResponseDiagnostics  protowire.Number = 4
+	fieldResponseProviderMeta protowire.Number = 5
+)
+
+// Schema
+const (
+	fieldSchemaVersion protowire.Number = 1
+	fieldSchemaBlock   protowire.Number = 2
+)
+
+// Schema.Block
+const (
+	fieldBlockAttributes      protowire.Number = 2
+	fieldBlockBlockTypes      protowire.Number = 3
+	fieldBlockDescription     protowire.Number = 4
+	fieldBlockDescriptionKind protowire.Number = 5
+	fieldBlockDeprecated      protowire.Number = 6
+)
+
+// Schema.Attribute
+const (
+	fieldAttributeName            protowire.Number = 1
+	fieldAttributeType            protowire.Number = 2
+	fieldAttributeDescription     protowire.Number = 3
+	fieldAttributeRequired        protowire.Number = 4
+	fieldAttributeOptional        protowire.Number = 5
+	fieldAttributeComputed        protowire.Number = 6
+	fieldAttributeSensitive       protowire.Number = 7
+	fieldAttributeDescriptionKind protowire.Number = 8
+	fieldAttributeDeprecated      protowire.Number = 9
+)
+
+// Schema.NestedBlock
+const (
+	fieldNestedBlockTypeName protowire.Number = 1
+	fieldNestedBlockBlock    protowire.Number = 2
+	fieldNestedBlockNesting  protowire.Number = 3
+	fieldNestedBlockMinItems protowire.Number = 4
+	fieldNestedBlockMaxItems protowire.Number = 5
+)
+
+var nestingModes = map[uint64]tfjson.SchemaNestingMode{
+	1: tfjson.SchemaNestingModeSingle,
+	2: tfjson.SchemaNestingModeList,
+	3: tfjson.SchemaNestingModeSet,
+	4: tfjson.SchemaNestingModeMap,
+	5: tfjson.SchemaNestingModeGroup,
+}
+
+// GetSchema returns the schema of the provider in the format of "terraform
+// providers schema -json".
+func (p *Provider) GetSchema(ctx context.Context) (*tfjson.ProviderSchema, error) {
+	// GetProviderSchema.Request is an empty message.
+	resp, err := p.invoke(ctx, methodGetSchema, []byte{})
+	if err != nil {
+		return nil, errors.Wrap(err, errGetSchema)
+	}
+	ps, err := decodeResponse(resp)
+	return ps, errors.Wrap(err, errGetSchema)
+}
+
+// ImpliedType returns the type of the values of the given schema block.
+func ImpliedType(b *tfjson.SchemaBlock) cty.Type {
+	attrs := make(map[string]cty.Type, len(b.Attributes)+len(b.NestedBlocks))
+	for name, a := range b.Attributes {
+		attrs[name] = a.AttributeType
+	}
+	for name, nb := range b.NestedBlocks {
+		ty := ImpliedType(nb.Block)
+		switch nb.NestingMode {
+		case tfjson.SchemaNestingModeList:
+			ty = cty.List(ty)
+		case tfjson.SchemaNestingModeSet:
+			ty = cty.Set(ty)
+		case tfjson.SchemaNestingModeMap:
+			ty = cty.Map(ty)
+		case tfjson.SchemaNestingModeSingle, tfjson.SchemaNestingModeGroup:
+		}
+		attrs[name] = ty
+	}
+	return cty.Object(attrs)
+}
+
+// decodeResponse decodes a GetProviderSchema.Response message. It returns an
+// error if the response has error diagnostics.
+func decodeResponse(b []byte) (*tfjson.ProviderSchema, error) {
+	fs, err := fields(b)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot decode response")
+	}
+	ps := &tfjson.ProviderSchema{
+		ResourceSchemas:   map[string]*tfjson.Schema{},
+		DataSourceSchemas: map[string]*tfjson.Schema{},
+	}
+	var diags []string
+	for _, f := range fs {
+		switch f.num {
+		case fieldResponseProvider:
+			ps.ConfigSchema, err = decodeSchema(f.bytes)
+		case fieldResponseResources:
+			err = decodeSchemaMapEntry(f.bytes, ps.ResourceSchemas)
+		case fieldResponseDataSources:
+			err = decodeSchemaMapEntry(f.bytes, ps.DataSourceSchemas)
+		case fieldResponseDiagnostics:
+			var d string
+			d, err = decodeErrorDiagnostic(f.bytes)
+			if d != "" {
+				diags = append(diags, d)
+			}
+		case fieldResponseProviderMeta:
+			// Provider meta schemas are not part of the JSON output of
+			// "terraform providers schema".
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(diags) > 0 {
+		return nil, errors.Errorf("provider returned errors: %s", strings.Join(diags, "; "))
+	}
+	return ps, nil
+}
+
+func decodeSchemaMapEntry(b []byte, into map[string]*tfjson.Schema) error {
+	fs, err := fields(b)
+	if err != nil {
+		return errors.Wrap(err, "cannot decode schema map entry")
+	}
+	var key string
+	var s *tfjson.Schema
+	for _, f := range fs {
+		switch f.num {
+		case fieldMapKey:
+			key = string(f.bytes)
+		case fieldMapValue:
+			if s, err = decodeSchema(f.bytes); err != nil {
+				return errors.Wrapf(err, "cannot decode schema of %s", key)
+			}
+		}
+	}
+	if s == nil {
+		s = &tfjson.Schema{Block: &tfjson.SchemaBlock{DescriptionKind: tfjson.SchemaDescriptionKindPlain}}
+	}
+	into[key] = s
+	return nil
+}
+
+func decodeSchema(b []byte) (*tfjson.Schema, error) {
+	fs, err := fields(b)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot decode schema")
+	}
+	s := &tfjson.Schema{}
+	for _, f := range fs {
+		switch f.num {
+		case fieldSchemaVersion:
+			s.Version = f.varint
+		case fieldSchemaBlock:
+			if s.Block, err = decodeBlock(f.bytes); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if s.Block == nil {
+		s.Block = &tfjson.SchemaBlock{DescriptionKind: tfjson.SchemaDescriptionKindPlain}
+	}
+	return s, nil
+}
+
+func decodeBlock(b []byte) (*tfjson.SchemaBlock, error) { //nolint:gocyclo
+	// NOTE: This is a flat switch over the fields of the message and easy
+	// to follow despite its cyclomatic complexity.
+	fs, err := fields(b)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot decode block")
+	}
+	blk := &tfjson.SchemaBlock{DescriptionKind: tfjson.SchemaDescriptionKindPlain}
+	for _, f := range fs {
+		switch f.num {
+		case fieldBlockAttributes:
+			name, a, err := decodeAttribute(f.bytes)
+			if err != nil {
+				return nil, err
+			}
+			if blk.Attributes == nil {
+				blk.Attributes = map[string]*tfjson.SchemaAttribute{}
+			}
+			blk.Attributes[name] = a
+		case fieldBlockBlockTypes:
+			name, nb, err := decodeNestedBlock(f.bytes)
+			if err != nil {
+				return nil, err
+			}
+			if blk.NestedBlocks == nil {
+				blk.NestedBlocks = map[string]*tfjson.SchemaBlockType{}
+			}
+			blk.NestedBlocks[name] = nb
+		case fieldBlockDescription:
+			blk.Description = string(f.bytes)
+		case fieldBlockDescriptionKind:
+			blk.DescriptionKind = descriptionKind(f.varint)
+		case fieldBlockDeprecated:
+			blk.Deprecated = f.varint != 0
+		}
+	}
+	return blk, nil
+}
+
+func decodeAttribute(b []byte) (string, *tfjson.SchemaAttribute, error) { //nolint:gocyclo
+	// NOTE: This is a flat switch over the fields of the message and easy
+	// to follow despite its cyclomatic complexity.
+	fs, err := fields(b)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "cannot decode attribute")
+	}
+	var name string
+	a := &tfjson.SchemaAttribute{DescriptionKind: tfjson.SchemaDescriptionKindPlain}
+	for _, f := range fs {
+		switch f.num {
+		case fieldAttributeName:
+			name = string(f.bytes)
+		case fieldAttributeType:
+			var t cty.Type
+			if err := t.UnmarshalJSON(f.bytes); err != nil {
+				return "", nil, errors.Wrapf(err, "cannot decode type of attribute %s", name)
+			}
+			a.AttributeType = t
+		case fieldAttributeDescription:
+			a.Description = string(f.bytes)
+		case fieldAttributeRequired:
+			a.Required = f.varint != 0
+		case fieldAttributeOptional:
+			a.Optional = f.varint != 0
+		case fieldAttributeComputed:
+			a.Computed = f.varint != 0
+		case fieldAttributeSensitive:
+			a.Sensitive = f.varint != 0
+		case fieldAttributeDescriptionKind:
+			a.DescriptionKind = descriptionKind(f.varint)
+		case fieldAttributeDeprecated:
+			a.Deprecated = f.varint != 0
+		}
+	}
+	return name, a, nil
+}
+
+func decodeNestedBlock(b []byte) (string, *tfjson.SchemaBlockType, error) {
+	fs, err := fields(b)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "cannot decode nested block")
+	}
+	var name string
+	nb := &tfjson.SchemaBlockType{}
+	for _, f := range fs {
+		switch f.num {
+		case fieldNestedBlockTypeName:
+			name = string(f.bytes)
+		case fieldNestedBlockBlock:
+			if nb.Block, err = decodeBlock(f.bytes); err != nil {
+				return "", nil, errors.Wrapf(err, "cannot decode nested block %s", name)
+			}
+		case fieldNestedBlockNesting:
+			nb.NestingMode = nestingModes[f.varint]
+		case fieldNestedBlockMinItems:
+			nb.MinItems = f.varint
+		case fieldNestedBlockMaxItems:
+			nb.MaxItems = f.varint
+		}
+	}
+	if nb.Block == nil {
+		nb.Block = &tfjson.SchemaBlock{DescriptionKind: tfjson.SchemaDescriptionKindPlain}
+	}
+	return name, nb, nil
+}
+
+func descriptionKind(v uint64) tfjson.SchemaDescriptionKind {
+	if v == 1 {
+		return tfjson.SchemaDescriptionKindMarkdown
+	}
+	return tfjson.SchemaDescriptionKindPlain
+}
@@ -0,0 +1,191 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tfplugin
+
+import (
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/pkg/errors"
+	"github.com/zclconf/go-cty/cty"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+var equateTypes = cmp.Comparer(func(a, b cty.Type) bool { return a.Equals(b) })
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	return appendBytes(b, num, []byte(s))
+}
+
+func attribute(name, typ string, flags ...protowire.Number) []byte {
+	b := appendString(nil, fieldAttributeName, name)
+	b = appendString(b, fieldAttributeType, typ)
+	for _, f := range flags {
+		b = appendVarint(b, f, 1)
+	}
+	return b
+}
+
+func schemaEntry(key string, schema []byte) []byte {
+	return appendBytes(appendString(nil, fieldMapKey, key), fieldMapValue, schema)
+}
+
+func diagnostic(severity uint64, summary, detail string) []byte {
+	b := appendVarint(nil, fieldDiagnosticSeverity, severity)
+	b = appendString(b, fieldDiagnosticSummary, summary)
+	return appendString(b, fieldDiagnosticDetail, detail)
+}
+
+func TestDecodeResponse(t *testing.T) {
+	// A resource with the schema of null_resource plus a nested block and the
+	// fields that null_resource does not use.
+	nested := appendBytes(nil, fieldBlockAttributes, attribute("value", `"number"`, fieldAttributeRequired))
+	nested = appendVarint(nested, fieldBlockDeprecated, 1)
+	nestedBlock := appendString(nil, fieldNestedBlockTypeName, "setting")
+	nestedBlock = appendBytes(nestedBlock, fieldNestedBlockBlock, nested)
+	nestedBlock = appendVarint(nestedBlock, fieldNestedBlockNesting, 2)
+	nestedBlock = appendVarint(nestedBlock, fieldNestedBlockMinItems, 1)
+	nestedBlock = appendVarint(nestedBlock, fieldNestedBlockMaxItems, 3)
+
+	block := appendBytes(nil, fieldBlockAttributes, attribute("id", `"string"`, fieldAttributeComputed))
+	block = appendBytes(block, fieldBlockAttributes, attribute("triggers", `["map","string"]`, fieldAttributeOptional))
+	block = appendBytes(block, fieldBlockAttributes, attribute("secret", `"string"`, fieldAttributeOptional, fieldAttributeSensitive))
+	block = appendBytes(block, fieldBlockBlockTypes, nestedBlock)
+	block = appendString(block, fieldBlockDescription, "A **resource**.")
+	block = appendVarint(block, fieldBlockDescriptionKind, 1)
+	resource := appendVarint(nil, fieldSchemaVersion, 1)
+	resource = appendBytes(resource, fieldSchemaBlock, block)
+
+	type want struct {
+		ps  *tfjson.ProviderSchema
+		err error
+	}
+	cases := map[string]struct {
+		reason string
+		msg    []byte
+		want   want
+	}{
+		"Schema": {
+			reason: "The provider, resource and data source schemas should be decoded as in the output of terraform providers schema.",
+			msg: func() []byte {
+				b := appendBytes(nil, fieldResponseProvider, nil)
+				b = appendBytes(b, fieldResponseResources, schemaEntry("null_resource", resource))
+				b = appendBytes(b, fieldResponseDataSources, schemaEntry("null_data_source", nil))
+				b = appendBytes(b, fieldResponseProviderMeta, appendVarint(nil, fieldSchemaVersion, 2))
+				// Warnings should not fail the decoding.
+				return appendBytes(b, fieldResponseDiagnostics, diagnostic(2, "deprecated", "use something else"))
+			}(),
+			want: want{
+				ps: &tfjson.ProviderSchema{
+					ConfigSchema: &tfjson.Schema{Block: &tfjson.SchemaBlock{DescriptionKind: tfjson.SchemaDescriptionKindPlain}},
+					ResourceSchemas: map[string]*tfjson.Schema{
+						"null_resource": {
+							Version: 1,
+							Block: &tfjson.SchemaBlock{
+								Attributes: map[string]*tfjson.SchemaAttribute{
+									"id":       {AttributeType: cty.String, Computed: true, DescriptionKind: tfjson.SchemaDescriptionKindPlain},
+									"triggers": {AttributeType: cty.Map(cty.String), Optional: true, DescriptionKind: tfjson.SchemaDescriptionKindPlain},
+									"secret":   {AttributeType: cty.String, Optional: true, Sensitive: true, DescriptionKind: tfjson.SchemaDescriptionKindPlain},
+								},
+								NestedBlocks: map[string]*tfjson.SchemaBlockType{
+									"setting": {
+										NestingMode: tfjson.SchemaNestingModeList,
+										MinItems:    1,
+										MaxItems:    3,
+										Block: &tfjson.SchemaBlock{
+											Attributes: map[string]*tfjson.SchemaAttribute{
+												"value": {AttributeType: cty.Number, Required: true, DescriptionKind: tfjson.SchemaDescriptionKindPlain},
+											},
+											Deprecated:      true,
+											DescriptionKind: tfjson.SchemaDescriptionKindPlain,
+										},
+									},
+								},
+								Description:     "A **resource**.",
+								DescriptionKind: tfjson.SchemaDescriptionKindMarkdown,
+							},
+						},
+					},
+					DataSourceSchemas: map[string]*tfjson.Schema{
+						"null_data_source": {Block: &tfjson.SchemaBlock{DescriptionKind: tfjson.SchemaDescriptionKindPlain}},
+					},
+				},
+			},
+		},
+		"ErrorDiagnostics": {
+			reason: "Error diagnostics of the provider should be returned as an error.",
+			msg: func() []byte {
+				b := appendBytes(nil, fieldResponseDiagnostics, diagnostic(severityError, "boom", "it failed"))
+				return appendBytes(b, fieldResponseDiagnostics, diagnostic(severityError, "bang", ""))
+			}(),
+			want: want{err: errors.New("provider returned errors: boom: it failed; bang")},
+		},
+		"InvalidType": {
+			reason: "Attribute types that are not valid JSON type expressions should be rejected.",
+			msg: appendBytes(nil, fieldResponseResources, schemaEntry("null_resource",
+				appendBytes(nil, fieldSchemaBlock, appendBytes(nil, fieldBlockAttributes, attribute("id", `"unknown"`))))),
+			want: want{err: errors.Wrap(errors.Wrap(errors.New(`invalid primitive type name "unknown"`), "cannot decode type of attribute id"), "cannot decode schema of null_resource")},
+		},
+		"Truncated": {
+			reason: "Truncated messages should be rejected.",
+			msg:    appendBytes(nil, fieldResponseResources, []byte{0x01})[:2],
+			want:   want{err: errors.Wrap(protowire.ParseError(-1), "cannot decode response")},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			ps, err := decodeResponse(tc.msg)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ndecodeResponse(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.ps, ps, equateTypes); diff != "" {
+				t.Errorf("\n%s\ndecodeResponse(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestImpliedType(t *testing.T) {
+	b := &tfjson.SchemaBlock{
+		Attributes: map[string]*tfjson.SchemaAttribute{
+			"id": {AttributeType: cty.String},
+		},
+		NestedBlocks: map[string]*tfjson.SchemaBlockType{
+			"list":   {NestingMode: tfjson.SchemaNestingModeList, Block: &tfjson.SchemaBlock{}},
+			"set":    {NestingMode: tfjson.SchemaNestingModeSet, Block: &tfjson.SchemaBlock{}},
+			"map":    {NestingMode: tfjson.SchemaNestingModeMap, Block: &tfjson.SchemaBlock{}},
+			"single": {NestingMode: tfjson.SchemaNestingModeSingle, Block: &tfjson.SchemaBlock{}},
+		},
+	}
+	want := cty.Object(map[string]cty.Type{
+		"id":     cty.String,
+		"list":   cty.List(cty.EmptyObject),
+		"set":    cty.Set(cty.EmptyObject),
+		"map":    cty.Map(cty.EmptyObject),
+		"single": cty.EmptyObject,
+	})
+	if got := ImpliedType(b); !got.Equals(want) {
+		t.Errorf("ImpliedType(...): want %s, got %s", want.GoString(), got.GoString())
+	}
+}
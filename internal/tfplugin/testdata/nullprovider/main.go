@@ -0,0 +1,61 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command nullprovider serves a Terraform provider with the null_resource of
+// the null provider, which the tests run over the plugin protocol without
+// downloading the provider.
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/plugin"
+)
+
+func main() {
+	plugin.Serve(&plugin.ServeOpts{ProviderFunc: func() *schema.Provider {
+		return &schema.Provider{
+			ResourcesMap: map[string]*schema.Resource{
+				"null_resource": {
+					CreateContext: func(_ context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+						d.SetId(fmt.Sprintf("%d", rand.Int())) // #nosec G404 the ID does not have to be secure
+						return nil
+					},
+					ReadContext: func(context.Context, *schema.ResourceData, interface{}) diag.Diagnostics {
+						return nil
+					},
+					DeleteContext: func(_ context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+						d.SetId("")
+						return nil
+					},
+					Importer: &schema.ResourceImporter{StateContext: schema.ImportStatePassthroughContext},
+					Schema: map[string]*schema.Schema{
+						"triggers": {
+							Type:     schema.TypeMap,
+							Optional: true,
+							ForceNew: true,
+							Elem:     &schema.Schema{Type: schema.TypeString},
+						},
+					},
+				},
+			},
+		}
+	}})
+}
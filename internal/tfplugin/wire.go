@@ -0,0 +1,179 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tfplugin
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+	ctymsgpack "github.com/zclconf/go-cty/cty/msgpack"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The messages of the protocol are encoded and decoded by hand because the
+// generated Go types of the protocol are internal to terraform-plugin-go.
+
+// DynamicValue
+const (
+	fieldDynamicValueMsgpack protowire.Number = 1
+	fieldDynamicValueJSON    protowire.Number = 2
+)
+
+// Diagnostic
+const (
+	fieldDiagnosticSeverity protowire.Number = 1
+	fieldDiagnosticSummary  protowire.Number = 2
+	fieldDiagnosticDetail   protowire.Number = 3
+
+	severityError = 1
+)
+
+// map entries
+const (
+	fieldMapKey   protowire.Number = 1
+	fieldMapValue protowire.Number = 2
+)
+
+// field is a decoded protobuf field. Only one of bytes and varint is set
+// depending on the wire type.
+type field struct {
+	num    protowire.Number
+	bytes  []byte
+	varint uint64
+}
+
+func fields(b []byte) ([]field, error) {
+	var result []field
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		b = b[n:]
+		f := field{num: num}
+		switch typ {
+		case protowire.BytesType:
+			f.bytes, n = protowire.ConsumeBytes(b)
+		case protowire.VarintType:
+			f.varint, n = protowire.ConsumeVarint(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		b = b[n:]
+		result = append(result, f)
+	}
+	return result, nil
+}
+
+// decodeErrorDiagnostic returns the summary and the detail of the given
+// diagnostic if it is an error.
+func decodeErrorDiagnostic(b []byte) (string, error) {
+	fs, err := fields(b)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot decode diagnostic")
+	}
+	var severity uint64
+	var summary, detail string
+	for _, f := range fs {
+		switch f.num {
+		case fieldDiagnosticSeverity:
+			severity = f.varint
+		case fieldDiagnosticSummary:
+			summary = string(f.bytes)
+		case fieldDiagnosticDetail:
+			detail = string(f.bytes)
+		}
+	}
+	if severity != severityError {
+		return "", nil
+	}
+	if detail != "" {
+		return summary + ": " + detail, nil
+	}
+	return summary, nil
+}
+
+// decodeDiagnostics calls the given function with the fields of the given
+// response message other than its diagnostics with the given field number,
+// and returns an error if the response has error diagnostics.
+func decodeDiagnostics(b []byte, diagnostics protowire.Number, fn func(f field) error) error {
+	fs, err := fields(b)
+	if err != nil {
+		return errors.Wrap(err, "cannot decode response")
+	}
+	var diags []string
+	for _, f := range fs {
+		if f.num != diagnostics {
+			if err := fn(f); err != nil {
+				return err
+			}
+			continue
+		}
+		d, err := decodeErrorDiagnostic(f.bytes)
+		if err != nil {
+			return err
+		}
+		if d != "" {
+			diags = append(diags, d)
+		}
+	}
+	if len(diags) > 0 {
+		return errors.Errorf("provider returned errors: %s", strings.Join(diags, "; "))
+	}
+	return nil
+}
+
+// appendBytes appends the given bytes as the field with the given number.
+func appendBytes(b []byte, num protowire.Number, v []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+// appendDynamicValue appends the given value encoded as a DynamicValue
+// message as the field with the given number.
+func appendDynamicValue(b []byte, num protowire.Number, v cty.Value) ([]byte, error) {
+	raw, err := ctymsgpack.Marshal(v, v.Type())
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot encode value")
+	}
+	return appendBytes(b, num, appendBytes(nil, fieldDynamicValueMsgpack, raw)), nil
+}
+
+// decodeDynamicValue decodes the given DynamicValue message as a value of
+// the given type.
+func decodeDynamicValue(b []byte, ty cty.Type) (cty.Value, error) {
+	fs, err := fields(b)
+	if err != nil {
+		return cty.NilVal, errors.Wrap(err, "cannot decode value")
+	}
+	for _, f := range fs {
+		switch f.num {
+		case fieldDynamicValueMsgpack:
+			v, err := ctymsgpack.Unmarshal(f.bytes, ty)
+			return v, errors.Wrap(err, "cannot decode value")
+		case fieldDynamicValueJSON:
+			v, err := ctyjson.Unmarshal(f.bytes, ty)
+			return v, errors.Wrap(err, "cannot decode value")
+		}
+	}
+	return cty.NullVal(ty), nil
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package tfschema extracts the schema of a Terraform provider by talking to
+// its native binary over the plugin gRPC protocol, without the Terraform CLI.
+package tfschema
+
+import (
+	"context"
+
+	tfjson "github.com/hashicorp/terraform-json"
+
+	"github.com/crossplane-contrib/provider-jet-template/internal/tfplugin"
+)
+
+const (
+	// formatVersion is the format version of the JSON output of
+	// "terraform providers schema" that Extract mimics.
+	formatVersion = "1.0"
+)
+
+// Extract launches the provider binary at the given path, fetches its schema
+// and returns it in the format of "terraform providers schema -json" under the
+// given provider address, e.g. registry.terraform.io/hashicorp/null.
+func Extract(ctx context.Context, binaryPath, providerAddress string) (*tfjson.ProviderSchemas, error) {
+	p, err := tfplugin.Start(binaryPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer p.Close()
+
+	ps, err := p.GetSchema(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &tfjson.ProviderSchemas{
+		FormatVersion: formatVersion,
+		Schemas:       map[string]*tfjson.ProviderSchema{providerAddress: ps},
+	}, nil
+}
@@ -0,0 +1,255 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package tfstate contains utilities to work with the Terraform state of the
+// managed resources outside of their workspaces.
+package tfstate
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	xpresource "github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/terrajet/pkg/config"
+	"github.com/crossplane/terrajet/pkg/resource"
+	"github.com/crossplane/terrajet/pkg/resource/json"
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// AnnotationKeyExportState is the annotation that enables or disables the
+	// export of the workspace state of a managed resource when set to "true"
+	// or "false". It takes precedence over the provider-wide default.
+	AnnotationKeyExportState = "template.jet.crossplane.io/export-state"
+
+	// LabelKeyResourceUID is the label of the exported Secret that holds the
+	// UID of the managed resource whose state is exported.
+	LabelKeyResourceUID = "template.jet.crossplane.io/resource-uid"
+	// AnnotationKeyResourceName is the annotation of the exported Secret that
+	// holds the name of the managed resource whose state is exported.
+	AnnotationKeyResourceName = "template.jet.crossplane.io/resource-name"
+	// AnnotationKeyResourceType is the annotation of the exported Secret that
+	// holds the Terraform resource type of the exported state.
+	AnnotationKeyResourceType = "template.jet.crossplane.io/terraform-resource-type"
+
+	// KeyState is the key of the Terraform state in the exported Secret.
+	KeyState = "terraform.tfstate"
+	// KeyMainTF is the key of the Terraform configuration in the exported
+	// Secret.
+	KeyMainTF = "main.tf.json"
+
+	fileState  = "terraform.tfstate"
+	fileMainTF = "main.tf.json"
+
+	errFmtReadFile     = "cannot read %s"
+	errRedactState     = "cannot redact Terraform state"
+	errRedactMainTF    = "cannot redact Terraform configuration"
+	errGetKind         = "cannot get the kind of the managed resource"
+	errApplySecret     = "cannot apply the Secret of the exported state"
+	errUnmarshalState  = "cannot unmarshal Terraform state"
+	errUnmarshalAttrs  = "cannot unmarshal state attributes"
+	errMarshalAttrs    = "cannot marshal state attributes"
+	errUnmarshalMainTF = "cannot unmarshal Terraform configuration"
+)
+
+// ExporterOption lets you configure an Exporter.
+type ExporterOption func(*Exporter)
+
+// WithExportByDefault configures whether the workspace state of the managed
+// resources that do not have the AnnotationKeyExportState annotation should
+// be exported.
+func WithExportByDefault(b bool) ExporterOption {
+	return func(e *Exporter) {
+		e.byDefault = b
+	}
+}
+
+// WithFs lets you set the fs the workspace files are read from. Used mostly
+// for testing.
+func WithFs(fs afero.Fs) ExporterOption {
+	return func(e *Exporter) {
+		e.fs = afero.Afero{Fs: fs}
+	}
+}
+
+// NewExporter returns a new Exporter that writes the Secrets of the
+// cluster-scoped managed resources to the given namespace. The Secrets of the
+// namespaced managed resources are written to their own namespaces.
+func NewExporter(kube client.Client, namespace string, opts ...ExporterOption) *Exporter {
+	e := &Exporter{
+		kube:       kube,
+		applicator: xpresource.NewAPIUpdatingApplicator(kube),
+		fs:         afero.Afero{Fs: afero.NewOsFs()},
+		namespace:  namespace,
+	}
+	for _, f := range opts {
+		f(e)
+	}
+	return e
+}
+
+// Exporter mirrors the Terraform state and configuration of the workspaces of
+// managed resources into Secrets so that they can be audited and used to
+// rebuild the workspaces. The sensitive attributes are redacted according to
+// the schema of the Terraform resource.
+type Exporter struct {
+	kube       client.Client
+	applicator xpresource.Applicator
+	fs         afero.Afero
+	namespace  string
+	byDefault  bool
+}
+
+// Enabled returns whether the workspace state of the given object should be
+// exported.
+func (e *Exporter) Enabled(o metav1.Object) bool {
+	switch o.GetAnnotations()[AnnotationKeyExportState] {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	return e.byDefault
+}
+
+// SecretName returns the name of the Secret that the workspace state of the
+// given object is exported to.
+func SecretName(o metav1.Object) string {
+	return fmt.Sprintf("tfstate-%s", o.GetUID())
+}
+
+// Export mirrors the Terraform state and configuration files in the given
+// workspace directory into the Secret of the given resource. The given
+// backend block, if any, is rendered into the exported configuration, so
+// that the Terraform sessions using it share the state and its lock with the
+// provider.
+func (e *Exporter) Export(ctx context.Context, tr resource.Terraformed, cfg *config.Resource, dir string, backend map[string]interface{}) error {
+	state, err := e.fs.ReadFile(filepath.Join(dir, fileState))
+	if err != nil {
+		return errors.Wrapf(err, errFmtReadFile, fileState)
+	}
+	if state, err = redactState(state, cfg); err != nil {
+		return errors.Wrap(err, errRedactState)
+	}
+	mainTF, err := e.fs.ReadFile(filepath.Join(dir, fileMainTF))
+	if err != nil {
+		return errors.Wrapf(err, errFmtReadFile, fileMainTF)
+	}
+	if mainTF, err = redactMainTF(mainTF, cfg, backend); err != nil {
+		return errors.Wrap(err, errRedactMainTF)
+	}
+	gvk, err := xpresource.GetKind(tr, e.kube.Scheme())
+	if err != nil {
+		return errors.Wrap(err, errGetKind)
+	}
+	s := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      SecretName(tr),
+			Namespace: e.namespaceOf(tr),
+			Labels: map[string]string{
+				LabelKeyResourceUID: string(tr.GetUID()),
+			},
+			Annotations: map[string]string{
+				AnnotationKeyResourceName: tr.GetName(),
+				AnnotationKeyResourceType: tr.GetTerraformResourceType(),
+			},
+			OwnerReferences: []metav1.OwnerReference{meta.AsOwner(meta.TypedReferenceTo(tr, gvk))},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			KeyState:  state,
+			KeyMainTF: mainTF,
+		},
+	}
+	return errors.Wrap(e.applicator.Apply(ctx, s), errApplySecret)
+}
+
+func (e *Exporter) namespaceOf(o metav1.Object) string {
+	if ns := o.GetNamespace(); ns != "" {
+		return ns
+	}
+	return e.namespace
+}
+
+func redactState(raw []byte, cfg *config.Resource) ([]byte, error) {
+	s := &json.StateV4{}
+	if err := json.JSParser.Unmarshal(raw, s); err != nil {
+		return nil, errors.Wrap(err, errUnmarshalState)
+	}
+	for i := range s.Resources {
+		for j := range s.Resources[i].Instances {
+			in := &s.Resources[i].Instances[j]
+			if len(in.AttributesRaw) == 0 {
+				continue
+			}
+			attrs := map[string]interface{}{}
+			if err := json.JSParser.Unmarshal(in.AttributesRaw, &attrs); err != nil {
+				return nil, errors.Wrap(err, errUnmarshalAttrs)
+			}
+			RedactAttributes(attrs, cfg.TerraformResource.Schema)
+			raw, err := json.JSParser.Marshal(attrs)
+			if err != nil {
+				return nil, errors.Wrap(err, errMarshalAttrs)
+			}
+			in.AttributesRaw = raw
+		}
+	}
+	return json.JSParser.Marshal(s)
+}
+
+func redactMainTF(raw []byte, cfg *config.Resource, backend map[string]interface{}) ([]byte, error) {
+	m := map[string]interface{}{}
+	if err := json.JSParser.Unmarshal(raw, &m); err != nil {
+		return nil, errors.Wrap(err, errUnmarshalMainTF)
+	}
+	if backend != nil {
+		tf, ok := m["terraform"].(map[string]interface{})
+		if !ok {
+			tf = map[string]interface{}{}
+			m["terraform"] = tf
+		}
+		tf["backend"] = backend
+	}
+	// The provider configuration usually consists of credentials, so we do
+	// not export any of its values.
+	if p, ok := m["provider"].(map[string]interface{}); ok {
+		RedactAll(p)
+	}
+	if r, ok := m["resource"].(map[string]interface{}); ok {
+		for _, byName := range r {
+			redactResourceBlocks(byName, cfg)
+		}
+	}
+	return json.JSParser.Marshal(m)
+}
+
+func redactResourceBlocks(byName interface{}, cfg *config.Resource) {
+	blocks, ok := byName.(map[string]interface{})
+	if !ok {
+		return
+	}
+	for _, b := range blocks {
+		if params, ok := b.(map[string]interface{}); ok {
+			RedactAttributes(params, cfg.TerraformResource.Schema)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tfstate
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/crossplane/terrajet/pkg/config"
+	"github.com/crossplane/terrajet/pkg/resource/json"
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	kschema "k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane-contrib/provider-jet-template/apis/null/v1alpha1"
+)
+
+func TestExport(t *testing.T) {
+	cfg := &config.Resource{TerraformResource: &schema.Resource{Schema: map[string]*schema.Schema{
+		"name":     {Type: schema.TypeString, Required: true},
+		"password": {Type: schema.TypeString, Optional: true, Sensitive: true},
+	}}}
+	state := `{"version":4,"terraform_version":"1.1.6","serial":1,"lineage":"l","outputs":{},"resources":[{"mode":"managed","type":"test_resource","name":"example","provider":"provider[\"registry.terraform.io/hashicorp/test\"]","instances":[{"schema_version":0,"attributes":{"name":"example","password":"secret"}}]}]}`
+	mainTF := `{"provider":{"test":{"token":"secret"}},"resource":{"test_resource":{"example":{"name":"example","password":"secret"}}}}`
+	s := runtime.NewScheme()
+	if err := v1alpha1.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+
+	type want struct {
+		namespace string
+		state     map[string]interface{}
+		mainTF    map[string]interface{}
+		err       error
+	}
+	cases := map[string]struct {
+		reason    string
+		namespace string
+		files     map[string]string
+		backend   map[string]interface{}
+		want      want
+	}{
+		"ClusterScoped": {
+			reason: "The redacted state and configuration of cluster-scoped resources should be exported to the namespace of the provider.",
+			files:  map[string]string{fileState: state, fileMainTF: mainTF},
+			want: want{
+				namespace: "crossplane-system",
+				state:     map[string]interface{}{"name": "example", "password": redactedValue},
+				mainTF: map[string]interface{}{
+					"provider": map[string]interface{}{"test": map[string]interface{}{"token": redactedValue}},
+					"resource": map[string]interface{}{"test_resource": map[string]interface{}{"example": map[string]interface{}{"name": "example", "password": redactedValue}}},
+				},
+			},
+		},
+		"Namespaced": {
+			reason:    "The state of namespaced resources should be exported to their own namespace.",
+			namespace: "team",
+			files:     map[string]string{fileState: state, fileMainTF: mainTF},
+			want: want{
+				namespace: "team",
+				state:     map[string]interface{}{"name": "example", "password": redactedValue},
+				mainTF: map[string]interface{}{
+					"provider": map[string]interface{}{"test": map[string]interface{}{"token": redactedValue}},
+					"resource": map[string]interface{}{"test_resource": map[string]interface{}{"example": map[string]interface{}{"name": "example", "password": redactedValue}}},
+				},
+			},
+		},
+		"Backend": {
+			reason:  "The backend block should be rendered into the exported configuration.",
+			files:   map[string]string{fileState: state, fileMainTF: `{"terraform":{"required_providers":{"test":{"source":"hashicorp/test"}}},"provider":{"test":{"token":"secret"}}}`},
+			backend: map[string]interface{}{"kubernetes": map[string]interface{}{"secret_suffix": "uid", "namespace": "crossplane-system"}},
+			want: want{
+				namespace: "crossplane-system",
+				state:     map[string]interface{}{"name": "example", "password": redactedValue},
+				mainTF: map[string]interface{}{
+					"terraform": map[string]interface{}{
+						"required_providers": map[string]interface{}{"test": map[string]interface{}{"source": "hashicorp/test"}},
+						"backend":            map[string]interface{}{"kubernetes": map[string]interface{}{"secret_suffix": "uid", "namespace": "crossplane-system"}},
+					},
+					"provider": map[string]interface{}{"test": map[string]interface{}{"token": redactedValue}},
+				},
+			},
+		},
+		"NoState": {
+			reason: "Nothing should be exported without a state file.",
+			files:  map[string]string{fileMainTF: mainTF},
+			want:   want{err: errors.Wrapf(&os.PathError{Op: "open", Path: "/ws/" + fileState, Err: os.ErrNotExist}, errFmtReadFile, fileState)},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			for f, content := range tc.files {
+				if err := afero.WriteFile(fs, "/ws/"+f, []byte(content), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var got *corev1.Secret
+			kube := &test.MockClient{
+				MockGet: test.NewMockGetFn(kerrors.NewNotFound(kschema.GroupResource{}, "")),
+				MockCreate: func(_ context.Context, obj client.Object, _ ...client.CreateOption) error {
+					got = obj.(*corev1.Secret)
+					return nil
+				},
+				MockScheme: test.NewMockSchemeFn(s),
+			}
+			mg := &v1alpha1.Resource{}
+			mg.SetName("example")
+			mg.SetNamespace(tc.namespace)
+			mg.SetUID("uid")
+			err := NewExporter(kube, "crossplane-system", WithFs(fs)).Export(context.Background(), mg, cfg, "/ws", tc.backend)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nExport(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if tc.want.err != nil {
+				return
+			}
+			if diff := cmp.Diff(tc.want.namespace, got.GetNamespace()); diff != "" {
+				t.Errorf("\n%s\nExport(...): -want namespace, +got namespace:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.state, exportedAttributes(t, got.Data[KeyState])); diff != "" {
+				t.Errorf("\n%s\nExport(...): -want state, +got state:\n%s", tc.reason, diff)
+			}
+			gotMainTF := map[string]interface{}{}
+			if err := json.JSParser.Unmarshal(got.Data[KeyMainTF], &gotMainTF); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want.mainTF, gotMainTF); diff != "" {
+				t.Errorf("\n%s\nExport(...): -want configuration, +got configuration:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+// exportedAttributes returns the attributes of the only resource in the
+// given state.
+func exportedAttributes(t *testing.T, raw []byte) map[string]interface{} {
+	t.Helper()
+	st := &json.StateV4{}
+	if err := json.JSParser.Unmarshal(raw, st); err != nil {
+		t.Fatal(err)
+	}
+	attrs := map[string]interface{}{}
+	if err := json.JSParser.Unmarshal(st.Resources[0].Instances[0].AttributesRaw, &attrs); err != nil {
+		t.Fatal(err)
+	}
+	return attrs
+}
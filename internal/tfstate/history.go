@@ -0,0 +1,300 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tfstate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	xpresource "github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/terrajet/pkg/config"
+	"github.com/crossplane/terrajet/pkg/resource"
+	"github.com/crossplane/terrajet/pkg/resource/json"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// AnnotationKeyRollbackTo is the annotation that restores the parameters
+	// of a managed resource from the state snapshot with the given ID.
+	AnnotationKeyRollbackTo = "template.jet.crossplane.io/rollback-to"
+
+	// LabelKeySnapshot is the label of a state snapshot Secret that holds
+	// the ID of the snapshot. The IDs of the snapshots of a managed resource
+	// increase with every snapshot.
+	LabelKeySnapshot = "template.jet.crossplane.io/snapshot"
+	// AnnotationKeySnapshotTime is the annotation of a state snapshot Secret
+	// that holds the time the snapshot was taken at.
+	AnnotationKeySnapshotTime = "template.jet.crossplane.io/snapshot-time"
+	// AnnotationKeyGeneration is the annotation of a state snapshot Secret
+	// that holds the generation of the managed resource whose apply
+	// produced the state.
+	AnnotationKeyGeneration = "template.jet.crossplane.io/generation"
+
+	errListSnapshots     = "cannot list the state snapshots"
+	errCreateSnapshot    = "cannot create the state snapshot"
+	errDeleteSnapshot    = "cannot delete the state snapshot"
+	errNoSnapshot        = "state snapshot is not found"
+	errFmtParseSnapshot  = "cannot parse state snapshot %s"
+	errSnapshotNoResults = "state snapshot has no resource attributes"
+)
+
+// A Snapshot is a Terraform state of a managed resource produced by an
+// apply.
+type Snapshot struct {
+	// ID of the snapshot.
+	ID int
+	// Time the snapshot was taken at.
+	Time time.Time
+	// Generation of the managed resource whose apply produced the state.
+	Generation int64
+	// State is the redacted Terraform state.
+	State []byte
+}
+
+// Parameters returns the arguments of the Terraform resource in the state of
+// the snapshot, which are the parameters of the managed resource, including
+// the nested ones. The arguments missing from the snapshot are nil, so that
+// they are reset. The snapshot only has the redacted values of the sensitive
+// arguments, so the given current parameters of the managed resource are
+// kept for them at every depth.
+func (s *Snapshot) Parameters(cfg *config.Resource, current map[string]interface{}) (map[string]interface{}, error) {
+	st := &json.StateV4{}
+	if err := json.JSParser.Unmarshal(s.State, st); err != nil {
+		return nil, errors.Wrap(err, errUnmarshalState)
+	}
+	if len(st.GetAttributes()) == 0 {
+		return nil, errors.New(errSnapshotNoResults)
+	}
+	attrs := map[string]interface{}{}
+	if err := json.JSParser.Unmarshal(st.GetAttributes(), &attrs); err != nil {
+		return nil, errors.Wrap(err, errUnmarshalAttrs)
+	}
+	return restore(attrs, current, cfg.TerraformResource.Schema), nil
+}
+
+// restore returns the arguments of the given Terraform schema in the given
+// attributes, with the values of the sensitive ones taken from the given
+// current arguments.
+func restore(attrs, current map[string]interface{}, s map[string]*schema.Schema) map[string]interface{} {
+	params := make(map[string]interface{}, len(s))
+	for k, sch := range s {
+		if !sch.Optional && !sch.Required {
+			continue
+		}
+		if !sch.Sensitive {
+			params[k] = restoreBlocks(attrs[k], current[k], sch)
+			continue
+		}
+		if v, ok := current[k]; ok {
+			params[k] = v
+		}
+	}
+	return params
+}
+
+// restoreBlocks restores the arguments of the given value of a nested block
+// with the given schema like restore, with the values of the sensitive ones
+// taken from the blocks at the same position in the given current value.
+// Other values are returned as they are.
+func restoreBlocks(v, current interface{}, sch *schema.Schema) interface{} {
+	r, ok := sch.Elem.(*schema.Resource)
+	if !ok {
+		return v
+	}
+	blocks, ok := v.([]interface{})
+	if !ok {
+		return v
+	}
+	cur, _ := current.([]interface{})
+	restored := make([]interface{}, len(blocks))
+	for i, b := range blocks {
+		m, ok := b.(map[string]interface{})
+		if !ok {
+			restored[i] = b
+			continue
+		}
+		var c map[string]interface{}
+		if i < len(cur) {
+			c, _ = cur[i].(map[string]interface{})
+		}
+		restored[i] = restore(m, c, r.Schema)
+	}
+	return restored
+}
+
+// History keeps the last state snapshots of the managed resources in
+// Secrets, owned by the managed resources. The snapshots of cluster-scoped
+// managed resources are kept in the namespace of the provider, and the ones
+// of namespace-scoped managed resources in their own namespace. The
+// sensitive attributes are redacted according to the schema of the Terraform
+// resource.
+type History struct {
+	kube      client.Client
+	fs        afero.Afero
+	namespace string
+}
+
+// NewHistory returns a new History that keeps the snapshots of the
+// cluster-scoped managed resources in the given namespace.
+func NewHistory(kube client.Client, namespace string) *History {
+	return &History{kube: kube, fs: afero.Afero{Fs: afero.NewOsFs()}, namespace: namespace}
+}
+
+// SnapshotName returns the name of the Secret of the state snapshot with the
+// given ID of the given object.
+func SnapshotName(o metav1.Object, id int) string {
+	return fmt.Sprintf("tfstate-%s-%d", o.GetUID(), id)
+}
+
+// Record takes a snapshot of the Terraform state in the given workspace
+// directory of the given resource unless the resource attributes in the
+// state are the same as in its last snapshot, and deletes the snapshots
+// beyond the given number of snapshots to keep.
+func (h *History) Record(ctx context.Context, tr resource.Terraformed, cfg *config.Resource, dir string, keep int) error {
+	raw, err := h.fs.ReadFile(filepath.Join(dir, fileState))
+	if err != nil {
+		return errors.Wrapf(err, errFmtReadFile, fileState)
+	}
+	state, err := redactState(raw, cfg)
+	if err != nil {
+		return errors.Wrap(err, errRedactState)
+	}
+	snapshots, err := h.Snapshots(ctx, tr)
+	if err != nil {
+		return err
+	}
+	id := 1
+	if n := len(snapshots); n > 0 {
+		if bytes.Equal(attributesOf(snapshots[n-1].State), attributesOf(state)) {
+			return nil
+		}
+		id = snapshots[n-1].ID + 1
+	}
+	gvk, err := xpresource.GetKind(tr, h.kube.Scheme())
+	if err != nil {
+		return errors.Wrap(err, errGetKind)
+	}
+	s := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      SnapshotName(tr, id),
+			Namespace: h.namespaceOf(tr),
+			Labels: map[string]string{
+				LabelKeyResourceUID: string(tr.GetUID()),
+				LabelKeySnapshot:    strconv.Itoa(id),
+			},
+			Annotations: map[string]string{
+				AnnotationKeyResourceName: tr.GetName(),
+				AnnotationKeyResourceType: tr.GetTerraformResourceType(),
+				AnnotationKeySnapshotTime: time.Now().UTC().Format(time.RFC3339),
+				AnnotationKeyGeneration:   strconv.FormatInt(tr.GetGeneration(), 10),
+			},
+			OwnerReferences: []metav1.OwnerReference{meta.AsOwner(meta.TypedReferenceTo(tr, gvk))},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{KeyState: state},
+	}
+	if err := h.kube.Create(ctx, s); err != nil {
+		return errors.Wrap(err, errCreateSnapshot)
+	}
+	return h.prune(ctx, tr, append(snapshots, Snapshot{ID: id}), keep)
+}
+
+// prune deletes the oldest of the given snapshots of the given object beyond
+// the given number of snapshots to keep.
+func (h *History) prune(ctx context.Context, o metav1.Object, snapshots []Snapshot, keep int) error {
+	for i := 0; i < len(snapshots)-keep; i++ {
+		s := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: SnapshotName(o, snapshots[i].ID), Namespace: h.namespaceOf(o)}}
+		if err := client.IgnoreNotFound(h.kube.Delete(ctx, s)); err != nil {
+			return errors.Wrap(err, errDeleteSnapshot)
+		}
+	}
+	return nil
+}
+
+// Snapshots returns the snapshots of the given object, oldest first.
+func (h *History) Snapshots(ctx context.Context, o metav1.Object) ([]Snapshot, error) {
+	l := &corev1.SecretList{}
+	if err := h.kube.List(ctx, l, client.InNamespace(h.namespaceOf(o)), client.MatchingLabels{LabelKeyResourceUID: string(o.GetUID())}, client.HasLabels{LabelKeySnapshot}); err != nil {
+		return nil, errors.Wrap(err, errListSnapshots)
+	}
+	snapshots := make([]Snapshot, 0, len(l.Items))
+	for _, s := range l.Items {
+		snap, err := snapshotOf(s)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snap)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].ID < snapshots[j].ID
+	})
+	return snapshots, nil
+}
+
+// Get returns the snapshot with the given ID of the given object.
+func (h *History) Get(ctx context.Context, o metav1.Object, id string) (*Snapshot, error) {
+	snapshots, err := h.Snapshots(ctx, o)
+	if err != nil {
+		return nil, err
+	}
+	for i := range snapshots {
+		if strconv.Itoa(snapshots[i].ID) == id {
+			return &snapshots[i], nil
+		}
+	}
+	return nil, errors.New(errNoSnapshot)
+}
+
+func (h *History) namespaceOf(o metav1.Object) string {
+	if ns := o.GetNamespace(); ns != "" {
+		return ns
+	}
+	return h.namespace
+}
+
+func snapshotOf(s corev1.Secret) (Snapshot, error) {
+	snap := Snapshot{State: s.Data[KeyState]}
+	var err error
+	if snap.ID, err = strconv.Atoi(s.Labels[LabelKeySnapshot]); err != nil {
+		return snap, errors.Wrapf(err, errFmtParseSnapshot, s.Name)
+	}
+	// The time and the generation are informational.
+	snap.Time, _ = time.Parse(time.RFC3339, s.Annotations[AnnotationKeySnapshotTime])
+	snap.Generation, _ = strconv.ParseInt(s.Annotations[AnnotationKeyGeneration], 10, 64)
+	return snap, nil
+}
+
+// attributesOf returns the attributes of the resource in the given Terraform
+// state, or nil if it cannot be parsed.
+func attributesOf(state []byte) []byte {
+	st := &json.StateV4{}
+	if err := json.JSParser.Unmarshal(state, st); err != nil {
+		return nil
+	}
+	return st.GetAttributes()
+}
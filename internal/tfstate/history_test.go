@@ -0,0 +1,113 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tfstate
+
+import (
+	"testing"
+
+	"github.com/crossplane/terrajet/pkg/config"
+	"github.com/crossplane/terrajet/pkg/resource/json"
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestSnapshotParameters(t *testing.T) {
+	cfg := &config.Resource{TerraformResource: &schema.Resource{Schema: map[string]*schema.Schema{
+		"id":       {Type: schema.TypeString, Computed: true},
+		"name":     {Type: schema.TypeString, Required: true},
+		"size":     {Type: schema.TypeInt, Optional: true},
+		"password": {Type: schema.TypeString, Optional: true, Sensitive: true},
+		"setting": {Type: schema.TypeList, Optional: true, Elem: &schema.Resource{Schema: map[string]*schema.Schema{
+			"mode":  {Type: schema.TypeString, Optional: true},
+			"token": {Type: schema.TypeString, Optional: true, Sensitive: true},
+		}}},
+	}}}
+	attrs := map[string]interface{}{
+		"id":       "example",
+		"name":     "old",
+		"password": "old-password",
+		"setting": []interface{}{
+			map[string]interface{}{"mode": "a", "token": "old-a"},
+			map[string]interface{}{"mode": "b", "token": "old-b"},
+		},
+	}
+	// The snapshots keep redacted states.
+	RedactAttributes(attrs, cfg.TerraformResource.Schema)
+	st := `{"version":4,"terraform_version":"1.1.6","serial":1,"lineage":"l","outputs":{},"resources":[{"mode":"managed","type":"test_resource","name":"example","provider":"provider[\"registry.terraform.io/hashicorp/test\"]","instances":[{"schema_version":0,"attributes":` + mustMarshal(t, attrs) + `}]}]}`
+
+	cases := map[string]struct {
+		reason  string
+		current map[string]interface{}
+		want    map[string]interface{}
+	}{
+		"KeepSensitive": {
+			reason: "The current values of the sensitive arguments should be kept at every depth, and the arguments missing from the snapshot should be reset.",
+			current: map[string]interface{}{
+				"name":     "new",
+				"size":     3.0,
+				"password": "new-password",
+				"setting": []interface{}{
+					map[string]interface{}{"mode": "c", "token": "new-a"},
+				},
+			},
+			want: map[string]interface{}{
+				"name":     "old",
+				"size":     nil,
+				"password": "new-password",
+				"setting": []interface{}{
+					map[string]interface{}{"mode": "a", "token": "new-a"},
+					map[string]interface{}{"mode": "b"},
+				},
+			},
+		},
+		"NoCurrentSensitive": {
+			reason: "The redacted values of the sensitive arguments should never be restored.",
+			current: map[string]interface{}{
+				"name": "new",
+			},
+			want: map[string]interface{}{
+				"name": "old",
+				"size": nil,
+				"setting": []interface{}{
+					map[string]interface{}{"mode": "a"},
+					map[string]interface{}{"mode": "b"},
+				},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := &Snapshot{State: []byte(st)}
+			got, err := s.Parameters(cfg, tc.current)
+			if err != nil {
+				t.Fatalf("Parameters(...): %s", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nParameters(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func mustMarshal(t *testing.T, v interface{}) string {
+	t.Helper()
+	raw, err := json.JSParser.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(raw)
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tfstate
+
+import (
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// redactedValue replaces the values of sensitive attributes.
+const redactedValue = "(sensitive value)"
+
+// RedactAttributes replaces the values of the attributes that are marked as
+// sensitive in the given Terraform schema, including the ones in nested
+// blocks, in place.
+func RedactAttributes(attrs map[string]interface{}, s map[string]*schema.Schema) {
+	for k, sch := range s {
+		v, ok := attrs[k]
+		if !ok || v == nil {
+			continue
+		}
+		if sch.Sensitive {
+			attrs[k] = redactedValue
+			continue
+		}
+		r, ok := sch.Elem.(*schema.Resource)
+		if !ok {
+			continue
+		}
+		switch nested := v.(type) {
+		case map[string]interface{}:
+			RedactAttributes(nested, r.Schema)
+		case []interface{}:
+			for _, e := range nested {
+				if m, ok := e.(map[string]interface{}); ok {
+					RedactAttributes(m, r.Schema)
+				}
+			}
+		}
+	}
+}
+
+// RedactAll replaces all the leaf values in the given map, in place. It's
+// used for the blocks for which we don't have a schema, such as the provider
+// configuration that typically contains credentials.
+func RedactAll(attrs map[string]interface{}) {
+	for k, v := range attrs {
+		switch nested := v.(type) {
+		case map[string]interface{}:
+			RedactAll(nested)
+		case []interface{}:
+			for i, e := range nested {
+				if m, ok := e.(map[string]interface{}); ok {
+					RedactAll(m)
+					continue
+				}
+				nested[i] = redactedValue
+			}
+		case nil:
+		default:
+			attrs[k] = redactedValue
+		}
+	}
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tfstate
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestRedactAttributes(t *testing.T) {
+	s := map[string]*schema.Schema{
+		"name":     {Type: schema.TypeString, Required: true},
+		"password": {Type: schema.TypeString, Optional: true, Sensitive: true},
+		"tags":     {Type: schema.TypeMap, Optional: true, Sensitive: true, Elem: &schema.Schema{Type: schema.TypeString}},
+		"setting": {Type: schema.TypeList, Optional: true, Elem: &schema.Resource{Schema: map[string]*schema.Schema{
+			"mode":  {Type: schema.TypeString, Optional: true},
+			"token": {Type: schema.TypeString, Optional: true, Sensitive: true},
+			"inner": {Type: schema.TypeList, Optional: true, Elem: &schema.Resource{Schema: map[string]*schema.Schema{
+				"key": {Type: schema.TypeString, Optional: true, Sensitive: true},
+			}}},
+		}}},
+	}
+	cases := map[string]struct {
+		reason string
+		attrs  map[string]interface{}
+		want   map[string]interface{}
+	}{
+		"TopLevel": {
+			reason: "Sensitive attributes should be redacted and non-sensitive ones kept.",
+			attrs:  map[string]interface{}{"name": "example", "password": "secret", "tags": map[string]interface{}{"a": "b"}},
+			want:   map[string]interface{}{"name": "example", "password": redactedValue, "tags": redactedValue},
+		},
+		"NestedBlocks": {
+			reason: "Sensitive attributes of nested blocks should be redacted at every depth.",
+			attrs: map[string]interface{}{"setting": []interface{}{
+				map[string]interface{}{"mode": "a", "token": "secret-a", "inner": []interface{}{map[string]interface{}{"key": "secret"}}},
+				map[string]interface{}{"mode": "b"},
+			}},
+			want: map[string]interface{}{"setting": []interface{}{
+				map[string]interface{}{"mode": "a", "token": redactedValue, "inner": []interface{}{map[string]interface{}{"key": redactedValue}}},
+				map[string]interface{}{"mode": "b"},
+			}},
+		},
+		"NullAndUnknown": {
+			reason: "Null sensitive attributes and attributes without a schema should be kept.",
+			attrs:  map[string]interface{}{"password": nil, "other": "value"},
+			want:   map[string]interface{}{"password": nil, "other": "value"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			RedactAttributes(tc.attrs, s)
+			if diff := cmp.Diff(tc.want, tc.attrs); diff != "" {
+				t.Errorf("\n%s\nRedactAttributes(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRedactAll(t *testing.T) {
+	attrs := map[string]interface{}{
+		"region": "us-east-1",
+		"token":  "secret",
+		"assume": []interface{}{map[string]interface{}{"role": "arn"}, "plain"},
+		"nested": map[string]interface{}{"key": 1.0, "empty": nil},
+	}
+	want := map[string]interface{}{
+		"region": redactedValue,
+		"token":  redactedValue,
+		"assume": []interface{}{map[string]interface{}{"role": redactedValue}, redactedValue},
+		"nested": map[string]interface{}{"key": redactedValue, "empty": nil},
+	}
+	RedactAll(attrs)
+	if diff := cmp.Diff(want, attrs); diff != "" {
+		t.Errorf("\nAll the values of blocks without a schema should be redacted.\nRedactAll(...): -want, +got:\n%s", diff)
+	}
+}
@@ -0,0 +1,256 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workspace
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+
+	"github.com/crossplane/terrajet/pkg/resource/json"
+	"github.com/pkg/errors"
+)
+
+const (
+	extEncrypted = ".enc"
+	extTemp      = ".tmp"
+	dekSize      = 32
+
+	errFmtEncrypt    = "cannot encrypt %s"
+	errFmtDecrypt    = "cannot decrypt %s"
+	errFmtUnknownKey = "unknown encryption key %q"
+	errCiphertext    = "ciphertext is too short"
+)
+
+// encryptedFiles are the workspace files that may contain credentials or
+// sensitive attributes.
+var encryptedFiles = []string{fileMain, fileState, fileStateBackup, FilePlan}
+
+// envelope is an encrypted workspace file. The file is encrypted with a data
+// key, which is encrypted with the key with the given ID.
+type envelope struct {
+	KeyID string `json:"keyID"`
+	Key   []byte `json:"key"`
+	Data  []byte `json:"data"`
+}
+
+// An Encrypter encrypts the workspace files at rest with envelope encryption.
+type Encrypter struct {
+	keys KeySource
+}
+
+// NewEncrypter returns a new Encrypter that encrypts with the keys of the
+// given source.
+func NewEncrypter(keys KeySource) *Encrypter {
+	return &Encrypter{keys: keys}
+}
+
+// Decrypt decrypts the encrypted files of the workspace in the given
+// directory so that Terraform can use them. A decrypted file that already
+// exists is kept, since Terrajet writes the configuration of the workspace
+// before every operation, unless it's a Terraform state older than the
+// encrypted one.
+func (e *Encrypter) Decrypt(ctx context.Context, dir string) error {
+	ring, err := e.keys.Keys(ctx)
+	if err != nil {
+		return err
+	}
+	for _, name := range encryptedFiles {
+		if err := ring.decryptFile(dir, name); err != nil {
+			return errors.Wrapf(err, errFmtDecrypt, name)
+		}
+	}
+	return nil
+}
+
+// Encrypt encrypts the decrypted files of the workspace in the given
+// directory with the primary key and removes them. The files encrypted with
+// other keys before are encrypted with the primary key again, so that the
+// keys are rotated by the next operation on every workspace.
+func (e *Encrypter) Encrypt(ctx context.Context, dir string) error {
+	ring, err := e.keys.Keys(ctx)
+	if err != nil {
+		return err
+	}
+	for _, name := range encryptedFiles {
+		if err := ring.encryptFile(dir, name); err != nil {
+			return errors.Wrapf(err, errFmtEncrypt, name)
+		}
+	}
+	return nil
+}
+
+// RemovePlan removes the plan saved in the workspace in the given directory,
+// and its encrypted copy.
+func RemovePlan(dir string) error {
+	for _, name := range []string{FilePlan, FilePlan + extEncrypted} {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *KeyRing) decryptFile(dir, name string) error {
+	path := filepath.Join(dir, name)
+	raw, err := os.ReadFile(filepath.Clean(path + extEncrypted))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	plain, err := r.open(raw, aad(dir, name))
+	if err != nil {
+		return err
+	}
+	keep, err := keepDecrypted(path, plain)
+	if err != nil || keep {
+		return err
+	}
+	return writeFile(path, plain)
+}
+
+func (r *KeyRing) encryptFile(dir, name string) error {
+	path := filepath.Join(dir, name)
+	plain, err := os.ReadFile(filepath.Clean(path))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	raw, err := r.seal(plain, aad(dir, name))
+	if err != nil {
+		return err
+	}
+	if err := writeFile(path+extEncrypted, raw); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// keepDecrypted returns whether the existing decrypted file at the given
+// path has to be kept rather than replaced with the given decrypted content.
+func keepDecrypted(path string, plain []byte) (bool, error) {
+	existing, err := os.ReadFile(filepath.Clean(path))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if filepath.Base(path) == fileMain {
+		return true, nil
+	}
+	return serialOf(existing) > serialOf(plain), nil
+}
+
+// seal encrypts the given content with a new data key, which is encrypted
+// with the primary key. The given additional data binds the ciphertext to
+// the file it's written to.
+func (r *KeyRing) seal(plain, additional []byte) ([]byte, error) {
+	dek := make([]byte, dekSize)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, err
+	}
+	data, err := sealGCM(dek, plain, additional)
+	if err != nil {
+		return nil, err
+	}
+	key, err := sealGCM(r.keys[r.primary], dek, []byte(r.primary))
+	if err != nil {
+		return nil, err
+	}
+	return json.JSParser.Marshal(envelope{KeyID: r.primary, Key: key, Data: data})
+}
+
+// open decrypts the given envelope with the key it was encrypted with.
+func (r *KeyRing) open(raw, additional []byte) ([]byte, error) {
+	env := envelope{}
+	if err := json.JSParser.Unmarshal(raw, &env); err != nil {
+		return nil, err
+	}
+	kek, ok := r.keys[env.KeyID]
+	if !ok {
+		return nil, errors.Errorf(errFmtUnknownKey, env.KeyID)
+	}
+	dek, err := openGCM(kek, env.Key, []byte(env.KeyID))
+	if err != nil {
+		return nil, err
+	}
+	return openGCM(dek, env.Data, additional)
+}
+
+func sealGCM(key, plain, additional []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plain, additional), nil
+}
+
+func openGCM(key, sealed, additional []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New(errCiphertext)
+	}
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], additional)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// aad returns the additional data of the file with the given name in the
+// workspace in the given directory.
+func aad(dir, name string) []byte {
+	return []byte(filepath.Base(dir) + "/" + name)
+}
+
+// writeFile writes the given content to the file at the given path through
+// a temporary file, so that the file is never partially written.
+func writeFile(path string, content []byte) error {
+	if err := os.WriteFile(path+extTemp, content, 0600); err != nil {
+		return err
+	}
+	return os.Rename(path+extTemp, path)
+}
+
+// serialOf returns the serial of the given Terraform state, or zero if it
+// cannot be parsed.
+func serialOf(raw []byte) uint64 {
+	st := &json.StateV4{}
+	if err := json.JSParser.Unmarshal(raw, st); err != nil {
+		return 0
+	}
+	return st.Serial
+}
@@ -0,0 +1,221 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workspace
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/crossplane/terrajet/pkg/resource/json"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+func newTestKeyRing(t *testing.T, primary string, ids ...string) *KeyRing {
+	t.Helper()
+	entries := map[string][]byte{KeyPrimary: []byte(primary)}
+	for _, id := range ids {
+		entries[id] = bytes.Repeat([]byte(id[:1]), keySize)
+	}
+	r, err := NewKeyRing(entries)
+	if err != nil {
+		t.Fatalf("NewKeyRing(...): %s", err)
+	}
+	return r
+}
+
+func TestKeyRingOpen(t *testing.T) {
+	current := newTestKeyRing(t, "new", "new", "old")
+	plain := []byte(`{"version":4,"serial":1}`)
+	additional := aad("/workspaces/uid", fileState)
+	// errAuth is the error of a ciphertext that cannot be authenticated.
+	_, errAuth := openGCM(make([]byte, keySize), make([]byte, 32), nil)
+
+	type args struct {
+		sealedBy   *KeyRing
+		additional []byte
+		truncate   bool
+	}
+	type want struct {
+		plain []byte
+		err   error
+	}
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"RoundTrip": {
+			reason: "A file sealed with the primary key should be opened again.",
+			args:   args{sealedBy: current, additional: additional},
+			want:   want{plain: plain},
+		},
+		"OtherFile": {
+			reason: "A ciphertext moved to another file of the workspace should not be opened.",
+			args:   args{sealedBy: current, additional: aad("/workspaces/uid", fileMain)},
+			want:   want{err: errAuth},
+		},
+		"OtherWorkspace": {
+			reason: "A ciphertext moved to another workspace should not be opened.",
+			args:   args{sealedBy: current, additional: aad("/workspaces/other", fileState)},
+			want:   want{err: errAuth},
+		},
+		"RotatedKey": {
+			reason: "A file sealed with a key that is not the primary one anymore should be opened.",
+			args:   args{sealedBy: newTestKeyRing(t, "old", "old"), additional: additional},
+			want:   want{plain: plain},
+		},
+		"UnknownKey": {
+			reason: "A file sealed with a key that was removed from the key ring should not be opened.",
+			args:   args{sealedBy: newTestKeyRing(t, "gone", "gone"), additional: additional},
+			want:   want{err: errors.Errorf(errFmtUnknownKey, "gone")},
+		},
+		"Truncated": {
+			reason: "A truncated ciphertext should not be opened.",
+			args:   args{sealedBy: current, additional: additional, truncate: true},
+			want:   want{err: errors.New(errCiphertext)},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			raw, err := tc.args.sealedBy.seal(plain, additional)
+			if err != nil {
+				t.Fatalf("seal(...): %s", err)
+			}
+			if tc.args.truncate {
+				env := envelope{}
+				if err := json.JSParser.Unmarshal(raw, &env); err != nil {
+					t.Fatal(err)
+				}
+				env.Data = env.Data[:4]
+				if raw, err = json.JSParser.Marshal(env); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := current.open(raw, tc.args.additional)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nopen(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(string(tc.want.plain), string(got)); diff != "" {
+				t.Errorf("\n%s\nopen(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestEncrypterRotatesKeys(t *testing.T) {
+	dir := t.TempDir()
+	state := []byte(`{"version":4,"serial":1}`)
+	if err := os.WriteFile(filepath.Join(dir, fileState), state, 0600); err != nil {
+		t.Fatal(err)
+	}
+	ring := newTestKeyRing(t, "old", "old")
+	e := NewEncrypter(KeySourceFn(func(context.Context) (*KeyRing, error) { return ring, nil }))
+	ctx := context.Background()
+	if err := e.Encrypt(ctx, dir); err != nil {
+		t.Fatalf("Encrypt(...): %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, fileState)); !os.IsNotExist(err) {
+		t.Errorf("Encrypt(...): the decrypted file was not removed: %v", err)
+	}
+
+	ring = newTestKeyRing(t, "new", "new", "old")
+	if err := e.Decrypt(ctx, dir); err != nil {
+		t.Fatalf("Decrypt(...): %s", err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, fileState))
+	if err != nil {
+		t.Fatalf("Decrypt(...): %s", err)
+	}
+	if diff := cmp.Diff(string(state), string(got)); diff != "" {
+		t.Errorf("\nThe file encrypted with the rotated key should be decrypted.\nDecrypt(...): -want, +got:\n%s", diff)
+	}
+	if err := e.Encrypt(ctx, dir); err != nil {
+		t.Fatalf("Encrypt(...): %s", err)
+	}
+	raw, err := os.ReadFile(filepath.Join(dir, fileState+extEncrypted))
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := envelope{}
+	if err := json.JSParser.Unmarshal(raw, &env); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff("new", env.KeyID); diff != "" {
+		t.Errorf("\nThe file should be encrypted with the primary key again.\nEncrypt(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestKeepDecrypted(t *testing.T) {
+	state := func(serial string) string {
+		return `{"version":4,"serial":` + serial + `}`
+	}
+	type args struct {
+		name     string
+		existing string
+		plain    string
+	}
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   bool
+	}{
+		"NoDecryptedFile": {
+			reason: "The decrypted content should be written if there is no decrypted file.",
+			args:   args{name: fileState, plain: state("1")},
+		},
+		"HigherSerial": {
+			reason: "A decrypted state newer than the encrypted one should be kept.",
+			args:   args{name: fileState, existing: state("2"), plain: state("1")},
+			want:   true,
+		},
+		"EqualSerial": {
+			reason: "A decrypted state as old as the encrypted one should be replaced.",
+			args:   args{name: fileState, existing: state("1"), plain: state("1")},
+		},
+		"LowerSerial": {
+			reason: "A decrypted state older than the encrypted one should be replaced.",
+			args:   args{name: fileState, existing: state("1"), plain: state("2")},
+		},
+		"Configuration": {
+			reason: "The configuration written by Terrajet before every operation should be kept.",
+			args:   args{name: fileMain, existing: `{"resource":{}}`, plain: `{}`},
+			want:   true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tc.args.name)
+			if tc.args.existing != "" {
+				if err := os.WriteFile(path, []byte(tc.args.existing), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := keepDecrypted(path, []byte(tc.args.plain))
+			if err != nil {
+				t.Fatalf("keepDecrypted(...): %s", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nkeepDecrypted(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workspace
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/terrajet/pkg/resource"
+	"github.com/crossplane/terrajet/pkg/terraform"
+	"github.com/pkg/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+const (
+	errFmtList   = "cannot list %s"
+	errFmtRemove = "cannot remove workspace %s"
+)
+
+var (
+	terraformedType = reflect.TypeOf((*resource.Terraformed)(nil)).Elem()
+	hubType         = reflect.TypeOf((*conversion.Hub)(nil)).Elem()
+)
+
+// A GarbageCollectorOption configures a GarbageCollector.
+type GarbageCollectorOption func(*GarbageCollector)
+
+// WithInterval sets the interval at which the GarbageCollector runs after
+// the initial run at startup.
+func WithInterval(d time.Duration) GarbageCollectorOption {
+	return func(gc *GarbageCollector) {
+		gc.interval = d
+	}
+}
+
+// A GarbageCollector removes the workspaces whose managed resources no longer
+// exist, e.g. because they were deleted while the provider wasn't running.
+type GarbageCollector struct {
+	kube     client.Client
+	store    *terraform.WorkspaceStore
+	logger   logging.Logger
+	interval time.Duration
+}
+
+// NewGarbageCollector returns a new GarbageCollector that finds the managed
+// resources with the given client and removes the workspaces of the missing
+// ones from the given store.
+func NewGarbageCollector(kube client.Client, store *terraform.WorkspaceStore, log logging.Logger, opts ...GarbageCollectorOption) *GarbageCollector {
+	gc := &GarbageCollector{
+		kube:     kube,
+		store:    store,
+		logger:   log,
+		interval: time.Hour,
+	}
+	for _, o := range opts {
+		o(gc)
+	}
+	return gc
+}
+
+// Start collects the garbage when the manager starts and then periodically
+// until the given context is done. Failed runs are logged and retried at the
+// next interval.
+func (gc *GarbageCollector) Start(ctx context.Context) error {
+	t := time.NewTicker(gc.interval)
+	defer t.Stop()
+	for {
+		if n, err := gc.Collect(ctx); err != nil {
+			gc.logger.Info("Cannot collect the garbage workspaces", "error", err)
+		} else if n > 0 {
+			gc.logger.Info("Removed garbage workspaces", "count", n)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+// Collect removes the workspaces whose managed resources no longer exist and
+// returns their number.
+func (gc *GarbageCollector) Collect(ctx context.Context) (int, error) {
+	// The workspaces are listed before the managed resources, so that the
+	// resources of the listed workspaces are known to the cache.
+	entries, err := os.ReadDir(Root())
+	if err != nil {
+		return 0, errors.Wrap(err, errReadRoot)
+	}
+	live, err := gc.managedUIDs(ctx)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, e := range entries {
+		if !e.IsDir() || !uid.MatchString(e.Name()) || live[e.Name()] {
+			continue
+		}
+		// The workspace store doesn't remove the workspaces it doesn't know.
+		o := &metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{UID: types.UID(e.Name())}}
+		if err := gc.store.Remove(o); err != nil {
+			return n, errors.Wrapf(err, errFmtRemove, e.Name())
+		}
+		if err := os.RemoveAll(filepath.Join(Root(), e.Name())); err != nil {
+			return n, errors.Wrapf(err, errFmtRemove, e.Name())
+		}
+		if err := unlink(e.Name()); err != nil {
+			return n, errors.Wrapf(err, errFmtRemove, e.Name())
+		}
+		n++
+	}
+	return n, nil
+}
+
+// managedUIDs returns the UIDs of all managed resources of the kinds in the
+// scheme of the client.
+func (gc *GarbageCollector) managedUIDs(ctx context.Context) (map[string]bool, error) {
+	uids := map[string]bool{}
+	for _, gvk := range managedKinds(gc.kube.Scheme()) {
+		listGVK := gvk.GroupVersion().WithKind(gvk.Kind + "List")
+		obj, err := gc.kube.Scheme().New(listGVK)
+		if err != nil {
+			return nil, errors.Wrapf(err, errFmtList, listGVK.Kind)
+		}
+		l, ok := obj.(client.ObjectList)
+		if !ok {
+			continue
+		}
+		if err := gc.kube.List(ctx, l); err != nil {
+			return nil, errors.Wrapf(err, errFmtList, listGVK.Kind)
+		}
+		if err := apimeta.EachListItem(l, func(o runtime.Object) error {
+			if mo, ok := o.(metav1.Object); ok {
+				uids[string(mo.GetUID())] = true
+			}
+			return nil
+		}); err != nil {
+			return nil, errors.Wrapf(err, errFmtList, listGVK.Kind)
+		}
+	}
+	return uids, nil
+}
+
+// managedKinds returns the managed resource kinds of the given scheme. The
+// kinds served in several versions are listed in their storage version,
+// which is the conversion hub, so that their lists need no conversion.
+func managedKinds(s *runtime.Scheme) map[schema.GroupKind]schema.GroupVersionKind {
+	kinds := map[schema.GroupKind]schema.GroupVersionKind{}
+	for gvk, t := range s.AllKnownTypes() {
+		if !reflect.PtrTo(t).Implements(terraformedType) {
+			continue
+		}
+		if _, ok := kinds[gvk.GroupKind()]; !ok || reflect.PtrTo(t).Implements(hubType) {
+			kinds[gvk.GroupKind()] = gvk
+		}
+	}
+	return kinds
+}
@@ -0,0 +1,267 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workspace
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/terrajet/pkg/resource/json"
+	"github.com/crossplane/terrajet/pkg/terraform"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	envPluginCacheDir = "TF_PLUGIN_CACHE_DIR"
+
+	dirTemplates     = "workspaces"
+	fileInitDuration = ".init-duration"
+	providersDepth   = 5
+
+	errCreateCache      = "cannot create the plugin cache directory"
+	errSetCache         = "cannot resolve the plugin cache directory"
+	errWriteTemplate    = "cannot write the configuration of the shared workspace"
+	errCheckLock        = "cannot check the dependency lock file of the workspace"
+	errLinkProviders    = "cannot link the providers of the shared workspace"
+	errCopyLock         = "cannot copy the dependency lock file of the shared workspace"
+	errFmtInitTemplate  = "cannot init the shared workspace of provider %s: %s"
+	errFmtCheckTemplate = "cannot check the shared workspace of provider %s"
+)
+
+var (
+	workspaceInits = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "template_jet_workspace_inits_total",
+		Help: "Number of workspaces initialised, by whether they ran terraform init or reused a shared workspace.",
+	}, []string{"provider", "mode"})
+	workspaceInitDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "template_jet_workspace_init_duration_seconds",
+		Help:    "Duration of the terraform init runs of the shared workspaces.",
+		Buckets: prometheus.ExponentialBuckets(0.25, 2, 10),
+	}, []string{"provider"})
+	workspaceInitSaved = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "template_jet_workspace_init_saved_seconds_total",
+		Help: "Estimated time saved by reusing shared workspaces instead of running terraform init, based on the duration of the init of the shared workspace.",
+	}, []string{"provider"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(workspaceInits, workspaceInitDuration, workspaceInitSaved)
+}
+
+// An Initializer initialises the workspaces from shared workspaces, so that
+// terraform init runs once per provider requirement instead of once per
+// managed resource. A shared workspace is initialised with the plugin cache
+// and only declares the provider requirement. The workspaces get its
+// dependency lock file and links to its providers, so Terrajet finds them
+// initialised.
+type Initializer struct {
+	dir    string
+	logger logging.Logger
+
+	mu     sync.Mutex
+	shared map[string]*sharedWorkspace
+}
+
+// sharedWorkspace is the shared workspace of a provider requirement.
+type sharedWorkspace struct {
+	mu       sync.Mutex
+	dir      string
+	duration time.Duration
+	ready    bool
+}
+
+// NewInitializer returns a new Initializer that keeps the plugin cache and
+// the shared workspaces in the given directory.
+func NewInitializer(dir string, log logging.Logger) (*Initializer, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, errSetCache)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, dirTemplates), 0700); err != nil {
+		return nil, errors.Wrap(err, errCreateCache)
+	}
+	return &Initializer{dir: dir, logger: log, shared: map[string]*sharedWorkspace{}}, nil
+}
+
+// Env returns the environment variables that make terraform use the plugin
+// cache. They have to be in the environment of every terraform process that
+// may install providers, including the inits Terrajet runs itself.
+func (i *Initializer) Env() []string {
+	return []string{envPluginCacheDir + "=" + i.dir}
+}
+
+// Prepare initialises the workspace in the given directory for the given
+// provider requirement from the shared workspace of the requirement, unless
+// the workspace is already initialised. The shared workspace is initialised
+// with the given environment first if needed.
+func (i *Initializer) Prepare(ctx context.Context, dir string, req terraform.ProviderRequirement, env []string) error {
+	_, err := os.Stat(filepath.Join(dir, fileLock))
+	if !os.IsNotExist(err) {
+		return errors.Wrap(err, errCheckLock)
+	}
+	s, err := i.sharedFor(ctx, req, env)
+	if err != nil {
+		return err
+	}
+	// The lock file is copied last, so that a workspace whose preparation was
+	// interrupted is prepared again.
+	if err := linkProviders(s.dir, dir); err != nil {
+		return errors.Wrap(err, errLinkProviders)
+	}
+	lock, err := os.ReadFile(filepath.Join(s.dir, fileLock))
+	if err != nil {
+		return errors.Wrap(err, errCopyLock)
+	}
+	if err := writeFile(filepath.Join(dir, fileLock), lock); err != nil {
+		return errors.Wrap(err, errCopyLock)
+	}
+	workspaceInits.WithLabelValues(req.Source, "shared").Inc()
+	workspaceInitSaved.WithLabelValues(req.Source).Add(s.duration.Seconds())
+	return nil
+}
+
+// sharedFor returns the shared workspace of the given provider requirement,
+// and initialises it with the given environment if it's not initialised yet.
+// Concurrent calls for the same requirement wait for a single init.
+func (i *Initializer) sharedFor(ctx context.Context, req terraform.ProviderRequirement, env []string) (*sharedWorkspace, error) {
+	key := strings.NewReplacer("/", "_", ":", "_").Replace(req.Source) + "_" + req.Version
+	i.mu.Lock()
+	s, ok := i.shared[key]
+	if !ok {
+		s = &sharedWorkspace{dir: filepath.Join(i.dir, dirTemplates, key)}
+		i.shared[key] = s
+	}
+	i.mu.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ready {
+		return s, nil
+	}
+	// The shared workspaces are kept in the plugin cache directory, so they
+	// are reused after restarts if it's persistent.
+	d, err := initDuration(s.dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, errFmtCheckTemplate, req.Source)
+	}
+	if d == 0 {
+		if d, err = i.initShared(ctx, s.dir, req, env); err != nil {
+			return nil, err
+		}
+	}
+	s.duration, s.ready = d, true
+	return s, nil
+}
+
+// initShared runs terraform init in the given shared workspace directory of
+// the given provider requirement, and returns how long it took.
+func (i *Initializer) initShared(ctx context.Context, dir string, req terraform.ProviderRequirement, env []string) (time.Duration, error) {
+	if err := os.RemoveAll(dir); err != nil {
+		return 0, errors.Wrap(err, errWriteTemplate)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return 0, errors.Wrap(err, errWriteTemplate)
+	}
+	// The provider is declared under the same name as in the configuration
+	// Terrajet writes to the workspaces.
+	name := req.Source[strings.LastIndex(req.Source, "/")+1:]
+	main, err := json.JSParser.Marshal(map[string]interface{}{
+		"terraform": map[string]interface{}{
+			"required_providers": map[string]interface{}{
+				name: map[string]string{"source": req.Source, "version": req.Version},
+			},
+		},
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, errWriteTemplate)
+	}
+	if err := writeFile(filepath.Join(dir, fileMain), main); err != nil {
+		return 0, errors.Wrap(err, errWriteTemplate)
+	}
+	start := time.Now()
+	cmd := exec.CommandContext(ctx, "terraform", "init", "-input=false")
+	cmd.Dir = dir
+	cmd.Env = append(append(os.Environ(), env...), i.Env()...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return 0, errors.Wrapf(err, errFmtInitTemplate, req.Source, string(out))
+	}
+	d := time.Since(start)
+	i.logger.Debug("Initialised shared workspace", "provider", req.Source, "version", req.Version, "duration", d.String())
+	workspaceInits.WithLabelValues(req.Source, "terraform").Inc()
+	workspaceInitDuration.WithLabelValues(req.Source).Observe(d.Seconds())
+	// The duration marks the shared workspace as initialised, so it's
+	// written last.
+	return d, errors.Wrap(writeFile(filepath.Join(dir, fileInitDuration), []byte(d.String())), errWriteTemplate)
+}
+
+// initDuration returns how long the init of the shared workspace in the
+// given directory took, or zero if it's not initialised.
+func initDuration(dir string) (time.Duration, error) {
+	raw, err := os.ReadFile(filepath.Clean(filepath.Join(dir, fileInitDuration)))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	// A damaged file makes the shared workspace be initialised again.
+	if d, err := time.ParseDuration(strings.TrimSpace(string(raw))); err == nil {
+		return d, nil
+	}
+	return 0, nil
+}
+
+// linkProviders replaces the providers of the workspace in the given
+// directory with links to the installed providers of the given shared
+// workspace, which are in turn links into the plugin cache. The providers are
+// installed at host/namespace/type/version/platform.
+func linkProviders(shared, dir string) error {
+	root := filepath.Join(shared, dirProviders)
+	if err := os.RemoveAll(filepath.Join(dir, dirProviders)); err != nil {
+		return err
+	}
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == "." || strings.Count(rel, string(filepath.Separator)) != providersDepth-1 {
+			return err
+		}
+		target, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			return err
+		}
+		link := filepath.Join(dir, dirProviders, rel)
+		if err := os.MkdirAll(filepath.Dir(link), 0700); err != nil {
+			return err
+		}
+		if err := os.Symlink(target, link); err != nil || !info.IsDir() {
+			return err
+		}
+		return filepath.SkipDir
+	})
+}
@@ -0,0 +1,205 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workspace
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/terrajet/pkg/terraform"
+	"github.com/google/go-cmp/cmp"
+)
+
+// fakeInit is a terraform executable whose init installs the null provider
+// into the plugin cache and links it into the workspace like terraform does.
+// It records its invocations in the file given by INIT_LOG.
+const fakeInit = `#!/bin/sh
+echo "$TF_PLUGIN_CACHE_DIR $EXTRA $*" >> "$INIT_LOG"
+[ -n "$FAIL" ] && { echo "$FAIL"; exit 1; }
+platform=registry.terraform.io/hashicorp/null/3.1.1/linux_amd64
+mkdir -p "$TF_PLUGIN_CACHE_DIR/$platform" .terraform/providers/$(dirname $platform)
+touch "$TF_PLUGIN_CACHE_DIR/$platform/terraform-provider-null_v3.1.1_x5"
+ln -s "$TF_PLUGIN_CACHE_DIR/$platform" .terraform/providers/$platform
+echo 'provider "registry.terraform.io/hashicorp/null" {}' > .terraform.lock.hcl
+`
+
+const providerPath = "registry.terraform.io/hashicorp/null/3.1.1/linux_amd64"
+
+// setupFakeInit puts fakeInit on the PATH and returns the file it logs its
+// invocations to.
+func setupFakeInit(t *testing.T) string {
+	t.Helper()
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "terraform"), []byte(fakeInit), 0700); err != nil { //nolint:gosec
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	log := filepath.Join(t.TempDir(), "init.log")
+	t.Setenv("INIT_LOG", log)
+	return log
+}
+
+// initLog returns the invocations of fakeInit.
+func initLog(t *testing.T, path string) []string {
+	t.Helper()
+	raw, err := os.ReadFile(filepath.Clean(path))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(raw)), "\n")
+}
+
+func TestInitializerPrepare(t *testing.T) {
+	req := terraform.ProviderRequirement{Source: "hashicorp/null", Version: "3.1.1"}
+	env := []string{"EXTRA=setup"}
+
+	type want struct {
+		inits    int
+		prepared bool
+		err      bool
+	}
+	cases := map[string]struct {
+		reason string
+		// initialised is whether the workspace is already initialised.
+		initialised bool
+		// restarted is whether the shared workspace was initialised before a
+		// restart of the provider.
+		restarted bool
+		fail      string
+		want      want
+	}{
+		"InitShared": {
+			reason: "The shared workspace should be initialised with the plugin cache and the workspace prepared from it.",
+			want:   want{inits: 1, prepared: true},
+		},
+		"ReuseShared": {
+			reason:    "A shared workspace initialised before a restart should be reused.",
+			restarted: true,
+			want:      want{inits: 1, prepared: true},
+		},
+		"Initialised": {
+			reason:      "An initialised workspace should be left alone.",
+			initialised: true,
+		},
+		"InitFails": {
+			reason: "A failed init of the shared workspace should be reported.",
+			fail:   "no provider",
+			want:   want{inits: 1, err: true},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			log := setupFakeInit(t)
+			t.Setenv("FAIL", tc.fail)
+			cache := t.TempDir()
+			dir := t.TempDir()
+			if tc.initialised {
+				if err := os.WriteFile(filepath.Join(dir, fileLock), nil, 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tc.restarted {
+				i, err := NewInitializer(cache, logging.NewNopLogger())
+				if err != nil {
+					t.Fatalf("NewInitializer(...): %s", err)
+				}
+				if err := i.Prepare(context.Background(), t.TempDir(), req, env); err != nil {
+					t.Fatalf("Prepare(...): %s", err)
+				}
+			}
+			i, err := NewInitializer(cache, logging.NewNopLogger())
+			if err != nil {
+				t.Fatalf("NewInitializer(...): %s", err)
+			}
+			err = i.Prepare(context.Background(), dir, req, env)
+			if diff := cmp.Diff(tc.want.err, err != nil); diff != "" {
+				t.Errorf("\n%s\nPrepare(...): -want error, +got error:\n%s\n%v", tc.reason, diff, err)
+			}
+			inits := initLog(t, log)
+			if diff := cmp.Diff(tc.want.inits, len(inits)); diff != "" {
+				t.Errorf("\n%s\nPrepare(...): -want inits, +got inits:\n%s", tc.reason, diff)
+			}
+			for _, l := range inits {
+				if diff := cmp.Diff(cache+" setup init -input=false", l); diff != "" {
+					t.Errorf("\n%s\nPrepare(...): -want init, +got init:\n%s", tc.reason, diff)
+				}
+			}
+			_, err = os.Stat(filepath.Join(dir, fileLock))
+			if diff := cmp.Diff(tc.want.prepared || tc.initialised, err == nil); diff != "" {
+				t.Errorf("\n%s\nPrepare(...): -want lock file, +got lock file:\n%s", tc.reason, diff)
+			}
+			if !tc.want.prepared {
+				return
+			}
+			target, err := os.Readlink(filepath.Join(dir, dirProviders, providerPath))
+			if err != nil {
+				t.Fatalf("Prepare(...): the provider is not linked into the workspace: %s", err)
+			}
+			if diff := cmp.Diff(filepath.Join(cache, providerPath), target); diff != "" {
+				t.Errorf("\n%s\nPrepare(...): -want link target, +got link target:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestInitializerPrepareConcurrently(t *testing.T) {
+	log := setupFakeInit(t)
+	i, err := NewInitializer(t.TempDir(), logging.NewNopLogger())
+	if err != nil {
+		t.Fatalf("NewInitializer(...): %s", err)
+	}
+	req := terraform.ProviderRequirement{Source: "hashicorp/null", Version: "3.1.1"}
+	var wg sync.WaitGroup
+	errs := make([]error, 5)
+	for n := range errs {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			errs[n] = i.Prepare(context.Background(), t.TempDir(), req, nil)
+		}(n)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Errorf("Prepare(...): %s", err)
+		}
+	}
+	if got := len(initLog(t, log)); got != 1 {
+		t.Errorf("Prepare(...): got %d inits of the shared workspace, want 1", got)
+	}
+}
+
+func TestInitializerEnv(t *testing.T) {
+	i, err := NewInitializer(t.TempDir(), logging.NewNopLogger())
+	if err != nil {
+		t.Fatalf("NewInitializer(...): %s", err)
+	}
+	if os.Getenv(envPluginCacheDir) == i.dir {
+		t.Errorf("NewInitializer(...): %s is set in the environment of the process", envPluginCacheDir)
+	}
+	if diff := cmp.Diff([]string{envPluginCacheDir + "=" + i.dir}, i.Env()); diff != "" {
+		t.Errorf("\nThe plugin cache should be passed in the environment.\nEnv(): -want, +got:\n%s", diff)
+	}
+}
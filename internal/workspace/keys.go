@@ -0,0 +1,125 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workspace
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// KeyPrimary is the entry of a key ring that holds the ID of the key the
+	// workspace files are encrypted with. The other entries are the keys,
+	// named after their IDs.
+	KeyPrimary = "primary"
+
+	keySize = 32
+
+	errReadKeys         = "cannot read the workspace encryption keys"
+	errGetKeySecret     = "cannot get the Secret of the workspace encryption keys"
+	errNoPrimaryKey     = "no primary workspace encryption key is set"
+	errFmtNoPrimaryKey  = "primary workspace encryption key %q is not found"
+	errFmtKeySize       = "workspace encryption key %q must be %d bytes long"
+	errFmtReadKeyFromFS = "cannot read workspace encryption key %q"
+)
+
+// A KeyRing holds the keys the workspace files are encrypted with, by their
+// IDs. New files are encrypted with the primary key, and the other keys are
+// kept to decrypt the files encrypted before the primary key was rotated.
+type KeyRing struct {
+	primary string
+	keys    map[string][]byte
+}
+
+// NewKeyRing returns the key ring of the given entries, which hold the ID of
+// the primary key under KeyPrimary and the 32 bytes long AES-256 keys under
+// their IDs.
+func NewKeyRing(entries map[string][]byte) (*KeyRing, error) {
+	r := &KeyRing{primary: strings.TrimSpace(string(entries[KeyPrimary])), keys: map[string][]byte{}}
+	if r.primary == "" {
+		return nil, errors.New(errNoPrimaryKey)
+	}
+	for id, k := range entries {
+		if id == KeyPrimary {
+			continue
+		}
+		if len(k) != keySize {
+			return nil, errors.Errorf(errFmtKeySize, id, keySize)
+		}
+		r.keys[id] = k
+	}
+	if _, ok := r.keys[r.primary]; !ok {
+		return nil, errors.Errorf(errFmtNoPrimaryKey, r.primary)
+	}
+	return r, nil
+}
+
+// A KeySource returns the key ring of the workspace files. The key ring is
+// read before every use, so that keys can be rotated without restarts.
+type KeySource interface {
+	Keys(ctx context.Context) (*KeyRing, error)
+}
+
+// A KeySourceFn is a function that satisfies KeySource.
+type KeySourceFn func(ctx context.Context) (*KeyRing, error)
+
+// Keys returns the key ring.
+func (fn KeySourceFn) Keys(ctx context.Context) (*KeyRing, error) {
+	return fn(ctx)
+}
+
+// NewDirectoryKeySource returns a KeySource that reads the key ring from the
+// files in the given directory, named after their entries, e.g. a mounted
+// Secret.
+func NewDirectoryKeySource(dir string) KeySource {
+	return KeySourceFn(func(_ context.Context) (*KeyRing, error) {
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, errors.Wrap(err, errReadKeys)
+		}
+		entries := map[string][]byte{}
+		for _, f := range files {
+			// Mounted Secrets link their entries to hidden directories.
+			if f.IsDir() || strings.HasPrefix(f.Name(), ".") {
+				continue
+			}
+			if entries[f.Name()], err = os.ReadFile(filepath.Join(dir, f.Name())); err != nil {
+				return nil, errors.Wrapf(err, errFmtReadKeyFromFS, f.Name())
+			}
+		}
+		return NewKeyRing(entries)
+	})
+}
+
+// NewSecretKeySource returns a KeySource that reads the key ring from the
+// entries of the Secret with the given namespace and name.
+func NewSecretKeySource(kube client.Reader, namespace, name string) KeySource {
+	return KeySourceFn(func(ctx context.Context) (*KeyRing, error) {
+		s := &corev1.Secret{}
+		if err := kube.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, s); err != nil {
+			return nil, errors.Wrap(err, errGetKeySecret)
+		}
+		return NewKeyRing(s.Data)
+	})
+}
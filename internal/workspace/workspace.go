@@ -0,0 +1,246 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package workspace manages the directories of the Terraform workspaces of
+// the managed resources, so that they can outlive the provider process.
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/terrajet/pkg/resource/json"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// FilePlan is the Terraform plan saved in a workspace to be applied once it's
+// approved.
+const FilePlan = "approval.tfplan"
+
+const (
+	fileMain        = "main.tf.json"
+	fileState       = "terraform.tfstate"
+	fileStateBackup = "terraform.tfstate.backup"
+	fileLock        = ".terraform.lock.hcl"
+	dirProviders    = ".terraform/providers"
+
+	errCreateRoot   = "cannot create the workspace directory"
+	errSetRoot      = "cannot set the workspace directory"
+	errReadRoot     = "cannot read the workspace directory"
+	errFmtLink      = "cannot link workspace %s into the temporary directory"
+	errFmtNotLink   = "%s is not a link to the workspace directory"
+	errFmtRepair    = "cannot repair workspace %s"
+	errFmtCheckFile = "cannot check %s"
+)
+
+// uid matches the names of the workspace directories, which are the UIDs of
+// their managed resources.
+var uid = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// root is the directory the workspaces are kept in if it's not the temporary
+// directory.
+var root string
+
+// Root returns the directory the workspaces are kept in.
+func Root() string {
+	if root != "" {
+		return root
+	}
+	return os.TempDir()
+}
+
+// Dir returns the directory of the workspace of the given managed resource.
+func Dir(o metav1.Object) string {
+	return filepath.Join(Root(), string(o.GetUID()))
+}
+
+// SetRoot makes the workspaces be kept in the given directory, e.g. on a
+// mounted volume, instead of the temporary directory. The Terrajet workspace
+// store keeps the workspaces in the temporary directory of the process, so
+// it finds them through the links that Prepare makes there, while the
+// temporary directory is left to the rest of the process. SetRoot must be
+// called before the workspace store is used.
+func SetRoot(dir string) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return errors.Wrap(err, errSetRoot)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return errors.Wrap(err, errCreateRoot)
+	}
+	root = dir
+	return nil
+}
+
+// Prepare makes the workspace of the given managed resource ready to be used
+// by the Terrajet workspace store. If the workspaces are not kept in the
+// temporary directory, the workspace store finds the workspace through a
+// link in the temporary directory.
+func Prepare(o metav1.Object) error {
+	return link(string(o.GetUID()))
+}
+
+// link links the workspace with the given name into the temporary directory
+// unless the workspaces are kept there.
+func link(name string) error {
+	dir, l := filepath.Join(Root(), name), filepath.Join(os.TempDir(), name)
+	if dir == l {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return errors.Wrapf(err, errFmtLink, name)
+	}
+	fi, err := os.Lstat(l)
+	switch {
+	case os.IsNotExist(err):
+		return errors.Wrapf(os.Symlink(dir, l), errFmtLink, name)
+	case err != nil:
+		return errors.Wrapf(err, errFmtLink, name)
+	case fi.Mode()&os.ModeSymlink == 0:
+		return errors.Errorf(errFmtNotLink, l)
+	}
+	return nil
+}
+
+// unlink removes the link of the workspace with the given name from the
+// temporary directory unless the workspaces are kept there.
+func unlink(name string) error {
+	l := filepath.Join(os.TempDir(), name)
+	if filepath.Join(Root(), name) == l {
+		return nil
+	}
+	if err := os.Remove(l); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Discover finds the existing workspaces in the root directory and repairs
+// the ones that failed their integrity check, so that they can be reused.
+// It returns the UIDs of the managed resources of the found workspaces.
+func Discover(log logging.Logger) ([]string, error) {
+	entries, err := os.ReadDir(Root())
+	if err != nil {
+		return nil, errors.Wrap(err, errReadRoot)
+	}
+	var uids []string
+	for _, e := range entries {
+		if !e.IsDir() || !uid.MatchString(e.Name()) {
+			continue
+		}
+		repaired, err := repair(filepath.Join(Root(), e.Name()))
+		if err != nil {
+			return nil, errors.Wrapf(err, errFmtRepair, e.Name())
+		}
+		// The temporary directory may not have survived the restart.
+		if err := link(e.Name()); err != nil {
+			return nil, err
+		}
+		if len(repaired) > 0 {
+			log.Info("Repaired workspace", "uid", e.Name(), "repairs", repaired)
+		}
+		uids = append(uids, e.Name())
+	}
+	return uids, nil
+}
+
+// repair checks the integrity of the workspace in the given directory and
+// removes or restores its damaged files. The workspace store writes the
+// missing configuration and state files of a workspace again, and initialises
+// the workspaces without a dependency lock file again. It returns the
+// repairs made.
+func repair(dir string) ([]string, error) {
+	var repairs []string
+	ok, err := validState(filepath.Join(dir, fileState))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		r, err := restoreState(dir)
+		if err != nil {
+			return nil, err
+		}
+		repairs = append(repairs, r)
+	}
+	ok, err = validJSON(filepath.Join(dir, fileMain))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if err := os.Remove(filepath.Join(dir, fileMain)); err != nil {
+			return nil, err
+		}
+		repairs = append(repairs, "removed invalid "+fileMain)
+	}
+	// An interrupted initialisation may leave a lock file without the
+	// providers it locks.
+	_, errLock := os.Stat(filepath.Join(dir, fileLock))
+	_, errProviders := os.Stat(filepath.Join(dir, dirProviders))
+	if errLock == nil && os.IsNotExist(errProviders) {
+		if err := os.Remove(filepath.Join(dir, fileLock)); err != nil {
+			return nil, err
+		}
+		repairs = append(repairs, "removed "+fileLock+" without installed providers")
+	}
+	return repairs, nil
+}
+
+// restoreState replaces the damaged state of the workspace in the given
+// directory with its backup if the backup is valid, or removes it so that
+// it's imported again otherwise.
+func restoreState(dir string) (string, error) {
+	ok, err := validState(filepath.Join(dir, fileStateBackup))
+	if err != nil {
+		return "", err
+	}
+	// A missing backup is valid, but cannot be restored.
+	if _, statErr := os.Stat(filepath.Join(dir, fileStateBackup)); ok && statErr == nil {
+		return "restored " + fileState + " from " + fileStateBackup,
+			os.Rename(filepath.Join(dir, fileStateBackup), filepath.Join(dir, fileState))
+	}
+	return "removed invalid " + fileState, os.Remove(filepath.Join(dir, fileState))
+}
+
+// validState returns whether the Terraform state file at the given path is
+// missing or can be parsed.
+func validState(path string) (bool, error) {
+	raw, err := os.ReadFile(filepath.Clean(path))
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, errFmtCheckFile, path)
+	}
+	st := &json.StateV4{}
+	return json.JSParser.Unmarshal(raw, st) == nil && st.Version == 4, nil
+}
+
+// validJSON returns whether the JSON file at the given path is missing or
+// can be parsed.
+func validJSON(path string) (bool, error) {
+	raw, err := os.ReadFile(filepath.Clean(path))
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, errFmtCheckFile, path)
+	}
+	v := map[string]interface{}{}
+	return json.JSParser.Unmarshal(raw, &v) == nil, nil
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestPrepare(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	dir := filepath.Join(t.TempDir(), "workspaces")
+	if err := SetRoot(dir); err != nil {
+		t.Fatalf("SetRoot(...): %s", err)
+	}
+	defer func() { root = "" }()
+	if os.TempDir() != tmp {
+		t.Errorf("SetRoot(...): the temporary directory of the process changed to %s", os.TempDir())
+	}
+
+	o := &metav1.ObjectMeta{UID: types.UID("8a7d4f52-7a26-4a0e-9a9c-0c5f4c0e8a1b")}
+	for i := 0; i < 2; i++ {
+		if err := Prepare(o); err != nil {
+			t.Fatalf("Prepare(...): %s", err)
+		}
+	}
+	// The workspace store writes the workspace through the link.
+	if err := os.WriteFile(filepath.Join(tmp, string(o.UID), fileMain), []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(Dir(o), fileMain)); err != nil {
+		t.Errorf("Prepare(...): the workspace is not written to the workspace directory: %s", err)
+	}
+
+	if err := unlink(string(o.UID)); err != nil {
+		t.Fatalf("unlink(...): %s", err)
+	}
+	if _, err := os.Lstat(filepath.Join(tmp, string(o.UID))); !os.IsNotExist(err) {
+		t.Errorf("unlink(...): the link was not removed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(Dir(o), fileMain)); err != nil {
+		t.Errorf("unlink(...): the workspace was removed: %s", err)
+	}
+}